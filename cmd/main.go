@@ -6,9 +6,14 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"simple_html_docgen/pkg/config"
 	"simple_html_docgen/pkg/export"
 	mcpHandler "simple_html_docgen/pkg/handler"
+	"simple_html_docgen/pkg/importer"
+	"strings"
+	"syscall"
 
 	"github.com/gomcpgo/mcp/pkg/handler"
 	"github.com/gomcpgo/mcp/pkg/protocol"
@@ -28,6 +33,9 @@ func main() {
 		addMedia     string
 		mediaPath    string
 		mediaType    string
+		importFile   string
+		importName   string
+		extractDoc   string
 	)
 
 	flag.StringVar(&createDoc, "create", "", "Create a new document with the specified name")
@@ -36,10 +44,13 @@ func main() {
 	flag.BoolVar(&listDocs, "list", false, "List all documents")
 	flag.StringVar(&getDoc, "get", "", "Get document by ID")
 	flag.StringVar(&exportDoc, "export", "", "Export document by ID")
-	flag.StringVar(&exportFormat, "format", "html", "Export format (html, pdf, docx)")
+	flag.StringVar(&exportFormat, "format", "html", "Export format ("+strings.Join(export.Formats(), ", ")+")")
 	flag.StringVar(&addMedia, "add-media", "", "Add media to document (specify document ID)")
 	flag.StringVar(&mediaPath, "media-path", "", "Path to media file")
-	flag.StringVar(&mediaType, "media-type", "image", "Media type (image, video)")
+	flag.StringVar(&mediaType, "media-type", "image", "Media type (image, video, audio, font, svg, attachment)")
+	flag.StringVar(&importFile, "import", "", "Import a DOCX, ODT, Markdown or RST file as a new document")
+	flag.StringVar(&importName, "name", "", "Document name for import (defaults to file name)")
+	flag.StringVar(&extractDoc, "extract-inline-media", "", "Extract inline base64 images of document with the specified ID")
 	flag.Parse()
 
 	// Load configuration
@@ -49,8 +60,10 @@ func main() {
 	}
 
 	// Create handler
-	exportSvc := export.NewExporter()
-	h := mcpHandler.NewHandler(cfg, exportSvc)
+	exportSvc := export.NewExporter(cfg)
+	defer exportSvc.Close()
+	importSvc := importer.NewImporter()
+	h := mcpHandler.NewHandler(cfg, exportSvc, importSvc)
 	ctx := context.Background()
 
 	// Terminal mode operations
@@ -108,6 +121,21 @@ func main() {
 		return
 	}
 
+	if importFile != "" {
+		runTerminalCommand(ctx, h, "import_file", map[string]interface{}{
+			"source_path": importFile,
+			"name":        importName,
+		})
+		return
+	}
+
+	if extractDoc != "" {
+		runTerminalCommand(ctx, h, "extract_inline_media", map[string]interface{}{
+			"document_id": extractDoc,
+		})
+		return
+	}
+
 	// MCP Server mode (default)
 	registry := handler.NewHandlerRegistry()
 	registry.RegisterToolHandler(h)
@@ -118,7 +146,17 @@ func main() {
 		Registry: registry,
 	})
 
+	// Shut down the shared Chrome when the server is stopped by a signal
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		exportSvc.Close()
+		os.Exit(0)
+	}()
+
 	if err := srv.Run(); err != nil {
+		exportSvc.Close()
 		log.Fatalf("Server error: %v", err)
 	}
 }
@@ -0,0 +1,562 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Chart types supported by Render
+const (
+	TypeBar     = "bar"
+	TypeLine    = "line"
+	TypePie     = "pie"
+	TypeScatter = "scatter"
+	TypeStacked = "stacked"
+)
+
+// Types lists all supported chart types
+var Types = []string{TypeBar, TypeLine, TypePie, TypeScatter, TypeStacked}
+
+const (
+	defaultWidth  = 640
+	defaultHeight = 400
+	fontFamily    = "Helvetica, Arial, sans-serif"
+	axisColor     = "#444444"
+	gridColor     = "#e0e0e0"
+	textColor     = "#222222"
+)
+
+// DefaultColors is a colorblind-friendly palette used when series have no color
+var DefaultColors = []string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f",
+	"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac",
+}
+
+// Series is one data series of a chart
+type Series struct {
+	Name    string    `json:"name"`
+	Values  []float64 `json:"values"`             // Y values (or slice values for pie)
+	XValues []float64 `json:"x_values,omitempty"` // X values, scatter charts only
+	Color   string    `json:"color,omitempty"`    // CSS color; defaults to the palette
+}
+
+// Spec describes a chart to render
+type Spec struct {
+	Type   string   `json:"chart_type"`
+	Title  string   `json:"title,omitempty"`
+	Labels []string `json:"labels,omitempty"` // Category labels (bar, line, stacked) or slice labels (pie)
+	Series []Series `json:"series"`
+	Colors []string `json:"colors,omitempty"`  // Palette override; pie slices use it per slice
+	XLabel string   `json:"x_label,omitempty"` // Axis title
+	YLabel string   `json:"y_label,omitempty"` // Axis title
+	Width  int      `json:"width,omitempty"`   // Pixels, default 640
+	Height int      `json:"height,omitempty"`  // Pixels, default 400
+}
+
+// plotArea is the rectangle inside the axes
+type plotArea struct {
+	left, top, right, bottom float64
+}
+
+func (p plotArea) width() float64  { return p.right - p.left }
+func (p plotArea) height() float64 { return p.bottom - p.top }
+
+// Render validates a chart spec and renders it as a standalone SVG document.
+// The SVG has fixed pixel dimensions and uses only inline attributes and
+// generic fonts, so it renders identically on screen and in Chrome's PDF output.
+func Render(spec Spec) ([]byte, error) {
+	if err := validate(&spec); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="%s" role="img">`,
+		spec.Width, spec.Height, spec.Width, spec.Height, fontFamily)
+	b.WriteString("\n")
+	if spec.Title != "" {
+		fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(spec.Title))
+	}
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", spec.Width, spec.Height)
+
+	if spec.Title != "" {
+		fmt.Fprintf(&b, `<text x="%s" y="28" text-anchor="middle" font-size="18" font-weight="bold" fill="%s">%s</text>`+"\n",
+			num(float64(spec.Width)/2), textColor, html.EscapeString(spec.Title))
+	}
+
+	switch spec.Type {
+	case TypePie:
+		renderPie(&b, spec)
+	case TypeScatter:
+		renderScatter(&b, spec)
+	default:
+		renderCategorical(&b, spec)
+	}
+
+	b.WriteString("</svg>\n")
+	return []byte(b.String()), nil
+}
+
+// validate checks a spec and fills in defaults
+func validate(spec *Spec) error {
+	valid := false
+	for _, t := range Types {
+		if spec.Type == t {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid chart type: %s (must be one of %s)", spec.Type, strings.Join(Types, ", "))
+	}
+
+	if len(spec.Series) == 0 {
+		return fmt.Errorf("at least one series is required")
+	}
+
+	if spec.Width <= 0 {
+		spec.Width = defaultWidth
+	}
+	if spec.Height <= 0 {
+		spec.Height = defaultHeight
+	}
+	if spec.Width < 200 || spec.Height < 150 || spec.Width > 4000 || spec.Height > 4000 {
+		return fmt.Errorf("chart size must be between 200x150 and 4000x4000 pixels")
+	}
+
+	for i, series := range spec.Series {
+		if len(series.Values) == 0 {
+			return fmt.Errorf("series %d has no values", i+1)
+		}
+		for _, values := range [][]float64{series.Values, series.XValues} {
+			for _, v := range values {
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					return fmt.Errorf("series %d contains a non-finite value", i+1)
+				}
+			}
+		}
+	}
+
+	switch spec.Type {
+	case TypePie:
+		if len(spec.Series) != 1 {
+			return fmt.Errorf("pie charts take exactly one series")
+		}
+		total := 0.0
+		for _, v := range spec.Series[0].Values {
+			if v < 0 {
+				return fmt.Errorf("pie chart values cannot be negative")
+			}
+			total += v
+		}
+		if total == 0 {
+			return fmt.Errorf("pie chart values must not all be zero")
+		}
+		if err := fillLabels(spec, len(spec.Series[0].Values)); err != nil {
+			return err
+		}
+	case TypeScatter:
+		for i, series := range spec.Series {
+			if len(series.XValues) != len(series.Values) {
+				return fmt.Errorf("series %d: scatter charts need x_values with the same length as values", i+1)
+			}
+		}
+	default:
+		count := len(spec.Series[0].Values)
+		for i, series := range spec.Series {
+			if len(series.Values) != count {
+				return fmt.Errorf("series %d has %d values, expected %d", i+1, len(series.Values), count)
+			}
+		}
+		if err := fillLabels(spec, count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fillLabels checks label count, generating 1..n labels when none are given
+func fillLabels(spec *Spec, count int) error {
+	if len(spec.Labels) == 0 {
+		spec.Labels = make([]string, count)
+		for i := range spec.Labels {
+			spec.Labels[i] = strconv.Itoa(i + 1)
+		}
+		return nil
+	}
+	if len(spec.Labels) != count {
+		return fmt.Errorf("got %d labels for %d values", len(spec.Labels), count)
+	}
+	return nil
+}
+
+// color returns the color for series (or pie slice) i
+func color(spec Spec, i int) string {
+	if spec.Type != TypePie && i < len(spec.Series) && spec.Series[i].Color != "" {
+		return html.EscapeString(spec.Series[i].Color)
+	}
+	if spec.Type == TypePie && spec.Series[0].Color != "" && len(spec.Labels) == 1 {
+		return html.EscapeString(spec.Series[0].Color)
+	}
+	palette := DefaultColors
+	if len(spec.Colors) > 0 {
+		palette = spec.Colors
+	}
+	return html.EscapeString(palette[i%len(palette)])
+}
+
+// layout computes the plot area, leaving room for title, axis titles and legend
+func layout(spec Spec, legendRows int) plotArea {
+	area := plotArea{left: 64, top: 20, right: float64(spec.Width) - 20, bottom: float64(spec.Height) - 40}
+	if spec.Title != "" {
+		area.top = 48
+	}
+	if spec.YLabel != "" {
+		area.left += 20
+	}
+	if spec.XLabel != "" {
+		area.bottom -= 22
+	}
+	area.bottom -= float64(legendRows) * 20
+	return area
+}
+
+// legendEntry is one item in a chart legend
+type legendEntry struct {
+	label, color string
+}
+
+// legendRows returns how many rows the legend needs at this chart width
+func legendRows(spec Spec, entries []legendEntry) int {
+	if len(entries) < 2 {
+		return 0
+	}
+	rows, x := 1, 0.0
+	for _, entry := range entries {
+		w := legendItemWidth(entry.label)
+		if x > 0 && x+w > float64(spec.Width)-40 {
+			rows++
+			x = 0
+		}
+		x += w
+	}
+	return rows
+}
+
+// legendItemWidth estimates the width of a legend item
+func legendItemWidth(label string) float64 {
+	return 24 + textWidth(label, 12)
+}
+
+// renderLegend draws legend entries centered below the plot
+func renderLegend(b *strings.Builder, spec Spec, entries []legendEntry, top float64) {
+	if len(entries) < 2 {
+		return
+	}
+
+	// Break entries into rows that fit the chart width
+	var rows [][]legendEntry
+	var row []legendEntry
+	x := 0.0
+	for _, entry := range entries {
+		w := legendItemWidth(entry.label)
+		if x > 0 && x+w > float64(spec.Width)-40 {
+			rows = append(rows, row)
+			row, x = nil, 0
+		}
+		row = append(row, entry)
+		x += w
+	}
+	rows = append(rows, row)
+
+	for r, row := range rows {
+		rowWidth := 0.0
+		for _, entry := range row {
+			rowWidth += legendItemWidth(entry.label)
+		}
+		x := (float64(spec.Width) - rowWidth) / 2
+		y := top + float64(r)*20
+		for _, entry := range row {
+			fmt.Fprintf(b, `<rect x="%s" y="%s" width="12" height="12" fill="%s"/>`, num(x), num(y), entry.color)
+			fmt.Fprintf(b, `<text x="%s" y="%s" font-size="12" fill="%s">%s</text>`+"\n",
+				num(x+16), num(y+10), textColor, html.EscapeString(entry.label))
+			x += legendItemWidth(entry.label)
+		}
+	}
+}
+
+// seriesLegend builds legend entries for multi-series charts
+func seriesLegend(spec Spec) []legendEntry {
+	entries := make([]legendEntry, len(spec.Series))
+	for i, series := range spec.Series {
+		name := series.Name
+		if name == "" {
+			name = fmt.Sprintf("Series %d", i+1)
+		}
+		entries[i] = legendEntry{label: name, color: color(spec, i)}
+	}
+	return entries
+}
+
+// renderAxisTitles draws the x and y axis titles
+func renderAxisTitles(b *strings.Builder, spec Spec, area plotArea) {
+	if spec.XLabel != "" {
+		fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="middle" font-size="13" fill="%s">%s</text>`+"\n",
+			num(area.left+area.width()/2), num(area.bottom+44), textColor, html.EscapeString(spec.XLabel))
+	}
+	if spec.YLabel != "" {
+		x, y := 18.0, area.top+area.height()/2
+		fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="middle" font-size="13" fill="%s" transform="rotate(-90 %s %s)">%s</text>`+"\n",
+			num(x), num(y), textColor, num(x), num(y), html.EscapeString(spec.YLabel))
+	}
+}
+
+// renderValueAxis draws horizontal grid lines and y tick labels
+func renderValueAxis(b *strings.Builder, area plotArea, scale linearScale) {
+	for _, tick := range scale.ticks {
+		y := scale.toPixel(tick, area.bottom, area.top)
+		fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="1"/>`,
+			num(area.left), num(y), num(area.right), num(y), gridColor)
+		fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="end" font-size="12" fill="%s">%s</text>`+"\n",
+			num(area.left-8), num(y+4), textColor, scale.format(tick))
+	}
+	fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="1"/>`+"\n",
+		num(area.left), num(area.top), num(area.left), num(area.bottom), axisColor)
+}
+
+// renderCategorical draws bar, stacked bar and line charts
+func renderCategorical(b *strings.Builder, spec Spec) {
+	legend := seriesLegend(spec)
+	rows := legendRows(spec, legend)
+	area := layout(spec, rows)
+
+	// Value range; stacked bars stack positives and negatives separately
+	lo, hi := 0.0, 0.0
+	count := len(spec.Labels)
+	for i := 0; i < count; i++ {
+		pos, neg := 0.0, 0.0
+		for _, series := range spec.Series {
+			v := series.Values[i]
+			if spec.Type == TypeStacked {
+				if v >= 0 {
+					pos += v
+				} else {
+					neg += v
+				}
+			} else {
+				pos, neg = math.Max(pos, v), math.Min(neg, v)
+			}
+		}
+		hi, lo = math.Max(hi, pos), math.Min(lo, neg)
+	}
+	// Line charts need not start at zero
+	if spec.Type == TypeLine {
+		lo, hi = math.Inf(1), math.Inf(-1)
+		for _, series := range spec.Series {
+			for _, v := range series.Values {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+		if lo > 0 && lo < hi/2 {
+			lo = 0
+		}
+	}
+	scale := newLinearScale(lo, hi, int(area.height()/50))
+
+	renderValueAxis(b, area, scale)
+
+	band := area.width() / float64(count)
+	zero := scale.toPixel(math.Max(scale.min, math.Min(0, scale.max)), area.bottom, area.top)
+
+	switch spec.Type {
+	case TypeBar:
+		groupWidth := band * 0.8
+		barWidth := groupWidth / float64(len(spec.Series))
+		for s, series := range spec.Series {
+			for i, v := range series.Values {
+				x := area.left + band*float64(i) + (band-groupWidth)/2 + barWidth*float64(s)
+				y := scale.toPixel(v, area.bottom, area.top)
+				top, height := math.Min(y, zero), math.Abs(zero-y)
+				fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"><title>%s</title></rect>`+"\n",
+					num(x), num(top), num(math.Max(barWidth-1, 1)), num(height), color(spec, s),
+					html.EscapeString(spec.Labels[i]+": "+formatValue(v)))
+			}
+		}
+	case TypeStacked:
+		barWidth := band * 0.6
+		for i := 0; i < count; i++ {
+			pos, neg := 0.0, 0.0
+			x := area.left + band*float64(i) + (band-barWidth)/2
+			for s, series := range spec.Series {
+				v := series.Values[i]
+				var from, to float64
+				if v >= 0 {
+					from, to = pos, pos+v
+					pos = to
+				} else {
+					from, to = neg, neg+v
+					neg = to
+				}
+				y1 := scale.toPixel(from, area.bottom, area.top)
+				y2 := scale.toPixel(to, area.bottom, area.top)
+				fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"><title>%s</title></rect>`+"\n",
+					num(x), num(math.Min(y1, y2)), num(barWidth), num(math.Abs(y1-y2)), color(spec, s),
+					html.EscapeString(spec.Labels[i]+": "+formatValue(v)))
+			}
+		}
+	case TypeLine:
+		for s, series := range spec.Series {
+			points := make([]string, len(series.Values))
+			for i, v := range series.Values {
+				points[i] = num(area.left+band*(float64(i)+0.5)) + "," + num(scale.toPixel(v, area.bottom, area.top))
+			}
+			fmt.Fprintf(b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2.5" stroke-linejoin="round"/>`+"\n",
+				strings.Join(points, " "), color(spec, s))
+			for i, v := range series.Values {
+				fmt.Fprintf(b, `<circle cx="%s" cy="%s" r="3.5" fill="%s"><title>%s</title></circle>`+"\n",
+					num(area.left+band*(float64(i)+0.5)), num(scale.toPixel(v, area.bottom, area.top)), color(spec, s),
+					html.EscapeString(spec.Labels[i]+": "+formatValue(v)))
+			}
+		}
+	}
+
+	// Baseline at zero (or the bottom of the axis)
+	fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="1"/>`+"\n",
+		num(area.left), num(zero), num(area.right), num(zero), axisColor)
+
+	// Category labels, thinned out when they would overlap
+	step := int(math.Ceil(maxLabelWidth(spec.Labels) / (band * 0.95)))
+	if step < 1 {
+		step = 1
+	}
+	for i, label := range spec.Labels {
+		if i%step != 0 {
+			continue
+		}
+		fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="middle" font-size="12" fill="%s">%s</text>`+"\n",
+			num(area.left+band*(float64(i)+0.5)), num(area.bottom+18), textColor, html.EscapeString(label))
+	}
+
+	renderAxisTitles(b, spec, area)
+	legendTop := area.bottom + 32
+	if spec.XLabel != "" {
+		legendTop += 22
+	}
+	renderLegend(b, spec, legend, legendTop)
+}
+
+// renderScatter draws scatter plots with numeric x and y axes
+func renderScatter(b *strings.Builder, spec Spec) {
+	legend := seriesLegend(spec)
+	area := layout(spec, legendRows(spec, legend))
+
+	xlo, xhi, ylo, yhi := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, series := range spec.Series {
+		for i := range series.Values {
+			xlo, xhi = math.Min(xlo, series.XValues[i]), math.Max(xhi, series.XValues[i])
+			ylo, yhi = math.Min(ylo, series.Values[i]), math.Max(yhi, series.Values[i])
+		}
+	}
+	xScale := newLinearScale(xlo, xhi, int(area.width()/80))
+	yScale := newLinearScale(ylo, yhi, int(area.height()/50))
+
+	renderValueAxis(b, area, yScale)
+	for _, tick := range xScale.ticks {
+		x := xScale.toPixel(tick, area.left, area.right)
+		fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="1"/>`,
+			num(x), num(area.top), num(x), num(area.bottom), gridColor)
+		fmt.Fprintf(b, `<text x="%s" y="%s" text-anchor="middle" font-size="12" fill="%s">%s</text>`+"\n",
+			num(x), num(area.bottom+18), textColor, xScale.format(tick))
+	}
+	fmt.Fprintf(b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="1"/>`+"\n",
+		num(area.left), num(area.bottom), num(area.right), num(area.bottom), axisColor)
+
+	for s, series := range spec.Series {
+		for i, v := range series.Values {
+			fmt.Fprintf(b, `<circle cx="%s" cy="%s" r="4" fill="%s" fill-opacity="0.8"><title>%s</title></circle>`+"\n",
+				num(xScale.toPixel(series.XValues[i], area.left, area.right)), num(yScale.toPixel(v, area.bottom, area.top)),
+				color(spec, s), html.EscapeString("("+formatValue(series.XValues[i])+", "+formatValue(v)+")"))
+		}
+	}
+
+	renderAxisTitles(b, spec, area)
+	legendTop := area.bottom + 32
+	if spec.XLabel != "" {
+		legendTop += 22
+	}
+	renderLegend(b, spec, legend, legendTop)
+}
+
+// renderPie draws a pie chart with a legend showing percentages
+func renderPie(b *strings.Builder, spec Spec) {
+	values := spec.Series[0].Values
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+
+	legend := make([]legendEntry, len(values))
+	for i, v := range values {
+		legend[i] = legendEntry{
+			label: fmt.Sprintf("%s (%s%%)", spec.Labels[i], strconv.FormatFloat(v/total*100, 'f', 1, 64)),
+			color: color(spec, i),
+		}
+	}
+	area := layout(spec, legendRows(spec, legend))
+	area.left = 20
+
+	cx, cy := area.left+area.width()/2, area.top+area.height()/2
+	radius := math.Min(area.width(), area.height())/2 - 4
+
+	angle := -math.Pi / 2
+	for i, v := range values {
+		if v == 0 {
+			continue
+		}
+		sweep := v / total * 2 * math.Pi
+		title := html.EscapeString(legend[i].label)
+		if sweep >= 2*math.Pi-1e-9 {
+			// A single full slice cannot be drawn as an arc
+			fmt.Fprintf(b, `<circle cx="%s" cy="%s" r="%s" fill="%s"><title>%s</title></circle>`+"\n",
+				num(cx), num(cy), num(radius), color(spec, i), title)
+			break
+		}
+		x1, y1 := cx+radius*math.Cos(angle), cy+radius*math.Sin(angle)
+		x2, y2 := cx+radius*math.Cos(angle+sweep), cy+radius*math.Sin(angle+sweep)
+		largeArc := 0
+		if sweep > math.Pi {
+			largeArc = 1
+		}
+		fmt.Fprintf(b, `<path d="M%s,%s L%s,%s A%s,%s 0 %d 1 %s,%s Z" fill="%s" stroke="#ffffff" stroke-width="1.5"><title>%s</title></path>`+"\n",
+			num(cx), num(cy), num(x1), num(y1), num(radius), num(radius), largeArc, num(x2), num(y2), color(spec, i), title)
+		angle += sweep
+	}
+
+	renderLegend(b, spec, legend, area.bottom+16)
+}
+
+// maxLabelWidth estimates the widest category label at 12px
+func maxLabelWidth(labels []string) float64 {
+	widest := 0.0
+	for _, label := range labels {
+		widest = math.Max(widest, textWidth(label, 12))
+	}
+	return widest
+}
+
+// textWidth estimates rendered text width; average Helvetica glyphs are ~0.55em
+func textWidth(text string, fontSize float64) float64 {
+	return float64(len([]rune(text))) * fontSize * 0.55
+}
+
+// formatValue formats a data value exactly as given, for tooltips
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// num formats a coordinate compactly with at most two decimals
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
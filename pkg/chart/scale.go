@@ -0,0 +1,122 @@
+package chart
+
+import (
+	"math"
+	"strconv"
+)
+
+// linearScale maps data values onto pixels with "nice" rounded tick values
+type linearScale struct {
+	min, max float64
+	ticks    []float64
+	decimals int
+}
+
+// newLinearScale builds a scale covering lo..hi with roughly targetTicks ticks,
+// using Heckbert's nice-number algorithm so ticks fall on 1, 2 or 5 multiples
+func newLinearScale(lo, hi float64, targetTicks int) linearScale {
+	if targetTicks < 2 {
+		targetTicks = 2
+	}
+	if lo == hi {
+		// Give a flat series some room above and below
+		pad := math.Max(math.Abs(lo)*0.1, 1)
+		lo, hi = lo-pad, hi+pad
+		if lo < 0 && hi > 0 && lo+pad >= 0 {
+			lo = 0
+		}
+	}
+
+	span := niceNumber(hi-lo, false)
+	step := niceNumber(span/float64(targetTicks-1), true)
+	min := math.Floor(lo/step) * step
+	max := math.Ceil(hi/step) * step
+
+	decimals := 0
+	if step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step)))
+	}
+
+	var ticks []float64
+	for v := min; v <= max+step/2; v += step {
+		// Avoid -0 and accumulated floating point noise in labels
+		ticks = append(ticks, math.Round(v/step)*step)
+	}
+
+	return linearScale{min: min, max: max, ticks: ticks, decimals: decimals}
+}
+
+// toPixel maps a value to a pixel coordinate between from (min) and to (max)
+func (s linearScale) toPixel(v, from, to float64) float64 {
+	if s.max == s.min {
+		return from
+	}
+	return from + (v-s.min)/(s.max-s.min)*(to-from)
+}
+
+// format renders a value with the precision of the tick step, using
+// thousands separators for large numbers
+func (s linearScale) format(v float64) string {
+	if v == 0 {
+		v = 0 // Normalize -0
+	}
+	text := strconv.FormatFloat(v, 'f', s.decimals, 64)
+	if math.Abs(v) < 10000 {
+		return text
+	}
+
+	intPart, fracPart := text, ""
+	for i, r := range text {
+		if r == '.' {
+			intPart, fracPart = text[:i], text[i:]
+			break
+		}
+	}
+	sign := ""
+	if intPart[0] == '-' {
+		sign, intPart = "-", intPart[1:]
+	}
+	var grouped []byte
+	for i := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			grouped = append(grouped, ',')
+		}
+		grouped = append(grouped, intPart[i])
+	}
+	return sign + string(grouped) + fracPart
+}
+
+// niceNumber rounds x to a 1, 2, 5 or 10 multiple of a power of ten
+func niceNumber(x float64, round bool) float64 {
+	if x <= 0 {
+		return 1
+	}
+	exp := math.Floor(math.Log10(x))
+	f := x / math.Pow(10, exp)
+
+	var nice float64
+	if round {
+		switch {
+		case f < 1.5:
+			nice = 1
+		case f < 3:
+			nice = 2
+		case f < 7:
+			nice = 5
+		default:
+			nice = 10
+		}
+	} else {
+		switch {
+		case f <= 1:
+			nice = 1
+		case f <= 2:
+			nice = 2
+		case f <= 5:
+			nice = 5
+		default:
+			nice = 10
+		}
+	}
+	return nice * math.Pow(10, exp)
+}
@@ -0,0 +1,538 @@
+package citation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// bibTeXTypes maps BibTeX entry types to CSL types
+var bibTeXTypes = map[string]string{
+	"article":       TypeArticle,
+	"book":          TypeBook,
+	"booklet":       TypeBook,
+	"inbook":        TypeChapter,
+	"incollection":  TypeChapter,
+	"inproceedings": TypeConference,
+	"conference":    TypeConference,
+	"phdthesis":     TypeThesis,
+	"mastersthesis": TypeThesis,
+	"thesis":        TypeThesis,
+	"techreport":    TypeReport,
+	"report":        TypeReport,
+	"online":        TypeWebpage,
+	"electronic":    TypeWebpage,
+	"www":           TypeWebpage,
+}
+
+// defaultMacros are the month abbreviations predefined by BibTeX
+var defaultMacros = map[string]string{
+	"jan": "January", "feb": "February", "mar": "March", "apr": "April",
+	"may": "May", "jun": "June", "jul": "July", "aug": "August",
+	"sep": "September", "oct": "October", "nov": "November", "dec": "December",
+}
+
+var yearRegex = regexp.MustCompile(`\d{4}`)
+
+// bibParser reads BibTeX source
+type bibParser struct {
+	src    string
+	pos    int
+	macros map[string]string
+}
+
+// ParseBibTeX reads the entries of a BibTeX file. @string macros are
+// expanded; @comment and @preamble blocks are ignored.
+func ParseBibTeX(src string) ([]*Entry, error) {
+	p := &bibParser{src: src, macros: make(map[string]string)}
+	for k, v := range defaultMacros {
+		p.macros[k] = v
+	}
+
+	var entries []*Entry
+	for {
+		at := strings.IndexByte(p.src[p.pos:], '@')
+		if at == -1 {
+			return entries, nil
+		}
+		p.pos += at + 1
+
+		entryType := strings.ToLower(p.identifier())
+		p.skipSpace()
+		if p.pos >= len(p.src) || (p.src[p.pos] != '{' && p.src[p.pos] != '(') {
+			// A stray @ in free text between entries
+			continue
+		}
+		closer := byte('}')
+		if p.src[p.pos] == '(' {
+			closer = ')'
+		}
+		start := p.pos
+		p.pos++
+
+		switch entryType {
+		case "comment", "preamble":
+			p.pos = start
+			if _, err := p.braced(); err != nil {
+				return nil, err
+			}
+			continue
+		case "string":
+			name, value, err := p.field()
+			if err != nil {
+				return nil, err
+			}
+			p.macros[strings.ToLower(name)] = value
+			p.skipSpace()
+			if p.pos < len(p.src) && p.src[p.pos] == closer {
+				p.pos++
+			}
+			continue
+		}
+
+		entry, err := p.entry(entryType, closer)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// entry reads the key and fields of an entry after its opening delimiter
+func (p *bibParser) entry(entryType string, closer byte) (*Entry, error) {
+	p.skipSpace()
+	keyStart := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != ',' && p.src[p.pos] != closer && !unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	key := p.src[keyStart:p.pos]
+	if key == "" {
+		return nil, p.errorf("@%s entry has no key", entryType)
+	}
+
+	fields := make(map[string]string)
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated entry %s", key)
+		}
+		switch p.src[p.pos] {
+		case closer:
+			p.pos++
+			return newBibTeXEntry(key, entryType, fields), nil
+		case ',':
+			p.pos++
+			continue
+		}
+
+		name, value, err := p.field()
+		if err != nil {
+			return nil, fmt.Errorf("entry %s: %w", key, err)
+		}
+		fields[strings.ToLower(name)] = value
+	}
+}
+
+// field reads "name = value", where the value may concatenate braced or
+// quoted strings, numbers and macros with #
+func (p *bibParser) field() (string, string, error) {
+	p.skipSpace()
+	name := p.identifier()
+	if name == "" {
+		return "", "", p.errorf("expected a field name")
+	}
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '=' {
+		return "", "", p.errorf("expected = after %s", name)
+	}
+	p.pos++
+
+	var value strings.Builder
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return "", "", p.errorf("missing value for %s", name)
+		}
+		switch c := p.src[p.pos]; {
+		case c == '{':
+			part, err := p.braced()
+			if err != nil {
+				return "", "", err
+			}
+			value.WriteString(part[1 : len(part)-1])
+		case c == '"':
+			part, err := p.quoted()
+			if err != nil {
+				return "", "", err
+			}
+			value.WriteString(part)
+		default:
+			word := p.identifier()
+			if word == "" {
+				return "", "", p.errorf("invalid value for %s", name)
+			}
+			if macro, ok := p.macros[strings.ToLower(word)]; ok {
+				value.WriteString(macro)
+			} else {
+				value.WriteString(word)
+			}
+		}
+
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '#' {
+			p.pos++
+			continue
+		}
+		return name, value.String(), nil
+	}
+}
+
+// braced returns the balanced group opened by { or ( at the current position
+func (p *bibParser) braced() (string, error) {
+	start := p.pos
+	open, close := p.src[start], byte('}')
+	if open == '(' {
+		close = ')'
+	}
+	depth := 0
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				p.pos++
+				return p.src[start:p.pos], nil
+			}
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unbalanced braces")
+}
+
+// quoted returns the content of the "..." string at the current position.
+// Quotes inside braces do not end the string.
+func (p *bibParser) quoted() (string, error) {
+	start := p.pos
+	depth := 0
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			if depth == 0 {
+				p.pos++
+				return p.src[start+1 : p.pos-1], nil
+			}
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+// identifier reads a field name, entry type, number or macro name
+func (p *bibParser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_-:.+/", c) >= 0) {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *bibParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// errorf reports an error with the current line number
+func (p *bibParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.src[:min(p.pos, len(p.src))], "\n") + 1
+	return fmt.Errorf("bibtex line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// newBibTeXEntry builds an entry from raw BibTeX fields
+func newBibTeXEntry(key, entryType string, fields map[string]string) *Entry {
+	entry := &Entry{
+		Key:       key,
+		Type:      bibTeXTypes[entryType],
+		Authors:   parseBibTeXNames(fields["author"]),
+		Editors:   parseBibTeXNames(fields["editor"]),
+		Title:     cleanLaTeX(fields["title"]),
+		Publisher: cleanLaTeX(firstOf(fields, "publisher", "school", "institution", "organization")),
+		Place:     cleanLaTeX(firstOf(fields, "address", "location")),
+		Edition:   cleanLaTeX(fields["edition"]),
+		Volume:    cleanLaTeX(fields["volume"]),
+		Issue:     cleanLaTeX(fields["number"]),
+		Pages:     cleanLaTeX(fields["pages"]),
+		URL:       strings.TrimSpace(fields["url"]),
+		DOI:       strings.TrimSpace(fields["doi"]),
+		Note:      cleanLaTeX(firstOf(fields, "note", "howpublished")),
+	}
+	if entry.Type == "" {
+		entry.Type = TypeMisc
+	}
+
+	switch entry.Type {
+	case TypeArticle:
+		entry.ContainerTitle = cleanLaTeX(firstOf(fields, "journal", "journaltitle"))
+	case TypeChapter, TypeConference:
+		entry.ContainerTitle = cleanLaTeX(fields["booktitle"])
+	}
+	switch entryType {
+	case "phdthesis":
+		entry.Genre = "PhD thesis"
+	case "mastersthesis":
+		entry.Genre = "Master's thesis"
+	case "techreport":
+		entry.Genre = "Technical report"
+	}
+	if t := cleanLaTeX(fields["type"]); t != "" {
+		entry.Genre = t
+	}
+
+	if m := yearRegex.FindString(firstOf(fields, "year", "date")); m != "" {
+		entry.Year = m
+	}
+	if strings.HasPrefix(strings.ToLower(entry.URL), "\\url{") {
+		entry.URL = strings.TrimSuffix(entry.URL[5:], "}")
+	}
+	return entry
+}
+
+// firstOf returns the first non-empty field among names
+func firstOf(fields map[string]string, names ...string) string {
+	for _, name := range names {
+		if v := strings.TrimSpace(fields[name]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseBibTeXNames splits an author or editor list on "and" outside braces
+// and parses each name in either "First von Last" or "von Last, First" form
+func parseBibTeXNames(value string) []Name {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	var names []Name
+	for _, raw := range splitTopLevel(value, " and ") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" || strings.EqualFold(raw, "others") {
+			continue
+		}
+		// A fully braced name is a corporate or literal name
+		if strings.HasPrefix(raw, "{") && strings.HasSuffix(raw, "}") && !strings.Contains(raw[1:len(raw)-1], "}") {
+			names = append(names, Name{Literal: cleanLaTeX(raw)})
+			continue
+		}
+
+		parts := splitTopLevel(raw, ",")
+		if len(parts) > 1 {
+			names = append(names, Name{
+				Family: cleanLaTeX(strings.TrimSpace(parts[0])),
+				Given:  cleanLaTeX(strings.TrimSpace(parts[len(parts)-1])),
+			})
+			continue
+		}
+
+		words := splitTopLevel(raw, " ")
+		if len(words) == 1 {
+			names = append(names, Name{Family: cleanLaTeX(words[0])})
+			continue
+		}
+		// The family name is the last word plus any lowercase "von" words before it
+		familyStart := len(words) - 1
+		for familyStart > 1 && startsLower(words[familyStart-1]) {
+			familyStart--
+		}
+		names = append(names, Name{
+			Family: cleanLaTeX(strings.Join(words[familyStart:], " ")),
+			Given:  cleanLaTeX(strings.Join(words[:familyStart], " ")),
+		})
+	}
+	return names
+}
+
+// splitTopLevel splits s on sep outside braces, case-insensitively, and
+// drops empty pieces
+func splitTopLevel(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		default:
+			if depth == 0 && i+len(sep) <= len(s) && strings.EqualFold(s[i:i+len(sep)], sep) {
+				if part := s[start:i]; strings.TrimSpace(part) != "" {
+					parts = append(parts, part)
+				}
+				start = i + len(sep)
+				i = start - 1
+			}
+		}
+	}
+	if part := s[start:]; strings.TrimSpace(part) != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+func startsLower(word string) bool {
+	for _, r := range word {
+		return unicode.IsLower(r)
+	}
+	return false
+}
+
+// latexAccents maps an accent command and base letter to the accented letter
+var latexAccents = buildAccents(map[byte][2]string{
+	'\'': {"aeiouyAEIOUYcnszCNSZ", "áéíóúýÁÉÍÓÚÝćńśźĆŃŚŹ"},
+	'`':  {"aeiouAEIOU", "àèìòùÀÈÌÒÙ"},
+	'^':  {"aeiouAEIOU", "âêîôûÂÊÎÔÛ"},
+	'"':  {"aeiouyAEIOUY", "äëïöüÿÄËÏÖÜŸ"},
+	'~':  {"anoANO", "ãñõÃÑÕ"},
+	'c':  {"cCsS", "çÇşŞ"},
+	'v':  {"csznrezCSZNREZ", "čšžňřěžČŠŽŇŘĚŽ"},
+	'H':  {"oOuU", "őŐűŰ"},
+	'r':  {"aAuU", "åÅůŮ"},
+	'=':  {"aeiouAEIOU", "āēīōūĀĒĪŌŪ"},
+	'.':  {"zZeE", "żŻėĖ"},
+	'u':  {"agAG", "ăğĂĞ"},
+	'k':  {"aeAE", "ąęĄĘ"},
+})
+
+func buildAccents(table map[byte][2]string) map[string]string {
+	accents := make(map[string]string)
+	for accent, letters := range table {
+		targets := []rune(letters[1])
+		for i := 0; i < len(letters[0]); i++ {
+			accents[string(accent)+letters[0][i:i+1]] = string(targets[i])
+		}
+	}
+	return accents
+}
+
+// latexSymbols are commands that stand for a character
+var latexSymbols = map[string]string{
+	"ss": "ß", "o": "ø", "O": "Ø", "ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ",
+	"aa": "å", "AA": "Å", "l": "ł", "L": "Ł", "i": "ı", "j": "ȷ",
+	"&": "&", "%": "%", "$": "$", "_": "_", "#": "#", "{": "{", "}": "}",
+	"textendash": "–", "textemdash": "—", "ldots": "…", "dots": "…",
+	"textquoteright": "’", "textquoteleft": "‘", "S": "§", "copyright": "©",
+	"LaTeX": "LaTeX", "TeX": "TeX", " ": " ",
+}
+
+// cleanLaTeX converts the LaTeX markup common in BibTeX fields to plain
+// text: accents, escaped characters, dashes, quotes and font commands
+func cleanLaTeX(s string) string {
+	if !strings.ContainsAny(s, "\\{}~-`'") {
+		return strings.Join(strings.Fields(s), " ")
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '{' || c == '}':
+		case c == '~':
+			out.WriteString("\u00a0")
+		case strings.HasPrefix(s[i:], "---"):
+			out.WriteString("—")
+			i += 2
+		case strings.HasPrefix(s[i:], "--"):
+			out.WriteString("–")
+			i++
+		case strings.HasPrefix(s[i:], "``"):
+			out.WriteString("“")
+			i++
+		case strings.HasPrefix(s[i:], "''"):
+			out.WriteString("”")
+			i++
+		case c == '\\' && i+1 < len(s):
+			if letter, end, ok := latexAccent(s, i+1); ok {
+				out.WriteString(letter)
+				i = end - 1
+				continue
+			}
+			// A control word (\emph, \ss) or a control symbol (\&, \%).
+			// Unknown commands are dropped, keeping their argument.
+			j := i + 1
+			for j < len(s) && isASCIILetter(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				j++
+			} else if j < len(s) && s[j] == ' ' && latexSymbols[s[i+1:j]] != "" {
+				// TeX ignores the space after a control word
+				out.WriteString(latexSymbols[s[i+1:j]])
+				i = j
+				continue
+			}
+			out.WriteString(latexSymbols[s[i+1:j]])
+			i = j - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return strings.Join(strings.Fields(out.String()), " ")
+}
+
+// latexAccent decodes an accent command starting at s[i], just after the
+// backslash: \'e, \'{e}, \"{o}, \c{c}, \v s or \'{\i}. It returns the
+// accented letter and the position after the command.
+func latexAccent(s string, i int) (string, int, bool) {
+	accent := s[i]
+	j := i + 1
+	switch {
+	case strings.IndexByte("'`^\"~=.", accent) >= 0:
+	case strings.IndexByte("cvHruk", accent) >= 0 && j < len(s) && (s[j] == '{' || s[j] == ' '):
+		if s[j] == ' ' {
+			j++
+		}
+	default:
+		return "", 0, false
+	}
+
+	braced := j < len(s) && s[j] == '{'
+	if braced {
+		j++
+	}
+	if strings.HasPrefix(s[j:], "\\i") || strings.HasPrefix(s[j:], "\\j") {
+		// Dotless i and j carry accents in older BibTeX files
+		j++
+	}
+	if j >= len(s) {
+		return "", 0, false
+	}
+	letter, ok := latexAccents[string(accent)+s[j:j+1]]
+	if !ok {
+		return "", 0, false
+	}
+	j++
+	if braced && j < len(s) && s[j] == '}' {
+		j++
+	}
+	return letter, j, true
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,117 @@
+// Package citation parses BibTeX and CSL JSON bibliographies and formats
+// citations and reference lists in common styles.
+package citation
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Bibliography file formats
+const (
+	FormatBibTeX  = "bibtex"
+	FormatCSLJSON = "csl-json"
+)
+
+// Entry types, named as in CSL
+const (
+	TypeArticle    = "article-journal"
+	TypeBook       = "book"
+	TypeChapter    = "chapter"
+	TypeConference = "paper-conference"
+	TypeThesis     = "thesis"
+	TypeReport     = "report"
+	TypeWebpage    = "webpage"
+	TypeMisc       = "document"
+)
+
+// Name is a person's name. Literal is used for organizations and names
+// that cannot be split.
+type Name struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+// Entry is one bibliography item
+type Entry struct {
+	Key            string
+	Type           string
+	Authors        []Name
+	Editors        []Name
+	Title          string
+	ContainerTitle string // Journal, book or proceedings title
+	Publisher      string
+	Place          string
+	Edition        string
+	Volume         string
+	Issue          string
+	Pages          string
+	Year           string
+	Genre          string // Thesis or report kind, e.g. "PhD thesis"
+	URL            string
+	DOI            string
+	Note           string
+}
+
+// Bibliography is a set of entries by key
+type Bibliography struct {
+	Format  string
+	Entries map[string]*Entry
+	Keys    []string // Keys in file order
+}
+
+// DetectFormat returns the format of a bibliography file from its
+// extension, falling back to its content
+func DetectFormat(data []byte, filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".bib", ".bibtex":
+		return FormatBibTeX
+	case ".json":
+		return FormatCSLJSON
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return FormatCSLJSON
+	}
+	return FormatBibTeX
+}
+
+// Parse reads a bibliography in the given format, detecting it when empty
+func Parse(data []byte, format, filename string) (*Bibliography, error) {
+	if format == "" {
+		format = DetectFormat(data, filename)
+	}
+
+	var entries []*Entry
+	var err error
+	switch format {
+	case FormatBibTeX:
+		entries, err = ParseBibTeX(string(data))
+	case FormatCSLJSON:
+		entries, err = ParseCSLJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported bibliography format: %s (must be %s or %s)", format, FormatBibTeX, FormatCSLJSON)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("bibliography contains no entries")
+	}
+
+	bib := &Bibliography{Format: format, Entries: make(map[string]*Entry, len(entries))}
+	for _, entry := range entries {
+		if entry.Key == "" {
+			return nil, fmt.Errorf("bibliography entry has no key")
+		}
+		if _, exists := bib.Entries[entry.Key]; exists {
+			return nil, fmt.Errorf("duplicate bibliography key: %s", entry.Key)
+		}
+		bib.Entries[entry.Key] = entry
+		bib.Keys = append(bib.Keys, entry.Key)
+	}
+	return bib, nil
+}
@@ -0,0 +1,88 @@
+package citation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBibTeX(t *testing.T) {
+	src := `@string{acm = "ACM Press"}
+% A comment line
+@comment{ignored @article{x}}
+@Article{smith2020,
+  author = {Smith, John and Jane Q. Doe and {World Health Organization}},
+  title = "The {GPU} Era: {\"U}ber speed",
+  journal = acm # " Journal",
+  year = 2020, month = jan,
+  pages = {10--20}, doi = {10.1/xyz},
+}
+@book(knuth84, author="Donald E. Knuth", title={The \TeX book}, publisher={Addison-Wesley}, year={1984})
+`
+	entries, err := ParseBibTeX(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Entry{
+		{
+			Key:            "smith2020",
+			Type:           TypeArticle,
+			Authors:        []Name{{Family: "Smith", Given: "John"}, {Family: "Doe", Given: "Jane Q."}, {Literal: "World Health Organization"}},
+			Title:          "The GPU Era: Über speed",
+			ContainerTitle: "ACM Press Journal",
+			Pages:          "10–20",
+			Year:           "2020",
+			DOI:            "10.1/xyz",
+		},
+		{
+			Key:       "knuth84",
+			Type:      TypeBook,
+			Authors:   []Name{{Family: "Knuth", Given: "Donald E."}},
+			Title:     "The TeXbook",
+			Publisher: "Addison-Wesley",
+			Year:      "1984",
+		},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ParseBibTeX:\n got %+v\nwant %+v", entries, want)
+	}
+}
+
+func TestParseBibTeXErrors(t *testing.T) {
+	for _, src := range []string{
+		"@article{x, title={open",
+		"@article{, title={No key}}",
+	} {
+		if _, err := ParseBibTeX(src); err == nil {
+			t.Errorf("ParseBibTeX(%q) succeeded", src)
+		}
+	}
+}
+
+func TestParseCSLJSON(t *testing.T) {
+	data := `[{"id":"a1","type":"article-journal","title":"T","author":[{"family":"Beethoven","given":"Ludwig","non-dropping-particle":"van"},{"literal":"ACME"}],"issued":{"date-parts":[["2019",3]]},"page":12,"volume":"4"}]`
+	entries, err := ParseCSLJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Entry{{
+		Key:     "a1",
+		Type:    TypeArticle,
+		Authors: []Name{{Family: "van Beethoven", Given: "Ludwig"}, {Literal: "ACME"}},
+		Title:   "T",
+		Volume:  "4",
+		Pages:   "12",
+		Year:    "2019",
+	}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ParseCSLJSON:\n got %+v\nwant %+v", entries, want)
+	}
+
+	// A single item is accepted too
+	if entries, err := ParseCSLJSON([]byte(`{"id":"b","title":"Single"}`)); err != nil || len(entries) != 1 || entries[0].Key != "b" {
+		t.Errorf("single item: %+v, %v", entries, err)
+	}
+	if _, err := ParseCSLJSON([]byte(`{"id":`)); err == nil || !strings.Contains(err.Error(), "invalid CSL JSON") {
+		t.Errorf("truncated JSON: err = %v", err)
+	}
+}
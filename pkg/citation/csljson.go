@@ -0,0 +1,166 @@
+package citation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cslName is a name in CSL JSON
+type cslName struct {
+	Family              string `json:"family"`
+	Given               string `json:"given"`
+	Literal             string `json:"literal"`
+	NonDroppingParticle string `json:"non-dropping-particle"`
+	DroppingParticle    string `json:"dropping-particle"`
+}
+
+// cslDate is a date in CSL JSON, either as date parts or as text
+type cslDate struct {
+	DateParts [][]json.RawMessage `json:"date-parts"`
+	Raw       string              `json:"raw"`
+	Literal   string              `json:"literal"`
+}
+
+// cslItem is the subset of a CSL JSON item used for formatting. Fields
+// that CSL allows as either strings or numbers are read raw.
+type cslItem struct {
+	ID             json.RawMessage `json:"id"`
+	Type           string          `json:"type"`
+	Title          string          `json:"title"`
+	Author         []cslName       `json:"author"`
+	Editor         []cslName       `json:"editor"`
+	ContainerTitle string          `json:"container-title"`
+	Publisher      string          `json:"publisher"`
+	PublisherPlace string          `json:"publisher-place"`
+	Edition        json.RawMessage `json:"edition"`
+	Volume         json.RawMessage `json:"volume"`
+	Issue          json.RawMessage `json:"issue"`
+	Page           json.RawMessage `json:"page"`
+	Genre          string          `json:"genre"`
+	Issued         *cslDate        `json:"issued"`
+	URL            string          `json:"URL"`
+	DOI            string          `json:"DOI"`
+	Note           string          `json:"note"`
+}
+
+// cslTypes maps CSL types to the smaller set of types that are formatted
+// differently
+var cslTypes = map[string]string{
+	"article-journal":    TypeArticle,
+	"article-magazine":   TypeArticle,
+	"article-newspaper":  TypeArticle,
+	"article":            TypeArticle,
+	"book":               TypeBook,
+	"chapter":            TypeChapter,
+	"entry-encyclopedia": TypeChapter,
+	"paper-conference":   TypeConference,
+	"thesis":             TypeThesis,
+	"report":             TypeReport,
+	"webpage":            TypeWebpage,
+	"post-weblog":        TypeWebpage,
+}
+
+// ParseCSLJSON reads a CSL JSON bibliography, an array of items as
+// exported by Zotero and other reference managers
+func ParseCSLJSON(data []byte) ([]*Entry, error) {
+	var items []cslItem
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		// A single item
+		var item cslItem
+		if err := json.Unmarshal(trimmed, &item); err != nil {
+			return nil, fmt.Errorf("invalid CSL JSON: %w", err)
+		}
+		items = []cslItem{item}
+	} else if err := json.Unmarshal(trimmed, &items); err != nil {
+		return nil, fmt.Errorf("invalid CSL JSON: %w", err)
+	}
+
+	entries := make([]*Entry, 0, len(items))
+	for i, item := range items {
+		key := rawString(item.ID)
+		if key == "" {
+			return nil, fmt.Errorf("CSL JSON item %d has no id", i+1)
+		}
+		entry := &Entry{
+			Key:            key,
+			Type:           cslTypes[item.Type],
+			Authors:        cslNames(item.Author),
+			Editors:        cslNames(item.Editor),
+			Title:          strings.TrimSpace(item.Title),
+			ContainerTitle: strings.TrimSpace(item.ContainerTitle),
+			Publisher:      strings.TrimSpace(item.Publisher),
+			Place:          strings.TrimSpace(item.PublisherPlace),
+			Edition:        rawString(item.Edition),
+			Volume:         rawString(item.Volume),
+			Issue:          rawString(item.Issue),
+			Pages:          strings.ReplaceAll(rawString(item.Page), "-", "–"),
+			Genre:          strings.TrimSpace(item.Genre),
+			URL:            strings.TrimSpace(item.URL),
+			DOI:            strings.TrimSpace(item.DOI),
+			Note:           strings.TrimSpace(item.Note),
+		}
+		if entry.Type == "" {
+			entry.Type = TypeMisc
+		}
+		if item.Issued != nil {
+			entry.Year = cslYear(item.Issued)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// cslNames converts CSL names, folding particles into the family and
+// given names
+func cslNames(names []cslName) []Name {
+	var result []Name
+	for _, n := range names {
+		if n.Literal != "" {
+			result = append(result, Name{Literal: n.Literal})
+			continue
+		}
+		family := strings.TrimSpace(n.NonDroppingParticle + " " + n.Family)
+		given := strings.TrimSpace(n.Given + " " + n.DroppingParticle)
+		if family == "" && given == "" {
+			continue
+		}
+		result = append(result, Name{Family: family, Given: given})
+	}
+	return result
+}
+
+// cslYear returns the year of a CSL date
+func cslYear(d *cslDate) string {
+	if len(d.DateParts) > 0 && len(d.DateParts[0]) > 0 {
+		if year := rawString(d.DateParts[0][0]); year != "" {
+			return year
+		}
+	}
+	if m := yearRegex.FindString(d.Raw + " " + d.Literal); m != "" {
+		return m
+	}
+	return ""
+}
+
+// rawString returns a JSON string or number as text
+func rawString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		if i, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+		return n.String()
+	}
+	return ""
+}
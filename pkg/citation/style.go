@@ -0,0 +1,806 @@
+package citation
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Citation styles
+const (
+	StyleAPA     = "apa"     // APA 7th edition, author-year: (Smith & Doe, 2020)
+	StyleChicago = "chicago" // Chicago author-date: (Smith and Doe 2020)
+	StyleIEEE    = "ieee"    // IEEE, numbered in order of first citation: [1]
+)
+
+// DefaultStyle is used when no style is given
+const DefaultStyle = StyleAPA
+
+// Styles lists the supported citation styles
+var Styles = []string{StyleAPA, StyleChicago, StyleIEEE}
+
+// ValidateStyle checks that a citation style is supported
+func ValidateStyle(style string) error {
+	for _, s := range Styles {
+		if style == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid citation style: %s (must be one of %s)", style, strings.Join(Styles, ", "))
+}
+
+// Cite is one in-text citation of one or more sources
+type Cite struct {
+	Keys      []string
+	Locator   string // Page or other locator, e.g. "12" or "chap. 3"
+	Narrative bool   // Author in running text: Smith (2020) rather than (Smith, 2020)
+}
+
+// Reference is one formatted entry of the reference list
+type Reference struct {
+	Key   string
+	Label string // "[1]" for numbered styles, "" otherwise
+	HTML  string
+}
+
+// Processor formats the citations of one document. All cited keys are
+// registered first so numbers and year suffixes (2020a, 2020b) are known
+// before any citation is formatted.
+type Processor struct {
+	bib      *Bibliography
+	style    string
+	order    []string       // Cited keys in order of first citation
+	numbers  map[string]int // Numbered styles: key to number
+	suffixes map[string]string
+}
+
+// NewProcessor creates a processor for a bibliography and style
+func NewProcessor(bib *Bibliography, style string) (*Processor, error) {
+	if style == "" {
+		style = DefaultStyle
+	}
+	if err := ValidateStyle(style); err != nil {
+		return nil, err
+	}
+	return &Processor{bib: bib, style: style, numbers: make(map[string]int), suffixes: make(map[string]string)}, nil
+}
+
+// Register records the cited keys in document order
+func (p *Processor) Register(keys []string) {
+	for _, key := range keys {
+		if _, seen := p.numbers[key]; seen {
+			continue
+		}
+		if _, ok := p.bib.Entries[key]; !ok {
+			p.numbers[key] = 0
+			continue
+		}
+		p.order = append(p.order, key)
+		p.numbers[key] = len(p.order)
+	}
+	p.suffixes = nil
+}
+
+// numbered reports whether the style numbers its citations
+func (p *Processor) numbered() bool {
+	return p.style == StyleIEEE
+}
+
+// yearSuffixes assigns a, b, ... to cited works with the same author
+// label and year, ordered by title
+func (p *Processor) yearSuffixes() map[string]string {
+	if p.suffixes != nil {
+		return p.suffixes
+	}
+	p.suffixes = make(map[string]string)
+	if p.numbered() {
+		return p.suffixes
+	}
+
+	groups := make(map[string][]*Entry)
+	for _, key := range p.order {
+		entry := p.bib.Entries[key]
+		label := p.authorLabel(entry, false) + "|" + entry.Year
+		groups[label] = append(groups[label], entry)
+	}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			return strings.ToLower(group[i].Title) < strings.ToLower(group[j].Title)
+		})
+		for i, entry := range group {
+			p.suffixes[entry.Key] = string(rune('a' + i%26))
+		}
+	}
+	return p.suffixes
+}
+
+// Citation formats an in-text citation as HTML, linking each source to its
+// reference list entry at #ref-<key>
+func (p *Processor) Citation(cite Cite) string {
+	var parts []string
+	for i, key := range cite.Keys {
+		entry, ok := p.bib.Entries[key]
+		if !ok {
+			parts = append(parts, "<strong>"+html.EscapeString(key)+"?</strong>")
+			continue
+		}
+		locator := ""
+		if i == len(cite.Keys)-1 {
+			locator = p.formatLocator(cite.Locator)
+		}
+		parts = append(parts, p.citeOne(entry, locator, cite.Narrative))
+	}
+
+	switch {
+	case p.numbered():
+		return strings.Join(parts, ", ")
+	case cite.Narrative:
+		return strings.Join(parts, "; ")
+	default:
+		return "(" + strings.Join(parts, "; ") + ")"
+	}
+}
+
+// citeOne formats the citation of a single source
+func (p *Processor) citeOne(entry *Entry, locator string, narrative bool) string {
+	link := func(text string) string {
+		return `<a href="#ref-` + html.EscapeString(entry.Key) + `">` + text + `</a>`
+	}
+
+	if p.numbered() {
+		number := strconv.Itoa(p.numbers[entry.Key])
+		if locator != "" {
+			number += ", " + html.EscapeString(locator)
+		}
+		cite := link("[" + number + "]")
+		if narrative {
+			cite = html.EscapeString(p.authorLabel(entry, true)) + " " + cite
+		}
+		return cite
+	}
+
+	year := html.EscapeString(yearOrND(entry.Year) + p.yearSuffixes()[entry.Key])
+	if locator != "" {
+		year += ", " + html.EscapeString(locator)
+	}
+	authors := html.EscapeString(p.authorLabel(entry, narrative))
+	if narrative {
+		return link(authors) + " (" + year + ")"
+	}
+	if p.style == StyleChicago {
+		return link(authors + " " + year)
+	}
+	return link(authors + ", " + year)
+}
+
+var pageLocatorRegex = regexp.MustCompile(`^[0-9ivxlcdm]+(?:\s*[-–]\s*[0-9ivxlcdm]+)?$`)
+
+// formatLocator adds "p."/"pp." to bare page numbers where the style uses it
+func (p *Processor) formatLocator(locator string) string {
+	locator = strings.TrimSpace(locator)
+	if locator == "" || !pageLocatorRegex.MatchString(locator) {
+		return locator
+	}
+	locator = strings.Replace(strings.ReplaceAll(locator, " ", ""), "-", "–", 1)
+	if p.style == StyleChicago {
+		return locator
+	}
+	if strings.Contains(locator, "–") {
+		return "pp. " + locator
+	}
+	return "p. " + locator
+}
+
+// authorLabel returns the short author form used in citations
+func (p *Processor) authorLabel(entry *Entry, narrative bool) string {
+	names := entry.Authors
+	if len(names) == 0 {
+		names = entry.Editors
+	}
+	if len(names) == 0 {
+		return shortTitle(entry.Title)
+	}
+
+	families := make([]string, len(names))
+	for i, n := range names {
+		families[i] = familyName(n)
+	}
+
+	and := " and "
+	if p.style == StyleAPA && !narrative {
+		and = " & "
+	}
+	switch {
+	case len(families) == 1:
+		return families[0]
+	case len(families) == 2:
+		return families[0] + and + families[1]
+	case len(families) == 3 && p.style == StyleChicago:
+		return families[0] + ", " + families[1] + "," + and + families[2]
+	default:
+		return families[0] + " et al."
+	}
+}
+
+// References returns the formatted reference list: in citation order for
+// numbered styles, alphabetically by author and year otherwise
+func (p *Processor) References() []Reference {
+	keys := append([]string(nil), p.order...)
+	suffixes := p.yearSuffixes()
+	if !p.numbered() {
+		sort.SliceStable(keys, func(i, j int) bool {
+			a, b := p.bib.Entries[keys[i]], p.bib.Entries[keys[j]]
+			if sa, sb := sortKey(a), sortKey(b); sa != sb {
+				return sa < sb
+			}
+			if a.Year != b.Year {
+				return a.Year < b.Year
+			}
+			return suffixes[a.Key] < suffixes[b.Key]
+		})
+	}
+
+	refs := make([]Reference, 0, len(keys))
+	for _, key := range keys {
+		entry := p.bib.Entries[key]
+		ref := Reference{Key: key}
+		switch p.style {
+		case StyleIEEE:
+			ref.Label = fmt.Sprintf("[%d]", p.numbers[key])
+			ref.HTML = formatIEEE(entry)
+		case StyleChicago:
+			ref.HTML = formatChicago(entry, suffixes[key])
+		default:
+			ref.HTML = formatAPA(entry, suffixes[key])
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// sortKey orders author-year references by author names, then title
+func sortKey(entry *Entry) string {
+	names := entry.Authors
+	if len(names) == 0 {
+		names = entry.Editors
+	}
+	var b strings.Builder
+	for _, n := range names {
+		b.WriteString(strings.ToLower(familyName(n) + " " + n.Given + "|"))
+	}
+	if len(names) == 0 {
+		b.WriteString(strings.ToLower(strings.TrimPrefix(entry.Title, "The ")))
+	}
+	return b.String()
+}
+
+// formatAPA formats a reference in APA 7th edition style
+func formatAPA(e *Entry, suffix string) string {
+	var b strings.Builder
+	title := esc(e.Title)
+	italicTitle := e.Type != TypeArticle && e.Type != TypeChapter && e.Type != TypeConference
+
+	authors := e.Authors
+	if len(authors) == 0 && e.Type != TypeChapter {
+		authors = e.Editors
+	}
+	year := "(" + esc(yearOrND(e.Year)+suffix) + ")."
+	if len(authors) > 0 {
+		b.WriteString(sentence(joinNames(authors, apaName, ", ", ", &amp; ", ", &amp; ")))
+		if len(e.Authors) == 0 {
+			if len(authors) > 1 {
+				b.WriteString(" (Eds.).")
+			} else {
+				b.WriteString(" (Ed.).")
+			}
+		}
+		b.WriteString(" " + year + " ")
+		if italicTitle {
+			b.WriteString("<i>" + title + "</i>")
+		} else {
+			b.WriteString(title)
+		}
+	} else {
+		// Without an author the title moves to the front
+		if italicTitle {
+			b.WriteString("<i>" + title + "</i>")
+		} else {
+			b.WriteString(title)
+		}
+		b.WriteString(". " + year)
+		title = ""
+	}
+
+	switch e.Type {
+	case TypeArticle:
+		b.WriteString(". ")
+		if e.ContainerTitle != "" {
+			b.WriteString("<i>" + esc(e.ContainerTitle) + "</i>")
+			if e.Volume != "" {
+				b.WriteString(", <i>" + esc(e.Volume) + "</i>")
+			}
+			if e.Issue != "" {
+				b.WriteString("(" + esc(e.Issue) + ")")
+			}
+			if e.Pages != "" {
+				b.WriteString(", " + esc(e.Pages))
+			}
+			b.WriteString(".")
+		}
+	case TypeChapter, TypeConference:
+		b.WriteString(". ")
+		if e.ContainerTitle != "" {
+			b.WriteString("In ")
+			if len(e.Editors) > 0 && len(e.Authors) > 0 {
+				b.WriteString(joinNames(e.Editors, givenFirstInitials, ", ", ", &amp; ", " &amp; "))
+				if len(e.Editors) > 1 {
+					b.WriteString(" (Eds.), ")
+				} else {
+					b.WriteString(" (Ed.), ")
+				}
+			}
+			b.WriteString("<i>" + esc(e.ContainerTitle) + "</i>")
+			if e.Pages != "" {
+				b.WriteString(" (pp. " + esc(e.Pages) + ")")
+			}
+			b.WriteString(". ")
+		}
+		if e.Publisher != "" {
+			b.WriteString(sentence(esc(e.Publisher)))
+		}
+	default:
+		var details []string
+		if e.Edition != "" {
+			details = append(details, esc(edition(e.Edition)))
+		}
+		if e.Type == TypeReport && e.Issue != "" {
+			details = append(details, "Report No. "+esc(e.Issue))
+		}
+		if len(details) > 0 {
+			b.WriteString(" (" + strings.Join(details, ", ") + ")")
+		}
+		if e.Type == TypeThesis {
+			b.WriteString(" [" + esc(firstNonEmpty(e.Genre, "Thesis")))
+			if e.Publisher != "" {
+				b.WriteString(", " + esc(e.Publisher))
+			}
+			b.WriteString("]")
+		}
+		if title != "" || len(details) > 0 || e.Type == TypeThesis {
+			b.WriteString(".")
+		}
+		if e.Publisher != "" && e.Type != TypeThesis {
+			b.WriteString(" " + sentence(esc(e.Publisher)))
+		} else if e.ContainerTitle != "" {
+			b.WriteString(" " + sentence(esc(e.ContainerTitle)))
+		}
+	}
+
+	if link := doiOrURL(e, "https://doi.org/"); link != "" {
+		b.WriteString(" " + link)
+	}
+	return cleanPunctuation(b.String())
+}
+
+// formatChicago formats a reference in Chicago author-date style
+func formatChicago(e *Entry, suffix string) string {
+	var b strings.Builder
+	year := esc(yearOrND(e.Year) + suffix)
+
+	authors := e.Authors
+	if len(authors) == 0 && e.Type != TypeChapter {
+		authors = e.Editors
+	}
+	quotedTitle := e.Type == TypeArticle || e.Type == TypeChapter || e.Type == TypeConference || e.Type == TypeThesis || e.Type == TypeWebpage
+	title := "<i>" + esc(e.Title) + "</i>"
+	if quotedTitle {
+		title = "“" + sentence(esc(e.Title)) + "”"
+	}
+
+	if len(authors) > 0 {
+		b.WriteString(joinChicagoNames(authors))
+		if len(e.Authors) == 0 {
+			if len(authors) > 1 {
+				b.WriteString(", eds")
+			} else {
+				b.WriteString(", ed")
+			}
+		}
+		b.WriteString(". " + year + ". " + title)
+	} else {
+		b.WriteString(title + " " + year)
+	}
+	if !quotedTitle {
+		b.WriteString(".")
+	}
+
+	switch e.Type {
+	case TypeArticle:
+		if e.ContainerTitle != "" {
+			b.WriteString(" <i>" + esc(e.ContainerTitle) + "</i>")
+			if e.Volume != "" {
+				b.WriteString(" " + esc(e.Volume))
+			}
+			if e.Issue != "" {
+				b.WriteString(" (" + esc(e.Issue) + ")")
+			}
+			if e.Pages != "" {
+				b.WriteString(": " + esc(e.Pages))
+			}
+			b.WriteString(".")
+		}
+	case TypeChapter, TypeConference:
+		if e.ContainerTitle != "" {
+			b.WriteString(" In <i>" + esc(e.ContainerTitle) + "</i>")
+			if len(e.Editors) > 0 && len(e.Authors) > 0 {
+				b.WriteString(", edited by " + joinNames(e.Editors, givenFirst, ", ", ", and ", " and "))
+			}
+			if e.Pages != "" {
+				b.WriteString(", " + esc(e.Pages))
+			}
+			b.WriteString(".")
+		}
+		b.WriteString(placePublisher(e))
+	case TypeThesis:
+		b.WriteString(" " + esc(firstNonEmpty(e.Genre, "Thesis")))
+		if e.Publisher != "" {
+			b.WriteString(", " + esc(e.Publisher))
+		}
+		b.WriteString(".")
+	case TypeWebpage:
+		if site := firstNonEmpty(e.ContainerTitle, e.Publisher); site != "" {
+			b.WriteString(" " + sentence(esc(site)))
+		}
+	default:
+		if e.Edition != "" {
+			b.WriteString(" " + esc(edition(e.Edition)) + ".")
+		}
+		if e.Type == TypeReport && e.Issue != "" {
+			b.WriteString(" " + esc(firstNonEmpty(e.Genre, "Report")) + " " + esc(e.Issue) + ".")
+		}
+		b.WriteString(placePublisher(e))
+	}
+
+	if link := doiOrURL(e, "https://doi.org/"); link != "" {
+		b.WriteString(" " + link + ".")
+	}
+	return cleanPunctuation(b.String())
+}
+
+// formatIEEE formats a reference in IEEE style
+func formatIEEE(e *Entry) string {
+	var parts []string
+	authors := e.Authors
+	if len(authors) > 6 {
+		authors = authors[:1]
+	}
+	authorText := joinNames(authors, givenFirstInitials, ", ", ", and ", " and ")
+	if len(e.Authors) > 6 {
+		authorText += " <i>et al.</i>"
+	}
+	if len(e.Authors) == 0 && len(e.Editors) > 0 {
+		authorText = joinNames(e.Editors, givenFirstInitials, ", ", ", and ", " and ") + ", Ed."
+		if len(e.Editors) > 1 {
+			authorText += "s."
+		}
+	}
+
+	quoted := "“" + esc(e.Title) + ",”"
+	var b strings.Builder
+	if authorText != "" {
+		b.WriteString(authorText + ", ")
+	}
+
+	switch e.Type {
+	case TypeArticle:
+		b.WriteString(quoted)
+		if e.ContainerTitle != "" {
+			parts = append(parts, "<i>"+esc(e.ContainerTitle)+"</i>")
+		}
+		if e.Volume != "" {
+			parts = append(parts, "vol. "+esc(e.Volume))
+		}
+		if e.Issue != "" {
+			parts = append(parts, "no. "+esc(e.Issue))
+		}
+		if e.Pages != "" {
+			parts = append(parts, pagesIEEE(e.Pages))
+		}
+		if e.Year != "" {
+			parts = append(parts, esc(e.Year))
+		}
+	case TypeChapter, TypeConference:
+		b.WriteString(quoted)
+		if e.ContainerTitle != "" {
+			container := "in <i>" + esc(e.ContainerTitle) + "</i>"
+			if len(e.Editors) > 0 && len(e.Authors) > 0 {
+				container += ", " + joinNames(e.Editors, givenFirstInitials, ", ", ", and ", " and ") + ", Ed"
+				if len(e.Editors) > 1 {
+					container += "s"
+				}
+				container += "."
+			}
+			parts = append(parts, container)
+		}
+		if e.Type == TypeChapter && e.Publisher != "" {
+			pub := esc(e.Publisher)
+			if e.Place != "" {
+				pub = esc(e.Place) + ": " + pub
+			}
+			parts = append(parts, pub)
+		} else if e.Place != "" {
+			parts = append(parts, esc(e.Place))
+		}
+		if e.Year != "" {
+			parts = append(parts, esc(e.Year))
+		}
+		if e.Pages != "" {
+			parts = append(parts, pagesIEEE(e.Pages))
+		}
+	case TypeThesis, TypeReport:
+		b.WriteString(quoted)
+		if e.Type == TypeThesis {
+			parts = append(parts, esc(firstNonEmpty(e.Genre, "Thesis")))
+		}
+		if e.Publisher != "" {
+			parts = append(parts, esc(e.Publisher))
+		}
+		if e.Place != "" {
+			parts = append(parts, esc(e.Place))
+		}
+		if e.Type == TypeReport {
+			report := esc(firstNonEmpty(e.Genre, "Tech. Rep."))
+			if e.Issue != "" {
+				report += " " + esc(e.Issue)
+			}
+			parts = append(parts, report)
+		}
+		if e.Year != "" {
+			parts = append(parts, esc(e.Year))
+		}
+	default:
+		// Books, web pages and other standalone works
+		b.WriteString("<i>" + esc(e.Title) + "</i>")
+		if e.Edition != "" {
+			parts = append(parts, esc(edition(e.Edition)))
+		}
+		pub := esc(firstNonEmpty(e.Publisher, e.ContainerTitle))
+		if e.Place != "" && pub != "" {
+			pub = esc(e.Place) + ": " + pub
+		}
+		if pub != "" {
+			parts = append(parts, pub)
+		}
+		if e.Year != "" {
+			parts = append(parts, esc(e.Year))
+		}
+		if len(parts) == 0 {
+			b.WriteString(".")
+		} else {
+			b.WriteString(",")
+		}
+	}
+
+	if len(parts) > 0 {
+		b.WriteString(" " + strings.Join(parts, ", ") + ".")
+	}
+	if e.DOI != "" {
+		b.WriteString(" doi: " + esc(strings.TrimPrefix(strings.TrimPrefix(e.DOI, "https://doi.org/"), "doi:")) + ".")
+	} else if e.URL != "" {
+		b.WriteString(` [Online]. Available: <a href="` + esc(e.URL) + `">` + esc(e.URL) + `</a>`)
+	}
+	return cleanPunctuation(b.String())
+}
+
+// Name formats
+
+func familyName(n Name) string {
+	if n.Literal != "" {
+		return n.Literal
+	}
+	return n.Family
+}
+
+// apaName formats "Smith, J. A."
+func apaName(n Name) string {
+	if n.Literal != "" || n.Given == "" {
+		return esc(familyName(n))
+	}
+	return esc(n.Family + ", " + initials(n.Given))
+}
+
+// givenFirstInitials formats "J. A. Smith"
+func givenFirstInitials(n Name) string {
+	if n.Literal != "" || n.Given == "" {
+		return esc(familyName(n))
+	}
+	return esc(initials(n.Given) + " " + n.Family)
+}
+
+// givenFirst formats "John A. Smith"
+func givenFirst(n Name) string {
+	if n.Literal != "" || n.Given == "" {
+		return esc(familyName(n))
+	}
+	return esc(n.Given + " " + n.Family)
+}
+
+// familyFirst formats "Smith, John A."
+func familyFirst(n Name) string {
+	if n.Literal != "" || n.Given == "" {
+		return esc(familyName(n))
+	}
+	return esc(n.Family + ", " + n.Given)
+}
+
+// joinNames formats and joins names, using last before the final name of
+// three or more and two between exactly two
+func joinNames(names []Name, format func(Name) string, sep, last, two string) string {
+	formatted := make([]string, len(names))
+	for i, n := range names {
+		formatted[i] = format(n)
+	}
+	switch len(formatted) {
+	case 0:
+		return ""
+	case 1:
+		return formatted[0]
+	case 2:
+		return formatted[0] + two + formatted[1]
+	default:
+		return strings.Join(formatted[:len(formatted)-1], sep) + last + formatted[len(formatted)-1]
+	}
+}
+
+// joinChicagoNames inverts only the first name: "Smith, John, and Jane Doe"
+func joinChicagoNames(names []Name) string {
+	if len(names) > 10 {
+		names = names[:7]
+		return joinNames(names, givenFirst, ", ", ", ", ", ") + ", et al"
+	}
+	first := familyFirst(names[0])
+	if len(names) == 1 {
+		return first
+	}
+	rest := make([]string, len(names)-1)
+	for i, n := range names[1:] {
+		rest[i] = givenFirst(n)
+	}
+	if len(rest) == 1 {
+		return first + ", and " + rest[0]
+	}
+	return first + ", " + strings.Join(rest[:len(rest)-1], ", ") + ", and " + rest[len(rest)-1]
+}
+
+// initials abbreviates given names: "John Ronald" to "J. R.", "Jean-Paul" to "J.-P."
+func initials(given string) string {
+	var parts []string
+	for _, word := range strings.Fields(given) {
+		var hyphenated []string
+		for _, piece := range strings.Split(word, "-") {
+			runes := []rune(strings.Trim(piece, "."))
+			if len(runes) == 0 {
+				continue
+			}
+			if !unicode.IsUpper(runes[0]) && len(runes) > 1 {
+				// Lowercase particles such as "de" are kept whole
+				hyphenated = append(hyphenated, string(runes))
+				continue
+			}
+			hyphenated = append(hyphenated, string(runes[0])+".")
+		}
+		if len(hyphenated) > 0 {
+			parts = append(parts, strings.Join(hyphenated, "-"))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Helpers
+
+func esc(s string) string {
+	return html.EscapeString(s)
+}
+
+func yearOrND(year string) string {
+	if year == "" {
+		return "n.d."
+	}
+	return year
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// shortTitle returns the first few words of a title for citations of
+// works without an author
+func shortTitle(title string) string {
+	words := strings.Fields(title)
+	if len(words) > 4 {
+		return strings.Join(words[:4], " ") + "…"
+	}
+	return title
+}
+
+// sentence ends text with a period unless it already ends with punctuation
+func sentence(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
+
+// edition formats an edition number: "2" to "2nd ed."
+func edition(ed string) string {
+	n, err := strconv.Atoi(ed)
+	if err != nil {
+		if strings.Contains(strings.ToLower(ed), "ed") {
+			return ed
+		}
+		return ed + " ed."
+	}
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s ed.", n, suffix)
+}
+
+// pagesIEEE formats "p. 5" or "pp. 5–10"
+func pagesIEEE(pages string) string {
+	if strings.ContainsAny(pages, "–-,") {
+		return "pp. " + esc(pages)
+	}
+	return "p. " + esc(pages)
+}
+
+// placePublisher formats " Place: Publisher."
+func placePublisher(e *Entry) string {
+	switch {
+	case e.Place != "" && e.Publisher != "":
+		return " " + esc(e.Place) + ": " + esc(e.Publisher) + "."
+	case e.Publisher != "":
+		return " " + sentence(esc(e.Publisher))
+	}
+	return ""
+}
+
+// doiOrURL returns a link to the DOI, or else the URL
+func doiOrURL(e *Entry, doiPrefix string) string {
+	if e.DOI != "" {
+		doi := strings.TrimPrefix(strings.TrimPrefix(e.DOI, "https://doi.org/"), "doi:")
+		return `<a href="` + esc(doiPrefix+doi) + `">` + esc(doiPrefix+doi) + `</a>`
+	}
+	if e.URL != "" {
+		return `<a href="` + esc(e.URL) + `">` + esc(e.URL) + `</a>`
+	}
+	return ""
+}
+
+var doublePunctuationRegex = regexp.MustCompile(`([.?!])(</i>|”)?\.`)
+
+// cleanPunctuation removes periods doubled by titles or abbreviations that
+// already end in punctuation
+func cleanPunctuation(s string) string {
+	return doublePunctuationRegex.ReplaceAllString(s, "$1$2")
+}
@@ -4,13 +4,20 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
 // Config holds the configuration for the Simple HTML Document Generator
 type Config struct {
-	RootDir string // Root directory for storing HTML documents
+	RootDir       string // Root directory for storing HTML documents
+	MermaidScript string // Local mermaid.min.js used to render Mermaid diagrams
+
+	ChromeMaxPages int // Maximum number of concurrent pages in the shared headless Chrome
 }
 
+// DefaultChromeMaxPages is used when SIMPLE_HTML_CHROME_PAGES is not set
+const DefaultChromeMaxPages = 4
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
 	rootDir := os.Getenv("SIMPLE_HTML_ROOT_DIR")
@@ -28,7 +35,24 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to create root directory %s: %w", rootDir, err)
 	}
 
+	// Mermaid is never loaded from a CDN; default to a copy next to the documents
+	mermaidScript := os.Getenv("SIMPLE_HTML_MERMAID_JS")
+	if mermaidScript == "" {
+		mermaidScript = filepath.Join(rootDir, "assets", "mermaid.min.js")
+	}
+
+	chromeMaxPages := DefaultChromeMaxPages
+	if v := os.Getenv("SIMPLE_HTML_CHROME_PAGES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid SIMPLE_HTML_CHROME_PAGES %q: must be a positive integer", v)
+		}
+		chromeMaxPages = n
+	}
+
 	return &Config{
-		RootDir: rootDir,
+		RootDir:        rootDir,
+		MermaidScript:  mermaidScript,
+		ChromeMaxPages: chromeMaxPages,
 	}, nil
 }
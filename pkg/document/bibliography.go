@@ -0,0 +1,85 @@
+package document
+
+import (
+	"fmt"
+	"path"
+	"simple_html_docgen/pkg/citation"
+)
+
+// AddBibliography attaches a BibTeX or CSL JSON bibliography to a document,
+// replacing any previous one. The file is checked by parsing it and stored
+// in the media folder as bibliography.bib or bibliography.json. An empty
+// format is detected from the filename and content.
+func (s *Service) AddBibliography(documentID, filename string, data []byte, format string) (*BibliographyInfo, error) {
+	if !ValidateDocumentID(documentID) {
+		return nil, fmt.Errorf("invalid document ID: %s", documentID)
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("bibliography content cannot be empty")
+	}
+
+	bib, err := citation.Parse(data, format, filename)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bibliography: %w", err)
+	}
+
+	defer s.lockDocument(documentID)()
+	metadata, err := s.storage.ReadMetadata(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	name := "bibliography.bib"
+	if bib.Format == citation.FormatCSLJSON {
+		name = "bibliography.json"
+	}
+	// A new file of the same format overwrites the previous one
+	replace := ""
+	if previous := metadata.Bibliography; previous != nil && path.Ext(previous.RelativePath) == path.Ext(name) {
+		replace = previous.RelativePath
+	}
+	relativePath, err := s.storage.WriteMediaFile(documentID, name, data, replace)
+	if err != nil && replace != "" {
+		relativePath, err = s.storage.WriteMediaFile(documentID, name, data, "")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write bibliography: %w", err)
+	}
+
+	info := &BibliographyInfo{
+		RelativePath: toURLPath(relativePath),
+		Format:       bib.Format,
+		EntryCount:   len(bib.Keys),
+	}
+	metadata.Bibliography = info
+	if err := s.storage.WriteMetadata(documentID, metadata); err != nil {
+		return nil, fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	result := *info
+	result.Keys = bib.Keys
+	return &result, nil
+}
+
+// GetBibliography returns the parsed bibliography attached to a document,
+// or nil if it has none
+func (s *Service) GetBibliography(documentID string) (*citation.Bibliography, error) {
+	metadata, err := s.storage.ReadMetadata(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	if metadata.Bibliography == nil {
+		return nil, nil
+	}
+
+	data, err := s.storage.ReadMediaFile(documentID, metadata.Bibliography.RelativePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bibliography: %w", err)
+	}
+	bib, err := citation.Parse(data, metadata.Bibliography.Format, metadata.Bibliography.RelativePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bibliography: %w", err)
+	}
+	return bib, nil
+}
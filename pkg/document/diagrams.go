@@ -0,0 +1,49 @@
+package document
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CachedDiagram returns the media path of a diagram rendered earlier under
+// the given key, if its file still exists
+func (s *Service) CachedDiagram(documentID, key string) (string, bool) {
+	metadata, err := s.storage.ReadMetadata(documentID)
+	if err != nil {
+		return "", false
+	}
+	relativePath, ok := metadata.Diagrams[key]
+	if !ok {
+		return "", false
+	}
+	if _, err := os.Stat(filepath.Join(s.GetDocumentPath(documentID), filepath.FromSlash(relativePath))); err != nil {
+		return "", false
+	}
+	return relativePath, true
+}
+
+// AddDiagram stores a rendered diagram SVG as media and records the path
+// it was stored under, which may be an existing file with the same
+// content, so CachedDiagram finds it again
+func (s *Service) AddDiagram(documentID, key, filename string, svg []byte) (string, error) {
+	info, err := s.AddMediaContent(documentID, filename, svg, AddMediaOptions{MediaType: MediaTypeSVG})
+	if err != nil {
+		return "", err
+	}
+	relativePath := toURLPath(info.RelativePath)
+
+	defer s.lockDocument(documentID)()
+	metadata, err := s.storage.ReadMetadata(documentID)
+	if err != nil {
+		return "", fmt.Errorf("failed to read metadata: %w", err)
+	}
+	if metadata.Diagrams == nil {
+		metadata.Diagrams = make(map[string]string)
+	}
+	metadata.Diagrams[key] = relativePath
+	if err := s.storage.WriteMetadata(documentID, metadata); err != nil {
+		return "", fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return relativePath, nil
+}
@@ -1,13 +1,21 @@
 package document
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"simple_html_docgen/pkg/imaging"
+	"strings"
+	"sync"
 	"time"
 )
 
 // Service provides document operations
 type Service struct {
 	storage StorageInterface
+	locks   sync.Map // Document ID to the *sync.Mutex guarding its metadata
 }
 
 // StorageInterface defines the storage operations needed by the service
@@ -17,7 +25,11 @@ type StorageInterface interface {
 	UpdateDocument(doc *Document) error
 	GetDocument(documentID string) (*Document, error)
 	ListDocuments() ([]*DocumentInfo, error)
-	CopyMediaFile(documentID, sourcePath string) (string, error)
+	CopyMediaFile(documentID, sourcePath, replace string) (string, error)
+	WriteMediaFile(documentID, filename string, data []byte, replace string) (string, error)
+	ReadMediaFile(documentID, relativePath string) ([]byte, error)
+	ReadMetadata(documentID string) (*Metadata, error)
+	WriteMetadata(documentID string, metadata *Metadata) error
 	DeleteDocument(documentID string) error
 	GetDocumentPath(documentID string) string
 	GetHTMLPath(documentID string) string
@@ -30,7 +42,17 @@ func NewService(storage StorageInterface) *Service {
 	}
 }
 
-// CreateDocument creates a new HTML document
+// lockDocument locks a document's metadata for a read-modify-write, which
+// exports running at the same time may also do, and returns the function
+// that unlocks it
+func (s *Service) lockDocument(documentID string) func() {
+	mu, _ := s.locks.LoadOrStore(documentID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// CreateDocument creates a new HTML document.
+// Inline base64 images are extracted into the media folder.
 func (s *Service) CreateDocument(name, htmlContent string) (*Document, error) {
 	if name == "" {
 		return nil, fmt.Errorf("document name cannot be empty")
@@ -56,10 +78,29 @@ func (s *Service) CreateDocument(name, htmlContent string) (*Document, error) {
 		return nil, fmt.Errorf("failed to create document: %w", err)
 	}
 
+	// The media folder only exists once the document has been created
+	// Remove the new document again if that fails
+	extracted, result, err := s.extractInlineMedia(doc.ID, doc.HTMLContent)
+	if err != nil {
+		s.storage.DeleteDocument(doc.ID)
+		return nil, err
+	}
+	if result.References > 0 {
+		doc.HTMLContent = extracted
+		unlock := s.lockDocument(doc.ID)
+		err := s.storage.UpdateDocument(doc)
+		unlock()
+		if err != nil {
+			s.storage.DeleteDocument(doc.ID)
+			return nil, fmt.Errorf("failed to update document: %w", err)
+		}
+	}
+
 	return doc, nil
 }
 
-// UpdateDocument updates an existing document's HTML content
+// UpdateDocument updates an existing document's HTML content.
+// Inline base64 images are extracted into the media folder.
 func (s *Service) UpdateDocument(documentID, htmlContent string) (*Document, error) {
 	if !ValidateDocumentID(documentID) {
 		return nil, fmt.Errorf("invalid document ID: %s", documentID)
@@ -69,12 +110,19 @@ func (s *Service) UpdateDocument(documentID, htmlContent string) (*Document, err
 		return nil, fmt.Errorf("HTML content cannot be empty")
 	}
 
+	defer s.lockDocument(documentID)()
+
 	// Get existing document to preserve metadata
 	doc, err := s.storage.GetDocument(documentID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get document: %w", err)
 	}
 
+	htmlContent, _, err = s.extractInlineMedia(documentID, htmlContent)
+	if err != nil {
+		return nil, err
+	}
+
 	// Update content and timestamp
 	doc.HTMLContent = htmlContent
 	doc.UpdatedAt = time.Now()
@@ -110,29 +158,348 @@ func (s *Service) ListDocuments() ([]*DocumentInfo, error) {
 	return docs, nil
 }
 
-// AddMedia adds a media file (image/video) to a document
-// Returns the relative path to use in HTML
-func (s *Service) AddMedia(documentID, sourcePath, mediaType string) (string, error) {
+// AddMedia adds a media file to a document after checking that its content
+// matches the media type. An empty media type is detected from the content.
+// Identical content is stored once; a different file with a taken name gets
+// a numeric suffix unless opts.Replace names the file to overwrite.
+func (s *Service) AddMedia(documentID, sourcePath string, opts AddMediaOptions) (*MediaInfo, error) {
 	if !ValidateDocumentID(documentID) {
-		return "", fmt.Errorf("invalid document ID: %s", documentID)
+		return nil, fmt.Errorf("invalid document ID: %s", documentID)
 	}
 
 	if sourcePath == "" {
-		return "", fmt.Errorf("source path cannot be empty")
+		return nil, fmt.Errorf("source path cannot be empty")
 	}
 
-	// Validate media type
-	if mediaType != "image" && mediaType != "video" {
-		return "", fmt.Errorf("invalid media type: %s (must be 'image' or 'video')", mediaType)
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat source file: %w", err)
+	}
+	if stat.IsDir() {
+		return nil, fmt.Errorf("source path is a directory: %s", sourcePath)
+	}
+
+	info, err := s.checkMedia(file, filepath.Base(sourcePath), stat.Size(), opts.MediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Optimized images and variants are derived from the full content, so read it into memory
+	if (opts.Optimize != nil || len(opts.ResponsiveWidths) > 0) && strings.HasPrefix(info.MIMEType, "image/") {
+		data, err := os.ReadFile(sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source file: %w", err)
+		}
+		return s.AddMediaContent(documentID, filepath.Base(sourcePath), data, opts)
 	}
 
 	// Copy file and get relative path
-	relativePath, err := s.storage.CopyMediaFile(documentID, sourcePath)
+	relativePath, err := s.storage.CopyMediaFile(documentID, sourcePath, opts.Replace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add media: %w", err)
+	}
+
+	info.RelativePath = relativePath
+	info.HTMLSnippet = MediaSnippet(info)
+	return info, nil
+}
+
+// AddMediaContent adds media supplied as raw bytes (e.g. decoded base64 or
+// generated SVG) to a document under the given filename, using the same
+// naming rules as AddMedia
+func (s *Service) AddMediaContent(documentID, filename string, data []byte, opts AddMediaOptions) (*MediaInfo, error) {
+	if !ValidateDocumentID(documentID) {
+		return nil, fmt.Errorf("invalid document ID: %s", documentID)
+	}
+
+	if filename == "" {
+		return nil, fmt.Errorf("filename cannot be empty")
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("media content cannot be empty")
+	}
+
+	info, err := s.checkMedia(bytes.NewReader(data), filename, int64(len(data)), opts.MediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.ResponsiveWidths) > 0 && !imaging.Supported(data) {
+		return nil, fmt.Errorf("responsive variants can only be generated for JPEG and PNG images")
+	}
+
+	var optimized *imaging.Result
+	if opts.Optimize != nil && imaging.Supported(data) {
+		optimized, err = imaging.Optimize(data, *opts.Optimize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to optimize image: %w", err)
+		}
+		data = optimized.Data
+	}
+
+	relativePath, err := s.storage.WriteMediaFile(documentID, filename, data, opts.Replace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add media: %w", err)
+	}
+
+	info.RelativePath = relativePath
+	if optimized != nil {
+		unlock := s.lockDocument(documentID)
+		record, err := s.recordOptimization(documentID, relativePath, filename, optimized)
+		unlock()
+		if err != nil {
+			return nil, err
+		}
+		info.SizeBytes = int64(len(data))
+		info.Width, info.Height = optimized.Width, optimized.Height
+		info.Optimization = record
+	}
+
+	if len(opts.ResponsiveWidths) > 0 {
+		quality := 0
+		if opts.Optimize != nil {
+			quality = opts.Optimize.Quality
+		}
+		if info.Width, info.Height, err = imaging.DisplaySize(data); err != nil {
+			return nil, err
+		}
+		info.Variants, err = s.addVariants(documentID, relativePath, data, opts.ResponsiveWidths, quality)
+		if err != nil {
+			return nil, err
+		}
+		info.HTMLSnippet = ResponsiveSnippet(info, opts.Sizes)
+		return info, nil
+	}
+
+	info.HTMLSnippet = MediaSnippet(info)
+	return info, nil
+}
+
+// addVariants generates and stores downscaled copies of an image named
+// <name>-<width>w.<ext>, and records them in the document metadata
+func (s *Service) addVariants(documentID, relativePath string, data []byte, widths []int, quality int) ([]MediaVariant, error) {
+	generated, err := imaging.Variants(data, widths, quality)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate variants: %w", err)
+	}
+
+	defer s.lockDocument(documentID)()
+	metadata, err := s.storage.ReadMetadata(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	key := toURLPath(relativePath)
+
+	// Regenerated variants overwrite the previous files of the same width
+	previous := make(map[int]string)
+	for _, variant := range metadata.Variants[key] {
+		previous[variant.Width] = variant.RelativePath
+	}
+
+	ext := filepath.Ext(relativePath)
+	stem := strings.TrimSuffix(filepath.Base(relativePath), ext)
+
+	variants := make([]MediaVariant, 0, len(generated))
+	for _, variant := range generated {
+		name := fmt.Sprintf("%s-%dw%s", stem, variant.Width, ext)
+		replace := previous[variant.Width]
+		variantPath, err := s.storage.WriteMediaFile(documentID, name, variant.Data, replace)
+		if err != nil && replace != "" {
+			// The previous file is gone; store the variant under a new name
+			variantPath, err = s.storage.WriteMediaFile(documentID, name, variant.Data, "")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write variant: %w", err)
+		}
+		variants = append(variants, MediaVariant{
+			RelativePath: toURLPath(variantPath),
+			Width:        variant.Width,
+			Height:       variant.Height,
+		})
+	}
+
+	if metadata.Variants == nil {
+		metadata.Variants = make(map[string][]MediaVariant)
+	}
+	metadata.Variants[key] = variants
+	if err := s.storage.WriteMetadata(documentID, metadata); err != nil {
+		return nil, fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	return variants, nil
+}
+
+// OptimizeMedia optimizes an existing JPEG or PNG media file in place and
+// records the original and optimized sizes in the document metadata
+func (s *Service) OptimizeMedia(documentID, relativePath string, opts imaging.Options) (*MediaInfo, error) {
+	if !ValidateDocumentID(documentID) {
+		return nil, fmt.Errorf("invalid document ID: %s", documentID)
+	}
+
+	if relativePath == "" {
+		return nil, fmt.Errorf("media path cannot be empty")
+	}
+
+	data, err := s.storage.ReadMediaFile(documentID, relativePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+
+	optimized, err := imaging.Optimize(data, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to optimize image: %w", err)
+	}
+
+	relativePath, err = s.storage.WriteMediaFile(documentID, relativePath, optimized.Data, relativePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to add media: %w", err)
+		return nil, fmt.Errorf("failed to write optimized media: %w", err)
 	}
 
-	return relativePath, nil
+	// Keep the very first original size when a file is optimized repeatedly
+	defer s.lockDocument(documentID)()
+	metadata, err := s.storage.ReadMetadata(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	if previous, ok := metadata.Media[toURLPath(relativePath)]; ok {
+		optimized.OriginalSize = previous.OriginalSizeBytes
+		optimized.OriginalWidth, optimized.OriginalHeight = previous.OriginalWidth, previous.OriginalHeight
+		optimized.MetadataStripped = optimized.MetadataStripped || previous.MetadataStripped
+	}
+
+	record, err := s.recordOptimization(documentID, relativePath, relativePath, optimized)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &MediaInfo{
+		RelativePath: relativePath,
+		MediaType:    MediaTypeImage,
+		MIMEType:     "image/" + optimized.Format,
+		SizeBytes:    int64(len(optimized.Data)),
+		Width:        optimized.Width,
+		Height:       optimized.Height,
+		Optimization: record,
+	}
+	info.HTMLSnippet = MediaSnippet(info)
+	return info, nil
+}
+
+// recordOptimization stores the thumbnail (if any) and saves the
+// optimization statistics in the document metadata. The caller holds the
+// document's lock.
+func (s *Service) recordOptimization(documentID, relativePath, filename string, optimized *imaging.Result) (*MediaOptimization, error) {
+	record := &MediaOptimization{
+		OriginalSizeBytes:  optimized.OriginalSize,
+		OptimizedSizeBytes: optimized.OptimizedSize,
+		OriginalWidth:      optimized.OriginalWidth,
+		OriginalHeight:     optimized.OriginalHeight,
+		Width:              optimized.Width,
+		Height:             optimized.Height,
+		MetadataStripped:   optimized.MetadataStripped,
+	}
+
+	metadata, err := s.storage.ReadMetadata(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	key := toURLPath(relativePath)
+
+	if optimized.Thumbnail != nil {
+		ext := filepath.Ext(filename)
+		thumbName := strings.TrimSuffix(filepath.Base(filename), ext) + "-thumb" + ext
+
+		// Regenerating a thumbnail overwrites the previous one
+		replace := ""
+		if previous, ok := metadata.Media[key]; ok && previous.ThumbnailPath != "" {
+			replace = previous.ThumbnailPath
+		}
+		thumbPath, err := s.storage.WriteMediaFile(documentID, thumbName, optimized.Thumbnail, replace)
+		if err != nil {
+			thumbPath, err = s.storage.WriteMediaFile(documentID, thumbName, optimized.Thumbnail, "")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write thumbnail: %w", err)
+		}
+		record.ThumbnailPath = toURLPath(thumbPath)
+	} else if previous, ok := metadata.Media[key]; ok {
+		record.ThumbnailPath = previous.ThumbnailPath
+	}
+
+	if metadata.Media == nil {
+		metadata.Media = make(map[string]*MediaOptimization)
+	}
+	metadata.Media[key] = record
+	if err := s.storage.WriteMetadata(documentID, metadata); err != nil {
+		return nil, fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	return record, nil
+}
+
+// checkMedia inspects media content and verifies it matches the requested
+// media type, detecting the type when none is given
+func (s *Service) checkMedia(r io.ReadSeeker, filename string, size int64, mediaType string) (*MediaInfo, error) {
+	if mediaType != "" {
+		if err := ValidateMediaType(mediaType); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := inspectMedia(r, filename, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if mediaType == "" {
+		mediaType = MediaTypeForMIME(info.MIMEType)
+	}
+
+	if !mediaTypeAccepts(mediaType, info.MIMEType) {
+		return nil, fmt.Errorf("content of %s is %s, which is not a valid %s", filename, info.MIMEType, mediaType)
+	}
+
+	info.MediaType = mediaType
+	return info, nil
+}
+
+// ExtractInlineMedia moves base64 data: URIs in an existing document into
+// content-hashed files in its media folder and rewrites the references
+func (s *Service) ExtractInlineMedia(documentID string) (*Document, *InlineMediaResult, error) {
+	if !ValidateDocumentID(documentID) {
+		return nil, nil, fmt.Errorf("invalid document ID: %s", documentID)
+	}
+
+	defer s.lockDocument(documentID)()
+	doc, err := s.storage.GetDocument(documentID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	htmlContent, result, err := s.extractInlineMedia(documentID, doc.HTMLContent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if result.References == 0 {
+		return doc, result, nil
+	}
+
+	doc.HTMLContent = htmlContent
+	doc.UpdatedAt = time.Now()
+
+	if err := s.storage.UpdateDocument(doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to update document: %w", err)
+	}
+
+	return doc, result, nil
 }
 
 // DeleteDocument deletes a document
@@ -148,6 +515,20 @@ func (s *Service) DeleteDocument(documentID string) error {
 	return nil
 }
 
+// ReadMedia returns the content of a media file given its path relative
+// to the document root, such as "media/logo.png"
+func (s *Service) ReadMedia(documentID, relativePath string) ([]byte, error) {
+	if !ValidateDocumentID(documentID) {
+		return nil, fmt.Errorf("invalid document ID: %s", documentID)
+	}
+
+	if relativePath == "" {
+		return nil, fmt.Errorf("media path cannot be empty")
+	}
+
+	return s.storage.ReadMediaFile(documentID, relativePath)
+}
+
 // GetDocumentPath returns the absolute path to the document directory
 func (s *Service) GetDocumentPath(documentID string) string {
 	return s.storage.GetDocumentPath(documentID)
@@ -0,0 +1,57 @@
+package document_test
+
+import (
+	"fmt"
+	"simple_html_docgen/pkg/document"
+	"simple_html_docgen/pkg/storage"
+	"sync"
+	"testing"
+)
+
+// newTestService returns a service storing documents in a temporary folder
+func newTestService(t *testing.T) *document.Service {
+	t.Helper()
+	return document.NewService(storage.NewStorage(t.TempDir()))
+}
+
+func TestConcurrentMetadataUpdates(t *testing.T) {
+	s := newTestService(t)
+	doc, err := s.CreateDocument("Report", "<p>Hello</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const diagrams = 100
+	var wg sync.WaitGroup
+	for i := range diagrams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg"><text>%d</text></svg>`, i)
+			if _, err := s.AddDiagram(doc.ID, fmt.Sprintf("key-%d", i), fmt.Sprintf("diagram-%d.svg", i), []byte(svg)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := s.SetProperties(doc.ID, document.Properties{Author: "Ada", Status: "draft"}); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+
+	for i := range diagrams {
+		if _, ok := s.CachedDiagram(doc.ID, fmt.Sprintf("key-%d", i)); !ok {
+			t.Errorf("diagram key-%d was lost", i)
+		}
+	}
+	props, err := s.GetProperties(doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props.Author != "Ada" || props.Status != "draft" {
+		t.Errorf("properties were lost: %+v", props)
+	}
+}
@@ -0,0 +1,166 @@
+package document
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// inlineImageExtensions maps image MIME types found in data: URIs to file extensions
+var inlineImageExtensions = map[string]string{
+	"image/png":     ".png",
+	"image/jpeg":    ".jpg",
+	"image/jpg":     ".jpg",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+	"image/bmp":     ".bmp",
+	"image/avif":    ".avif",
+	"image/x-icon":  ".ico",
+}
+
+// dataURIPattern matches a base64 data: URI payload (without surrounding quotes)
+const dataURIPattern = `data:([a-zA-Z0-9.+/-]+)((?:;[a-zA-Z0-9=.+_-]+)*);base64,([A-Za-z0-9+/=\s]+)`
+
+var (
+	// srcDataURIRegex matches src="data:..." and src='data:...' attributes
+	srcDataURIRegex = regexp.MustCompile(`(?i)(\bsrc\s*=\s*)(?:"` + dataURIPattern + `"|'` + dataURIPattern + `')`)
+	// urlDataURIRegex matches CSS url(data:...), url("data:...") and url('data:...')
+	urlDataURIRegex = regexp.MustCompile(`(?i)url\(\s*(["']?)` + dataURIPattern + `(["']?)\s*\)`)
+	// codeBlockRegex matches elements whose text is shown as written
+	codeBlockRegex = regexp.MustCompile(`(?is)<pre\b.*?</pre\s*>|<code\b.*?</code\s*>|<textarea\b.*?</textarea\s*>`)
+)
+
+// InlineMediaName returns the content-addressed file name used for extracted
+// media. Identical content always maps to the same name.
+func InlineMediaName(data []byte, ext string) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16] + ext
+}
+
+// decodeDataURI decodes a base64 data: URI payload and returns the bytes and
+// file extension. ok is false for MIME types that are not extracted.
+func decodeDataURI(mimeType, payload string) ([]byte, string, bool) {
+	ext, supported := inlineImageExtensions[strings.ToLower(mimeType)]
+	if !supported {
+		return nil, "", false
+	}
+
+	// Base64 in HTML is frequently wrapped across lines
+	cleaned := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, payload)
+
+	data, err := base64.StdEncoding.DecodeString(cleaned)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(cleaned, "="))
+		if err != nil {
+			return nil, "", false
+		}
+	}
+	if len(data) == 0 {
+		return nil, "", false
+	}
+
+	return data, ext, true
+}
+
+// extractInlineMedia replaces base64 data: URIs in src attributes and CSS
+// url() references with files in the document's media folder. Files are
+// named by content hash so identical images are stored only once.
+func (s *Service) extractInlineMedia(documentID, htmlContent string) (string, *InlineMediaResult, error) {
+	result := &InlineMediaResult{
+		BytesBefore: len(htmlContent),
+	}
+	written := make(map[string]string)
+
+	var saveErr error
+	save := func(mimeType, payload string) (string, bool) {
+		data, ext, ok := decodeDataURI(mimeType, payload)
+		if !ok {
+			return "", false
+		}
+
+		filename := InlineMediaName(data, ext)
+		if relativePath, seen := written[filename]; seen {
+			result.References++
+			return relativePath, true
+		}
+
+		relativePath, err := s.storage.WriteMediaFile(documentID, filename, data, "")
+		if err != nil {
+			saveErr = err
+			return "", false
+		}
+
+		written[filename] = relativePath
+		result.Files = append(result.Files, relativePath)
+		result.References++
+		return relativePath, true
+	}
+
+	htmlContent = outsideCode(htmlContent, func(segment string) string {
+		return srcDataURIRegex.ReplaceAllStringFunc(segment, func(match string) string {
+			if saveErr != nil {
+				return match
+			}
+			groups := srcDataURIRegex.FindStringSubmatch(match)
+			quote, mimeType, payload := `"`, groups[2], groups[4]
+			if mimeType == "" {
+				quote, mimeType, payload = `'`, groups[5], groups[7]
+			}
+			relativePath, ok := save(mimeType, payload)
+			if !ok {
+				return match
+			}
+			return groups[1] + quote + toURLPath(relativePath) + quote
+		})
+	})
+
+	htmlContent = outsideCode(htmlContent, func(segment string) string {
+		return urlDataURIRegex.ReplaceAllStringFunc(segment, func(match string) string {
+			if saveErr != nil {
+				return match
+			}
+			groups := urlDataURIRegex.FindStringSubmatch(match)
+			relativePath, ok := save(groups[2], groups[4])
+			if !ok {
+				return match
+			}
+			// Keep the original quoting so url() inside a style attribute stays valid
+			return "url(" + groups[1] + toURLPath(relativePath) + groups[5] + ")"
+		})
+	})
+
+	if saveErr != nil {
+		return "", nil, fmt.Errorf("failed to extract inline media: %w", saveErr)
+	}
+
+	result.BytesAfter = len(htmlContent)
+	return htmlContent, result, nil
+}
+
+// outsideCode applies replace to the parts of an HTML document outside
+// <pre>, <code> and <textarea>, so data: URIs in code samples stay as written
+func outsideCode(htmlContent string, replace func(string) string) string {
+	var b strings.Builder
+	pos := 0
+	for _, loc := range codeBlockRegex.FindAllStringIndex(htmlContent, -1) {
+		b.WriteString(replace(htmlContent[pos:loc[0]]))
+		b.WriteString(htmlContent[loc[0]:loc[1]])
+		pos = loc[1]
+	}
+	b.WriteString(replace(htmlContent[pos:]))
+	return b.String()
+}
+
+// toURLPath converts an OS-specific relative path to a forward-slash URL path
+func toURLPath(relativePath string) string {
+	return strings.ReplaceAll(relativePath, "\\", "/")
+}
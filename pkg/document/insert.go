@@ -0,0 +1,187 @@
+package document
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Insert positions relative to the target element
+const (
+	PositionAppend  = "append"  // Inside the element, after its content
+	PositionPrepend = "prepend" // Inside the element, before its content
+	PositionBefore  = "before"  // Before the element
+	PositionAfter   = "after"   // After the element
+	PositionReplace = "replace" // Instead of the element
+)
+
+// voidElements never have a closing tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true,
+	"track": true, "wbr": true,
+}
+
+var selectorRegex = regexp.MustCompile(`^(?:([a-zA-Z][a-zA-Z0-9-]*)|#([A-Za-z0-9_:.-]+)|\.([A-Za-z0-9_-]+))$`)
+
+// InsertTarget describes where an HTML fragment is inserted. Placeholder
+// takes precedence over Selector; with neither, the fragment is appended
+// to the end of <body> (or the document).
+type InsertTarget struct {
+	Selector    string // Simple selector: "tag", "#id" or ".class"; the first match is used
+	Placeholder string // Literal text (e.g. "<!-- table:sales -->") replaced by the fragment
+	Position    string // append (default), prepend, before, after or replace
+}
+
+// element is the location of an element within an HTML string
+type element struct {
+	start    int // Start of the opening tag
+	openEnd  int // End of the opening tag
+	closeBeg int // Start of the closing tag (equals openEnd for void elements)
+	end      int // End of the closing tag
+	void     bool
+}
+
+// InsertFragment inserts an HTML fragment into a document's HTML
+func InsertFragment(htmlContent, fragment string, target InsertTarget) (string, error) {
+	if target.Placeholder != "" {
+		idx := strings.Index(htmlContent, target.Placeholder)
+		if idx == -1 {
+			return "", fmt.Errorf("placeholder not found: %s", target.Placeholder)
+		}
+		return htmlContent[:idx] + fragment + htmlContent[idx+len(target.Placeholder):], nil
+	}
+
+	selector := target.Selector
+	position := target.Position
+	if position == "" {
+		position = PositionAppend
+	}
+	if selector == "" {
+		if !strings.Contains(strings.ToLower(htmlContent), "<body") {
+			return htmlContent + "\n" + fragment + "\n", nil
+		}
+		selector = "body"
+	}
+
+	el, err := findElement(htmlContent, selector)
+	if err != nil {
+		return "", err
+	}
+
+	switch position {
+	case PositionAppend, PositionPrepend:
+		if el.void {
+			return "", fmt.Errorf("cannot %s inside void element %s", position, selector)
+		}
+		at := el.closeBeg
+		if position == PositionPrepend {
+			at = el.openEnd
+		}
+		return htmlContent[:at] + "\n" + fragment + "\n" + htmlContent[at:], nil
+	case PositionBefore:
+		return htmlContent[:el.start] + fragment + "\n" + htmlContent[el.start:], nil
+	case PositionAfter:
+		return htmlContent[:el.end] + "\n" + fragment + htmlContent[el.end:], nil
+	case PositionReplace:
+		return htmlContent[:el.start] + fragment + htmlContent[el.end:], nil
+	default:
+		return "", fmt.Errorf("invalid position: %s (must be append, prepend, before, after or replace)", position)
+	}
+}
+
+// EnsureHeadContent adds content (such as a <style> block) to the document
+// head unless an element with the given id is already present
+func EnsureHeadContent(htmlContent, id, content string) string {
+	if regexp.MustCompile(`(?i)\bid\s*=\s*["']?` + regexp.QuoteMeta(id) + `["'\s>]`).MatchString(htmlContent) {
+		return htmlContent
+	}
+
+	lower := strings.ToLower(htmlContent)
+	if idx := strings.Index(lower, "</head>"); idx != -1 {
+		return htmlContent[:idx] + content + "\n" + htmlContent[idx:]
+	}
+	return content + "\n" + htmlContent
+}
+
+// findElement locates the first element matching a simple selector
+func findElement(htmlContent, selector string) (*element, error) {
+	match := selectorRegex.FindStringSubmatch(strings.TrimSpace(selector))
+	if match == nil {
+		return nil, fmt.Errorf("unsupported selector: %s (use tag, #id or .class)", selector)
+	}
+
+	var openRegex *regexp.Regexp
+	switch {
+	case match[1] != "":
+		openRegex = regexp.MustCompile(`(?i)<(` + regexp.QuoteMeta(match[1]) + `)\b[^>]*>`)
+	case match[2] != "":
+		openRegex = regexp.MustCompile(`(?i)<([a-z][a-z0-9-]*)\b[^>]*\bid\s*=\s*(?:"` + regexp.QuoteMeta(match[2]) + `"|'` + regexp.QuoteMeta(match[2]) + `'|` + regexp.QuoteMeta(match[2]) + `\b)[^>]*>`)
+	default:
+		openRegex = regexp.MustCompile(`(?i)<([a-z][a-z0-9-]*)\b[^>]*\bclass\s*=\s*["'](?:[^"']*\s)?` + regexp.QuoteMeta(match[3]) + `(?:\s[^"']*)?["'][^>]*>`)
+	}
+
+	loc := openRegex.FindStringSubmatchIndex(htmlContent)
+	if loc == nil {
+		return nil, fmt.Errorf("no element matches selector: %s", selector)
+	}
+
+	tag := strings.ToLower(htmlContent[loc[2]:loc[3]])
+	el := &element{start: loc[0], openEnd: loc[1]}
+	if voidElements[tag] || strings.HasSuffix(htmlContent[loc[0]:loc[1]], "/>") {
+		el.void = true
+		el.closeBeg, el.end = el.openEnd, el.openEnd
+		return el, nil
+	}
+
+	// Walk nested tags of the same name to find the matching close tag
+	tagRegex := regexp.MustCompile(`(?i)<(/?)` + regexp.QuoteMeta(tag) + `\b[^>]*>`)
+	depth := 1
+	for _, m := range tagRegex.FindAllStringSubmatchIndex(htmlContent[el.openEnd:], -1) {
+		if m[3] > m[2] {
+			depth--
+		} else if !strings.HasSuffix(htmlContent[el.openEnd+m[0]:el.openEnd+m[1]], "/>") {
+			depth++
+		}
+		if depth == 0 {
+			el.closeBeg = el.openEnd + m[0]
+			el.end = el.openEnd + m[1]
+			return el, nil
+		}
+	}
+
+	return nil, fmt.Errorf("element matching %s is not closed", selector)
+}
+
+// InsertHTML inserts an HTML fragment into a document at the given target.
+// If headContent is set it is added to the document head once, identified
+// by headID, so repeated inserts share a single stylesheet.
+func (s *Service) InsertHTML(documentID, fragment string, target InsertTarget, headID, headContent string) (*Document, error) {
+	if !ValidateDocumentID(documentID) {
+		return nil, fmt.Errorf("invalid document ID: %s", documentID)
+	}
+
+	defer s.lockDocument(documentID)()
+	doc, err := s.storage.GetDocument(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+
+	htmlContent, err := InsertFragment(doc.HTMLContent, fragment, target)
+	if err != nil {
+		return nil, err
+	}
+	if headContent != "" {
+		htmlContent = EnsureHeadContent(htmlContent, headID, headContent)
+	}
+
+	doc.HTMLContent = htmlContent
+	doc.UpdatedAt = time.Now()
+
+	if err := s.storage.UpdateDocument(doc); err != nil {
+		return nil, fmt.Errorf("failed to update document: %w", err)
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,482 @@
+package document
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif"  // Register GIF decoder for dimension detection
+	_ "image/jpeg" // Register JPEG decoder for dimension detection
+	_ "image/png"  // Register PNG decoder for dimension detection
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Media types accepted by AddMedia
+const (
+	MediaTypeImage      = "image"
+	MediaTypeVideo      = "video"
+	MediaTypeAudio      = "audio"
+	MediaTypeFont       = "font"
+	MediaTypeSVG        = "svg"
+	MediaTypeAttachment = "attachment"
+)
+
+// MediaTypes lists all supported media types
+var MediaTypes = []string{
+	MediaTypeImage,
+	MediaTypeVideo,
+	MediaTypeAudio,
+	MediaTypeFont,
+	MediaTypeSVG,
+	MediaTypeAttachment,
+}
+
+// sniffLength is the number of leading bytes used for content detection
+const sniffLength = 4096
+
+// textMIMETypes refines plain text detections using the file extension
+var textMIMETypes = map[string]string{
+	".csv":  "text/csv",
+	".tsv":  "text/tab-separated-values",
+	".json": "application/json",
+	".md":   "text/markdown",
+}
+
+// zipMIMETypes refines ZIP detections of ZIP-based formats using the file extension
+var zipMIMETypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".epub": "application/epub+zip",
+}
+
+var (
+	svgTagRegex     = regexp.MustCompile(`(?is)<svg\b[^>]*>`)
+	svgRootRegex    = regexp.MustCompile(`^<(?:[A-Za-z_][\w.-]*:)?svg[\s/>]`)
+	shebangRegex    = regexp.MustCompile(`^#![ \t]*/`)
+	svgAttrRegex    = regexp.MustCompile(`(?i)\s(width|height|viewBox)\s*=\s*["']([^"']*)["']`)
+	svgLengthRegex  = regexp.MustCompile(`^\s*([0-9]*\.?[0-9]+)\s*(px)?\s*$`)
+	altCleanupRegex = regexp.MustCompile(`[-_]+`)
+)
+
+// ValidateMediaType checks that the media type is supported
+func ValidateMediaType(mediaType string) error {
+	for _, t := range MediaTypes {
+		if mediaType == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid media type: %s (must be one of %s)", mediaType, strings.Join(MediaTypes, ", "))
+}
+
+// DetectMIMEType determines the MIME type of media from its leading bytes.
+// The filename is only used to refine generic results such as plain text or ZIP.
+func DetectMIMEType(head []byte, filename string) string {
+	if isExecutable(head) {
+		return "application/x-executable"
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	// Formats http.DetectContentType does not distinguish
+	switch {
+	case len(head) >= 4 && string(head[:4]) == "fLaC":
+		return "audio/flac"
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		switch string(head[8:12]) {
+		case "M4A ", "M4B ":
+			return "audio/mp4"
+		case "avif", "avis":
+			return "image/avif"
+		case "heic", "heix", "mif1":
+			return "image/heic"
+		case "qt  ":
+			return "video/quicktime"
+		}
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 && head[1]&0x06 != 0 && head[1] != 0xFE:
+		// MPEG audio frame sync without an ID3 tag (0xFFFE is a UTF-16 BOM)
+		return "audio/mpeg"
+	}
+
+	mimeType := http.DetectContentType(head)
+	if idx := strings.Index(mimeType, ";"); idx != -1 {
+		mimeType = mimeType[:idx]
+	}
+
+	switch mimeType {
+	case "text/xml", "text/plain", "text/html":
+		if isSVG(head) {
+			return "image/svg+xml"
+		}
+		if refined, ok := textMIMETypes[ext]; ok {
+			return refined
+		}
+	case "application/zip":
+		if refined, ok := zipMIMETypes[ext]; ok {
+			return refined
+		}
+	case "audio/wave":
+		// audio/wav is the name browsers recognize in <source type>
+		return "audio/wav"
+	case "application/ogg":
+		// Ogg without a video stream is most commonly Vorbis/Opus audio
+		if bytes.Contains(head, []byte("theora")) {
+			return "video/ogg"
+		}
+		return "audio/ogg"
+	}
+
+	return mimeType
+}
+
+// MediaTypeForMIME returns the media type that best describes a MIME type
+func MediaTypeForMIME(mimeType string) string {
+	switch {
+	case mimeType == "image/svg+xml":
+		return MediaTypeSVG
+	case strings.HasPrefix(mimeType, "image/"):
+		return MediaTypeImage
+	case strings.HasPrefix(mimeType, "video/"):
+		return MediaTypeVideo
+	case strings.HasPrefix(mimeType, "audio/"):
+		return MediaTypeAudio
+	case strings.HasPrefix(mimeType, "font/"), mimeType == "application/vnd.ms-fontobject":
+		return MediaTypeFont
+	default:
+		return MediaTypeAttachment
+	}
+}
+
+// mediaTypeAccepts reports whether content of the given MIME type may be added as mediaType
+func mediaTypeAccepts(mediaType, mimeType string) bool {
+	if mimeType == "application/x-executable" {
+		return false
+	}
+
+	switch mediaType {
+	case MediaTypeImage:
+		// SVG is an image too; the svg type only narrows it
+		return strings.HasPrefix(mimeType, "image/")
+	case MediaTypeAttachment:
+		return true
+	default:
+		return MediaTypeForMIME(mimeType) == mediaType
+	}
+}
+
+// isExecutable detects native executables and scripts
+func isExecutable(head []byte) bool {
+	magics := [][]byte{
+		[]byte("\x7fELF"),          // Linux ELF
+		[]byte("\xfe\xed\xfa\xce"), // Mach-O 32-bit
+		[]byte("\xfe\xed\xfa\xcf"), // Mach-O 64-bit
+		[]byte("\xce\xfa\xed\xfe"), // Mach-O 32-bit little endian
+		[]byte("\xcf\xfa\xed\xfe"), // Mach-O 64-bit little endian
+	}
+	for _, magic := range magics {
+		if bytes.HasPrefix(head, magic) {
+			return true
+		}
+	}
+	return isPE(head) || shebangRegex.Match(head)
+}
+
+// isPE detects Windows executables: an MZ header whose e_lfanew field
+// points at a PE signature
+func isPE(head []byte) bool {
+	if len(head) < 0x40 || !bytes.HasPrefix(head, []byte("MZ")) {
+		return false
+	}
+	offset := int64(binary.LittleEndian.Uint32(head[0x3C:]))
+	return offset+4 <= int64(len(head)) && string(head[offset:offset+4]) == "PE\x00\x00"
+}
+
+// isSVG reports whether text content is an SVG document: its root
+// element, after any byte order mark, XML declaration, comments, doctype
+// and whitespace, is <svg>
+func isSVG(head []byte) bool {
+	rest := bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	for {
+		rest = bytes.TrimLeft(rest, " \t\r\n")
+		var end []byte
+		switch {
+		case bytes.HasPrefix(rest, []byte("<?")):
+			end = []byte("?>")
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			end = []byte("-->")
+		case len(rest) >= 9 && strings.EqualFold(string(rest[:9]), "<!DOCTYPE"):
+			// An internal subset in brackets may hold '>'
+			if i := bytes.IndexAny(rest, "[>"); i != -1 && rest[i] == '[' {
+				end = []byte("]>")
+			} else {
+				end = []byte(">")
+			}
+		default:
+			return svgRootRegex.Match(rest)
+		}
+		i := bytes.Index(rest, end)
+		if i == -1 {
+			return false
+		}
+		rest = rest[i+len(end):]
+	}
+}
+
+// inspectMedia detects the MIME type of media and, where cheap, its
+// dimensions or duration
+func inspectMedia(r io.ReadSeeker, filename string, size int64) (*MediaInfo, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	head = head[:n]
+
+	info := &MediaInfo{
+		MIMEType:  DetectMIMEType(head, filename),
+		SizeBytes: size,
+	}
+
+	switch {
+	case info.MIMEType == "image/svg+xml":
+		info.Width, info.Height = svgDimensions(head)
+	case strings.HasPrefix(info.MIMEType, "image/"):
+		if _, err := r.Seek(0, io.SeekStart); err == nil {
+			if cfg, _, err := image.DecodeConfig(r); err == nil {
+				info.Width, info.Height = cfg.Width, cfg.Height
+			}
+		}
+	case info.MIMEType == "audio/wav":
+		info.DurationSeconds = wavDuration(head)
+	case info.MIMEType == "video/mp4" || info.MIMEType == "audio/mp4" || info.MIMEType == "video/quicktime":
+		info.DurationSeconds = mp4Duration(r, size)
+	}
+
+	return info, nil
+}
+
+// svgDimensions reads width/height from the root <svg> element, falling back to the viewBox
+func svgDimensions(head []byte) (int, int) {
+	tag := svgTagRegex.Find(head)
+	if tag == nil {
+		return 0, 0
+	}
+
+	var width, height float64
+	var viewBox string
+	for _, attr := range svgAttrRegex.FindAllSubmatch(tag, -1) {
+		value := string(attr[2])
+		switch strings.ToLower(string(attr[1])) {
+		case "width":
+			width = parseSVGLength(value)
+		case "height":
+			height = parseSVGLength(value)
+		case "viewbox":
+			viewBox = value
+		}
+	}
+
+	if (width == 0 || height == 0) && viewBox != "" {
+		parts := strings.Fields(strings.ReplaceAll(viewBox, ",", " "))
+		if len(parts) == 4 {
+			vbWidth, _ := strconv.ParseFloat(parts[2], 64)
+			vbHeight, _ := strconv.ParseFloat(parts[3], 64)
+			if width == 0 && height == 0 {
+				width, height = vbWidth, vbHeight
+			} else if width == 0 && vbHeight > 0 {
+				width = height * vbWidth / vbHeight
+			} else if height == 0 && vbWidth > 0 {
+				height = width * vbHeight / vbWidth
+			}
+		}
+	}
+
+	return int(width + 0.5), int(height + 0.5)
+}
+
+// parseSVGLength parses unitless or pixel SVG lengths; other units return 0
+func parseSVGLength(value string) float64 {
+	match := svgLengthRegex.FindStringSubmatch(value)
+	if match == nil {
+		return 0
+	}
+	length, _ := strconv.ParseFloat(match[1], 64)
+	return length
+}
+
+// wavDuration computes the duration of a PCM WAV file from its header chunks
+func wavDuration(head []byte) float64 {
+	if len(head) < 12 || string(head[:4]) != "RIFF" || string(head[8:12]) != "WAVE" {
+		return 0
+	}
+
+	var byteRate uint32
+	for offset := 12; offset+8 <= len(head); {
+		chunkID := string(head[offset : offset+4])
+		chunkSize := binary.LittleEndian.Uint32(head[offset+4 : offset+8])
+		body := offset + 8
+
+		switch chunkID {
+		case "fmt ":
+			if body+12 <= len(head) {
+				byteRate = binary.LittleEndian.Uint32(head[body+8 : body+12])
+			}
+		case "data":
+			if byteRate == 0 {
+				return 0
+			}
+			return float64(chunkSize) / float64(byteRate)
+		}
+
+		// Chunks are word aligned
+		offset = body + int(chunkSize) + int(chunkSize%2)
+	}
+
+	return 0
+}
+
+// mp4Duration reads the movie header (mvhd) box of an MP4/MOV file.
+// Only box headers are read, so this is cheap even for large files.
+func mp4Duration(r io.ReadSeeker, size int64) float64 {
+	var walk func(start, end int64) float64
+	walk = func(start, end int64) float64 {
+		header := make([]byte, 16)
+		for offset := start; offset+8 <= end; {
+			if _, err := r.Seek(offset, io.SeekStart); err != nil {
+				return 0
+			}
+			if _, err := io.ReadFull(r, header[:8]); err != nil {
+				return 0
+			}
+
+			boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+			boxType := string(header[4:8])
+			headerSize := int64(8)
+			if boxSize == 1 {
+				if _, err := io.ReadFull(r, header[8:16]); err != nil {
+					return 0
+				}
+				boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+				headerSize = 16
+			} else if boxSize == 0 {
+				boxSize = end - offset
+			}
+			if boxSize < headerSize {
+				return 0
+			}
+
+			switch boxType {
+			case "moov":
+				return walk(offset+headerSize, offset+boxSize)
+			case "mvhd":
+				body := make([]byte, 32)
+				if _, err := io.ReadFull(r, body); err != nil {
+					return 0
+				}
+				// Version 1 uses 64-bit times and duration
+				if body[0] == 1 {
+					timescale := binary.BigEndian.Uint32(body[20:24])
+					duration := binary.BigEndian.Uint64(body[24:32])
+					if timescale == 0 {
+						return 0
+					}
+					return float64(duration) / float64(timescale)
+				}
+				timescale := binary.BigEndian.Uint32(body[12:16])
+				duration := binary.BigEndian.Uint32(body[16:20])
+				if timescale == 0 {
+					return 0
+				}
+				return float64(duration) / float64(timescale)
+			}
+
+			offset += boxSize
+		}
+		return 0
+	}
+
+	return walk(0, size)
+}
+
+// MediaSnippet returns a ready-to-paste HTML snippet for the media
+func MediaSnippet(info *MediaInfo) string {
+	src := html.EscapeString(toURLPath(info.RelativePath))
+	name := filepath.Base(info.RelativePath)
+	alt := html.EscapeString(strings.TrimSpace(altCleanupRegex.ReplaceAllString(strings.TrimSuffix(name, filepath.Ext(name)), " ")))
+
+	sizeAttrs := ""
+	if info.Width > 0 && info.Height > 0 {
+		sizeAttrs = fmt.Sprintf(` width="%d" height="%d"`, info.Width, info.Height)
+	}
+
+	switch info.MediaType {
+	case MediaTypeImage, MediaTypeSVG:
+		return fmt.Sprintf(`<img src="%s" alt="%s"%s style="max-width: 100%%; height: auto;">`, src, alt, sizeAttrs)
+	case MediaTypeVideo:
+		return fmt.Sprintf(`<video controls preload="metadata"%s style="max-width: 100%%; height: auto;"><source src="%s" type="%s"></video>`, sizeAttrs, src, info.MIMEType)
+	case MediaTypeAudio:
+		return fmt.Sprintf(`<audio controls preload="metadata"><source src="%s" type="%s"></audio>`, src, info.MIMEType)
+	case MediaTypeFont:
+		return fmt.Sprintf(`<style>@font-face { font-family: "%s"; src: url("%s") format("%s"); }</style>`, alt, src, fontFormat(info.MIMEType))
+	default:
+		return fmt.Sprintf(`<a href="%s" download>%s</a>`, src, html.EscapeString(name))
+	}
+}
+
+// ResponsiveSnippet returns an <img srcset sizes> snippet covering the
+// image's variants and the full-size original. If sizes is empty the image
+// fills the viewport up to its natural width.
+func ResponsiveSnippet(info *MediaInfo, sizes string) string {
+	src := toURLPath(info.RelativePath)
+	name := filepath.Base(info.RelativePath)
+	alt := html.EscapeString(strings.TrimSpace(altCleanupRegex.ReplaceAllString(strings.TrimSuffix(name, filepath.Ext(name)), " ")))
+
+	candidates := make([]string, 0, len(info.Variants)+1)
+	for _, variant := range info.Variants {
+		candidates = append(candidates, fmt.Sprintf("%s %dw", srcsetURL(variant.RelativePath), variant.Width))
+	}
+	candidates = append(candidates, fmt.Sprintf("%s %dw", srcsetURL(src), info.Width))
+
+	if sizes == "" {
+		sizes = fmt.Sprintf("(max-width: %dpx) 100vw, %dpx", info.Width, info.Width)
+	}
+
+	// The largest variant below full size is a sensible fallback src
+	fallback := src
+	if len(info.Variants) > 0 {
+		fallback = toURLPath(info.Variants[len(info.Variants)-1].RelativePath)
+	}
+
+	return fmt.Sprintf(`<img src="%s" srcset="%s" sizes="%s" alt="%s" width="%d" height="%d" style="max-width: 100%%; height: auto;">`,
+		html.EscapeString(fallback), html.EscapeString(strings.Join(candidates, ", ")), html.EscapeString(sizes), alt, info.Width, info.Height)
+}
+
+// srcsetURL converts a relative path to a URL for a srcset candidate,
+// encoding the spaces and commas that would split the candidate list
+func srcsetURL(relativePath string) string {
+	return strings.NewReplacer(" ", "%20", ",", "%2C").Replace(toURLPath(relativePath))
+}
+
+// fontFormat returns the CSS @font-face format() hint for a font MIME type
+func fontFormat(mimeType string) string {
+	switch mimeType {
+	case "font/woff2":
+		return "woff2"
+	case "font/woff":
+		return "woff"
+	case "font/otf":
+		return "opentype"
+	case "application/vnd.ms-fontobject":
+		return "embedded-opentype"
+	default:
+		return "truetype"
+	}
+}
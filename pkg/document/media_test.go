@@ -0,0 +1,105 @@
+package document_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"simple_html_docgen/pkg/document"
+	"testing"
+)
+
+// peHeader returns the start of a Windows executable with its PE
+// signature at offset
+func peHeader(offset int) []byte {
+	head := make([]byte, offset+64)
+	copy(head, "MZ")
+	binary.LittleEndian.PutUint32(head[0x3C:], uint32(offset))
+	copy(head[offset:], "PE\x00\x00")
+	return head
+}
+
+func TestDetectMIMEType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	tests := []struct {
+		name     string
+		head     []byte
+		filename string
+		want     string
+	}{
+		{"png", png, "a.png", "image/png"},
+		{"pe executable", peHeader(0x80), "setup.exe", "application/x-executable"},
+		{"elf executable", []byte("\x7fELF\x02\x01\x01"), "tool", "application/x-executable"},
+		{"shell script", []byte("#!/bin/sh\necho hi\n"), "run.txt", "application/x-executable"},
+		{"env script", []byte("#! /usr/bin/env python\n"), "run", "application/x-executable"},
+		{"text starting with MZ", []byte("MZ is a postcode area.\nMore text follows here to pad the file out past sixty-four bytes."), "notes.txt", "text/plain"},
+		{"MZ with bad offset", append(peHeader(0x80)[:0x80], "XX\x00\x00"...), "x.bin", "application/octet-stream"},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "a.svg", "image/svg+xml"},
+		{"svg with prolog", []byte("\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- made by hand -->\n<!DOCTYPE svg PUBLIC \"-//W3C//DTD SVG 1.1//EN\" \"http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd\">\n<svg viewBox=\"0 0 10 10\"/>"), "a.svg", "image/svg+xml"},
+		{"svg with internal subset", []byte(`<?xml version="1.0"?><!DOCTYPE svg [<!ENTITY ns "http://www.w3.org/2000/svg">]><svg xmlns="&ns;"></svg>`), "a.svg", "image/svg+xml"},
+		{"html with inline svg", []byte(`<!DOCTYPE html><html><body><svg></svg></body></html>`), "page.html", "text/html"},
+		{"text mentioning svg", []byte("Use an <svg> element for icons."), "notes.md", "text/markdown"},
+		{"xml with svg child", []byte(`<?xml version="1.0"?><icons><svg/></icons>`), "icons.xml", "text/xml"},
+		{"csv", []byte("a,b\n1,2\n"), "data.csv", "text/csv"},
+	}
+	for _, tt := range tests {
+		if got := document.DetectMIMEType(tt.head, tt.filename); got != tt.want {
+			t.Errorf("%s: DetectMIMEType() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAddMediaContentDeduplicates(t *testing.T) {
+	s := newTestService(t)
+	doc, err := s.CreateDocument("Report", "<p>Hello</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg>`)
+	other := bytes.Replace(svg, []byte(`width="1"`), []byte(`width="2"`), 1)
+
+	first, err := s.AddMediaContent(doc.ID, "logo.svg", svg, document.AddMediaOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.RelativePath != "media/logo.svg" || first.MediaType != document.MediaTypeSVG {
+		t.Fatalf("first add = %s (%s), want media/logo.svg (svg)", first.RelativePath, first.MediaType)
+	}
+
+	// The same content under another name is stored once
+	same, err := s.AddMediaContent(doc.ID, "copy.svg", svg, document.AddMediaOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.RelativePath != first.RelativePath {
+		t.Errorf("identical content stored as %s, want %s", same.RelativePath, first.RelativePath)
+	}
+
+	// Different content with a taken name gets a suffix
+	renamed, err := s.AddMediaContent(doc.ID, "logo.svg", other, document.AddMediaOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.RelativePath == first.RelativePath {
+		t.Error("different content overwrote an existing file")
+	}
+
+	// Replace overwrites the named file
+	replaced, err := s.AddMediaContent(doc.ID, "logo.svg", other, document.AddMediaOptions{Replace: first.RelativePath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.RelativePath != first.RelativePath {
+		t.Errorf("replace wrote %s, want %s", replaced.RelativePath, first.RelativePath)
+	}
+	data, err := s.ReadMedia(doc.ID, first.RelativePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, other) {
+		t.Error("replaced file has the old content")
+	}
+
+	// Content that does not match the media type is rejected
+	if _, err := s.AddMediaContent(doc.ID, "fake.png", []byte("MZ is not an image"), document.AddMediaOptions{MediaType: document.MediaTypeImage}); err == nil {
+		t.Error("text accepted as an image")
+	}
+}
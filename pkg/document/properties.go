@@ -0,0 +1,81 @@
+package document
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GetProperties returns a document's properties, with the title defaulting
+// to the document name
+func (s *Service) GetProperties(documentID string) (*Properties, error) {
+	if !ValidateDocumentID(documentID) {
+		return nil, fmt.Errorf("invalid document ID: %s", documentID)
+	}
+
+	metadata, err := s.storage.ReadMetadata(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	props := Properties{}
+	if metadata.Properties != nil {
+		props = *metadata.Properties
+	}
+	if props.Title == "" {
+		props.Title = metadata.Name
+	}
+	return &props, nil
+}
+
+// SetProperties replaces a document's properties. Surrounding whitespace
+// is trimmed, empty keywords are dropped and the status is lowercased.
+// A cover image must be an image in the media folder.
+func (s *Service) SetProperties(documentID string, props Properties) (*Properties, error) {
+	if !ValidateDocumentID(documentID) {
+		return nil, fmt.Errorf("invalid document ID: %s", documentID)
+	}
+
+	defer s.lockDocument(documentID)()
+	metadata, err := s.storage.ReadMetadata(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	stored := &Properties{
+		Title:   strings.TrimSpace(props.Title),
+		Author:  strings.TrimSpace(props.Author),
+		Subject: strings.TrimSpace(props.Subject),
+		Status:  strings.ToLower(strings.TrimSpace(props.Status)),
+
+		CoverImage: strings.TrimSpace(props.CoverImage),
+	}
+	for _, k := range props.Keywords {
+		if k = strings.TrimSpace(k); k != "" {
+			stored.Keywords = append(stored.Keywords, k)
+		}
+	}
+
+	if stored.CoverImage != "" {
+		data, err := s.ReadMedia(documentID, stored.CoverImage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cover image: %w", err)
+		}
+		if mediaType := MediaTypeForMIME(DetectMIMEType(data, stored.CoverImage)); mediaType != MediaTypeImage && mediaType != MediaTypeSVG {
+			return nil, fmt.Errorf("cover image %s is not an image", stored.CoverImage)
+		}
+	}
+
+	if stored.Title == metadata.Name {
+		// The name is the default title and is not stored twice
+		stored.Title = ""
+	}
+
+	metadata.Properties = stored
+	metadata.UpdatedAt = time.Now()
+	if err := s.storage.WriteMetadata(documentID, metadata); err != nil {
+		return nil, fmt.Errorf("failed to write metadata: %w", err)
+	}
+
+	return s.GetProperties(documentID)
+}
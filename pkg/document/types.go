@@ -1,6 +1,9 @@
 package document
 
-import "time"
+import (
+	"simple_html_docgen/pkg/imaging"
+	"time"
+)
 
 // Document represents an HTML document
 type Document struct {
@@ -13,9 +16,15 @@ type Document struct {
 
 // Metadata represents document metadata stored in metadata.json
 type Metadata struct {
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Name      string                        `json:"name"`
+	CreatedAt time.Time                     `json:"created_at"`
+	UpdatedAt time.Time                     `json:"updated_at"`
+	Media     map[string]*MediaOptimization `json:"media,omitempty"`    // Optimization records keyed by relative media path
+	Variants  map[string][]MediaVariant     `json:"variants,omitempty"` // Responsive variants keyed by relative media path
+	Diagrams  map[string]string             `json:"diagrams,omitempty"` // Rendered diagram SVGs keyed by a hash of the diagram source
+
+	Bibliography *BibliographyInfo `json:"bibliography,omitempty"` // Attached bibliography file, if any
+	Properties   *Properties       `json:"properties,omitempty"`   // Title, author and other properties used in exports
 }
 
 // DocumentInfo is a lightweight document summary for listing
@@ -26,3 +35,76 @@ type DocumentInfo struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	FilePath  string    `json:"file_path"` // Relative path to index.html
 }
+
+// InlineMediaResult describes the outcome of extracting data: URIs into media files
+type InlineMediaResult struct {
+	Files       []string `json:"files"`        // Relative paths of media files written
+	References  int      `json:"references"`   // Number of data: URIs rewritten
+	BytesBefore int      `json:"bytes_before"` // HTML size before extraction
+	BytesAfter  int      `json:"bytes_after"`  // HTML size after extraction
+}
+
+// MediaInfo describes a media file added to a document
+type MediaInfo struct {
+	RelativePath    string  `json:"relative_path"`              // Path relative to the document root
+	MediaType       string  `json:"media_type"`                 // image, video, audio, font, svg or attachment
+	MIMEType        string  `json:"mime_type"`                  // MIME type detected from the file content
+	SizeBytes       int64   `json:"size_bytes"`                 // File size in bytes
+	Width           int     `json:"width,omitempty"`            // Pixel width for images, if known
+	Height          int     `json:"height,omitempty"`           // Pixel height for images, if known
+	DurationSeconds float64 `json:"duration_seconds,omitempty"` // Duration for audio/video, if known
+	HTMLSnippet     string  `json:"html_snippet"`               // Ready-to-paste HTML for the media
+
+	Optimization *MediaOptimization `json:"optimization,omitempty"` // Set when the image was optimized
+	Variants     []MediaVariant     `json:"variants,omitempty"`     // Responsive width variants, smallest first
+}
+
+// MediaVariant is a resized copy of an image used in a srcset
+type MediaVariant struct {
+	RelativePath string `json:"relative_path"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// MediaOptimization records the outcome of optimizing an image
+type MediaOptimization struct {
+	OriginalSizeBytes  int    `json:"original_size_bytes"`
+	OptimizedSizeBytes int    `json:"optimized_size_bytes"`
+	OriginalWidth      int    `json:"original_width"`
+	OriginalHeight     int    `json:"original_height"`
+	Width              int    `json:"width"`
+	Height             int    `json:"height"`
+	MetadataStripped   bool   `json:"metadata_stripped"`
+	ThumbnailPath      string `json:"thumbnail_path,omitempty"`
+}
+
+// BibliographyInfo describes the bibliography file attached to a document
+type BibliographyInfo struct {
+	RelativePath string   `json:"relative_path"`  // Path relative to the document root
+	Format       string   `json:"format"`         // bibtex or csl-json
+	EntryCount   int      `json:"entry_count"`    // Number of entries in the file
+	Keys         []string `json:"keys,omitempty"` // Citation keys, in file order (not stored)
+}
+
+// Properties are descriptive document properties written into exported
+// files, such as the PDF document information
+type Properties struct {
+	Title    string   `json:"title,omitempty"`    // Title; the document name is used when empty
+	Author   string   `json:"author,omitempty"`   // Author or authors
+	Subject  string   `json:"subject,omitempty"`  // Short description of the content
+	Keywords []string `json:"keywords,omitempty"` // Keywords for search and cataloguing
+	Status   string   `json:"status,omitempty"`   // Workflow status such as "draft" or "final"; some statuses stamp exports
+
+	CoverImage string `json:"cover_image,omitempty"` // Image in the media folder used as the cover of EPUB exports, e.g. "media/cover.jpg"
+}
+
+// AddMediaOptions controls how media is added to a document
+type AddMediaOptions struct {
+	MediaType string // Expected media type; detected from the content when empty
+	Replace   string // Existing media file (e.g. "media/chart.png") to overwrite on purpose
+
+	Optimize *imaging.Options // Optional image optimization (JPEG/PNG only)
+
+	ResponsiveWidths []int  // Generate width variants for srcset (JPEG/PNG only)
+	Sizes            string // Optional sizes attribute for the srcset snippet
+}
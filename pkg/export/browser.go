@@ -0,0 +1,231 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// browserCheckTimeout bounds the health check made before each use of the
+// browser and the closing of pages and the browser
+const browserCheckTimeout = 5 * time.Second
+
+// browserPool keeps one headless Chrome running between exports instead of
+// launching it for every PDF. Chrome is started on first use, checked
+// before each use and replaced if it has crashed or stopped responding.
+// At most maxPages callers use it at a time; others wait for a free slot.
+// Chrome is launched, checked and stopped without holding mu, so a slow
+// launch or shutdown only delays the callers that need its result, and
+// they can still give up when their context ends.
+type browserPool struct {
+	mu       sync.Mutex
+	current  *chromeProcess
+	starting chan struct{} // Closed when the launch in progress ends; nil when none is
+	slots    chan struct{}
+	closed   bool
+
+	// Chrome operations, replaced in tests
+	launch   func(ctx context.Context, download bool) (*chromeProcess, error)
+	check    func(c *chromeProcess) bool
+	shutdown func(c *chromeProcess)
+}
+
+// chromeProcess is one running Chrome and the number of callers using it.
+// A process that failed its health check or was closed while in use is
+// retired: later callers get a new one, and it is stopped when its last
+// user is done.
+type chromeProcess struct {
+	launcher *launcher.Launcher
+	browser  *rod.Browser
+	users    int
+	retired  bool
+}
+
+// newBrowserPool creates a pool allowing maxPages concurrent users
+func newBrowserPool(maxPages int) *browserPool {
+	if maxPages < 1 {
+		maxPages = 1
+	}
+	return &browserPool{
+		slots:    make(chan struct{}, maxPages),
+		launch:   startChrome,
+		check:    (*chromeProcess).healthy,
+		shutdown: (*chromeProcess).stop,
+	}
+}
+
+// acquire returns the shared browser bound to ctx and a function that
+// frees the caller's slot. Chrome is started if needed, preferring a
+// system installation; if none is found and download is set, rod
+// downloads Chromium. A browser that fails its health check is only
+// stopped once no other caller is using it.
+func (p *browserPool) acquire(ctx context.Context, download bool) (*rod.Browser, func(), error) {
+	proc, release, err := p.get(ctx, download)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proc.browser.Context(ctx), release, nil
+}
+
+// get takes a slot and returns the current process with the caller
+// counted as one of its users, and the function that undoes both
+func (p *browserPool) get(ctx context.Context, download bool) (*chromeProcess, func(), error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, fmt.Errorf("timed out waiting for browser: %w", ctx.Err())
+	}
+
+	proc, err := p.use(ctx, download)
+	if err != nil {
+		<-p.slots
+		return nil, nil, err
+	}
+	release := func() {
+		p.mu.Lock()
+		proc.users--
+		stop := proc.retired && proc.users == 0
+		p.mu.Unlock()
+		if stop {
+			p.shutdown(proc)
+		}
+		<-p.slots
+	}
+	return proc, release, nil
+}
+
+// use returns a healthy process with the caller added to its users,
+// launching one when there is none. Only one caller launches at a time;
+// the others wait for it or for their context to end.
+func (p *browserPool) use(ctx context.Context, download bool) (*chromeProcess, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, fmt.Errorf("browser has been shut down")
+		}
+
+		if proc := p.current; proc != nil {
+			proc.users++
+			p.mu.Unlock()
+			if p.check(proc) {
+				return proc, nil
+			}
+			p.mu.Lock()
+			proc.users--
+			proc.retired = true
+			if p.current == proc {
+				p.current = nil
+			}
+			stop := proc.users == 0
+			p.mu.Unlock()
+			if stop {
+				p.shutdown(proc)
+			}
+			continue
+		}
+
+		if wait := p.starting; wait != nil {
+			p.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, fmt.Errorf("timed out waiting for browser: %w", ctx.Err())
+			}
+		}
+
+		wait := make(chan struct{})
+		p.starting = wait
+		p.mu.Unlock()
+
+		proc, err := p.launch(ctx, download)
+
+		p.mu.Lock()
+		p.starting = nil
+		close(wait)
+		if err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+		if p.closed {
+			p.mu.Unlock()
+			p.shutdown(proc)
+			return nil, fmt.Errorf("browser has been shut down")
+		}
+		p.current = proc
+		proc.users++
+		p.mu.Unlock()
+		return proc, nil
+	}
+}
+
+// startChrome launches Chrome and connects to it. ctx bounds the launch
+// only; the connection lives until the browser is stopped.
+func startChrome(ctx context.Context, download bool) (*chromeProcess, error) {
+	chromePath, _ := launcher.LookPath()
+	if chromePath == "" && !download {
+		return nil, fmt.Errorf("chrome not found")
+	}
+
+	l := launcher.New().Context(ctx).Headless(true)
+	if chromePath != "" {
+		l = l.Bin(chromePath)
+	}
+	controlURL, err := l.Launch()
+	if err != nil {
+		return nil, fmt.Errorf("chrome not available: %w", err)
+	}
+
+	browser := rod.New().ControlURL(controlURL)
+	if err := browser.Connect(); err != nil {
+		l.Kill()
+		l.Cleanup()
+		return nil, fmt.Errorf("chrome not available: %w", err)
+	}
+	return &chromeProcess{launcher: l, browser: browser}, nil
+}
+
+// healthy reports whether the browser still answers
+func (c *chromeProcess) healthy() bool {
+	_, err := proto.BrowserGetVersion{}.Call(c.browser.Timeout(browserCheckTimeout))
+	return err == nil
+}
+
+// stop closes the browser and kills its process, removing its profile
+func (c *chromeProcess) stop() {
+	_ = c.browser.Timeout(browserCheckTimeout).Close()
+	c.launcher.Kill()
+	c.launcher.Cleanup()
+}
+
+// close shuts the pool down; later acquires fail. A browser still in use
+// is retired, so the exports using it finish and the last one stops it.
+func (p *browserPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	proc := p.current
+	p.current = nil
+	stop := false
+	if proc != nil {
+		proc.retired = true
+		stop = proc.users == 0
+	}
+	p.mu.Unlock()
+	if stop {
+		p.shutdown(proc)
+	}
+}
+
+// closePage closes a page even when the context it was used with has
+// expired, so pages do not pile up in the shared browser
+func closePage(page *rod.Page) {
+	ctx, cancel := context.WithTimeout(context.Background(), browserCheckTimeout)
+	defer cancel()
+	_ = page.Context(ctx).Close()
+}
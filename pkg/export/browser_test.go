@@ -0,0 +1,182 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChrome stands in for Chrome in browser pool tests
+type fakeChrome struct {
+	mu        sync.Mutex
+	launched  []*chromeProcess
+	unhealthy map[*chromeProcess]bool
+	stopped   map[*chromeProcess]int
+	gate      chan struct{} // When set, launches wait for it to be closed
+}
+
+func newFakePool(maxPages int) (*browserPool, *fakeChrome) {
+	f := &fakeChrome{unhealthy: map[*chromeProcess]bool{}, stopped: map[*chromeProcess]int{}}
+	p := newBrowserPool(maxPages)
+	p.launch = func(ctx context.Context, download bool) (*chromeProcess, error) {
+		if f.gate != nil {
+			select {
+			case <-f.gate:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		proc := &chromeProcess{}
+		f.launched = append(f.launched, proc)
+		return proc, nil
+	}
+	p.check = func(c *chromeProcess) bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return !f.unhealthy[c]
+	}
+	p.shutdown = func(c *chromeProcess) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.stopped[c]++
+	}
+	return p, f
+}
+
+func (f *fakeChrome) counts(c *chromeProcess) (launched, stopped int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.launched), f.stopped[c]
+}
+
+func TestBrowserPoolSharesOneProcess(t *testing.T) {
+	p, f := newFakePool(4)
+	f.gate = make(chan struct{})
+
+	var wg sync.WaitGroup
+	procs := make([]*chromeProcess, 4)
+	for i := range procs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			proc, release, err := p.get(context.Background(), false)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			procs[i] = proc
+			release()
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(f.gate)
+	wg.Wait()
+
+	if launched, _ := f.counts(nil); launched != 1 {
+		t.Fatalf("launched %d processes, want 1", launched)
+	}
+	for _, proc := range procs {
+		if proc != procs[0] {
+			t.Fatal("callers got different processes")
+		}
+	}
+}
+
+func TestBrowserPoolWaitHonorsContext(t *testing.T) {
+	p, f := newFakePool(2)
+	f.gate = make(chan struct{})
+	defer close(f.gate)
+
+	go p.get(context.Background(), false)
+	time.Sleep(20 * time.Millisecond)
+
+	// The launch in progress must not hold the pool locked
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := p.get(ctx, false)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("get() error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("get() waited %v for the launch", elapsed)
+	}
+}
+
+func TestBrowserPoolReplacesUnhealthyProcess(t *testing.T) {
+	p, f := newFakePool(2)
+	first, releaseFirst, err := p.get(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.mu.Lock()
+	f.unhealthy[first] = true
+	f.mu.Unlock()
+	second, releaseSecond, err := p.get(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatal("unhealthy process was reused")
+	}
+	if _, stopped := f.counts(first); stopped != 0 {
+		t.Fatal("process stopped while still in use")
+	}
+
+	releaseFirst()
+	if _, stopped := f.counts(first); stopped != 1 {
+		t.Fatalf("retired process stopped %d times after its last user, want 1", stopped)
+	}
+	releaseSecond()
+	if _, stopped := f.counts(second); stopped != 0 {
+		t.Fatal("current process stopped after release")
+	}
+}
+
+func TestBrowserPoolCloseWaitsForUsers(t *testing.T) {
+	p, f := newFakePool(2)
+	proc, release, err := p.get(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.close()
+	if _, stopped := f.counts(proc); stopped != 0 {
+		t.Fatal("close stopped a process in use")
+	}
+	if _, _, err := p.get(context.Background(), false); err == nil {
+		t.Fatal("get() after close succeeded")
+	}
+	release()
+	if _, stopped := f.counts(proc); stopped != 1 {
+		t.Fatalf("process stopped %d times after its last user, want 1", stopped)
+	}
+}
+
+func TestBrowserPoolCloseDuringLaunch(t *testing.T) {
+	p, f := newFakePool(1)
+	f.gate = make(chan struct{})
+
+	done := make(chan error)
+	go func() {
+		_, _, err := p.get(context.Background(), false)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	p.close()
+	close(f.gate)
+
+	if err := <-done; err == nil {
+		t.Fatal("get() succeeded on a closed pool")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.launched) != 1 || f.stopped[f.launched[0]] != 1 {
+		t.Fatal("process launched during close was not stopped")
+	}
+}
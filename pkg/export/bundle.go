@@ -0,0 +1,354 @@
+package export
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"simple_html_docgen/pkg/document"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Renditions that can be added to a ZIP bundle
+var bundleRenditions = []string{"pdf", "docx"}
+
+// BundleOptions controls ZIP exports
+type BundleOptions struct {
+	IncludeUnreferenced bool     // Also pack media files the document does not reference
+	Renditions          []string // Other formats to add, such as "pdf" and "docx"
+}
+
+// BundleResult describes a ZIP export
+type BundleResult struct {
+	Path    string   // The ZIP file written
+	Files   []string // Paths inside the archive, manifest.json last
+	Bytes   int64    // Size of the ZIP file
+	Missing []string // Referenced media files that do not exist
+}
+
+// Manifest is written to manifest.json in a ZIP bundle
+type Manifest struct {
+	DocumentID string               `json:"document_id"`
+	Name       string               `json:"name"`
+	Properties *document.Properties `json:"properties,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Generator  string               `json:"generator"`
+	Files      []ManifestFile       `json:"files"`
+}
+
+// ManifestFile lists one file of a ZIP bundle
+type ManifestFile struct {
+	Path      string `json:"path"`
+	SizeBytes int    `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
+	Role      string `json:"role"` // document, media or rendition
+}
+
+// Validate checks the renditions requested for a bundle
+func (o BundleOptions) Validate() error {
+	for _, r := range o.Renditions {
+		valid := false
+		for _, b := range bundleRenditions {
+			valid = valid || r == b
+		}
+		if !valid {
+			return fmt.Errorf("invalid rendition: %s (must be one of %s)", r, strings.Join(bundleRenditions, ", "))
+		}
+	}
+	return nil
+}
+
+// ExportBundle exports a document as a ZIP archive with its media
+func (e *Exporter) ExportBundle(documentID, outputPath string, opts Options, docSvc *document.Service) (*BundleResult, error) {
+	doc, err := docSvc.GetDocument(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	outputPath, err = prepareOutputPath(documentID, "zip", outputPath, docSvc)
+	if err != nil {
+		return nil, err
+	}
+	return e.exportBundle(doc, outputPath, opts, docSvc)
+}
+
+// exportBundle packs index.html as for an HTML export, the media files it
+// references, any renditions and a manifest with checksums. Nothing else
+// from the document folder, such as metadata.json or earlier exports, is
+// included.
+func (e *Exporter) exportBundle(doc *document.Document, outputPath string, opts Options, docSvc *document.Service) (*BundleResult, error) {
+	if err := opts.Bundle.Validate(); err != nil {
+		return nil, err
+	}
+	root := docSvc.GetDocumentPath(doc.ID)
+
+	htmlContent, err := e.renderContent(doc, opts, docSvc, nil)
+	if err != nil {
+		return nil, err
+	}
+	htmlContent = RenderMath(HighlightCode(htmlContent, opts.CodeTheme))
+	if htmlContent, err = e.watermark(htmlContent, doc, opts, docSvc); err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		name string
+		data []byte
+		role string
+	}
+	entries := []entry{{"index.html", []byte(htmlContent), "document"}}
+	result := &BundleResult{Path: outputPath}
+
+	media, missing := referencedMedia(htmlContent, root)
+	result.Missing = missing
+	if opts.Bundle.IncludeUnreferenced {
+		media = mergeSorted(media, allMedia(root))
+	}
+	for _, name := range media {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		entries = append(entries, entry{name, data, "media"})
+	}
+
+	for _, format := range opts.Bundle.Renditions {
+		data, err := e.rendition(doc, format, opts, docSvc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s rendition: %w", format, err)
+		}
+		entries = append(entries, entry{fmt.Sprintf("%s.%s", doc.ID, format), data, "rendition"})
+	}
+
+	props, err := docSvc.GetProperties(doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	manifest := Manifest{
+		DocumentID: doc.ID,
+		Name:       doc.Name,
+		Properties: props,
+		CreatedAt:  doc.CreatedAt,
+		UpdatedAt:  doc.UpdatedAt,
+		ExportedAt: time.Now().UTC(),
+		Generator:  "simple-html-docgen",
+	}
+	for _, en := range entries {
+		sum := sha256.Sum256(en.data)
+		manifest.Files = append(manifest.Files, ManifestFile{Path: en.name, SizeBytes: len(en.data), SHA256: hex.EncodeToString(sum[:]), Role: en.role})
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	entries = append(entries, entry{"manifest.json", manifestJSON, ""})
+
+	// Write to a temporary file first so a failed export leaves no
+	// partial archive behind
+	tmp, err := os.CreateTemp(filepath.Dir(outputPath), ".bundle-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ZIP file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := zip.NewWriter(tmp)
+	for _, en := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: en.name, Method: zip.Deflate, Modified: doc.UpdatedAt})
+		if err != nil {
+			tmp.Close()
+			return nil, fmt.Errorf("failed to add %s: %w", en.name, err)
+		}
+		if _, err := w.Write(en.data); err != nil {
+			tmp.Close()
+			return nil, fmt.Errorf("failed to add %s: %w", en.name, err)
+		}
+		result.Files = append(result.Files, en.name)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write ZIP file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write ZIP file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), outputPath); err != nil {
+		return nil, fmt.Errorf("failed to write ZIP file: %w", err)
+	}
+
+	if info, err := os.Stat(outputPath); err == nil {
+		result.Bytes = info.Size()
+	}
+	return result, nil
+}
+
+// rendition exports the document in another format to a temporary file
+// and returns its content
+func (e *Exporter) rendition(doc *document.Document, format string, opts Options, docSvc *document.Service) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "docgen-rendition-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, doc.ID+"."+format)
+	switch format {
+	case "pdf":
+		_, err = e.exportPDF(doc, path, opts, docSvc)
+	case "docx":
+		_, err = e.exportWithPandoc(doc, format, path, opts, docSvc)
+	default:
+		err = fmt.Errorf("unsupported rendition: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// referencedMedia returns the files in the media folder that HTML refers
+// to, including those referred to by its local stylesheets, and the
+// references to media files that do not exist
+func referencedMedia(htmlContent, root string) ([]string, []string) {
+	found := map[string]bool{}
+	missing := map[string]bool{}
+	var visitCSS func(css, base string, depth int)
+
+	add := func(ref, base string) string {
+		name, ok := mediaPath(ref, base)
+		if !ok {
+			return ""
+		}
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil || info.IsDir() {
+			missing[name] = true
+			return ""
+		}
+		if found[name] {
+			return ""
+		}
+		found[name] = true
+		return name
+	}
+	visitCSS = func(css, base string, depth int) {
+		for _, m := range cssImportRegex.FindAllStringSubmatch(css, -1) {
+			if name := add(unquote(m[1]), base); name != "" && depth < 5 {
+				if data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name))); err == nil {
+					visitCSS(string(data), path.Dir(name), depth+1)
+				}
+			}
+		}
+		for _, m := range cssURLRegex.FindAllStringSubmatch(css, -1) {
+			add(unquote(m[1]), base)
+		}
+	}
+
+	for _, m := range assetAttrRegex.FindAllStringSubmatch(htmlContent, -1) {
+		add(html.UnescapeString(unquote(m[3])), "")
+	}
+	for _, m := range srcsetAttrRegex.FindAllStringSubmatch(htmlContent, -1) {
+		for _, candidate := range strings.Split(html.UnescapeString(unquote(m[1])), ",") {
+			if fields := strings.Fields(candidate); len(fields) > 0 {
+				add(fields[0], "")
+			}
+		}
+	}
+	for _, tag := range anchorTagRegex.FindAllString(htmlContent, -1) {
+		add(attrValue(tag[len("<a"):], "href"), "")
+	}
+	for _, tag := range linkTagRegex.FindAllString(htmlContent, -1) {
+		name := add(attrValue(tag[len("<link"):], "href"), "")
+		if name != "" && strings.EqualFold(path.Ext(name), ".css") {
+			if data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name))); err == nil {
+				visitCSS(string(data), path.Dir(name), 0)
+			}
+		}
+	}
+	for _, m := range styleTagRegex.FindAllStringSubmatch(htmlContent, -1) {
+		visitCSS(m[2], "", 0)
+	}
+	for _, m := range inlineStyleRegex.FindAllStringSubmatch(htmlContent, -1) {
+		visitCSS(html.UnescapeString(unquote(m[2])), "", 0)
+	}
+
+	return sortedKeys(found), sortedKeys(missing)
+}
+
+// mediaPath maps a relative URL to a slash-separated path inside the
+// document folder's media directory, resolving it against base, a
+// directory relative to the document folder
+func mediaPath(ref, base string) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return "", false
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+	name := path.Clean(path.Join(base, u.Path))
+	if !strings.HasPrefix(name, "media/") {
+		return "", false
+	}
+	return name, true
+}
+
+// allMedia lists the files in the media folder, leaving out hidden and
+// temporary files and the bibliography, which is document data
+func allMedia(root string) []string {
+	var names []string
+	mediaDir := filepath.Join(root, "media")
+	_ = filepath.WalkDir(mediaDir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		base := d.Name()
+		if p != mediaDir && (strings.HasPrefix(base, ".") || strings.HasSuffix(base, "~") || strings.HasSuffix(base, ".tmp")) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if p == filepath.Join(mediaDir, "bibliography.bib") || p == filepath.Join(mediaDir, "bibliography.json") {
+				return nil
+			}
+			if rel, err := filepath.Rel(root, p); err == nil {
+				names = append(names, filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names
+}
+
+// mergeSorted merges two sorted lists without duplicates
+func mergeSorted(a, b []string) []string {
+	set := map[string]bool{}
+	for _, s := range a {
+		set[s] = true
+	}
+	for _, s := range b {
+		set[s] = true
+	}
+	return sortedKeys(set)
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
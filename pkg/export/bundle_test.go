@@ -0,0 +1,107 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"simple_html_docgen/pkg/config"
+	"simple_html_docgen/pkg/document"
+	"strings"
+	"testing"
+)
+
+func TestExportBundle(t *testing.T) {
+	svc, doc := newTestDocument(t, `<html><head><link rel="stylesheet" href="media/style.css"></head><body>`+
+		`<h1>Guide</h1><p><img src="media/pic.png" alt="Pic"> <img src="media/gone.png" alt="Gone"></p>`+
+		`<p><a href="media/notes.txt">notes</a> <a href="https://example.com/media/x.png">remote</a></p></body></html>`)
+	if _, err := svc.SetProperties(doc.ID, document.Properties{Author: "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+	root := svc.GetDocumentPath(doc.ID)
+	for name, content := range map[string]string{
+		"media/style.css":  `@import "fonts.css"; h1 { background: url(pic.png) }`,
+		"media/fonts.css":  `@font-face { src: url(font.woff2) }`,
+		"media/font.woff2": "wOF2",
+		"media/notes.txt":  "Some notes",
+		"media/unused.txt": "Not referenced",
+		"media/.hidden":    "Hidden",
+	} {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "guide.zip")
+	res, err := NewExporter(&config.Config{}).ExportBundle(doc.ID, out, Options{}, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, contents := zipEntries(t, data)
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	want := "index.html media/font.woff2 media/fonts.css media/notes.txt media/pic.png media/style.css manifest.json"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("archive holds %s, want %s", got, want)
+	}
+	if strings.Join(res.Files, " ") != want || res.Bytes != int64(len(data)) {
+		t.Errorf("result = %v (%d bytes), archive has %d bytes", res.Files, res.Bytes, len(data))
+	}
+	if len(res.Missing) != 1 || res.Missing[0] != "media/gone.png" {
+		t.Errorf("missing = %v, want [media/gone.png]", res.Missing)
+	}
+	if !strings.Contains(contents["index.html"], `src="media/pic.png"`) {
+		t.Error("index.html does not keep relative media paths")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal([]byte(contents["manifest.json"]), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.DocumentID != doc.ID || manifest.Name != "Guide" || manifest.Properties == nil || manifest.Properties.Author != "Ada" {
+		t.Errorf("manifest header = %+v", manifest)
+	}
+	if len(manifest.Files) != len(files)-1 {
+		t.Fatalf("manifest lists %d files, want %d", len(manifest.Files), len(files)-1)
+	}
+	for _, f := range manifest.Files {
+		sum := sha256.Sum256([]byte(contents[f.Path]))
+		if f.SHA256 != hex.EncodeToString(sum[:]) || f.SizeBytes != len(contents[f.Path]) {
+			t.Errorf("manifest entry for %s does not match the archive", f.Path)
+		}
+		role := "media"
+		if f.Path == "index.html" {
+			role = "document"
+		}
+		if f.Role != role {
+			t.Errorf("%s has role %s, want %s", f.Path, f.Role, role)
+		}
+	}
+
+	// Unreferenced media is added on request, hidden files never
+	res, err = NewExporter(&config.Config{}).ExportBundle(doc.ID, out, Options{Bundle: BundleOptions{IncludeUnreferenced: true}}, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(res.Files, " ")
+	if !strings.Contains(joined, "media/unused.txt") || strings.Contains(joined, ".hidden") {
+		t.Errorf("archive with unreferenced media holds %s", joined)
+	}
+}
+
+func TestBundleOptionsValidate(t *testing.T) {
+	if err := (BundleOptions{Renditions: []string{"pdf", "docx"}}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (BundleOptions{Renditions: []string{"epub"}}).Validate(); err == nil {
+		t.Error("epub rendition accepted")
+	}
+}
@@ -0,0 +1,121 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"simple_html_docgen/pkg/citation"
+	"simple_html_docgen/pkg/document"
+	"strings"
+)
+
+// DefaultReferencesTitle heads the generated reference list
+const DefaultReferencesTitle = "References"
+
+var (
+	citeRegex       = regexp.MustCompile(`(?is)<cite\b([^>]*\bdata-key\s*=[^>]*)>(.*?)</cite>`)
+	referencesRegex = regexp.MustCompile(`(?i)<[a-z][^>]*\sid\s*=\s*["']?references["'\s/>]`)
+)
+
+// referencesStyles lays out the reference list: hanging indents for
+// author-year styles, a label column for numbered ones
+const referencesStyles = `<style id="citation-styles">
+.references .csl-entry { margin: 0 0 0.6em 0; padding-left: 2em; text-indent: -2em; line-height: 1.4; }
+.references.numbered .csl-entry { display: flex; padding-left: 0; text-indent: 0; }
+.references.numbered .csl-left-margin { flex: 0 0 3em; }
+.references.numbered .csl-right-inline { flex: 1; }
+.references a { word-break: break-all; }
+@media print { .references .csl-entry { page-break-inside: avoid; } }
+</style>`
+
+// attrValue returns the value of an attribute in a tag's attribute string
+func attrValue(attrs, name string) string {
+	re := regexp.MustCompile(`(?i)\s` + regexp.QuoteMeta(name) + `\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	m := re.FindStringSubmatch(" " + attrs)
+	if m == nil {
+		return ""
+	}
+	return html.UnescapeString(m[1] + m[2] + m[3])
+}
+
+// RenderCitations replaces <cite data-key="..."> elements with formatted
+// citations in the given style and adds a reference list of the cited
+// sources. A cite may name several keys separated by commas or semicolons,
+// give a page or other locator in data-locator, and set data-mode="narrative"
+// for an author-in-text citation. The list fills the element with
+// id="references" if the document has one and is otherwise appended to the
+// body under a heading. Keys missing from the bibliography are shown in
+// bold with a question mark.
+func RenderCitations(htmlContent string, bib *citation.Bibliography, style, title string) (string, error) {
+	if bib == nil || !strings.Contains(htmlContent, "data-key") {
+		return htmlContent, nil
+	}
+
+	matches := citeRegex.FindAllStringSubmatch(htmlContent, -1)
+	if len(matches) == 0 {
+		return htmlContent, nil
+	}
+
+	processor, err := citation.NewProcessor(bib, style)
+	if err != nil {
+		return "", err
+	}
+
+	cites := make([]citation.Cite, len(matches))
+	for i, m := range matches {
+		cites[i] = citation.Cite{
+			Keys: strings.FieldsFunc(attrValue(m[1], "data-key"), func(r rune) bool {
+				return r == ',' || r == ';' || r == ' '
+			}),
+			Locator:   attrValue(m[1], "data-locator"),
+			Narrative: strings.EqualFold(attrValue(m[1], "data-mode"), "narrative"),
+		}
+		processor.Register(cites[i].Keys)
+	}
+
+	i := 0
+	htmlContent = citeRegex.ReplaceAllStringFunc(htmlContent, func(string) string {
+		cite := cites[i]
+		i++
+		return fmt.Sprintf(`<span class="citation" data-cites="%s">%s</span>`,
+			html.EscapeString(strings.Join(cite.Keys, " ")), processor.Citation(cite))
+	})
+
+	refs := processor.References()
+	if len(refs) == 0 {
+		return htmlContent, nil
+	}
+
+	var list strings.Builder
+	for _, ref := range refs {
+		fmt.Fprintf(&list, `<div class="csl-entry" id="ref-%s">`, html.EscapeString(ref.Key))
+		if ref.Label != "" {
+			fmt.Fprintf(&list, `<span class="csl-left-margin">%s</span><span class="csl-right-inline">%s</span>`, ref.Label, ref.HTML)
+		} else {
+			list.WriteString(ref.HTML)
+		}
+		list.WriteString("</div>\n")
+	}
+
+	class := "references csl-bib-body"
+	if refs[0].Label != "" {
+		class += " numbered"
+	}
+	if referencesRegex.MatchString(htmlContent) {
+		// The document places the list itself and provides any heading
+		fragment := fmt.Sprintf(`<div class="%s">%s</div>`, class, list.String())
+		htmlContent, err = document.InsertFragment(htmlContent, fragment, document.InsertTarget{Selector: "#references"})
+	} else {
+		if title == "" {
+			title = DefaultReferencesTitle
+		}
+		section := fmt.Sprintf("<section id=\"references\" class=\"%s\" role=\"doc-bibliography\">\n<h2>%s</h2>\n%s</section>\n",
+			class, html.EscapeString(title), list.String())
+		htmlContent, err = document.InsertFragment(htmlContent, section, document.InsertTarget{})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return document.EnsureHeadContent(htmlContent, "citation-styles", referencesStyles), nil
+}
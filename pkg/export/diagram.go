@@ -0,0 +1,238 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"simple_html_docgen/pkg/document"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+var (
+	// <pre class="mermaid|dot|graphviz"> blocks, optionally wrapping <code class="language-...">
+	diagramPreRegex = regexp.MustCompile(`(?is)<pre\b([^>]*)>\s*(?:<code\b([^>]*)>)?(.*?)(?:</code>\s*)?</pre>`)
+	// <div class="mermaid"> blocks, the form used by Mermaid's own examples
+	diagramDivRegex = regexp.MustCompile(`(?is)<div\b([^>]*\bclass\s*=\s*["'][^"']*\bmermaid\b[^"']*["'][^>]*)>(.*?)</div>`)
+)
+
+// mermaidRenderJS renders one diagram with the page's mermaid global.
+// Labels are plain SVG text rather than HTML so the SVG also displays in
+// Word and other non-browser viewers.
+const mermaidRenderJS = `async (source) => {
+	mermaid.initialize({startOnLoad: false, securityLevel: "strict", htmlLabels: false, flowchart: {htmlLabels: false}});
+	const { svg } = await mermaid.render("diagram", source);
+	return svg;
+}`
+
+// renderDiagrams replaces Mermaid and Graphviz DOT blocks with SVG images
+// stored in the document's media folder. SVGs are named by a hash of the
+// diagram source, so unchanged diagrams are reused on later exports. A
+// browser may be passed in to render Mermaid; otherwise the shared browser
+// is used, started with a system Chrome only if an uncached Mermaid
+// diagram needs it. Diagrams that
+// cannot be rendered are left as they are, preceded by a comment giving
+// the reason.
+func (e *Exporter) renderDiagrams(htmlContent, documentID string, docSvc *document.Service, browser *rod.Browser) string {
+	if !strings.Contains(htmlContent, "mermaid") && !strings.Contains(htmlContent, "dot") && !strings.Contains(htmlContent, "graphviz") {
+		return htmlContent
+	}
+
+	var release func()
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+
+	render := func(block, attrs, kind, source string) string {
+		source = strings.TrimSpace(html.UnescapeString(source))
+		if source == "" {
+			return block
+		}
+
+		relativePath, err := e.renderDiagram(kind, source, documentID, docSvc, func() (*rod.Browser, error) {
+			if browser == nil {
+				// The browser slot is held until renderDiagrams returns
+				ctx, cancel := context.WithTimeout(context.Background(), e.diagramTimeout)
+				b, done, err := e.browsers.acquire(ctx, false)
+				if err != nil {
+					cancel()
+					return nil, err
+				}
+				browser = b
+				release = func() {
+					done()
+					cancel()
+				}
+			}
+			return browser, nil
+		})
+		if err != nil {
+			return fmt.Sprintf("<!-- %s diagram not rendered: %s -->\n%s", kind, strings.ReplaceAll(err.Error(), "--", "-"), block)
+		}
+
+		idAttr := ""
+		if m := idAttrRegex.FindStringSubmatch(attrs); m != nil {
+			idAttr = fmt.Sprintf(` id="%s"`, html.EscapeString(m[1]+m[2]))
+		}
+		label := "Diagram"
+		if kind == "mermaid" {
+			label = "Mermaid diagram"
+		}
+		return fmt.Sprintf(`<img src="%s" alt="%s" class="diagram"%s style="max-width: 100%%; height: auto;">`, relativePath, label, idAttr)
+	}
+
+	htmlContent = diagramPreRegex.ReplaceAllStringFunc(htmlContent, func(block string) string {
+		m := diagramPreRegex.FindStringSubmatch(block)
+		kind := diagramKind(m[1], m[2])
+		if kind == "" {
+			return block
+		}
+		return render(block, m[1], kind, m[3])
+	})
+
+	return diagramDivRegex.ReplaceAllStringFunc(htmlContent, func(block string) string {
+		m := diagramDivRegex.FindStringSubmatch(block)
+		if strings.Contains(m[2], "<") {
+			// Already rendered in the page, e.g. an SVG Mermaid inserted
+			return block
+		}
+		return render(block, m[1], "mermaid", m[2])
+	})
+}
+
+// diagramKind returns "mermaid" or "dot" from the classes of a <pre> and
+// its <code>, or "" if the block is not a diagram
+func diagramKind(preAttrs, codeAttrs string) string {
+	classes := classList(preAttrs)
+	for _, c := range classList(codeAttrs) {
+		classes = append(classes, strings.TrimPrefix(c, "language-"))
+	}
+	for _, c := range classes {
+		switch strings.ToLower(c) {
+		case "mermaid":
+			return "mermaid"
+		case "dot", "graphviz":
+			return "dot"
+		}
+	}
+	return ""
+}
+
+// classList returns the classes in a tag's attributes
+func classList(attrs string) []string {
+	m := classAttrRegex.FindStringSubmatch(" " + attrs)
+	if m == nil {
+		return nil
+	}
+	return strings.Fields(m[1] + m[2])
+}
+
+// renderDiagram returns the media path of a diagram's SVG, rendering and
+// storing it unless a cached copy exists
+func (e *Exporter) renderDiagram(kind, source, documentID string, docSvc *document.Service, getBrowser func() (*rod.Browser, error)) (string, error) {
+	sum := sha256.Sum256([]byte(kind + "\n" + source))
+	key := fmt.Sprintf("%s-%s", kind, hex.EncodeToString(sum[:])[:16])
+	filename := key + ".svg"
+
+	// The SVG may have been stored under another name when a media file
+	// with the same content already existed, so the path is looked up
+	if relativePath, ok := docSvc.CachedDiagram(documentID, key); ok {
+		return relativePath, nil
+	}
+	if _, err := os.Stat(filepath.Join(docSvc.GetDocumentPath(documentID), "media", filename)); err == nil {
+		return "media/" + filename, nil
+	}
+
+	var svg []byte
+	var err error
+	switch kind {
+	case "mermaid":
+		browser, berr := getBrowser()
+		if berr != nil {
+			return "", fmt.Errorf("mermaid needs Chrome: %w", berr)
+		}
+		svg, err = e.renderMermaid(browser, source)
+	case "dot":
+		svg, err = e.renderDOT(source)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	relativePath, err := docSvc.AddDiagram(documentID, key, filename, svg)
+	if err != nil {
+		return "", fmt.Errorf("failed to save diagram: %w", err)
+	}
+	return relativePath, nil
+}
+
+// renderMermaid renders a Mermaid diagram to SVG in a blank browser page
+// using the local Mermaid script, without network access
+func (e *Exporter) renderMermaid(browser *rod.Browser, source string) ([]byte, error) {
+	script, err := os.ReadFile(e.mermaidScript)
+	if err != nil {
+		return nil, fmt.Errorf("mermaid script not found at %s (set SIMPLE_HTML_MERMAID_JS to a local mermaid.min.js)", e.mermaidScript)
+	}
+
+	page, err := browser.Page(proto.TargetCreateTarget{URL: "about:blank"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create page: %w", err)
+	}
+	defer closePage(page)
+	page = page.Timeout(e.diagramTimeout)
+
+	if err := page.SetDocumentContent("<!DOCTYPE html><html><head></head><body></body></html>"); err != nil {
+		return nil, fmt.Errorf("failed to prepare page: %w", err)
+	}
+	if err := page.AddScriptTag("", string(script)); err != nil {
+		return nil, fmt.Errorf("failed to load mermaid script: %w", err)
+	}
+
+	result, err := page.Eval(mermaidRenderJS, source)
+	if err != nil {
+		return nil, fmt.Errorf("mermaid error: %w", err)
+	}
+	svg := result.Value.Str()
+	if !strings.Contains(svg, "<svg") {
+		return nil, fmt.Errorf("mermaid produced no SVG")
+	}
+	return []byte(svg), nil
+}
+
+// renderDOT renders a Graphviz DOT graph to SVG with the local dot binary
+func (e *Exporter) renderDOT(source string) ([]byte, error) {
+	if _, err := exec.LookPath("dot"); err != nil {
+		return nil, fmt.Errorf("dot not found: please install Graphviz to render DOT diagrams")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.diagramTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "dot", "-Tsvg")
+	cmd.Stdin = strings.NewReader(source)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("dot timed out after %v", e.diagramTimeout)
+		}
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("dot error: %s", strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("dot failed: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
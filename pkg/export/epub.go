@@ -0,0 +1,582 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"simple_html_docgen/pkg/document"
+	"strings"
+)
+
+// epubCSS is the stylesheet of EPUB exports. Reading systems apply their
+// own fonts, margins and colors on top, so it only sets what keeps the
+// content readable on any screen: relative sizes, images that fit the
+// page and no breaks inside figures or right after headings.
+const epubCSS = `body { margin: 0 4%; font-family: serif; line-height: 1.5; orphans: 2; widows: 2; }
+h1, h2, h3, h4, h5, h6 { font-family: sans-serif; line-height: 1.2; page-break-after: avoid; break-after: avoid; page-break-inside: avoid; break-inside: avoid; }
+img, svg, video { max-width: 100%; height: auto; }
+figure { margin: 1em 0; page-break-inside: avoid; break-inside: avoid; }
+figcaption { font-size: 0.9em; font-style: italic; }
+pre { white-space: pre-wrap; word-wrap: break-word; font-size: 0.85em; page-break-inside: avoid; break-inside: avoid; }
+code, pre, kbd, samp { font-family: monospace; }
+table { border-collapse: collapse; max-width: 100%; margin: 1em 0; }
+th, td { border: 1px solid #888; padding: 0.2em 0.4em; vertical-align: top; }
+blockquote { margin: 1em 5%; }
+.media-placeholder { font-style: italic; }
+.cover { margin: 0; padding: 0; text-align: center; }
+.cover img { max-width: 100%; max-height: 100vh; }
+nav ol { list-style: none; padding-left: 1em; }
+`
+
+// Image types every EPUB reading system supports
+var epubImageTypes = setOf("image/gif", "image/jpeg", "image/png", "image/svg+xml", "image/webp")
+
+// Files looked for in the media folder when no cover image is set
+var defaultCoverImages = []string{"media/cover.jpg", "media/cover.jpeg", "media/cover.png", "media/cover.webp", "media/cover.gif", "media/cover.svg"}
+
+var (
+	bodyContentRegex   = regexp.MustCompile(`(?is)<body\b[^>]*>(.*)</body>`)
+	embeddedMediaRegex = regexp.MustCompile(`(?is)<(video|audio|object|iframe)\b([^>]*)>.*?</(?:video|audio|object|iframe)>|<embed\b([^>]*)>`)
+	xmlTagRegex        = regexp.MustCompile(`<(/?)([A-Za-z][^\s/>]*)[^>]*?(/?)>`)
+	headingRegex       = regexp.MustCompile(`(?s)<h([1-6])((?:\s[^>]*)?)>(.*?)</h[1-6]>`)
+	xmlIDRegex         = regexp.MustCompile(`\sid="([^"]*)"`)
+	fragmentHrefRegex  = regexp.MustCompile(`\shref="#([^"]*)"`)
+)
+
+// epubChapter is one XHTML content document of an EPUB
+type epubChapter struct {
+	file     string
+	title    string
+	body     string
+	sections []epubHeading // Headings one level below the chapter's, for the navigation document
+}
+
+// epubHeading is a heading in the XHTML of a document
+type epubHeading struct {
+	start, end int // Position of the element
+	level      int
+	id, title  string
+	ancestors  []openElement // Elements open around the heading
+}
+
+// openElement is the start tag of an element open at some position
+type openElement struct {
+	start, end int
+	tag        string
+}
+
+// exportEPUB builds an EPUB 3 publication in Go. The document is split
+// into chapters at its main headings, with a navigation document, a cover
+// from the cover_image property or media/cover.*, the images it uses and
+// a stylesheet for reflowable reading. The result is checked with
+// VerifyEPUB before it is written.
+func (e *Exporter) exportEPUB(doc *document.Document, outputPath string, opts Options, docSvc *document.Service) (string, error) {
+	htmlContent, err := e.renderContent(doc, opts, docSvc, nil)
+	if err != nil {
+		return "", err
+	}
+	htmlContent = SelectHighResImages(RenderMath(HighlightCode(htmlContent, opts.CodeTheme)))
+
+	props, err := docSvc.GetProperties(doc.ID)
+	if err != nil {
+		return "", err
+	}
+	root := docSvc.GetDocumentPath(doc.ID)
+	lang := documentLang(htmlContent)
+	if lang == "" {
+		lang = "en"
+	}
+
+	body := htmlContent
+	if m := bodyContentRegex.FindStringSubmatch(htmlContent); m != nil {
+		body = m[1]
+	}
+	content := ToXHTML(epubMedia(body, root))
+	chapters := splitChapters(content, props.Title)
+	linkChapters(chapters)
+
+	var all strings.Builder
+	for _, ch := range chapters {
+		all.WriteString(ch.body)
+	}
+	media, _ := referencedMedia(all.String(), root)
+	cover := coverImage(props.CoverImage, root)
+	if cover != "" {
+		media = mergeSorted(media, []string{cover})
+	}
+
+	data, err := writeEPUB(doc, props, lang, chapters, media, cover, root)
+	if err != nil {
+		return "", err
+	}
+	problems, err := VerifyEPUB(data)
+	if err != nil {
+		return "", err
+	}
+	if len(problems) > 0 {
+		return "", fmt.Errorf("generated EPUB is not valid: %s", strings.Join(problems, "; "))
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write EPUB file: %w", err)
+	}
+	return outputPath, nil
+}
+
+// coverImage returns the cover of an EPUB: the given image, or the first
+// of defaultCoverImages in the media folder. Only images every reading
+// system can show are used.
+func coverImage(name, root string) string {
+	candidates := defaultCoverImages
+	if name != "" {
+		candidates = []string{path.Clean(name)}
+	}
+	for _, c := range candidates {
+		if data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(c))); err == nil && epubImageTypes[document.DetectMIMEType(data, c)] {
+			return c
+		}
+	}
+	return ""
+}
+
+// epubMedia prepares the HTML's media for an e-book. Images that are
+// remote, missing or of a type reading systems need not support are
+// replaced with their alt text, and audio, video and other embedded
+// content with a short note. Links to local files are removed, since
+// only the publication's own documents can be linked.
+func epubMedia(htmlContent, root string) string {
+	usable := func(ref string) bool {
+		name, ok := mediaPath(ref, "")
+		if !ok {
+			return false
+		}
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		return err == nil && epubImageTypes[document.DetectMIMEType(data, name)]
+	}
+
+	htmlContent = imgTagRegex.ReplaceAllStringFunc(htmlContent, func(tag string) string {
+		attrs := tag[len("<img"):]
+		if usable(attrValue(attrs, "src")) {
+			return tag
+		}
+		if alt := attrValue(attrs, "alt"); alt != "" {
+			return fmt.Sprintf(`<span class="media-placeholder">%s</span>`, html.EscapeString(alt))
+		}
+		return ""
+	})
+
+	htmlContent = embeddedMediaRegex.ReplaceAllStringFunc(htmlContent, func(block string) string {
+		m := embeddedMediaRegex.FindStringSubmatch(block)
+		kind, attrs := strings.ToLower(m[1]), m[2]
+		if kind == "" {
+			kind, attrs = "embedded content", m[3]
+		}
+		label := attrValue(attrs, "title")
+		if label == "" {
+			label = attrValue(attrs, "aria-label")
+		}
+		if label == "" {
+			for _, name := range []string{"src", "data"} {
+				if src := attrValue(attrs, name); src != "" {
+					label = path.Base(src)
+				}
+			}
+		}
+		if label == "" {
+			label = "not included"
+		}
+		return fmt.Sprintf(`<span class="media-placeholder">[%s: %s]</span>`, kind, html.EscapeString(label))
+	})
+
+	return anchorTagRegex.ReplaceAllStringFunc(htmlContent, func(tag string) string {
+		href := strings.TrimSpace(attrValue(tag[len("<a"):], "href"))
+		if href == "" || strings.HasPrefix(href, "#") || strings.Contains(href, ":") {
+			return tag
+		}
+		return hrefAttrRegex.ReplaceAllString(tag, "")
+	})
+}
+
+// splitChapters splits XHTML into chapters at its main headings: the
+// highest level that occurs more than once, or the highest there is. A
+// chapter starts at the outermost element that the heading opens, so
+// content in <section> elements splits cleanly. Elements still open at a
+// split are closed and reopened, without their id, in the next chapter.
+func splitChapters(content, title string) []*epubChapter {
+	content = addHeadingIDs(content)
+	headings := findHeadings(content)
+
+	counts := map[int]int{}
+	for _, h := range headings {
+		counts[h.level]++
+	}
+	level := 0
+	for l := 6; l >= 1; l-- {
+		if counts[l] > 1 || counts[l] > 0 && counts[level] < 2 {
+			level = l
+		}
+	}
+
+	type split struct {
+		pos       int
+		ancestors []openElement
+		heading   *epubHeading
+	}
+	var splits []split
+	for i := range headings {
+		h := &headings[i]
+		if h.level != level {
+			continue
+		}
+		pos, ancestors := h.start, h.ancestors
+		for len(ancestors) > 0 && strings.TrimSpace(content[ancestors[len(ancestors)-1].end:pos]) == "" {
+			pos = ancestors[len(ancestors)-1].start
+			ancestors = ancestors[:len(ancestors)-1]
+		}
+		splits = append(splits, split{pos, ancestors, h})
+	}
+
+	var chapters []*epubChapter
+	addChapter := func(from, to split) {
+		var b strings.Builder
+		for _, a := range from.ancestors {
+			b.WriteString(xmlIDRegex.ReplaceAllString(a.tag, ""))
+		}
+		b.WriteString(content[from.pos:to.pos])
+		for i := len(to.ancestors) - 1; i >= 0; i-- {
+			name := xmlTagRegex.FindStringSubmatch(to.ancestors[i].tag)[2]
+			b.WriteString("</" + name + ">")
+		}
+		ch := &epubChapter{file: fmt.Sprintf("chapter-%03d.xhtml", len(chapters)+1), title: title, body: b.String()}
+		if from.heading != nil {
+			ch.title = from.heading.title
+			for _, h := range headings {
+				if h.start > from.heading.start && h.start < to.pos && h.level == level+1 {
+					ch.sections = append(ch.sections, h)
+				}
+			}
+		} else {
+			for _, h := range headings {
+				if h.start < to.pos {
+					ch.title = h.title
+					break
+				}
+			}
+		}
+		chapters = append(chapters, ch)
+	}
+
+	start := split{pos: 0}
+	end := split{pos: len(content)}
+	if len(splits) == 0 {
+		addChapter(start, end)
+		return chapters
+	}
+	// Content before the first heading becomes a chapter of its own when
+	// there is something to read or see in it
+	if before := content[:splits[0].pos]; strings.TrimSpace(anyTagRegex.ReplaceAllString(before, "")) != "" || strings.Contains(before, "<img") {
+		addChapter(start, splits[0])
+	} else {
+		splits[0].pos, splits[0].ancestors = 0, nil
+	}
+	for i, s := range splits {
+		next := end
+		if i+1 < len(splits) {
+			next = splits[i+1]
+		}
+		addChapter(s, next)
+	}
+	return chapters
+}
+
+// addHeadingIDs gives headings without an id one, so the navigation
+// document can link to them
+func addHeadingIDs(content string) string {
+	used := map[string]bool{}
+	for _, m := range xmlIDRegex.FindAllStringSubmatch(content, -1) {
+		used[m[1]] = true
+	}
+	n := 0
+	return headingRegex.ReplaceAllStringFunc(content, func(h string) string {
+		m := headingRegex.FindStringSubmatch(h)
+		if xmlIDRegex.MatchString(m[2]) {
+			return h
+		}
+		id := ""
+		for id == "" || used[id] {
+			n++
+			id = fmt.Sprintf("section-%d", n)
+		}
+		used[id] = true
+		return fmt.Sprintf(`<h%s id="%s"%s>%s</h%s>`, m[1], id, m[2], m[3], m[1])
+	})
+}
+
+// findHeadings returns the headings of well-formed XHTML with the
+// elements open around each
+func findHeadings(content string) []epubHeading {
+	var headings []epubHeading
+	var stack []openElement
+	for _, loc := range xmlTagRegex.FindAllStringSubmatchIndex(content, -1) {
+		closing := loc[3] > loc[2]
+		name := content[loc[4]:loc[5]]
+		selfClosing := loc[7] > loc[6]
+		switch {
+		case closing:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case selfClosing:
+		case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+			m := headingRegex.FindStringSubmatchIndex(content[loc[0]:])
+			if m == nil || m[0] != 0 {
+				stack = append(stack, openElement{loc[0], loc[1], content[loc[0]:loc[1]]})
+				continue
+			}
+			h := epubHeading{
+				start:     loc[0],
+				end:       loc[0] + m[1],
+				level:     int(name[1] - '0'),
+				title:     strings.TrimSpace(html.UnescapeString(anyTagRegex.ReplaceAllString(content[loc[0]+m[6]:loc[0]+m[7]], ""))),
+				ancestors: append([]openElement(nil), stack...),
+			}
+			if id := xmlIDRegex.FindStringSubmatch(content[loc[0]+m[4] : loc[0]+m[5]]); id != nil {
+				h.id = id[1]
+			}
+			headings = append(headings, h)
+			stack = append(stack, openElement{loc[0], loc[1], content[loc[0]:loc[1]]})
+		default:
+			stack = append(stack, openElement{loc[0], loc[1], content[loc[0]:loc[1]]})
+		}
+	}
+	return headings
+}
+
+// linkChapters points links to fragments in other chapters at the right
+// file, and drops links to fragments that exist nowhere
+func linkChapters(chapters []*epubChapter) {
+	owner := map[string]string{}
+	for _, ch := range chapters {
+		for _, m := range xmlIDRegex.FindAllStringSubmatch(ch.body, -1) {
+			if _, ok := owner[m[1]]; !ok {
+				owner[m[1]] = ch.file
+			}
+		}
+	}
+	for _, ch := range chapters {
+		ch.body = fragmentHrefRegex.ReplaceAllStringFunc(ch.body, func(attr string) string {
+			id := fragmentHrefRegex.FindStringSubmatch(attr)[1]
+			switch file, ok := owner[id]; {
+			case !ok:
+				return ""
+			case file == ch.file:
+				return attr
+			default:
+				return fmt.Sprintf(` href="%s#%s"`, file, id)
+			}
+		})
+	}
+}
+
+// writeEPUB packs the publication into an EPUB file
+func writeEPUB(doc *document.Document, props *document.Properties, lang string, chapters []*epubChapter, media []string, cover, root string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	// The mimetype comes first, uncompressed and without extra fields, so
+	// the file can be recognized from its first bytes
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write EPUB: %w", err)
+	}
+	if _, err := w.Write([]byte("application/epub+zip")); err != nil {
+		return nil, fmt.Errorf("failed to write EPUB: %w", err)
+	}
+
+	files := map[string][]byte{
+		"META-INF/container.xml": []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="EPUB/package.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`),
+		"EPUB/style.css": []byte(epubCSS),
+	}
+	names := []string{"META-INF/container.xml", "EPUB/package.opf", "EPUB/nav.xhtml", "EPUB/style.css"}
+
+	var manifest, spine strings.Builder
+	manifest.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	manifest.WriteString(`    <item id="css" href="style.css" media-type="text/css"/>` + "\n")
+
+	title := props.Title
+	if cover != "" {
+		files["EPUB/cover.xhtml"] = []byte(xhtmlPage(lang, title, fmt.Sprintf(`<section class="cover" epub:type="cover"><img src="%s" alt="%s"/></section>`,
+			escapeXMLAttr(mediaHref(cover)), escapeXMLAttr(title))))
+		names = append(names, "EPUB/cover.xhtml")
+		manifest.WriteString(`    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>` + "\n")
+		spine.WriteString(`    <itemref idref="cover"/>` + "\n")
+	}
+
+	for i, ch := range chapters {
+		id := fmt.Sprintf("chapter-%03d", i+1)
+		files["EPUB/"+ch.file] = []byte(xhtmlPage(lang, ch.title, ch.body))
+		names = append(names, "EPUB/"+ch.file)
+		manifest.WriteString(fmt.Sprintf(`    <item id="%s" href="%s" media-type="application/xhtml+xml"%s/>`+"\n", id, ch.file, contentProperties(ch.body)))
+		spine.WriteString(fmt.Sprintf(`    <itemref idref="%s"/>`+"\n", id))
+	}
+
+	for i, name := range media {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		mimeType := document.DetectMIMEType(data, name)
+		if strings.HasPrefix(mimeType, "text/plain") && strings.EqualFold(path.Ext(name), ".css") {
+			mimeType = "text/css"
+		}
+		mimeType, _, _ = strings.Cut(mimeType, ";")
+		id, properties := fmt.Sprintf("media-%d", i+1), ""
+		if name == cover {
+			id, properties = "cover-image", ` properties="cover-image"`
+		}
+		files["EPUB/"+name] = data
+		names = append(names, "EPUB/"+name)
+		manifest.WriteString(fmt.Sprintf(`    <item id="%s" href="%s" media-type="%s"%s/>`+"\n", id, escapeXMLAttr(mediaHref(name)), mimeType, properties))
+	}
+
+	files["EPUB/nav.xhtml"] = []byte(navDocument(lang, title, chapters))
+	files["EPUB/package.opf"] = []byte(packageDocument(doc, props, lang, manifest.String(), spine.String(), cover != ""))
+
+	for _, name := range names {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: doc.UpdatedAt})
+		if err != nil {
+			return nil, fmt.Errorf("failed to write EPUB: %w", err)
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, fmt.Errorf("failed to write EPUB: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write EPUB: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// packageDocument writes the OPF package document
+func packageDocument(doc *document.Document, props *document.Properties, lang, manifest, spine string, hasCover bool) string {
+	var meta strings.Builder
+	meta.WriteString(fmt.Sprintf("    <dc:identifier id=\"uid\">%s</dc:identifier>\n", epubIdentifier(doc.ID)))
+	meta.WriteString(fmt.Sprintf("    <dc:title>%s</dc:title>\n", escapeXMLText(props.Title)))
+	meta.WriteString(fmt.Sprintf("    <dc:language>%s</dc:language>\n", escapeXMLText(lang)))
+	if props.Author != "" {
+		meta.WriteString(fmt.Sprintf("    <dc:creator>%s</dc:creator>\n", escapeXMLText(props.Author)))
+	}
+	if props.Subject != "" {
+		meta.WriteString(fmt.Sprintf("    <dc:description>%s</dc:description>\n", escapeXMLText(props.Subject)))
+	}
+	for _, k := range props.Keywords {
+		meta.WriteString(fmt.Sprintf("    <dc:subject>%s</dc:subject>\n", escapeXMLText(k)))
+	}
+	meta.WriteString(fmt.Sprintf("    <dc:date>%s</dc:date>\n", doc.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")))
+	meta.WriteString(fmt.Sprintf("    <meta property=\"dcterms:modified\">%s</meta>\n", doc.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z")))
+	if hasCover {
+		// For EPUB 2 reading systems
+		meta.WriteString("    <meta name=\"cover\" content=\"cover-image\"/>\n")
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid" xml:lang="%s">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+%s  </metadata>
+  <manifest>
+%s  </manifest>
+  <spine>
+%s  </spine>
+</package>
+`, escapeXMLAttr(lang), meta.String(), manifest, spine)
+}
+
+// navDocument writes the navigation document: a table of contents with
+// the chapters and the headings one level below them
+func navDocument(lang, title string, chapters []*epubChapter) string {
+	var toc strings.Builder
+	toc.WriteString(`<nav epub:type="toc" id="toc"><h1>Contents</h1><ol>`)
+	for _, ch := range chapters {
+		toc.WriteString(fmt.Sprintf(`<li><a href="%s">%s</a>`, ch.file, escapeXMLText(chapterTitle(ch.title))))
+		if len(ch.sections) > 0 {
+			toc.WriteString("<ol>")
+			for _, h := range ch.sections {
+				toc.WriteString(fmt.Sprintf(`<li><a href="%s#%s">%s</a></li>`, ch.file, h.id, escapeXMLText(chapterTitle(h.title))))
+			}
+			toc.WriteString("</ol>")
+		}
+		toc.WriteString("</li>")
+	}
+	toc.WriteString("</ol></nav>")
+	toc.WriteString(fmt.Sprintf(`<nav epub:type="landmarks" hidden="hidden"><ol><li><a epub:type="toc" href="#toc">Contents</a></li><li><a epub:type="bodymatter" href="%s">%s</a></li></ol></nav>`,
+		chapters[0].file, escapeXMLText(chapterTitle(chapters[0].title))))
+	return xhtmlPage(lang, title, toc.String())
+}
+
+// xhtmlPage wraps XHTML body content in a complete content document
+func xhtmlPage(lang, title, body string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="%s" xmlns:epub="http://www.idpf.org/2007/ops" lang="%s" xml:lang="%s">
+<head>
+<meta charset="utf-8"/>
+<title>%s</title>
+<link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+%s
+</body>
+</html>
+`, xhtmlNamespace, escapeXMLAttr(lang), escapeXMLAttr(lang), escapeXMLText(title), body)
+}
+
+// contentProperties returns the manifest properties a content document
+// needs for the markup it contains
+func contentProperties(body string) string {
+	var properties []string
+	if strings.Contains(body, "<math ") {
+		properties = append(properties, "mathml")
+	}
+	if strings.Contains(body, "<svg ") {
+		properties = append(properties, "svg")
+	}
+	if len(properties) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(` properties="%s"`, strings.Join(properties, " "))
+}
+
+// mediaHref turns a file path into a relative URL
+func mediaHref(name string) string {
+	return (&url.URL{Path: name}).String()
+}
+
+// chapterTitle returns a title for navigation, which cannot be empty
+func chapterTitle(title string) string {
+	if title == "" {
+		return "Untitled"
+	}
+	return title
+}
+
+// epubIdentifier derives a stable UUID URN from a document ID, so every
+// export of a document is recognized as the same publication
+func epubIdentifier(documentID string) string {
+	sum := sha1.Sum([]byte("simple-html-docgen:" + documentID))
+	sum[6] = sum[6]&0x0f | 0x50 // Version 5, name-based with SHA-1
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"simple_html_docgen/pkg/config"
+	"simple_html_docgen/pkg/document"
+	"simple_html_docgen/pkg/storage"
+	"strings"
+	"testing"
+)
+
+// newTestDocument creates a document with an image in a temporary folder
+func newTestDocument(t *testing.T, htmlContent string) (*document.Service, *document.Document) {
+	t.Helper()
+	svc := document.NewService(storage.NewStorage(t.TempDir()))
+	doc, err := svc.CreateDocument("Guide", htmlContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.AddMediaContent(doc.ID, "pic.png", buf.Bytes(), document.AddMediaOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return svc, doc
+}
+
+// zipEntries reads every file of a ZIP archive, in order
+func zipEntries(t *testing.T, data []byte) ([]*zip.File, map[string]string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[f.Name] = string(b)
+	}
+	return zr.File, contents
+}
+
+func TestExportEPUB(t *testing.T) {
+	svc, doc := newTestDocument(t, `<html lang="de"><body><h1>Guide</h1><p>Intro <a href="#usage">see usage</a></p>`+
+		`<h2>Setup</h2><p><img src="media/pic.png" alt="Pic"></p>`+
+		`<h2 id="usage">Usage</h2><p>Run it. <a href="media/notes.pdf">notes</a></p><h3>Details</h3><p>More.</p></body></html>`)
+	if _, err := svc.SetProperties(doc.ID, document.Properties{Author: "Ada"}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := NewExporter(&config.Config{}).ExportDocument(doc.ID, "epub", "", Options{}, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, contents := zipEntries(t, data)
+
+	if files[0].Name != "mimetype" || files[0].Method != zip.Store || contents["mimetype"] != "application/epub+zip" {
+		t.Errorf("first entry is %s (method %d), want an uncompressed mimetype", files[0].Name, files[0].Method)
+	}
+	if !strings.Contains(contents["META-INF/container.xml"], `full-path="EPUB/package.opf"`) {
+		t.Error("container.xml does not point at the package document")
+	}
+
+	opf := contents["EPUB/package.opf"]
+	for _, want := range []string{
+		"<dc:title>Guide</dc:title>", "<dc:creator>Ada</dc:creator>", "<dc:language>de</dc:language>",
+		`href="media/pic.png" media-type="image/png"`, `properties="nav"`,
+		`<itemref idref="chapter-001"/>
+    <itemref idref="chapter-002"/>
+    <itemref idref="chapter-003"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("package document lacks %s", want)
+		}
+	}
+	if _, ok := contents["EPUB/media/pic.png"]; !ok {
+		t.Error("image not included")
+	}
+
+	nav := contents["EPUB/nav.xhtml"]
+	for _, want := range []string{
+		`<a href="chapter-002.xhtml">Setup</a>`,
+		`<a href="chapter-003.xhtml">Usage</a><ol><li><a href="chapter-003.xhtml#section-3">Details</a></li></ol>`,
+	} {
+		if !strings.Contains(nav, want) {
+			t.Errorf("navigation lacks %s", want)
+		}
+	}
+
+	// Links across chapters point at the chapter file; links to local files are dropped
+	if !strings.Contains(contents["EPUB/chapter-001.xhtml"], `<a href="chapter-003.xhtml#usage">see usage</a>`) {
+		t.Error("cross-chapter link not rewritten")
+	}
+	if strings.Contains(contents["EPUB/chapter-003.xhtml"], "notes.pdf") {
+		t.Error("link to a local file kept")
+	}
+
+	problems, err := VerifyEPUB(data)
+	if err != nil || len(problems) > 0 {
+		t.Errorf("VerifyEPUB() = %v, %v", problems, err)
+	}
+}
+
+func TestVerifyEPUBReportsProblems(t *testing.T) {
+	svc, doc := newTestDocument(t, `<h1>Guide</h1><p>Text</p>`)
+	out, err := NewExporter(&config.Config{}).ExportDocument(doc.ID, "epub", "", Options{}, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, contents := zipEntries(t, data)
+
+	// Rewrite the archive with a compressed mimetype and without a chapter
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		if f.Name == "EPUB/chapter-001.xhtml" {
+			continue
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, contents[f.Name])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := VerifyEPUB(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := strings.Join(problems, "\n")
+	if !strings.Contains(all, "mimetype") || !strings.Contains(all, "chapter-001.xhtml") {
+		t.Errorf("VerifyEPUB() = %q, want the mimetype and the missing chapter reported", problems)
+	}
+}
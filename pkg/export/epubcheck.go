@@ -0,0 +1,327 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	opsNamespace  = "http://www.idpf.org/2007/ops"
+	epubMediaType = "application/epub+zip"
+)
+
+var modifiedDateRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`)
+
+// epubContainer is META-INF/container.xml
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the part of an OPF package document that is checked
+type epubPackage struct {
+	Version  string `xml:"version,attr"`
+	UniqueID string `xml:"unique-identifier,attr"`
+	Metadata struct {
+		Identifiers []struct {
+			ID    string `xml:"id,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"http://purl.org/dc/elements/1.1/ identifier"`
+		Titles    []string `xml:"http://purl.org/dc/elements/1.1/ title"`
+		Languages []string `xml:"http://purl.org/dc/elements/1.1/ language"`
+		Metas     []struct {
+			Property string `xml:"property,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Itemrefs []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// epubLink is a reference from a content document to a file and fragment
+type epubLink struct {
+	from, target, fragment string
+}
+
+// VerifyEPUB checks the structure of an EPUB 3 file and returns the
+// problems found, which are empty for a valid file: the mimetype and
+// container, the package metadata, manifest and spine, and that content
+// documents are well-formed XHTML whose links resolve. The checks cover
+// what this package produces; they are not a full validator.
+func VerifyEPUB(data []byte) ([]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an EPUB (ZIP) file: %w", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	if len(zr.File) == 0 {
+		return []string{"the EPUB is empty"}, nil
+	}
+
+	var problems []string
+	if first := zr.File[0]; first.Name != "mimetype" {
+		problems = append(problems, "the first file is not mimetype")
+	} else {
+		if first.Method != zip.Store {
+			problems = append(problems, "the mimetype file is compressed")
+		}
+		if len(first.Extra) > 0 {
+			problems = append(problems, "the mimetype file has extra fields")
+		}
+		if content, err := readZipFile(first); err != nil || string(content) != epubMediaType {
+			problems = append(problems, "the mimetype file does not contain "+epubMediaType)
+		}
+	}
+
+	var container epubContainer
+	if err := unmarshalZipFile(files["META-INF/container.xml"], &container); err != nil {
+		return append(problems, fmt.Sprintf("META-INF/container.xml: %v", err)), nil
+	}
+	if len(container.Rootfiles) == 0 || container.Rootfiles[0].MediaType != "application/oebps-package+xml" {
+		return append(problems, "META-INF/container.xml names no package document"), nil
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := unmarshalZipFile(files[opfPath], &pkg); err != nil {
+		return append(problems, fmt.Sprintf("%s: %v", opfPath, err)), nil
+	}
+
+	problems = append(problems, verifyEPUBMetadata(&pkg)...)
+
+	// Manifest items by ID and by file
+	base := path.Dir(opfPath)
+	items := map[string]int{}
+	byFile := map[string]int{}
+	navs := 0
+	for i, item := range pkg.Items {
+		if _, dup := items[item.ID]; dup || item.ID == "" {
+			problems = append(problems, fmt.Sprintf("manifest item ID %q is empty or not unique", item.ID))
+		}
+		items[item.ID] = i
+		name, err := url.PathUnescape(item.Href)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("manifest item %s has an invalid href", item.ID))
+			continue
+		}
+		name = path.Join(base, name)
+		if files[name] == nil {
+			problems = append(problems, fmt.Sprintf("manifest item %s: %s is missing", item.ID, name))
+		}
+		byFile[name] = i
+		if slices.Contains(strings.Fields(item.Properties), "nav") {
+			navs++
+			if item.MediaType != "application/xhtml+xml" {
+				problems = append(problems, "the navigation document is not XHTML")
+			}
+		}
+	}
+	if navs != 1 {
+		problems = append(problems, fmt.Sprintf("the manifest has %d navigation documents (must be 1)", navs))
+	}
+	for _, f := range zr.File {
+		if _, ok := byFile[f.Name]; !ok && f.Name != "mimetype" && f.Name != opfPath && !strings.HasPrefix(f.Name, "META-INF/") && !strings.HasSuffix(f.Name, "/") {
+			problems = append(problems, fmt.Sprintf("%s is not listed in the manifest", f.Name))
+		}
+	}
+
+	if len(pkg.Itemrefs) == 0 {
+		problems = append(problems, "the spine is empty")
+	}
+	for _, ref := range pkg.Itemrefs {
+		i, ok := items[ref.IDRef]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("spine item %s is not in the manifest", ref.IDRef))
+		} else if pkg.Items[i].MediaType != "application/xhtml+xml" {
+			problems = append(problems, fmt.Sprintf("spine item %s is not an XHTML content document", ref.IDRef))
+		}
+	}
+
+	// Content documents
+	ids := map[string]map[string]bool{}
+	var links []epubLink
+	for name, i := range byFile {
+		item := pkg.Items[i]
+		if item.MediaType != "application/xhtml+xml" || files[name] == nil {
+			continue
+		}
+		docIDs, docLinks, found, err := scanXHTML(files[name])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s is not well-formed XHTML: %v", name, err))
+			continue
+		}
+		ids[name] = docIDs
+		for _, l := range docLinks {
+			l.from = name
+			links = append(links, l)
+		}
+		properties := strings.Fields(item.Properties)
+		if found["math"] && !slices.Contains(properties, "mathml") {
+			problems = append(problems, fmt.Sprintf("%s contains MathML but is not declared with the mathml property", name))
+		}
+		if found["svg"] && !slices.Contains(properties, "svg") {
+			problems = append(problems, fmt.Sprintf("%s contains SVG but is not declared with the svg property", name))
+		}
+		if slices.Contains(properties, "nav") && !found["toc"] {
+			problems = append(problems, "the navigation document has no toc nav element")
+		}
+	}
+
+	for _, l := range links {
+		target := l.from
+		if l.target != "" {
+			target = path.Join(path.Dir(l.from), l.target)
+			if _, ok := byFile[target]; !ok {
+				problems = append(problems, fmt.Sprintf("%s links to %s, which is not in the manifest", l.from, target))
+				continue
+			}
+		}
+		if l.fragment != "" && ids[target] != nil && !ids[target][l.fragment] {
+			problems = append(problems, fmt.Sprintf("%s links to a missing fragment %s#%s", l.from, target, l.fragment))
+		}
+	}
+
+	slices.Sort(problems)
+	return slices.Compact(problems), nil
+}
+
+// verifyEPUBMetadata checks the required package metadata
+func verifyEPUBMetadata(pkg *epubPackage) []string {
+	var problems []string
+	if pkg.Version != "3.0" {
+		problems = append(problems, fmt.Sprintf("the package version is %q (must be 3.0)", pkg.Version))
+	}
+	hasIdentifier := false
+	for _, id := range pkg.Metadata.Identifiers {
+		hasIdentifier = hasIdentifier || id.ID == pkg.UniqueID && strings.TrimSpace(id.Value) != ""
+	}
+	if !hasIdentifier {
+		problems = append(problems, "the package has no unique identifier")
+	}
+	if len(pkg.Metadata.Titles) == 0 || strings.TrimSpace(pkg.Metadata.Titles[0]) == "" {
+		problems = append(problems, "the package has no title")
+	}
+	if len(pkg.Metadata.Languages) == 0 || strings.TrimSpace(pkg.Metadata.Languages[0]) == "" {
+		problems = append(problems, "the package has no language")
+	}
+	modified := false
+	for _, m := range pkg.Metadata.Metas {
+		modified = modified || m.Property == "dcterms:modified" && modifiedDateRegex.MatchString(strings.TrimSpace(m.Value))
+	}
+	if !modified {
+		problems = append(problems, "the package has no dcterms:modified date in the form 2006-01-02T15:04:05Z")
+	}
+	return problems
+}
+
+// scanXHTML parses a content document strictly and returns its IDs, its
+// links to local files and whether it contains MathML, SVG and a toc nav
+func scanXHTML(f *zip.File) (map[string]bool, []epubLink, map[string]bool, error) {
+	data, err := readZipFile(f)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ids := map[string]bool{}
+	found := map[string]bool{}
+	var links []epubLink
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	root := true
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root && (start.Name.Local != "html" || start.Name.Space != xhtmlNamespace) {
+			return nil, nil, nil, fmt.Errorf("the root element is not an XHTML html element")
+		}
+		root = false
+		switch start.Name.Space {
+		case mathmlNamespace:
+			found["math"] = true
+		case svgNamespace:
+			found["svg"] = true
+		}
+
+		for _, attr := range start.Attr {
+			switch {
+			case attr.Name.Local == "id" && attr.Name.Space == "":
+				if ids[attr.Value] {
+					return nil, nil, nil, fmt.Errorf("duplicate id %q", attr.Value)
+				}
+				ids[attr.Value] = true
+			case attr.Name.Local == "type" && attr.Name.Space == opsNamespace && start.Name.Local == "nav" && attr.Value == "toc":
+				found["toc"] = true
+			case (attr.Name.Local == "href" || attr.Name.Local == "src" || attr.Name.Local == "poster") && (attr.Name.Space == "" || attr.Name.Space == xlinkNamespace):
+				if start.Name.Local == "link" || start.Name.Local == "a" || attr.Name.Local != "href" || attr.Name.Space == xlinkNamespace {
+					if l, ok := localLink(attr.Value); ok {
+						links = append(links, l)
+					}
+				}
+			}
+		}
+	}
+	if root {
+		return nil, nil, nil, fmt.Errorf("the document is empty")
+	}
+	return ids, links, found, nil
+}
+
+// localLink splits a relative URL into its file and fragment; remote and
+// other absolute URLs are not local
+func localLink(ref string) (epubLink, bool) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(u.Path, "/") {
+		return epubLink{}, false
+	}
+	return epubLink{target: u.Path, fragment: u.Fragment}, true
+}
+
+// unmarshalZipFile decodes an XML file of a ZIP archive
+func unmarshalZipFile(f *zip.File, v interface{}) error {
+	if f == nil {
+		return fmt.Errorf("file is missing")
+	}
+	data, err := readZipFile(f)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// readZipFile reads a file of a ZIP archive
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
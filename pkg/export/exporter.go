@@ -8,141 +8,327 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"simple_html_docgen/pkg/config"
 	"simple_html_docgen/pkg/document"
+	"simple_html_docgen/pkg/pdf"
 	"strings"
 	"time"
 
 	"github.com/go-rod/rod"
-	"github.com/go-rod/rod/lib/launcher"
 	"github.com/go-rod/rod/lib/proto"
 	"github.com/go-rod/rod/lib/utils"
-	"github.com/ysmood/gson"
 )
 
+var htmlTagRegex = regexp.MustCompile(`(?i)<html\b([^>]*)>`)
+
 // Exporter handles document export operations
 type Exporter struct {
-	pandocTimeout time.Duration
-	chromeTimeout time.Duration
+	pandocTimeout  time.Duration
+	chromeTimeout  time.Duration
+	diagramTimeout time.Duration
+	mermaidScript  string
+	browsers       *browserPool
 }
 
 // NewExporter creates a new exporter instance
-func NewExporter() *Exporter {
+func NewExporter(cfg *config.Config) *Exporter {
 	return &Exporter{
-		pandocTimeout: 30 * time.Second,
-		chromeTimeout: 30 * time.Second,
+		pandocTimeout:  30 * time.Second,
+		chromeTimeout:  30 * time.Second,
+		diagramTimeout: 30 * time.Second,
+		mermaidScript:  cfg.MermaidScript,
+		browsers:       newBrowserPool(cfg.ChromeMaxPages),
 	}
 }
 
+// Close shuts down the shared headless Chrome, if it was started. Exports
+// already using it finish first; exports that need Chrome fail after Close.
+func (e *Exporter) Close() {
+	e.browsers.close()
+}
+
+// Options controls how a document is exported
+type Options struct {
+	CodeTheme       string // Syntax highlighting theme for code blocks; "" uses highlight.DefaultTheme
+	CitationStyle   string // Citation style; "" uses citation.DefaultStyle
+	ReferencesTitle string // Heading of the generated reference list; "" uses DefaultReferencesTitle
+
+	Numbering  NumberingOptions // Figure, table and heading numbering
+	PageSetup  PageSetup        // Paper size, margins, header and footer of PDF exports
+	PDFProfile string           // PDF profile: pdf.ProfileStandard (default), pdf.ProfileTagged or pdf.ProfilePDFA
+
+	Image          ImageOptions  // Screenshot settings of PNG and JPEG exports
+	InlineMaxBytes int64         // Largest file inlined into standalone HTML; 0 uses DefaultInlineMaxBytes
+	Bundle         BundleOptions // Contents of ZIP exports
+
+	Watermark   Watermark // Watermark on HTML, PDF and image exports; when empty, the document's status decides
+	NoWatermark bool      // Leave out the watermark for the document's status
+}
+
 // ExportDocument exports a document to the specified format
-func (e *Exporter) ExportDocument(documentID, format, outputPath string, docSvc *document.Service) (string, error) {
+func (e *Exporter) ExportDocument(documentID, format, outputPath string, opts Options, docSvc *document.Service) (string, error) {
 	// Get the document
 	doc, err := docSvc.GetDocument(documentID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get document: %w", err)
 	}
 
-	// Use provided output path or generate default
-	if outputPath == "" {
-		outputPath = filepath.Join(docSvc.GetDocumentPath(documentID), fmt.Sprintf("%s.%s", documentID, format))
-	} else {
-		// Ensure parent directory exists
-		dir := filepath.Dir(outputPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return "", fmt.Errorf("failed to create output directory: %w", err)
-		}
+	outputPath, err = prepareOutputPath(documentID, format, outputPath, docSvc)
+	if err != nil {
+		return "", err
 	}
 
 	switch format {
 	case "html":
-		return e.exportHTML(doc, outputPath)
+		return e.exportHTML(doc, outputPath, opts, docSvc)
 	case "pdf":
-		return e.exportPDF(doc, outputPath, docSvc)
-	case "docx":
-		return e.exportDOCX(doc, outputPath, docSvc)
+		return e.exportPDF(doc, outputPath, opts, docSvc)
+	case "html_standalone":
+		result, err := e.exportStandaloneHTML(doc, outputPath, opts, docSvc)
+		if err != nil {
+			return "", err
+		}
+		return result.Path, nil
+	case "png", "jpeg":
+		result, err := e.exportImages(doc, format, outputPath, opts, docSvc)
+		if err != nil {
+			return "", err
+		}
+		return result.Files[0], nil
+	case "epub":
+		return e.exportEPUB(doc, outputPath, opts, docSvc)
+	case "markdown", "txt":
+		return e.exportText(doc, format, outputPath, opts, docSvc)
+	case "zip":
+		result, err := e.exportBundle(doc, outputPath, opts, docSvc)
+		if err != nil {
+			return "", err
+		}
+		return result.Path, nil
 	default:
-		return "", fmt.Errorf("unsupported format: %s", format)
+		return e.exportWithPandoc(doc, format, outputPath, opts, docSvc)
 	}
 }
 
-// exportHTML exports the document as HTML (simple copy)
-func (e *Exporter) exportHTML(doc *document.Document, outputPath string) (string, error) {
+// formatExtensions maps formats whose file extension differs from the
+// format name; Pandoc formats declare theirs in pandocFormats
+var formatExtensions = map[string]string{
+	"html_standalone": "standalone.html",
+	"markdown":        "md",
+}
+
+// prepareOutputPath returns the provided output path, with its parent
+// directory created, or the default path in the document folder. A PDF
+// path always ends in .pdf, which Pandoc needs to make a PDF rather than
+// LaTeX source.
+func prepareOutputPath(documentID, format, outputPath string, docSvc *document.Service) (string, error) {
+	if outputPath == "" {
+		ext := format
+		if e, ok := formatExtensions[format]; ok {
+			ext = e
+		} else if pf, ok := pandocFormats[format]; ok {
+			ext = pf.Extension
+		}
+		return filepath.Join(docSvc.GetDocumentPath(documentID), fmt.Sprintf("%s.%s", documentID, ext)), nil
+	}
+	if format == "pdf" && !strings.EqualFold(filepath.Ext(outputPath), ".pdf") {
+		outputPath += ".pdf"
+	}
+
+	// Ensure parent directory exists
+	dir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return outputPath, nil
+}
+
+// renderContent applies the export steps shared by all formats: diagrams
+// are rendered to SVG, figures, tables and headings are numbered with
+// cross-references filled in, and citations are formatted with a
+// reference list from the document's bibliography
+func (e *Exporter) renderContent(doc *document.Document, opts Options, docSvc *document.Service, browser *rod.Browser) (string, error) {
+	htmlContent := e.renderDiagrams(doc.HTMLContent, doc.ID, docSvc, browser)
+	htmlContent, _ = ApplyNumbering(htmlContent, opts.Numbering)
+
+	bib, err := docSvc.GetBibliography(doc.ID)
+	if err != nil {
+		return "", err
+	}
+	htmlContent, err = RenderCitations(htmlContent, bib, opts.CitationStyle, opts.ReferencesTitle)
+	if err != nil {
+		return "", fmt.Errorf("failed to render citations: %w", err)
+	}
+	return htmlContent, nil
+}
+
+// exportHTML exports the document as HTML, with diagrams rendered to SVG,
+// citations formatted, code highlighted and LaTeX math rendered as MathML
+func (e *Exporter) exportHTML(doc *document.Document, outputPath string, opts Options, docSvc *document.Service) (string, error) {
+	htmlContent, err := e.renderContent(doc, opts, docSvc, nil)
+	if err != nil {
+		return "", err
+	}
+	htmlContent = RenderMath(HighlightCode(htmlContent, opts.CodeTheme))
+	if htmlContent, err = e.watermark(htmlContent, doc, opts, docSvc); err != nil {
+		return "", err
+	}
+
 	// Write HTML content to output file
-	if err := os.WriteFile(outputPath, []byte(doc.HTMLContent), 0644); err != nil {
+	if err := os.WriteFile(outputPath, []byte(htmlContent), 0644); err != nil {
 		return "", fmt.Errorf("failed to write HTML file: %w", err)
 	}
 
 	return outputPath, nil
 }
 
-// exportPDF exports the document as PDF, trying Chrome first, then falling back to Pandoc
-func (e *Exporter) exportPDF(doc *document.Document, outputPath string, docSvc *document.Service) (string, error) {
+// exportPDF exports the document as PDF, trying Chrome first, then falling
+// back to Pandoc, and sets the PDF's title, author and other properties
+func (e *Exporter) exportPDF(doc *document.Document, outputPath string, opts Options, docSvc *document.Service) (string, error) {
 	// Try Chrome/Chromium first (best CSS preservation)
-	if err := e.exportPDFWithChrome(doc, outputPath, docSvc); err == nil {
-		return outputPath, nil
+	if err := e.exportPDFWithChrome(doc, outputPath, opts, docSvc); err != nil {
+		// Fallback to Pandoc if Chrome is not available
+		if _, err := e.exportWithPandoc(doc, "pdf", outputPath, opts, docSvc); err != nil {
+			return "", err
+		}
 	}
 
-	// Fallback to Pandoc if Chrome is not available
-	return e.exportPDFWithPandoc(doc, outputPath, docSvc)
+	if err := setPDFProperties(doc, outputPath, opts.PDFProfile, docSvc); err != nil {
+		return "", err
+	}
+	return outputPath, nil
 }
 
-// exportPDFWithChrome exports the document as PDF using headless Chrome
-func (e *Exporter) exportPDFWithChrome(doc *document.Document, outputPath string, docSvc *document.Service) error {
-	// Inject default print styles as fallback (conservative approach)
-	// These will be overridden by any @media print rules the LLM includes
-	htmlWithPrintStyles := InjectDefaultPrintStyles(doc.HTMLContent)
+// setPDFProperties writes the document's properties and dates into the
+// document information of an exported PDF and prepares it for the profile.
+// A pdfa export that still fails the PDF/A checks, for example because
+// the producer did not embed a font, is removed and reported as an error.
+func setPDFProperties(doc *document.Document, pdfPath, profile string, docSvc *document.Service) error {
+	props, err := docSvc.GetProperties(doc.ID)
+	if err != nil {
+		return err
+	}
 
-	// Create a temporary HTML file
-	tmpHTMLPath := filepath.Join(docSvc.GetDocumentPath(doc.ID), "temp_export.html")
-	if err := os.WriteFile(tmpHTMLPath, []byte(htmlWithPrintStyles), 0644); err != nil {
-		return fmt.Errorf("failed to write temp HTML file: %w", err)
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return fmt.Errorf("failed to read PDF file: %w", err)
+	}
+	data, err = pdf.Process(data, pdf.Options{
+		Info: pdf.Info{
+			Title:        props.Title,
+			Author:       props.Author,
+			Subject:      props.Subject,
+			Keywords:     props.Keywords,
+			Creator:      "simple-html-docgen",
+			CreationDate: doc.CreatedAt,
+			ModDate:      doc.UpdatedAt,
+		},
+		Profile: profile,
+		Lang:    documentLang(doc.HTMLContent),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set PDF properties: %w", err)
+	}
+	if err := os.WriteFile(pdfPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write PDF file: %w", err)
 	}
-	defer os.Remove(tmpHTMLPath)
 
+	// Fonts and other content cannot be fixed afterwards, so a file that
+	// would claim PDF/A conformance without meeting it is not kept
+	if profile == pdf.ProfilePDFA {
+		problems, err := pdf.Verify(data, profile)
+		if err != nil {
+			return fmt.Errorf("failed to verify PDF: %w", err)
+		}
+		if len(problems) > 0 {
+			os.Remove(pdfPath)
+			return fmt.Errorf("the PDF does not conform to PDF/A-2b: %s", strings.Join(problems, "; "))
+		}
+	}
+	return nil
+}
+
+// watermark adds the export's watermark, if any, to HTML
+func (e *Exporter) watermark(htmlContent string, doc *document.Document, opts Options, docSvc *document.Service) (string, error) {
+	w, err := resolveWatermark(doc, opts, docSvc)
+	if err != nil {
+		return "", err
+	}
+	return applyWatermark(htmlContent, w, doc.ID, docSvc)
+}
+
+// documentLang returns the lang attribute of a document's html element
+func documentLang(htmlContent string) string {
+	if m := htmlTagRegex.FindStringSubmatch(htmlContent); m != nil {
+		return strings.TrimSpace(attrValue(m[1], "lang"))
+	}
+	return ""
+}
+
+// exportPDFWithChrome exports the document as PDF using headless Chrome
+func (e *Exporter) exportPDFWithChrome(doc *document.Document, outputPath string, opts Options, docSvc *document.Service) error {
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), e.chromeTimeout)
 	defer cancel()
 
-	// Try to find existing Chrome/Chromium installation first
-	chromePath, _ := launcher.LookPath()
+	// Use the shared browser, started with system Chrome if available,
+	// otherwise auto-downloaded
+	browser, release, err := e.browsers.acquire(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer release()
 
-	// Launch browser with system Chrome if available, otherwise auto-download
-	var controlURL string
-	if chromePath != "" {
-		// Use system Chrome/Chromium
-		l := launcher.New().Bin(chromePath).Headless(true)
-		controlURL = l.MustLaunch()
-	} else {
-		// Let rod auto-download Chromium as fallback
-		l := launcher.New().Headless(true)
-		controlURL = l.MustLaunch()
+	// Inject default print styles as fallback (conservative approach)
+	// These will be overridden by any @media print rules the LLM includes
+	// Print always gets the highest-resolution responsive image variant
+	// LaTeX math is rendered as MathML, which Chrome lays out natively,
+	// and Mermaid diagrams are rendered with this same browser. Code is
+	// highlighted with inline styles, which print without a stylesheet.
+	htmlContent, err := e.renderContent(doc, opts, docSvc, browser)
+	if err != nil {
+		return err
+	}
+	htmlWithPrintStyles := InjectDefaultPrintStyles(SelectHighResImages(RenderMath(HighlightCode(htmlContent, opts.CodeTheme))))
+	htmlWithPrintStyles = ensureTitle(htmlWithPrintStyles, doc.Name)
+	if htmlWithPrintStyles, err = e.watermark(htmlWithPrintStyles, doc, opts, docSvc); err != nil {
+		return err
+	}
+	if styles := opts.PageSetup.pageMarginStyles(); styles != "" {
+		// Page setup margins override those of the default print styles
+		htmlWithPrintStyles = document.EnsureHeadContent(htmlWithPrintStyles, "page-setup", styles)
 	}
 
-	browser := rod.New().ControlURL(controlURL).Context(ctx)
-	if err := browser.Connect(); err != nil {
-		return fmt.Errorf("chrome not available: %w", err)
+	// Create a temporary HTML file
+	tmpHTMLPath, err := writeTempHTML(docSvc.GetDocumentPath(doc.ID), htmlWithPrintStyles)
+	if err != nil {
+		return err
 	}
-	defer browser.MustClose()
+	defer os.Remove(tmpHTMLPath)
 
 	// Load the HTML file
 	page, err := browser.Page(proto.TargetCreateTarget{URL: "file://" + tmpHTMLPath})
 	if err != nil {
 		return fmt.Errorf("failed to create page: %w", err)
 	}
+	defer closePage(page)
 
 	// Wait for page to load
 	if err := page.WaitLoad(); err != nil {
 		return fmt.Errorf("failed to load page: %w", err)
 	}
 
-	// Generate PDF with custom settings
-	pdfStream, err := page.PDF(&proto.PagePrintToPDF{
-		PrintBackground:   true, // Include background colors and images
-		MarginTop:         gson.Num(0.4),
-		MarginBottom:      gson.Num(0.4),
-		MarginLeft:        gson.Num(0.4),
-		MarginRight:       gson.Num(0.4),
-		PreferCSSPageSize: true, // Use CSS @page size if specified
-	})
+	// Generate PDF with the requested page setup
+	params, err := opts.PageSetup.chromePDFParams()
+	if err != nil {
+		return err
+	}
+	// Bookmarks are built from the headings, which Chrome finds through
+	// the tagged structure
+	params.GenerateDocumentOutline = true
+	params.GenerateTaggedPDF = true
+	pdfStream, err := page.PDF(params)
 	if err != nil {
 		return fmt.Errorf("failed to generate PDF: %w", err)
 	}
@@ -155,66 +341,31 @@ func (e *Exporter) exportPDFWithChrome(doc *document.Document, outputPath string
 	return nil
 }
 
-// exportPDFWithPandoc exports the document as PDF using Pandoc (fallback)
-func (e *Exporter) exportPDFWithPandoc(doc *document.Document, outputPath string, docSvc *document.Service) (string, error) {
-	if err := e.checkPandoc(); err != nil {
-		return "", err
+// writeTempHTML writes HTML to a new temporary file in the document folder,
+// so relative media paths resolve, with a unique name so exports of the
+// same document running at once do not overwrite each other's input
+func writeTempHTML(dir, htmlContent string) (string, error) {
+	f, err := os.CreateTemp(dir, "temp_export-*.html")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp HTML file: %w", err)
 	}
-
-	// Create a temporary HTML file for Pandoc
-	tmpHTMLPath := filepath.Join(docSvc.GetDocumentPath(doc.ID), "temp_export.html")
-	if err := os.WriteFile(tmpHTMLPath, []byte(doc.HTMLContent), 0644); err != nil {
+	if _, err := f.WriteString(htmlContent); err != nil {
+		f.Close()
+		os.Remove(f.Name())
 		return "", fmt.Errorf("failed to write temp HTML file: %w", err)
 	}
-	defer os.Remove(tmpHTMLPath)
-
-	// Run Pandoc conversion
-	args := []string{
-		"-f", "html",
-		"-o", outputPath,
-		"--pdf-engine=xelatex", // For Unicode/emoji support
-		tmpHTMLPath,
-	}
-
-	if err := e.runPandoc(args, docSvc.GetDocumentPath(doc.ID)); err != nil {
-		return "", fmt.Errorf("PDF conversion failed: %w", err)
-	}
-
-	return outputPath, nil
-}
-
-// exportDOCX exports the document as DOCX using Pandoc
-func (e *Exporter) exportDOCX(doc *document.Document, outputPath string, docSvc *document.Service) (string, error) {
-	if err := e.checkPandoc(); err != nil {
-		return "", err
-	}
-
-	// Create a temporary HTML file for Pandoc
-	tmpHTMLPath := filepath.Join(docSvc.GetDocumentPath(doc.ID), "temp_export.html")
-	if err := os.WriteFile(tmpHTMLPath, []byte(doc.HTMLContent), 0644); err != nil {
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
 		return "", fmt.Errorf("failed to write temp HTML file: %w", err)
 	}
-	defer os.Remove(tmpHTMLPath)
-
-	// Run Pandoc conversion
-	args := []string{
-		"-f", "html",
-		"-o", outputPath,
-		tmpHTMLPath,
-	}
-
-	if err := e.runPandoc(args, docSvc.GetDocumentPath(doc.ID)); err != nil {
-		return "", fmt.Errorf("DOCX conversion failed: %w", err)
-	}
-
-	return outputPath, nil
+	return f.Name(), nil
 }
 
 // checkPandoc checks if Pandoc is installed
 func (e *Exporter) checkPandoc() error {
 	cmd := exec.Command("pandoc", "--version")
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("pandoc not found: please install pandoc to enable PDF/DOCX export")
+		return fmt.Errorf("pandoc not found: please install pandoc to enable DOCX, ODT and other Pandoc exports")
 	}
 	return nil
 }
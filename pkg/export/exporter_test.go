@@ -0,0 +1,30 @@
+package export
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPrepareOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		format string
+		path   string
+		want   string
+	}{
+		{"pdf", "out/report.pdf", "out/report.pdf"},
+		{"pdf", "out/report.PDF", "out/report.PDF"},
+		{"pdf", "out/report", "out/report.pdf"},
+		{"pdf", "out/report.tex", "out/report.tex.pdf"},
+		{"docx", "out/report", "out/report"},
+	}
+	for _, tt := range tests {
+		got, err := prepareOutputPath("doc", tt.format, filepath.Join(dir, tt.path), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(dir, tt.want); got != want {
+			t.Errorf("prepareOutputPath(%s, %s) = %s, want %s", tt.format, tt.path, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"simple_html_docgen/pkg/highlight"
+	"strings"
+)
+
+// <pre><code> blocks, the form used for fenced code in HTML
+var codeBlockRegex = regexp.MustCompile(`(?is)<pre\b([^>]*)>\s*<code\b([^>]*)>(.*?)</code>\s*</pre>`)
+
+var styleAttrRegex = regexp.MustCompile(`(?i)\sstyle\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// codeLanguage returns the language named by a language-x or lang-x class
+// on a code block's <code> or <pre>, or ""
+func codeLanguage(preAttrs, codeAttrs string) string {
+	for _, attrs := range []string{codeAttrs, preAttrs} {
+		for _, c := range classList(attrs) {
+			lower := strings.ToLower(c)
+			for _, prefix := range []string{"language-", "lang-"} {
+				if strings.HasPrefix(lower, prefix) {
+					return lower[len(prefix):]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// HighlightCode colors code blocks marked with a supported language class
+// using inline-styled spans from the named theme, so the result needs no
+// stylesheet or script and survives printing to PDF. The <pre> gets the
+// theme's colors and wraps long lines. Blocks that already contain markup
+// and blocks in unknown languages are left as they are.
+func HighlightCode(htmlContent, themeName string) string {
+	if !strings.Contains(htmlContent, "<code") {
+		return htmlContent
+	}
+	theme, ok := highlight.GetTheme(themeName)
+	if !ok {
+		return htmlContent
+	}
+
+	return codeBlockRegex.ReplaceAllStringFunc(htmlContent, func(block string) string {
+		m := codeBlockRegex.FindStringSubmatch(block)
+		preAttrs, codeAttrs, content := m[1], m[2], m[3]
+		lang := codeLanguage(preAttrs, codeAttrs)
+		if lang == "" || strings.Contains(content, "<") {
+			return block
+		}
+
+		highlighted, ok := highlight.HTML(html.UnescapeString(content), lang, theme)
+		if !ok {
+			return block
+		}
+
+		preStyle := fmt.Sprintf("background-color: %s; color: %s; padding: 0.75em 1em; border-radius: 4px; white-space: pre-wrap; word-wrap: break-word;",
+			theme.Background, theme.Foreground)
+		return fmt.Sprintf("<pre%s><code%s>%s</code></pre>", addStyle(preAttrs, preStyle), codeAttrs, highlighted)
+	})
+}
+
+// addStyle puts style declarations in front of a tag's existing style
+// attribute, so the document's own declarations still win
+func addStyle(attrs, style string) string {
+	loc := styleAttrRegex.FindStringSubmatchIndex(attrs)
+	if loc == nil {
+		return attrs + ` style="` + style + `"`
+	}
+	// Insert after the opening quote of whichever value group matched
+	valueStart := loc[2]
+	if valueStart == -1 {
+		valueStart = loc[4]
+	}
+	return attrs[:valueStart] + style + " " + attrs[valueStart:]
+}
+
+// PrepareCodeForPandoc rewrites language-x code blocks to the form Pandoc's
+// HTML reader recognizes, <pre class="x"><code>, so Pandoc highlights them
+// with its own styles. Unsupported languages are left as they are.
+func PrepareCodeForPandoc(htmlContent string) string {
+	if !strings.Contains(htmlContent, "<code") {
+		return htmlContent
+	}
+
+	return codeBlockRegex.ReplaceAllStringFunc(htmlContent, func(block string) string {
+		m := codeBlockRegex.FindStringSubmatch(block)
+		pandocLang := highlight.PandocLanguage(codeLanguage(m[1], m[2]))
+		if pandocLang == "" {
+			return block
+		}
+		return fmt.Sprintf(`<pre class="%s"><code>%s</code></pre>`, pandocLang, m[3])
+	})
+}
+
+// pandocHighlightArgs returns the Pandoc option selecting the highlight
+// style closest to the named theme
+func pandocHighlightArgs(themeName string) []string {
+	theme, ok := highlight.GetTheme(themeName)
+	if !ok {
+		return nil
+	}
+	return []string{"--highlight-style=" + theme.PandocStyle}
+}
@@ -0,0 +1,42 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlightCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		html        string
+		highlighted bool
+	}{
+		{"language class on code", `<pre><code class="language-go">x := 1</code></pre>`, true},
+		{"lang class on pre", `<pre class="lang-py"><code>x = 1</code></pre>`, true},
+		{"existing markup", `<pre><code class="language-go"><b>x</b></code></pre>`, false},
+		{"unknown language", `<pre><code class="language-cobol">x</code></pre>`, false},
+		{"inline code", `<p><code class="language-go">x := 1</code></p>`, false},
+	}
+	for _, tt := range tests {
+		got := HighlightCode(tt.html, "")
+		if highlighted := got != tt.html; highlighted != tt.highlighted {
+			t.Errorf("%s: highlighted = %v, want %v:\n%s", tt.name, highlighted, tt.highlighted, got)
+		}
+		if tt.highlighted && (!strings.Contains(got, `<span style="`) || !strings.Contains(got, ` style="background-color`)) {
+			t.Errorf("%s: no inline styles:\n%s", tt.name, got)
+		}
+	}
+}
+
+func TestPrepareCodeForPandoc(t *testing.T) {
+	tests := map[string]string{
+		`<pre><code class="language-go">x := 1</code></pre>`: `<pre class="go"><code>x := 1</code></pre>`,
+		`<pre class="lang-py"><code>x = 1</code></pre>`:      `<pre class="python"><code>x = 1</code></pre>`,
+		`<pre><code class="language-cobol">x</code></pre>`:   `<pre><code class="language-cobol">x</code></pre>`,
+	}
+	for in, want := range tests {
+		if got := PrepareCodeForPandoc(in); got != want {
+			t.Errorf("PrepareCodeForPandoc(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
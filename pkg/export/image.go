@@ -0,0 +1,384 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"simple_html_docgen/pkg/document"
+	"simple_html_docgen/pkg/imaging"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Image export modes
+const (
+	ImageFull  = "full"  // One screenshot of the whole document
+	ImageSplit = "split" // The print layout cut into page-sized images
+)
+
+const (
+	DefaultViewportWidth = 1280 // CSS pixels
+	DefaultJPEGQuality   = 90
+
+	maxImageSize     = 16384 // Largest screenshot side Chrome captures reliably, in pixels
+	maxImageParts    = 500
+	cssPixelsPerInch = 96
+	thumbnailAspect  = 0.75 // Height to width of full-mode thumbnails
+)
+
+// ImageOptions controls PNG and JPEG exports. Split mode lays the
+// document out for print with the paper size, orientation and margins of
+// the page setup (letter when none is given) and cuts it into page-sized
+// images. They are not rendered from the PDF, so they can break elsewhere.
+type ImageOptions struct {
+	Mode           string  // full (default) or split
+	ViewportWidth  int     // Browser width in CSS pixels for full mode; 0 uses DefaultViewportWidth
+	DeviceScale    float64 // Device pixel ratio, e.g. 2 for sharp images on high-density screens; 0 uses 1
+	Quality        int     // JPEG quality from 1 to 100; 0 uses DefaultJPEGQuality
+	ThumbnailWidth int     // Width of thumbnails in pixels; 0 makes none
+}
+
+// ImageResult lists the files written by an image export
+type ImageResult struct {
+	Files      []string // One file in full mode, one per part in split mode
+	Thumbnails []string // One per file when thumbnails were requested
+	Width      int      // Pixel size of the first image
+	Height     int
+}
+
+// Validate checks the image options
+func (o ImageOptions) Validate() error {
+	switch o.Mode {
+	case "", ImageFull, ImageSplit:
+	default:
+		return fmt.Errorf("invalid image_mode: %s (must be full or split)", o.Mode)
+	}
+	if o.ViewportWidth != 0 && (o.ViewportWidth < 200 || o.ViewportWidth > 4000) {
+		return fmt.Errorf("invalid viewport_width: %d (must be between 200 and 4000)", o.ViewportWidth)
+	}
+	if o.DeviceScale != 0 && (o.DeviceScale < 0.5 || o.DeviceScale > 4) {
+		return fmt.Errorf("invalid device_scale: %g (must be between 0.5 and 4)", o.DeviceScale)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("invalid quality: %d (must be between 1 and 100)", o.Quality)
+	}
+	if o.ThumbnailWidth < 0 || o.ThumbnailWidth > 1000 {
+		return fmt.Errorf("invalid thumbnail_width: %d (must be between 1 and 1000)", o.ThumbnailWidth)
+	}
+	return nil
+}
+
+// ExportImages exports a document as PNG or JPEG images and returns all
+// files written
+func (e *Exporter) ExportImages(documentID, format, outputPath string, opts Options, docSvc *document.Service) (*ImageResult, error) {
+	if format != "png" && format != "jpeg" {
+		return nil, fmt.Errorf("unsupported image format: %s", format)
+	}
+	doc, err := docSvc.GetDocument(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	outputPath, err = prepareOutputPath(documentID, format, outputPath, docSvc)
+	if err != nil {
+		return nil, err
+	}
+	return e.exportImages(doc, format, outputPath, opts, docSvc)
+}
+
+// exportImages screenshots the document with headless Chrome
+func (e *Exporter) exportImages(doc *document.Document, format, outputPath string, opts Options, docSvc *document.Service) (*ImageResult, error) {
+	imageOpts := opts.Image
+	if err := imageOpts.Validate(); err != nil {
+		return nil, err
+	}
+	if imageOpts.DeviceScale == 0 {
+		imageOpts.DeviceScale = 1
+	}
+	if imageOpts.Quality == 0 {
+		imageOpts.Quality = DefaultJPEGQuality
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.chromeTimeout)
+	defer cancel()
+	browser, release, err := e.browsers.acquire(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// The document is prepared as for a Chrome PDF; split mode also gets
+	// the print styles and print-resolution images
+	htmlContent, err := e.renderContent(doc, opts, docSvc, browser)
+	if err != nil {
+		return nil, err
+	}
+	htmlContent = RenderMath(HighlightCode(htmlContent, opts.CodeTheme))
+	if imageOpts.Mode == ImageSplit {
+		htmlContent = InjectDefaultPrintStyles(SelectHighResImages(htmlContent))
+	}
+	htmlContent = ensureTitle(htmlContent, doc.Name)
+	if htmlContent, err = e.watermark(htmlContent, doc, opts, docSvc); err != nil {
+		return nil, err
+	}
+
+	tmpHTMLPath, err := writeTempHTML(docSvc.GetDocumentPath(doc.ID), htmlContent)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpHTMLPath)
+
+	page, err := browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create page: %w", err)
+	}
+	defer closePage(page)
+
+	var images []image.Image
+	if imageOpts.Mode == ImageSplit {
+		images, err = screenshotParts(page, "file://"+tmpHTMLPath, opts.PageSetup, imageOpts.DeviceScale)
+	} else {
+		images, err = screenshotFull(page, "file://"+tmpHTMLPath, imageOpts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImageResult{Width: images[0].Bounds().Dx(), Height: images[0].Bounds().Dy()}
+	base := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	ext := filepath.Ext(outputPath)
+	for i, img := range images {
+		path := outputPath
+		if imageOpts.Mode == ImageSplit {
+			path = fmt.Sprintf("%s-%d%s", base, i+1, ext)
+		}
+		if err := writeImage(path, img, format, imageOpts.Quality); err != nil {
+			return nil, err
+		}
+		result.Files = append(result.Files, path)
+
+		if imageOpts.ThumbnailWidth > 0 {
+			thumbPath := strings.TrimSuffix(path, ext) + "-thumb" + ext
+			if err := writeImage(thumbPath, thumbnail(img, imageOpts.ThumbnailWidth, imageOpts.Mode == ImageFull), format, imageOpts.Quality); err != nil {
+				return nil, err
+			}
+			result.Thumbnails = append(result.Thumbnails, thumbPath)
+		}
+	}
+	return result, nil
+}
+
+// screenshotFull captures the whole document at the viewport width
+func screenshotFull(page *rod.Page, url string, opts ImageOptions) ([]image.Image, error) {
+	width := opts.ViewportWidth
+	if width == 0 {
+		width = DefaultViewportWidth
+	}
+	if err := page.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
+		Width:             width,
+		Height:            int(float64(width) * thumbnailAspect),
+		DeviceScaleFactor: opts.DeviceScale,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to set viewport: %w", err)
+	}
+	if err := loadPage(page, url); err != nil {
+		return nil, err
+	}
+
+	metrics, err := proto.PageGetLayoutMetrics{}.Call(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure page: %w", err)
+	}
+	if metrics.CSSContentSize != nil {
+		if height := metrics.CSSContentSize.Height * opts.DeviceScale; height > maxImageSize {
+			return nil, fmt.Errorf("the document is %.0f pixels tall, more than the %d a screenshot can hold; use image_mode split or a smaller device_scale", height, maxImageSize)
+		}
+	}
+
+	data, err := page.Screenshot(true, &proto.PageCaptureScreenshot{Format: proto.PageCaptureScreenshotFormatPng})
+	if err != nil {
+		return nil, fmt.Errorf("failed to take screenshot: %w", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	return []image.Image{img}, nil
+}
+
+// splitScript finds where the parts of a split image export start. A part
+// ends at the first forced page break, or above the first block its end
+// would cut through, unless that leaves it less than half full. A spacer
+// is then added so the last part can be scrolled to the top.
+const splitScript = `async (pageHeight) => {
+	await document.fonts.ready;
+	const forcedValues = ['page', 'always', 'left', 'right', 'recto', 'verso'];
+	const total = Math.ceil(document.documentElement.scrollHeight);
+	const forced = [];
+	const blocks = [];
+	for (const el of document.body.querySelectorAll('*')) {
+		const rect = el.getBoundingClientRect();
+		if (rect.width === 0 && rect.height === 0) continue;
+		const style = getComputedStyle(el);
+		const top = rect.top + window.scrollY;
+		const bottom = rect.bottom + window.scrollY;
+		if (forcedValues.includes(style.breakBefore)) forced.push(top);
+		if (forcedValues.includes(style.breakAfter)) forced.push(bottom);
+		if (style.display !== 'inline' && style.position !== 'fixed') blocks.push([top, bottom]);
+	}
+	forced.sort((a, b) => a - b);
+
+	const starts = [0];
+	let start = 0;
+	while (start + pageHeight < total && starts.length < ` + "%d" + `) {
+		let end = start + pageHeight;
+		const breakAt = forced.find(y => y > start + 1 && y < end);
+		if (breakAt !== undefined) {
+			end = breakAt;
+		} else {
+			let best = end;
+			for (const [top, bottom] of blocks) {
+				if (top < end && bottom > end && top > start + pageHeight / 2 && top < best) best = top;
+			}
+			end = best;
+		}
+		end = Math.floor(end);
+		if (end <= start) end = start + pageHeight;
+		starts.push(end);
+		start = end;
+	}
+
+	const spacer = document.createElement('div');
+	spacer.style.height = pageHeight + 'px';
+	document.body.appendChild(spacer);
+	return {starts, total};
+}`
+
+// screenshotParts lays the document out for print at the content width of
+// the page setup, cuts it into parts of the content height and draws each
+// onto a blank page with the margins. Fixed elements such as a watermark
+// appear on every part; @page rules, headers and footers do not.
+func screenshotParts(page *rod.Page, url string, setup PageSetup, scale float64) ([]image.Image, error) {
+	l, err := setup.layout()
+	if err != nil {
+		return nil, err
+	}
+	if l.width == 0 {
+		l.width, l.height = PaperSizes["letter"][0], PaperSizes["letter"][1]
+		if setup.Landscape {
+			l.width, l.height = l.height, l.width
+		}
+	}
+	contentWidth := int(math.Round((l.width - l.left - l.right) * cssPixelsPerInch))
+	contentHeight := int(math.Round((l.height - l.top - l.bottom) * cssPixelsPerInch))
+	if contentWidth < 50 || contentHeight < 50 {
+		return nil, fmt.Errorf("the margins leave no room for content")
+	}
+
+	if err := (proto.EmulationSetEmulatedMedia{Media: "print"}).Call(page); err != nil {
+		return nil, fmt.Errorf("failed to emulate print media: %w", err)
+	}
+	if err := page.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
+		Width:             contentWidth,
+		Height:            contentHeight,
+		DeviceScaleFactor: scale,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to set viewport: %w", err)
+	}
+	if err := loadPage(page, url); err != nil {
+		return nil, err
+	}
+
+	res, err := page.Eval(fmt.Sprintf(splitScript, maxImageParts), contentHeight)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split the document: %w", err)
+	}
+	total := res.Value.Get("total").Int()
+	var starts []int
+	for _, v := range res.Value.Get("starts").Arr() {
+		starts = append(starts, v.Int())
+	}
+
+	pageWidth := int(math.Round(l.width * cssPixelsPerInch * scale))
+	pageHeight := int(math.Round(l.height * cssPixelsPerInch * scale))
+	offset := image.Pt(int(math.Round(l.left*cssPixelsPerInch*scale)), int(math.Round(l.top*cssPixelsPerInch*scale)))
+
+	images := make([]image.Image, 0, len(starts))
+	for i, start := range starts {
+		end := total
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		if _, err := page.Eval(`(y) => window.scrollTo(0, y)`, start); err != nil {
+			return nil, fmt.Errorf("failed to scroll: %w", err)
+		}
+		data, err := page.Screenshot(false, &proto.PageCaptureScreenshot{Format: proto.PageCaptureScreenshotFormatPng})
+		if err != nil {
+			return nil, fmt.Errorf("failed to take screenshot of part %d: %w", i+1, err)
+		}
+		shot, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+		}
+
+		canvas := image.NewRGBA(image.Rect(0, 0, pageWidth, pageHeight))
+		draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+		visible := shot.Bounds()
+		visible.Max.Y = min(visible.Max.Y, visible.Min.Y+int(math.Round(float64(end-start)*scale)))
+		draw.Draw(canvas, visible.Sub(visible.Min).Add(offset), shot, visible.Min, draw.Src)
+		images = append(images, canvas)
+	}
+	return images, nil
+}
+
+// loadPage navigates to a URL and waits for it to load
+func loadPage(page *rod.Page, url string) error {
+	if err := page.Navigate(url); err != nil {
+		return fmt.Errorf("failed to load page: %w", err)
+	}
+	if err := page.WaitLoad(); err != nil {
+		return fmt.Errorf("failed to load page: %w", err)
+	}
+	return nil
+}
+
+// thumbnail scales an image down to a width. With crop, only the top of
+// the image is kept, at a 4:3 aspect ratio.
+func thumbnail(img image.Image, width int, crop bool) image.Image {
+	bounds := img.Bounds()
+	if h := int(float64(bounds.Dx()) * thumbnailAspect); crop && h < bounds.Dy() {
+		if sub, ok := img.(interface {
+			SubImage(image.Rectangle) image.Image
+		}); ok {
+			img = sub.SubImage(image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+h))
+		}
+	}
+	w, h := imaging.FitWidth(img.Bounds().Dx(), img.Bounds().Dy(), width)
+	return imaging.Resize(img, w, h)
+}
+
+// writeImage encodes an image as PNG or JPEG and writes it to a file
+func writeImage(path string, img image.Image, format string, quality int) error {
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write image file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,788 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"simple_html_docgen/pkg/document"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	whitespaceRegex  = regexp.MustCompile(`\s+`)
+	backtickRunRegex = regexp.MustCompile("`+")
+
+	// Line starts that Markdown would read as a heading, quote, list item
+	// or thematic break
+	markdownLineStartRegex = regexp.MustCompile(`^(\s*)(#{1,6}(?:\s|$)|>|[-+*](?:\s|$)|(\d+)([.)])(?:\s|$)|={3,}\s*$|-{3,}\s*$)`)
+	entityLikeRegex        = regexp.MustCompile(`^&(?:#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+)
+
+// Elements rendered as blocks; everything else is inline
+var textBlockElements = setOf("address", "article", "aside", "blockquote", "details", "div", "dl", "dd", "dt", "fieldset", "figcaption",
+	"figure", "footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "li", "main", "nav", "ol", "p", "pre", "section",
+	"summary", "table", "ul")
+
+// Inline elements kept as HTML in Markdown, which has no syntax for them
+var markdownInlineHTML = setOf("sup", "sub", "kbd", "mark", "u", "ins")
+
+// textNode is an element or, when name is empty, a run of text in the tree
+// the Markdown and plain text writers walk
+type textNode struct {
+	name     string
+	attrs    map[string]string
+	children []*textNode
+	text     string
+}
+
+// textBlock is a rendered block and whether it is a list, which nests
+// into list items without a blank line
+type textBlock struct {
+	text string
+	list bool
+}
+
+// textWriter renders a document tree as GitHub-flavored Markdown or as
+// plain text
+type textWriter struct {
+	markdown bool
+}
+
+// ToMarkdown converts an HTML document to GitHub-flavored Markdown. Tables
+// become pipe tables, code blocks fenced blocks with their language, math
+// with a TeX annotation $...$ and $$...$$, and sup, sub, kbd and similar
+// elements stay as inline HTML. Scripts, styles and inline SVG are dropped.
+func ToMarkdown(htmlContent string) string {
+	return (&textWriter{markdown: true}).render(htmlContent)
+}
+
+// ToPlainText converts an HTML document to plain text. Headings are
+// underlined, lists keep their markers, tables are laid out in columns,
+// code is indented and links and images are followed by their URL.
+func ToPlainText(htmlContent string) string {
+	return (&textWriter{}).render(htmlContent)
+}
+
+// render converts a document
+func (w *textWriter) render(htmlContent string) string {
+	if m := bodyContentRegex.FindStringSubmatch(htmlContent); m != nil {
+		htmlContent = m[1]
+	}
+	root := parseTextTree(ToXHTML(htmlContent))
+	var parts []string
+	for _, b := range w.blocks(root) {
+		parts = append(parts, b.text)
+	}
+	out := strings.Join(parts, "\n\n")
+	if out == "" {
+		return ""
+	}
+	return out + "\n"
+}
+
+// parseTextTree parses well-formed XHTML into a tree
+func parseTextTree(xhtml string) *textNode {
+	root := &textNode{name: "body"}
+	stack := []*textNode{root}
+	d := xml.NewDecoder(strings.NewReader("<body>" + xhtml + "</body>"))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF || err != nil {
+			break
+		}
+		parent := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 1 && len(root.children) == 0 && t.Name.Local == "body" {
+				// The wrapper added above is the root itself
+				stack = append(stack, root)
+				continue
+			}
+			n := &textNode{name: t.Name.Local, attrs: map[string]string{}}
+			for _, a := range t.Attr {
+				if a.Name.Space == "" || a.Name.Space == xlinkNamespace {
+					n.attrs[a.Name.Local] = a.Value
+				}
+			}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			parent.children = append(parent.children, &textNode{text: string(t)})
+		}
+	}
+	return root
+}
+
+// blocks renders the children of an element as blocks. Runs of text and
+// inline elements between blocks become paragraphs.
+func (w *textWriter) blocks(n *textNode) []textBlock {
+	var out []textBlock
+	var inline strings.Builder
+	flush := func() {
+		if text := w.paragraph(inline.String()); text != "" {
+			out = append(out, textBlock{text: text})
+		}
+		inline.Reset()
+	}
+
+	for _, c := range n.children {
+		if !w.isBlock(c) {
+			inline.WriteString(w.inline(c))
+			continue
+		}
+		flush()
+		out = append(out, w.block(c)...)
+	}
+	flush()
+	return out
+}
+
+// isBlock reports whether a node renders as a block
+func (w *textWriter) isBlock(n *textNode) bool {
+	return textBlockElements[n.name] || n.name == "math" && n.attrs["display"] == "block"
+}
+
+// hidden reports whether an element is not shown
+func hidden(n *textNode) bool {
+	_, isHidden := n.attrs["hidden"]
+	return isHidden || n.attrs["aria-hidden"] == "true" || n.name == "svg" || n.name == "template"
+}
+
+// block renders a block element
+func (w *textWriter) block(n *textNode) []textBlock {
+	if hidden(n) {
+		return nil
+	}
+	switch n.name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := w.paragraph(w.inlineChildren(n))
+		if text == "" {
+			return nil
+		}
+		text = strings.ReplaceAll(text, "\n", " ")
+		level := int(n.name[1] - '0')
+		if w.markdown {
+			return []textBlock{{text: strings.Repeat("#", level) + " " + text}}
+		}
+		switch level {
+		case 1:
+			return []textBlock{{text: text + "\n" + strings.Repeat("=", utf8.RuneCountInString(text))}}
+		case 2:
+			return []textBlock{{text: text + "\n" + strings.Repeat("-", utf8.RuneCountInString(text))}}
+		}
+		return []textBlock{{text: text}}
+
+	case "p", "dt", "summary", "figcaption":
+		text := w.paragraph(w.inlineChildren(n))
+		if text == "" {
+			return nil
+		}
+		if w.markdown && (n.name == "dt" || n.name == "summary") {
+			text = "**" + text + "**"
+		}
+		return []textBlock{{text: text}}
+
+	case "dd":
+		if w.markdown {
+			return w.blocks(n)
+		}
+		return indentBlocks(w.blocks(n), "    ")
+
+	case "hr":
+		if w.markdown {
+			return []textBlock{{text: "---"}}
+		}
+		return []textBlock{{text: strings.Repeat("-", 40)}}
+
+	case "pre":
+		return []textBlock{w.codeBlock(n)}
+
+	case "blockquote":
+		return []textBlock{{text: prefixLines(joinBlocks(w.blocks(n)), "> ", ">")}}
+
+	case "ul", "ol":
+		return []textBlock{{text: w.list(n), list: true}}
+
+	case "table":
+		// The caption, which holds any "Table 3:" label, goes before the table
+		var blocks []textBlock
+		for _, c := range n.children {
+			if c.name == "caption" && !hidden(c) {
+				if text := w.paragraph(w.inlineChildren(c)); text != "" {
+					blocks = append(blocks, textBlock{text: text})
+				}
+			}
+		}
+		return append(blocks, textBlock{text: w.table(n)})
+
+	case "math":
+		tex := mathTeX(n)
+		if w.markdown {
+			return []textBlock{{text: "$$\n" + tex + "\n$$"}}
+		}
+		return []textBlock{{text: tex}}
+
+	default:
+		return w.blocks(n)
+	}
+}
+
+// inline renders an inline node
+func (w *textWriter) inline(n *textNode) string {
+	if n.name == "" {
+		text := whitespaceRegex.ReplaceAllString(n.text, " ")
+		if w.markdown {
+			text = escapeMarkdownText(text)
+		}
+		return text
+	}
+	if hidden(n) {
+		return ""
+	}
+
+	switch n.name {
+	case "br":
+		if w.markdown {
+			return "\\\n"
+		}
+		return "\n"
+	case "strong", "b":
+		return w.emphasis(n, "**")
+	case "em", "i", "cite", "dfn", "var":
+		return w.emphasis(n, "*")
+	case "del", "s", "strike":
+		return w.emphasis(n, "~~")
+	case "code", "samp", "tt":
+		text := textContent(n)
+		if !w.markdown {
+			return text
+		}
+		return inlineCode(whitespaceRegex.ReplaceAllString(text, " "))
+	case "a":
+		return w.link(n)
+	case "img":
+		return w.image(n)
+	case "math":
+		if w.markdown {
+			return "$" + mathTeX(n) + "$"
+		}
+		return mathTeX(n)
+	case "video", "audio", "iframe", "embed", "object":
+		return w.mediaLink(n)
+	case "input":
+		return ""
+	}
+
+	content := w.inlineChildren(n)
+	if w.markdown && markdownInlineHTML[n.name] {
+		return "<" + n.name + ">" + content + "</" + n.name + ">"
+	}
+	return content
+}
+
+// inlineChildren renders the children of an element as inline content;
+// blocks nested in inline elements are joined with line breaks
+func (w *textWriter) inlineChildren(n *textNode) string {
+	var b strings.Builder
+	for _, c := range n.children {
+		if w.isBlock(c) {
+			for _, block := range w.block(c) {
+				b.WriteString("\n" + block.text + "\n")
+			}
+			continue
+		}
+		b.WriteString(w.inline(c))
+	}
+	return b.String()
+}
+
+// emphasis wraps inline content in a Markdown marker, keeping surrounding
+// spaces outside it so the marker still applies
+func (w *textWriter) emphasis(n *textNode, marker string) string {
+	content := w.inlineChildren(n)
+	if !w.markdown || strings.TrimSpace(content) == "" {
+		return content
+	}
+	trimmed := strings.TrimSpace(content)
+	start := content[:strings.Index(content, trimmed)]
+	end := content[len(start)+len(trimmed):]
+	return start + marker + trimmed + marker + end
+}
+
+// link renders a link: [text](url "title") in Markdown, "text (url)" in
+// plain text. Links to files in the document folder point into media/.
+func (w *textWriter) link(n *textNode) string {
+	text := w.inlineChildren(n)
+	href, ok := n.attrs["href"]
+	if !ok {
+		return text
+	}
+	href = relativeMediaRef(href)
+	if !w.markdown {
+		if strings.HasPrefix(href, "#") || href == strings.TrimSpace(text) || strings.TrimPrefix(href, "mailto:") == strings.TrimSpace(text) {
+			return text
+		}
+		if strings.TrimSpace(text) == "" {
+			return href
+		}
+		return text + " (" + href + ")"
+	}
+	if strings.TrimSpace(text) == "" {
+		text = escapeMarkdownText(href)
+	}
+	return "[" + strings.TrimSpace(text) + "](" + markdownURL(href) + markdownTitle(n.attrs["title"]) + ")"
+}
+
+// image renders an image: ![alt](src) in Markdown, "[image: alt (src)]"
+// in plain text
+func (w *textWriter) image(n *textNode) string {
+	src := relativeMediaRef(n.attrs["src"])
+	alt := whitespaceRegex.ReplaceAllString(strings.TrimSpace(n.attrs["alt"]), " ")
+	if src == "" {
+		return alt
+	}
+	if !w.markdown {
+		if alt == "" {
+			return "[image: " + src + "]"
+		}
+		return "[image: " + alt + " (" + src + ")]"
+	}
+	return "![" + escapeMarkdownText(alt) + "](" + markdownURL(src) + markdownTitle(n.attrs["title"]) + ")"
+}
+
+// mediaLink renders audio, video and embedded content as a link to the
+// file, which Markdown and plain text cannot play
+func (w *textWriter) mediaLink(n *textNode) string {
+	src := n.attrs["src"]
+	if src == "" {
+		src = n.attrs["data"]
+	}
+	for _, c := range n.children {
+		if src == "" && c.name == "source" {
+			src = c.attrs["src"]
+		}
+	}
+	if src == "" {
+		return ""
+	}
+	src = relativeMediaRef(src)
+	label := n.attrs["title"]
+	if label == "" {
+		label = n.attrs["aria-label"]
+	}
+	if label == "" {
+		label = n.name
+	}
+	if !w.markdown {
+		return "[" + label + ": " + src + "]"
+	}
+	return "[" + escapeMarkdownText(label) + "](" + markdownURL(src) + ")"
+}
+
+// codeBlock renders a <pre> as a fenced block in Markdown, with the
+// language of a language-x class, and indented in plain text
+func (w *textWriter) codeBlock(n *textNode) textBlock {
+	code := strings.TrimRight(strings.TrimPrefix(textContent(n), "\n"), " \n")
+	if !w.markdown {
+		return textBlock{text: prefixLines(code, "    ", "")}
+	}
+
+	lang := codeLanguage(` class="`+n.attrs["class"]+`"`, "")
+	for _, c := range n.children {
+		if c.name == "code" && lang == "" {
+			lang = codeLanguage("", ` class="`+c.attrs["class"]+`"`)
+		}
+	}
+	if lang == "" && strings.Contains(" "+n.attrs["class"]+" ", " mermaid ") {
+		lang = "mermaid"
+	}
+
+	fence := "```"
+	for _, run := range backtickRunRegex.FindAllString(code, -1) {
+		if len(run) >= len(fence) {
+			fence = strings.Repeat("`", len(run)+1)
+		}
+	}
+	return textBlock{text: fence + lang + "\n" + code + "\n" + fence}
+}
+
+// list renders a list with "-" or numbered markers. Item content is
+// indented under the marker, and items are separated by blank lines only
+// when one of them has several paragraphs.
+func (w *textWriter) list(n *textNode) string {
+	number := 1
+	if start, err := strconv.Atoi(n.attrs["start"]); err == nil {
+		number = start
+	}
+
+	var items []string
+	loose := false
+	for _, li := range n.children {
+		if li.name != "li" {
+			continue
+		}
+		marker := "- "
+		if n.name == "ol" {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		// Nested blocks line up with the item's text, not its checkbox
+		indent := strings.Repeat(" ", utf8.RuneCountInString(marker))
+		if box := taskCheckbox(li); box != nil {
+			if _, checked := box.attrs["checked"]; checked {
+				marker += "[x] "
+			} else {
+				marker += "[ ] "
+			}
+		}
+
+		var content strings.Builder
+		paragraphs := 0
+		for i, b := range w.blocks(li) {
+			if i > 0 {
+				if b.list {
+					content.WriteString("\n")
+				} else {
+					content.WriteString("\n\n")
+				}
+			}
+			if !b.list {
+				paragraphs++
+			}
+			content.WriteString(b.text)
+		}
+		loose = loose || paragraphs > 1
+		items = append(items, marker+prefixLines(content.String(), indent, "")[len(indent):])
+	}
+	if loose {
+		return strings.Join(items, "\n\n")
+	}
+	return strings.Join(items, "\n")
+}
+
+// table renders a table as a GitHub pipe table, with the first row as
+// header, or in plain text as columns padded with spaces
+func (w *textWriter) table(n *textNode) string {
+	var rows [][]string
+	var aligns []string
+	var collect func(*textNode)
+	collect = func(n *textNode) {
+		for _, c := range n.children {
+			switch c.name {
+			case "thead", "tbody", "tfoot":
+				collect(c)
+			case "tr":
+				var row []string
+				for _, cell := range c.children {
+					if cell.name != "td" && cell.name != "th" {
+						continue
+					}
+					// Cells hold one line; line breaks become <br> in Markdown
+					text := w.inlineChildren(cell)
+					if w.markdown {
+						text = strings.ReplaceAll(strings.ReplaceAll(text, "\\\n", "<br>"), "|", `\|`)
+					}
+					text = strings.TrimSpace(whitespaceRegex.ReplaceAllString(text, " "))
+					row = append(row, text)
+					if len(rows) == 0 {
+						aligns = append(aligns, cellAlign(cell))
+					}
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	collect(n)
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return ""
+	}
+	widths := make([]int, columns)
+	for i := range rows {
+		for len(rows[i]) < columns {
+			rows[i] = append(rows[i], "")
+		}
+		for j, cell := range rows[i] {
+			widths[j] = max(widths[j], utf8.RuneCountInString(cell), 3)
+		}
+	}
+	for len(aligns) < columns {
+		aligns = append(aligns, "")
+	}
+
+	var lines []string
+	for i, row := range rows {
+		cells := make([]string, columns)
+		for j, cell := range row {
+			cells[j] = cell + strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+		}
+		if w.markdown {
+			lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		} else {
+			lines = append(lines, strings.TrimRight(strings.Join(cells, "  "), " "))
+		}
+		if i > 0 {
+			continue
+		}
+		rules := make([]string, columns)
+		for j := range rules {
+			rules[j] = strings.Repeat("-", widths[j])
+			if w.markdown {
+				switch aligns[j] {
+				case "center":
+					rules[j] = ":" + rules[j][2:] + ":"
+				case "right":
+					rules[j] = rules[j][1:] + ":"
+				case "left":
+					rules[j] = ":" + rules[j][1:]
+				}
+			}
+		}
+		if w.markdown {
+			lines = append(lines, "| "+strings.Join(rules, " | ")+" |")
+		} else {
+			lines = append(lines, strings.Join(rules, "  "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// paragraph tidies rendered inline content: spaces are trimmed around
+// line breaks and, in Markdown, line starts that would read as markup are
+// escaped
+func (w *textWriter) paragraph(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	var out []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if w.markdown {
+			line = markdownLineStartRegex.ReplaceAllStringFunc(line, func(m string) string {
+				parts := markdownLineStartRegex.FindStringSubmatch(m)
+				if parts[3] != "" {
+					return parts[1] + parts[3] + `\` + m[len(parts[1])+len(parts[3]):]
+				}
+				return parts[1] + `\` + m[len(parts[1]):]
+			})
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// taskCheckbox returns the checkbox that starts a task list item, if any
+func taskCheckbox(li *textNode) *textNode {
+	for _, c := range li.children {
+		switch {
+		case c.name == "" && strings.TrimSpace(c.text) == "":
+			continue
+		case c.name == "input" && strings.EqualFold(c.attrs["type"], "checkbox"):
+			return c
+		case c.name == "p":
+			return taskCheckbox(c)
+		}
+		return nil
+	}
+	return nil
+}
+
+// cellAlign returns the alignment of a table cell from its align
+// attribute or text-align style
+func cellAlign(cell *textNode) string {
+	if align := strings.ToLower(cell.attrs["align"]); align != "" {
+		return align
+	}
+	style := strings.ToLower(strings.ReplaceAll(cell.attrs["style"], " ", ""))
+	for _, align := range []string{"center", "right", "left"} {
+		if strings.Contains(style, "text-align:"+align) {
+			return align
+		}
+	}
+	return ""
+}
+
+// mathTeX returns the TeX source of a <math> element from its annotation,
+// or its text
+func mathTeX(n *textNode) string {
+	var find func(*textNode) string
+	find = func(n *textNode) string {
+		if n.name == "annotation" && n.attrs["encoding"] == "application/x-tex" {
+			return textContent(n)
+		}
+		for _, c := range n.children {
+			if tex := find(c); tex != "" {
+				return tex
+			}
+		}
+		return ""
+	}
+	if tex := find(n); tex != "" {
+		return strings.TrimSpace(tex)
+	}
+	return strings.TrimSpace(textContent(n))
+}
+
+// textContent returns the text of a node and its descendants
+func textContent(n *textNode) string {
+	if n.name == "" {
+		return n.text
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		if c.name == "br" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// escapeMarkdownText escapes the characters of text that Markdown would
+// read as markup. Underscores inside words, and < and & where they cannot
+// start a tag or entity, are left alone.
+func escapeMarkdownText(text string) string {
+	var b strings.Builder
+	runes := []rune(text)
+	isWord := func(i int) bool {
+		return i >= 0 && i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]))
+	}
+	for i, r := range runes {
+		escape := false
+		switch r {
+		case '\\', '`', '*', '[', ']', '~':
+			escape = true
+		case '_':
+			escape = !isWord(i-1) || !isWord(i+1)
+		case '<':
+			escape = i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || strings.ContainsRune("/!?", runes[i+1]))
+		case '&':
+			escape = entityLikeRegex.MatchString(string(runes[i:min(len(runes), i+34)]))
+		}
+		if escape {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// inlineCode wraps text in enough backticks that none inside end it
+func inlineCode(text string) string {
+	fence := "`"
+	for _, run := range backtickRunRegex.FindAllString(text, -1) {
+		if len(run) >= len(fence) {
+			fence = strings.Repeat("`", len(run)+1)
+		}
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+// markdownURL writes a link destination, in angle brackets when it has
+// spaces or parentheses
+func markdownURL(u string) string {
+	if strings.ContainsAny(u, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(u) + ">"
+	}
+	return u
+}
+
+// markdownTitle writes an optional link title
+func markdownTitle(title string) string {
+	if title == "" {
+		return ""
+	}
+	return ` "` + strings.ReplaceAll(title, `"`, `\"`) + `"`
+}
+
+// relativeMediaRef rewrites a reference to a file in the media folder,
+// such as "./media/a.png", as the relative path "media/a.png". Other
+// references are returned unchanged.
+func relativeMediaRef(ref string) string {
+	ref = strings.TrimSpace(ref)
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	if u.Scheme == "file" {
+		u.Scheme, u.Host = "", ""
+	}
+	p := u.Path
+	if i := strings.LastIndex(p, "/media/"); u.Scheme == "" && u.Host == "" && i >= 0 && strings.HasPrefix(p, "/") {
+		p = p[i+1:]
+	}
+	if name, ok := mediaPath(p, ""); ok && u.Scheme == "" {
+		u.Path = name
+		return u.String()
+	}
+	return ref
+}
+
+// joinBlocks joins rendered blocks with blank lines
+func joinBlocks(blocks []textBlock) string {
+	parts := make([]string, len(blocks))
+	for i, b := range blocks {
+		parts[i] = b.text
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// indentBlocks indents every line of rendered blocks
+func indentBlocks(blocks []textBlock, indent string) []textBlock {
+	for i := range blocks {
+		blocks[i].text = prefixLines(blocks[i].text, indent, "")
+	}
+	return blocks
+}
+
+// prefixLines adds a prefix to every line, or emptyPrefix to empty ones
+func prefixLines(text, prefix, emptyPrefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = emptyPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// exportText exports the document as GitHub-flavored Markdown or plain
+// text. Diagrams, numbering and citations are rendered as for HTML, and
+// images refer to the document's media folder by relative path.
+func (e *Exporter) exportText(doc *document.Document, format, outputPath string, opts Options, docSvc *document.Service) (string, error) {
+	htmlContent, err := e.renderContent(doc, opts, docSvc, nil)
+	if err != nil {
+		return "", err
+	}
+	htmlContent = SelectHighResImages(RenderMath(htmlContent))
+
+	var text string
+	if format == "markdown" {
+		text = ToMarkdown(htmlContent)
+	} else {
+		text = ToPlainText(htmlContent)
+	}
+
+	if err := os.WriteFile(outputPath, []byte(text), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s file: %w", format, err)
+	}
+	return outputPath, nil
+}
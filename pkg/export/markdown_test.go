@@ -0,0 +1,67 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+const captionedTable = `<table id="t"><caption><span class="caption-label">Table 1:</span> Sales</caption>` +
+	`<thead><tr><th>Region</th><th style="text-align:right">Total</th></tr></thead>` +
+	`<tbody><tr><td>North</td><td>1|2</td></tr></tbody></table><p>See <a href="#t">Table 1</a>.</p>`
+
+func TestToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"captioned table", captionedTable,
+			"Table 1: Sales\n\n| Region | Total |\n| ------ | ----: |\n| North  | 1\\|2  |\n\nSee [Table 1](#t).\n"},
+		{"headings and escapes", `<h1>Title</h1><h2>Sub</h2><p># not a heading</p>`,
+			"# Title\n\n## Sub\n\n\\# not a heading\n"},
+		{"task list", `<ul><li>one</li><li><input type="checkbox" checked> done<ul><li>nested</li></ul></li></ul>`,
+			"- one\n- [x] done\n  - nested\n"},
+		{"ordered list", `<ol start="3"><li>three</li><li>four</li></ol>`,
+			"3. three\n4. four\n"},
+		{"code block", `<pre><code class="language-go">fmt.Println("x")</code></pre>`,
+			"```go\nfmt.Println(\"x\")\n```\n"},
+		{"inline", "<p>Use <code>a`b</code>, <strong>bold</strong>, <em>it</em> and x<sup>2</sup>.</p>",
+			"Use ``a`b``, **bold**, *it* and x<sup>2</sup>.\n"},
+		{"links and images", `<p><img src="media/a.png" alt="A"> <a href="https://x.org/a b">link</a></p><script>alert(1)</script>`,
+			"![A](media/a.png) [link](<https://x.org/a b>)\n"},
+	}
+	for _, tt := range tests {
+		if got := ToMarkdown(tt.html); got != tt.want {
+			t.Errorf("%s: ToMarkdown() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestToPlainText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"captioned table", captionedTable,
+			"Table 1: Sales\n\nRegion  Total\n------  -----\nNorth   1|2\n\nSee Table 1.\n"},
+		{"headings", `<h1>Title</h1><h2>Sub</h2><h3>Third</h3><p># kept</p>`,
+			"Title\n=====\n\nSub\n---\n\nThird\n\n# kept\n"},
+		{"code block", `<pre><code>x := 1</code></pre>`,
+			"    x := 1\n"},
+		{"links and images", `<p><img src="media/a.png" alt="A"> <a href="https://x.org/">link</a></p>`,
+			"[image: A (media/a.png)] link (https://x.org/)\n"},
+	}
+	for _, tt := range tests {
+		if got := ToPlainText(tt.html); got != tt.want {
+			t.Errorf("%s: ToPlainText() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTableCaptionHidden(t *testing.T) {
+	got := ToMarkdown(`<table><caption hidden>Secret</caption><tr><td>a</td></tr></table>`)
+	if strings.Contains(got, "Secret") {
+		t.Errorf("hidden caption written: %q", got)
+	}
+}
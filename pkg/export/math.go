@@ -0,0 +1,306 @@
+package export
+
+import (
+	"html"
+	"regexp"
+	"simple_html_docgen/pkg/mathml"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// rawTextElements hold content that is never scanned for math delimiters
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "pre": true, "code": true, "kbd": true,
+	"samp": true, "textarea": true, "title": true, "math": true, "svg": true,
+}
+
+var (
+	tagNameRegex   = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9-]*)`)
+	classAttrRegex = regexp.MustCompile(`(?i)\sclass\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	idAttrRegex    = regexp.MustCompile(`(?i)\sid\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+	// shellVarRegex matches the text between the dollars of $NAME$, which
+	// is a shell or template variable rather than math
+	shellVarRegex = regexp.MustCompile(`^[A-Z][A-Z0-9_]{3,}$`)
+)
+
+// RenderMath converts LaTeX math in an HTML document to MathML so it
+// renders without a script or CDN: in browsers, in Chrome's PDF output
+// and, because Pandoc reads MathML as native equations, in DOCX. Math is
+// recognized as $...$, $$...$$, \(...\) and \[...\] in text, and as the
+// content of elements with class "math" (display when the element is a
+// div or also has class "display"). Code, scripts and styles are left
+// alone, and expressions that fail to parse are kept as written.
+func RenderMath(htmlContent string) string {
+	if !strings.ContainsAny(htmlContent, `$\`) && !strings.Contains(htmlContent, "math") {
+		return htmlContent
+	}
+
+	var out strings.Builder
+	pos := 0
+	for pos < len(htmlContent) {
+		lt := strings.IndexByte(htmlContent[pos:], '<')
+		if lt == -1 {
+			out.WriteString(renderTextMath(htmlContent[pos:]))
+			break
+		}
+		out.WriteString(renderTextMath(htmlContent[pos : pos+lt]))
+		pos += lt
+
+		if strings.HasPrefix(htmlContent[pos:], "<!--") {
+			end := strings.Index(htmlContent[pos:], "-->")
+			if end == -1 {
+				out.WriteString(htmlContent[pos:])
+				break
+			}
+			out.WriteString(htmlContent[pos : pos+end+3])
+			pos += end + 3
+			continue
+		}
+
+		match := tagNameRegex.FindStringSubmatch(htmlContent[pos:])
+		if match == nil {
+			out.WriteByte('<')
+			pos++
+			continue
+		}
+		tagEnd := pos + tagLength(htmlContent[pos:])
+		tag := htmlContent[pos:tagEnd]
+		name := strings.ToLower(match[2])
+		closing := match[1] == "/"
+
+		if !closing && rawTextElements[name] {
+			end := closingTagEnd(htmlContent, tagEnd, name)
+			out.WriteString(htmlContent[pos:end])
+			pos = end
+			continue
+		}
+
+		if !closing && (name == "span" || name == "div") && hasClass(tag, "math") {
+			end := closingTagEnd(htmlContent, tagEnd, name)
+			closeStart := strings.LastIndex(htmlContent[:end], "</")
+			if closeStart >= tagEnd {
+				display := name == "div" || hasClass(tag, "display")
+				if rendered, ok := renderMathElement(tag, htmlContent[tagEnd:closeStart], display); ok {
+					out.WriteString(rendered)
+					pos = end
+					continue
+				}
+			}
+		}
+
+		out.WriteString(tag)
+		pos = tagEnd
+	}
+
+	return out.String()
+}
+
+// renderMathElement converts the LaTeX content of a class="math" element,
+// stripping any delimiters and carrying over the element's id. Elements
+// containing markup (such as MathML already) are left alone.
+func renderMathElement(tag, content string, display bool) (string, bool) {
+	if strings.Contains(content, "<") {
+		return "", false
+	}
+	tex := strings.TrimSpace(html.UnescapeString(content))
+	for _, delims := range [][2]string{{`\(`, `\)`}, {`\[`, `\]`}, {"$$", "$$"}, {"$", "$"}} {
+		if len(tex) >= len(delims[0])+len(delims[1]) && strings.HasPrefix(tex, delims[0]) && strings.HasSuffix(tex, delims[1]) {
+			tex = tex[len(delims[0]) : len(tex)-len(delims[1])]
+			if delims[0] == `\[` || delims[0] == "$$" {
+				display = true
+			}
+			break
+		}
+	}
+
+	rendered, err := mathml.Convert(tex, display)
+	if err != nil {
+		return "", false
+	}
+	if m := idAttrRegex.FindStringSubmatch(tag); m != nil {
+		id := m[1] + m[2]
+		rendered = strings.Replace(rendered, "<math ", `<math id="`+html.EscapeString(id)+`" `, 1)
+	}
+	return rendered, true
+}
+
+// renderTextMath converts delimited math in a run of HTML text
+func renderTextMath(text string) string {
+	if !strings.ContainsAny(text, `$\`) {
+		return text
+	}
+
+	var out strings.Builder
+	i := 0
+	for i < len(text) {
+		var open, close string
+		display := false
+		switch {
+		case strings.HasPrefix(text[i:], `\$`):
+			out.WriteByte('$')
+			i += 2
+			continue
+		case strings.HasPrefix(text[i:], "$$"):
+			open, close, display = "$$", "$$", true
+		case strings.HasPrefix(text[i:], `\[`):
+			open, close, display = `\[`, `\]`, true
+		case strings.HasPrefix(text[i:], `\(`):
+			open, close = `\(`, `\)`
+		case text[i] == '$' && !endsWithAlnum(text[:i]):
+			open, close = "$", "$"
+		default:
+			out.WriteByte(text[i])
+			i++
+			continue
+		}
+
+		start := i + len(open)
+		end := findClosingDelimiter(text, start, close)
+		if end == -1 {
+			out.WriteString(open)
+			i = start
+			continue
+		}
+
+		source := text[i : end+len(close)]
+		rendered, err := mathml.Convert(html.UnescapeString(text[start:end]), display)
+		if err != nil {
+			out.WriteString(source)
+		} else {
+			out.WriteString(rendered)
+		}
+		i = end + len(close)
+	}
+
+	return out.String()
+}
+
+// findClosingDelimiter finds the end of a math span. Single dollars follow
+// Pandoc's rules so prices like "$5 and $10" are not taken as math: the
+// opening $ must be followed by a non-space and not preceded by a letter
+// or digit (checked by the caller), the closing $ preceded by a non-space
+// and not followed by a letter or digit, and the span may not cross a
+// blank line. In addition, a $ directly followed by a digit only opens math
+// that contains no spaces ($2x$), as it is far more often a price, and
+// $NAME$ followed by a space or the end of the text is a shell variable.
+func findClosingDelimiter(text string, start int, close string) int {
+	if close == "$" && (start >= len(text) || isSpaceByte(text[start])) {
+		return -1
+	}
+	currency := close == "$" && text[start] >= '0' && text[start] <= '9'
+
+	for j := start; j < len(text); j++ {
+		if text[j] == '\\' && close == "$" {
+			j++
+			continue
+		}
+		if close == "$" && strings.HasPrefix(text[j:], "\n\n") {
+			return -1
+		}
+		if currency && isSpaceByte(text[j]) {
+			return -1
+		}
+		if !strings.HasPrefix(text[j:], close) || j == start {
+			continue
+		}
+		if close == "$" {
+			if isSpaceByte(text[j-1]) || startsWithAlnum(text[j+1:]) {
+				continue
+			}
+			if shellVarRegex.MatchString(text[start:j]) && (j+1 == len(text) || isSpaceByte(text[j+1])) {
+				return -1
+			}
+		}
+		return j
+	}
+	return -1
+}
+
+// tagLength returns the length of the tag at the start of s, honoring
+// quoted attribute values that contain '>'
+func tagLength(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// closingTagEnd returns the position just after the tag closing an element
+// opened before pos, counting nested elements of the same name
+func closingTagEnd(htmlContent string, pos int, name string) int {
+	depth := 1
+	for pos < len(htmlContent) {
+		next := strings.IndexByte(htmlContent[pos:], '<')
+		if next == -1 {
+			break
+		}
+		pos += next
+		switch {
+		case hasPrefixFold(htmlContent[pos:], "</"+name) && isTagBoundary(htmlContent, pos+2+len(name)):
+			depth--
+			end := pos + tagLength(htmlContent[pos:])
+			if depth == 0 {
+				return end
+			}
+			pos = end
+		case hasPrefixFold(htmlContent[pos:], "<"+name) && isTagBoundary(htmlContent, pos+1+len(name)):
+			depth++
+			pos += tagLength(htmlContent[pos:])
+		default:
+			pos++
+		}
+	}
+	return len(htmlContent)
+}
+
+// hasPrefixFold reports whether s begins with prefix, ignoring ASCII case
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// isTagBoundary reports whether a tag name ends at position i
+func isTagBoundary(s string, i int) bool {
+	return i >= len(s) || s[i] == '>' || s[i] == '/' || isSpaceByte(s[i])
+}
+
+// hasClass reports whether a tag's class attribute contains the given class
+func hasClass(tag, class string) bool {
+	m := classAttrRegex.FindStringSubmatch(tag)
+	if m == nil {
+		return false
+	}
+	for _, c := range strings.Fields(m[1] + m[2]) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// startsWithAlnum reports whether s starts with a letter or digit
+func startsWithAlnum(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// endsWithAlnum reports whether s ends with a letter or digit
+func endsWithAlnum(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,38 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMathDollars(t *testing.T) {
+	tests := []struct {
+		text string
+		math []string // TeX sources expected as math, in order
+	}{
+		{"Let $x^2$ be", []string{"x^2"}},
+		{"$$E = mc^2$$", []string{"E = mc^2"}},
+		{`\(a\) and \[b\]`, []string{"a", "b"}},
+		{"It costs $5 and $10.", nil},
+		{"From $2x$ on", []string{"2x"}},
+		{"Run echo $USER$", nil},
+		{"Run echo $USER$ now", nil},
+		{"Paths: $HOME$ and $x$.", []string{"x"}},
+		{"The triangle $ABC$ is right-angled", []string{"ABC"}},
+		{"a$x$ and $y$b", nil},
+		{"$x$, then $y$.", []string{"x", "y"}},
+		{"Escaped \\$x\\$", nil},
+		{"$ x$ and $x $", nil},
+		{"$x\n\ny$", nil},
+	}
+	for _, tt := range tests {
+		got := RenderMath("<p>" + tt.text + "</p>")
+		var found []string
+		for _, part := range strings.Split(got, `<annotation encoding="application/x-tex">`)[1:] {
+			found = append(found, part[:strings.Index(part, "</annotation>")])
+		}
+		if strings.Join(found, "|") != strings.Join(tt.math, "|") {
+			t.Errorf("RenderMath(%q) rendered %q, want %q:\n%s", tt.text, found, tt.math, got)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default words used in captions and cross-references
+const (
+	DefaultFigureLabel  = "Figure"
+	DefaultTableLabel   = "Table"
+	DefaultSectionLabel = "Section"
+)
+
+var (
+	// numberLabelRegex matches the labels added by a previous numbering pass
+	numberLabelRegex = regexp.MustCompile(`<span class="(caption-label|heading-number)" data-numbering>([^<]*)</span> ?`)
+	figcaptionRegex  = regexp.MustCompile(`(?i)<(/?)(figure|figcaption)\b`)
+	mediaTagRegex    = regexp.MustCompile(`(?i)<(img|svg|picture|video|canvas|object|iframe)\b`)
+	refLinkRegex     = regexp.MustCompile(`(?is)<a\b([^>]*\sdata-ref\b[^>]*)>(.*?)</a>`)
+	anyTagRegex      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// NumberingOptions controls which elements are numbered
+type NumberingOptions struct {
+	Figures      bool   // Number captioned figures and tables
+	AutoFigures  bool   // Number them only when the document has data-ref links or earlier caption labels
+	Headings     bool   // Number headings as 1, 1.1, 1.1.1, ...
+	FigureLabel  string // "" uses DefaultFigureLabel
+	TableLabel   string // "" uses DefaultTableLabel
+	SectionLabel string // "" uses DefaultSectionLabel
+}
+
+// NumberingResult summarizes a numbering pass
+type NumberingResult struct {
+	Figures    int      `json:"figures"`              // Figures numbered
+	Tables     int      `json:"tables"`               // Tables numbered
+	Headings   int      `json:"headings"`             // Headings numbered
+	References int      `json:"references"`           // Cross-references resolved
+	Unresolved []string `json:"unresolved,omitempty"` // Targets of cross-references that were not found
+}
+
+// refTarget is the text a cross-reference to an element resolves to
+type refTarget struct {
+	text   string // e.g. "Figure 3", as HTML
+	number string // e.g. "3"; empty for unnumbered headings
+}
+
+// insertion is text added at pos, replacing the skip bytes after it
+type insertion struct {
+	pos  int
+	text string
+	skip int
+}
+
+// ApplyNumbering numbers the captions of figures and tables and,
+// optionally, the headings of an HTML document, then fills in links marked
+// with data-ref: <a href="#fig-x" data-ref></a> becomes "Figure 3", or just
+// "3" with data-ref="number". A figure holding a table and no image counts
+// as a table. Headings with class "unnumbered" are skipped, and a single h1
+// is taken as the title so numbering starts at h2. Labels are plain text
+// in the content, so every export format shows the same numbers, and
+// labels from an earlier pass are replaced, so running it again is safe.
+// Elements not being numbered keep any earlier labels and can still be
+// referenced; a heading without a number is referenced by its text.
+func ApplyNumbering(htmlContent string, opts NumberingOptions) (string, *NumberingResult) {
+	result := &NumberingResult{}
+	if opts.AutoFigures {
+		opts.Figures = strings.Contains(htmlContent, "data-ref") || strings.Contains(htmlContent, `class="caption-label"`)
+	}
+	if !opts.Figures && !opts.Headings && !strings.Contains(htmlContent, "data-ref") {
+		return htmlContent, result
+	}
+
+	figureLabel := html.EscapeString(defaultString(opts.FigureLabel, DefaultFigureLabel))
+	tableLabel := html.EscapeString(defaultString(opts.TableLabel, DefaultTableLabel))
+	sectionLabel := html.EscapeString(defaultString(opts.SectionLabel, DefaultSectionLabel))
+
+	// Captions may start with a label written by hand, such as "Figure 7:",
+	// "Fig. 7." or "Table 2:", possibly in bold; it is replaced by the new one
+	handLabelRegex := regexp.MustCompile(`^(\s*(?:<(?:b|strong|em|i)>\s*)?)((?i:figure|fig\.?|table|` +
+		regexp.QuoteMeta(figureLabel) + `|` + regexp.QuoteMeta(tableLabel) + `)\s+\d+(?:\.\d+)*\s*[:.])(\s*</(?:b|strong|em|i)>)?\s*`)
+
+	htmlContent = numberLabelRegex.ReplaceAllStringFunc(htmlContent, func(label string) string {
+		isCaption := strings.Contains(label, "caption-label")
+		if (isCaption && opts.Figures) || (!isCaption && opts.Headings) {
+			return ""
+		}
+		return label
+	})
+
+	targets := make(map[string]refTarget)
+	var inserts []insertion
+	addTarget := func(tag string, target refTarget) {
+		if id := tagID(tag); id != "" {
+			targets[id] = target
+		}
+	}
+
+	// numberCaption labels the caption opened just before pos, or reads the
+	// label left by an earlier pass when captions are not being numbered
+	numberCaption := func(pos int, label string, count *int) (refTarget, bool) {
+		if opts.Figures {
+			*count++
+			number := strconv.Itoa(*count)
+			inserts = append(inserts, insertion{pos: pos, text: fmt.Sprintf(`<span class="caption-label" data-numbering>%s %s:</span> `, label, number)})
+			// "Figure 7.5 shows ..." is text, not the label "Figure 7."
+			if m := handLabelRegex.FindStringSubmatchIndex(htmlContent[pos:]); m != nil && !startsWithDigit(htmlContent[pos+m[5]:]) {
+				if m[6] != -1 || strings.TrimSpace(htmlContent[pos:pos+m[3]]) == "" {
+					// The whole label, with any tags around it
+					inserts = append(inserts, insertion{pos: pos, skip: m[1]})
+				} else {
+					// Only the label text; the tag it opens stays
+					inserts = append(inserts, insertion{pos: pos + m[4], skip: m[1] - m[4]})
+				}
+			}
+			return refTarget{label + " " + number, number}, true
+		}
+		return existingLabel(htmlContent[pos:], "caption-label")
+	}
+
+	headingStart := 1
+	if strings.Count(strings.ToLower(htmlContent), "<h1") == 1 {
+		headingStart = 2
+	}
+	var counters [7]int
+
+	tableFigureEnd := 0
+	var tableFigure *refTarget
+
+	pos := 0
+	for pos < len(htmlContent) {
+		lt := strings.IndexByte(htmlContent[pos:], '<')
+		if lt == -1 {
+			break
+		}
+		pos += lt
+
+		if strings.HasPrefix(htmlContent[pos:], "<!--") {
+			end := strings.Index(htmlContent[pos:], "-->")
+			if end == -1 {
+				break
+			}
+			pos += end + 3
+			continue
+		}
+
+		match := tagNameRegex.FindStringSubmatch(htmlContent[pos:])
+		if match == nil || match[1] == "/" {
+			pos++
+			continue
+		}
+		tagEnd := pos + tagLength(htmlContent[pos:])
+		tag := htmlContent[pos:tagEnd]
+		name := strings.ToLower(match[2])
+
+		switch {
+		case rawTextElements[name]:
+			tagEnd = closingTagEnd(htmlContent, tagEnd, name)
+
+		case name == "figure":
+			end := closingTagEnd(htmlContent, tagEnd, name)
+			inner := htmlContent[tagEnd:end]
+			isTable := strings.Contains(strings.ToLower(inner), "<table") && !mediaTagRegex.MatchString(inner)
+			captionPos := figureCaption(inner)
+			if captionPos == -1 {
+				break
+			}
+			label, count := figureLabel, &result.Figures
+			if isTable {
+				label, count = tableLabel, &result.Tables
+			}
+			target, ok := numberCaption(tagEnd+captionPos, label, count)
+			if !ok {
+				break
+			}
+			addTarget(tag, target)
+			if isTable {
+				// Tables inside share the figure's number
+				tableFigureEnd, tableFigure = end, &target
+			}
+
+		case name == "table":
+			if pos < tableFigureEnd {
+				addTarget(tag, *tableFigure)
+				break
+			}
+			rest := strings.TrimLeft(htmlContent[tagEnd:], " \t\r\n")
+			if !hasPrefixFold(rest, "<caption") || !isTagBoundary(rest, len("<caption")) {
+				break
+			}
+			captionPos := len(htmlContent) - len(rest)
+			captionPos += tagLength(htmlContent[captionPos:])
+			if target, ok := numberCaption(captionPos, tableLabel, &result.Tables); ok {
+				addTarget(tag, target)
+			}
+
+		case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+			level := int(name[1] - '0')
+			numbered := opts.Headings && level >= headingStart && !hasClass(tag, "unnumbered")
+			if numbered {
+				counters[level]++
+				for i := level + 1; i < len(counters); i++ {
+					counters[i] = 0
+				}
+				parts := make([]string, 0, level-headingStart+1)
+				for i := headingStart; i <= level; i++ {
+					parts = append(parts, strconv.Itoa(counters[i]))
+				}
+				number := strings.Join(parts, ".")
+				inserts = append(inserts, insertion{pos: tagEnd, text: fmt.Sprintf(`<span class="heading-number" data-numbering>%s</span> `, number)})
+				addTarget(tag, refTarget{sectionLabel + " " + number, number})
+				result.Headings++
+				break
+			}
+			if target, ok := existingLabel(htmlContent[tagEnd:], "heading-number"); ok {
+				addTarget(tag, refTarget{sectionLabel + " " + target.text, target.text})
+				break
+			}
+			end := closingTagEnd(htmlContent, tagEnd, name)
+			text := strings.TrimSpace(anyTagRegex.ReplaceAllString(htmlContent[tagEnd:end], ""))
+			addTarget(tag, refTarget{text: text})
+		}
+		pos = tagEnd
+	}
+
+	if len(inserts) > 0 {
+		sort.SliceStable(inserts, func(i, j int) bool { return inserts[i].pos < inserts[j].pos })
+		var out strings.Builder
+		last := 0
+		for _, ins := range inserts {
+			out.WriteString(htmlContent[last:ins.pos])
+			out.WriteString(ins.text)
+			last = ins.pos + ins.skip
+		}
+		out.WriteString(htmlContent[last:])
+		htmlContent = out.String()
+	}
+
+	htmlContent = refLinkRegex.ReplaceAllStringFunc(htmlContent, func(link string) string {
+		m := refLinkRegex.FindStringSubmatch(link)
+		href := attrValue(m[1], "href")
+		if !strings.HasPrefix(href, "#") {
+			return link
+		}
+		text := "??"
+		if target, ok := targets[href[1:]]; ok {
+			text = target.text
+			if attrValue(m[1], "data-ref") == "number" && target.number != "" {
+				text = target.number
+			}
+			result.References++
+		} else {
+			result.Unresolved = append(result.Unresolved, href[1:])
+		}
+		return "<a" + m[1] + ">" + text + "</a>"
+	})
+
+	return htmlContent, result
+}
+
+// figureCaption returns the position just after the opening tag of a
+// figure's own figcaption, skipping those of nested figures, or -1
+func figureCaption(inner string) int {
+	depth := 0
+	for _, m := range figcaptionRegex.FindAllStringSubmatchIndex(inner, -1) {
+		closing := m[3] > m[2]
+		name := strings.ToLower(inner[m[4]:m[5]])
+		switch {
+		case name == "figure" && closing:
+			depth--
+		case name == "figure":
+			depth++
+		case !closing && depth == 0:
+			return m[0] + tagLength(inner[m[0]:])
+		}
+	}
+	return -1
+}
+
+// existingLabel reads a label left by an earlier numbering pass at the
+// start of s. For captions the text is "Figure 3"; for headings it is the
+// number alone.
+func existingLabel(s, class string) (refTarget, bool) {
+	m := numberLabelRegex.FindStringSubmatchIndex(s)
+	if m == nil || m[0] != 0 || s[m[2]:m[3]] != class {
+		return refTarget{}, false
+	}
+	text := strings.TrimSuffix(s[m[4]:m[5]], ":")
+	number := text
+	if i := strings.LastIndexByte(text, ' '); i != -1 {
+		number = text[i+1:]
+	}
+	return refTarget{text, number}, true
+}
+
+// startsWithDigit reports whether s starts with an ASCII digit
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// tagID returns the id attribute of a tag
+func tagID(tag string) string {
+	m := idAttrRegex.FindStringSubmatch(tag)
+	if m == nil {
+		return ""
+	}
+	return html.UnescapeString(m[1] + m[2])
+}
+
+// defaultString returns s, or def when s is empty
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
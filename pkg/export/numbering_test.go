@@ -0,0 +1,91 @@
+package export
+
+import "testing"
+
+const (
+	figureImg = `<figure><img src="a.png"><figcaption>`
+	endFigure = `</figcaption></figure>`
+)
+
+func label(text string) string {
+	return `<span class="caption-label" data-numbering>` + text + `</span> `
+}
+
+func TestApplyNumbering(t *testing.T) {
+	all := NumberingOptions{Figures: true, Headings: true}
+	tests := []struct {
+		name string
+		html string
+		opts NumberingOptions
+		want string
+	}{
+		{"figures and tables",
+			figureImg + `Arch` + endFigure + `<table><caption>Sales</caption><tr><td>1</td></tr></table>` +
+				`<figure><table><tr><td>1</td></tr></table><figcaption>Costs</figcaption></figure>`,
+			all,
+			figureImg + label("Figure 1:") + `Arch` + endFigure + `<table><caption>` + label("Table 1:") + `Sales</caption><tr><td>1</td></tr></table>` +
+				`<figure><table><tr><td>1</td></tr></table><figcaption>` + label("Table 2:") + `Costs</figcaption></figure>`},
+		{"references",
+			`<figure id="f"><img src="a.png"><figcaption>Arch</figcaption></figure><p><a href="#f" data-ref></a>, <a href="#f" data-ref="number"></a>, <a href="#x" data-ref></a></p>`,
+			all,
+			`<figure id="f"><img src="a.png"><figcaption>` + label("Figure 1:") + `Arch</figcaption></figure><p><a href="#f" data-ref>Figure 1</a>, <a href="#f" data-ref="number">1</a>, <a href="#x" data-ref>??</a></p>`},
+		{"headings",
+			`<h1>Title</h1><h2 id="s">Intro</h2><h2 class="unnumbered">Preface</h2><h3 id="d">Detail</h3><p><a href="#d" data-ref></a></p>`,
+			all,
+			`<h1>Title</h1><h2 id="s"><span class="heading-number" data-numbering>1</span> Intro</h2><h2 class="unnumbered">Preface</h2>` +
+				`<h3 id="d"><span class="heading-number" data-numbering>1.1</span> Detail</h3><p><a href="#d" data-ref>Section 1.1</a></p>`},
+		{"unnumbered heading reference",
+			`<h2 id="s">Intro</h2><p><a href="#s" data-ref></a></p>`,
+			NumberingOptions{},
+			`<h2 id="s">Intro</h2><p><a href="#s" data-ref>Intro</a></p>`},
+		{"custom labels",
+			figureImg + `Arch` + endFigure,
+			NumberingOptions{Figures: true, FigureLabel: "Abbildung"},
+			figureImg + label("Abbildung 1:") + `Arch` + endFigure},
+		{"hand-written label",
+			figureImg + `Figure 7: Already labelled` + endFigure,
+			all,
+			figureImg + label("Figure 1:") + `Already labelled` + endFigure},
+		{"hand-written labels in other forms",
+			figureImg + `<strong>Fig. 4.</strong> Bold` + endFigure + figureImg + `<b>Figure 8: all bold</b>` + endFigure +
+				`<table><caption> table 3.2: Sales</caption><tr><td>1</td></tr></table>`,
+			all,
+			figureImg + label("Figure 1:") + `Bold` + endFigure + figureImg + label("Figure 2:") + `<b>all bold</b>` + endFigure +
+				`<table><caption>` + label("Table 1:") + `Sales</caption><tr><td>1</td></tr></table>`},
+		{"caption text that is not a label",
+			figureImg + `Figure 7.5 shows the flow` + endFigure,
+			all,
+			figureImg + label("Figure 1:") + `Figure 7.5 shows the flow` + endFigure},
+		{"auto without references",
+			figureImg + `Figure 1: Arch` + endFigure,
+			NumberingOptions{AutoFigures: true},
+			figureImg + `Figure 1: Arch` + endFigure},
+		{"auto with references",
+			`<figure id="f"><img src="a.png"><figcaption>Arch</figcaption></figure><a href="#f" data-ref></a>`,
+			NumberingOptions{AutoFigures: true},
+			`<figure id="f"><img src="a.png"><figcaption>` + label("Figure 1:") + `Arch</figcaption></figure><a href="#f" data-ref>Figure 1</a>`},
+		{"earlier labels kept when not numbering",
+			`<figure id="f"><img src="a.png"><figcaption>` + label("Figure 4:") + `Arch</figcaption></figure><a href="#f" data-ref></a>`,
+			NumberingOptions{},
+			`<figure id="f"><img src="a.png"><figcaption>` + label("Figure 4:") + `Arch</figcaption></figure><a href="#f" data-ref>Figure 4</a>`},
+	}
+	for _, tt := range tests {
+		got, _ := ApplyNumbering(tt.html, tt.opts)
+		if got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+		// A second pass changes nothing
+		if again, _ := ApplyNumbering(got, tt.opts); again != got {
+			t.Errorf("%s: second pass changed the output:\n%s", tt.name, again)
+		}
+	}
+}
+
+func TestApplyNumberingResult(t *testing.T) {
+	html := figureImg + `A` + endFigure + figureImg + `B` + endFigure + `<table><caption>C</caption></table><h2>D</h2><a href="#nowhere" data-ref></a>`
+	_, result := ApplyNumbering(html, NumberingOptions{Figures: true, Headings: true})
+	if result.Figures != 2 || result.Tables != 1 || result.Headings != 1 || result.References != 0 ||
+		len(result.Unresolved) != 1 || result.Unresolved[0] != "nowhere" {
+		t.Errorf("result = %+v", result)
+	}
+}
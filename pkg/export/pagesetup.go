@@ -0,0 +1,373 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"simple_html_docgen/pkg/document"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// PaperSizes lists the named paper sizes as width and height in inches,
+// portrait
+var PaperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"a3":      {297 / 25.4, 420 / 25.4},
+	"a4":      {210 / 25.4, 297 / 25.4},
+	"a5":      {148 / 25.4, 210 / 25.4},
+}
+
+const (
+	defaultMargin       = 0.4  // Margin in inches when none is given
+	headerFooterMargin  = 0.75 // Margin in inches above a header or below a footer when none is given
+	headerFooterFontCSS = "font-family: sans-serif; font-size: 9px; color: #444;"
+)
+
+var (
+	lengthRegex        = regexp.MustCompile(`^\s*([0-9]*\.?[0-9]+)\s*(in|cm|mm|pt|px)?\s*$`)
+	pageRangesRegex    = regexp.MustCompile(`^\s*\d+(\s*-\s*\d+)?(\s*,\s*\d+(\s*-\s*\d+)?)*\s*$`)
+	chromeFieldRegex   = regexp.MustCompile(`<span\s+class\s*=\s*["']?(pageNumber|totalPages|title|date)["']?\s*>\s*</span>`)
+	placeholderRegex   = regexp.MustCompile(`\{(page|pages|title|date)\}`)
+	titleElementRegex  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	placeholderClasses = map[string]string{"page": "pageNumber", "pages": "totalPages", "title": "title", "date": "date"}
+)
+
+// PageSetup controls the page layout of PDF exports. The zero value keeps
+// the defaults: the paper size from the document's CSS @page rule or
+// Letter, portrait, 0.4in margins and no header or footer.
+type PageSetup struct {
+	PaperSize    string  // letter, legal, tabloid, a3, a4, a5 or custom
+	Width        string  // Paper width for custom size, e.g. "210mm" or "8.5in"
+	Height       string  // Paper height for custom size
+	Landscape    bool    // Landscape orientation
+	Margin       string  // All four margins, e.g. "2cm"; overridden per side below
+	MarginTop    string  // Top margin
+	MarginRight  string  // Right margin
+	MarginBottom string  // Bottom margin
+	MarginLeft   string  // Left margin
+	Scale        float64 // Rendering scale from 0.1 to 2; 0 means 1
+	PageRanges   string  // Pages to include, e.g. "1-5, 8"; "" for all
+	Background   bool    // Print background colors and images
+
+	// Header and footer HTML, with {page}, {pages}, {title} and {date}
+	// placeholders (Chrome's pageNumber, totalPages, title and date
+	// classes also work)
+	HeaderTemplate string
+	FooterTemplate string
+}
+
+// pageLayout is a PageSetup resolved to inches
+type pageLayout struct {
+	width, height            float64 // 0 when no paper size was given
+	top, right, bottom, left float64
+	marginsSet               bool
+	scale                    float64
+}
+
+// Validate checks the page setup values
+func (s PageSetup) Validate() error {
+	_, err := s.layout()
+	return err
+}
+
+// layout resolves the paper size and margins to inches
+func (s PageSetup) layout() (*pageLayout, error) {
+	l := &pageLayout{scale: s.Scale}
+
+	switch size := strings.ToLower(s.PaperSize); size {
+	case "":
+		if s.Width != "" || s.Height != "" {
+			return nil, fmt.Errorf("width and height require paper_size custom")
+		}
+	case "custom":
+		if s.Width == "" || s.Height == "" {
+			return nil, fmt.Errorf("paper_size custom requires width and height")
+		}
+		var err error
+		if l.width, err = parseLength(s.Width); err != nil {
+			return nil, fmt.Errorf("invalid width: %w", err)
+		}
+		if l.height, err = parseLength(s.Height); err != nil {
+			return nil, fmt.Errorf("invalid height: %w", err)
+		}
+		if l.width == 0 || l.height == 0 {
+			return nil, fmt.Errorf("paper width and height must be positive")
+		}
+	default:
+		dims, ok := PaperSizes[size]
+		if !ok {
+			return nil, fmt.Errorf("invalid paper_size: %s (must be one of %s or custom)", s.PaperSize, strings.Join(PaperSizeNames(), ", "))
+		}
+		l.width, l.height = dims[0], dims[1]
+	}
+	if s.Landscape && l.width > 0 {
+		l.width, l.height = l.height, l.width
+	}
+
+	all := defaultMargin
+	if s.Margin != "" {
+		m, err := parseLength(s.Margin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid margin: %w", err)
+		}
+		all = m
+		l.marginsSet = true
+	}
+	l.top, l.right, l.bottom, l.left = all, all, all, all
+	if s.Margin == "" {
+		// Leave room for the header and footer unless margins are given
+		if s.HeaderTemplate != "" && s.MarginTop == "" {
+			l.top = headerFooterMargin
+		}
+		if s.FooterTemplate != "" && s.MarginBottom == "" {
+			l.bottom = headerFooterMargin
+		}
+	}
+	for _, side := range []struct {
+		name  string
+		value string
+		dest  *float64
+	}{
+		{"margin_top", s.MarginTop, &l.top},
+		{"margin_right", s.MarginRight, &l.right},
+		{"margin_bottom", s.MarginBottom, &l.bottom},
+		{"margin_left", s.MarginLeft, &l.left},
+	} {
+		if side.value == "" {
+			continue
+		}
+		m, err := parseLength(side.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", side.name, err)
+		}
+		*side.dest = m
+		l.marginsSet = true
+	}
+	if l.width > 0 && (l.left+l.right >= l.width || l.top+l.bottom >= l.height) {
+		return nil, fmt.Errorf("margins leave no room on the page")
+	}
+
+	if s.Scale != 0 && (s.Scale < 0.1 || s.Scale > 2) {
+		return nil, fmt.Errorf("invalid scale: %g (must be between 0.1 and 2)", s.Scale)
+	}
+	if s.PageRanges != "" && !pageRangesRegex.MatchString(s.PageRanges) {
+		return nil, fmt.Errorf("invalid page_ranges: %s (use e.g. \"1-5, 8\")", s.PageRanges)
+	}
+	return l, nil
+}
+
+// PaperSizeNames returns the named paper sizes in sorted order
+func PaperSizeNames() []string {
+	names := make([]string, 0, len(PaperSizes))
+	for name := range PaperSizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseLength converts a CSS-style length to inches. A bare number is in
+// inches.
+func parseLength(s string) (float64, error) {
+	m := lengthRegex.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return 0, fmt.Errorf("%q is not a length (use e.g. 0.5in, 2cm, 15mm, 36pt or 48px)", s)
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a length", s)
+	}
+	switch m[2] {
+	case "cm":
+		v /= 2.54
+	case "mm":
+		v /= 25.4
+	case "pt":
+		v /= 72
+	case "px":
+		v /= 96
+	}
+	return v, nil
+}
+
+// chromePDFParams returns Chrome's print parameters for a page setup
+func (s PageSetup) chromePDFParams() (*proto.PagePrintToPDF, error) {
+	l, err := s.layout()
+	if err != nil {
+		return nil, err
+	}
+
+	params := &proto.PagePrintToPDF{
+		Landscape:       s.Landscape,
+		PrintBackground: s.Background,
+		MarginTop:       &l.top,
+		MarginBottom:    &l.bottom,
+		MarginLeft:      &l.left,
+		MarginRight:     &l.right,
+		PageRanges:      strings.TrimSpace(s.PageRanges),
+		// Without an explicit size, use CSS @page size if specified
+		PreferCSSPageSize: l.width == 0,
+	}
+	if l.width > 0 {
+		// The layout is already rotated for landscape
+		params.Landscape = false
+		params.PaperWidth = &l.width
+		params.PaperHeight = &l.height
+	}
+	if l.scale != 0 {
+		params.Scale = &l.scale
+	}
+	if s.HeaderTemplate != "" || s.FooterTemplate != "" {
+		params.DisplayHeaderFooter = true
+		params.HeaderTemplate = chromeTemplate(s.HeaderTemplate, l)
+		params.FooterTemplate = chromeTemplate(s.FooterTemplate, l)
+	}
+	return params, nil
+}
+
+// chromeTemplate turns a header or footer template into Chrome's form:
+// placeholders become the classes Chrome fills in, and the template is
+// given a readable font size and the page's side margins. Chrome shows
+// its own default header or footer for an empty template, so an empty
+// span is used instead.
+func chromeTemplate(template string, l *pageLayout) string {
+	if template == "" {
+		return "<span></span>"
+	}
+	template = placeholderRegex.ReplaceAllStringFunc(template, func(p string) string {
+		return fmt.Sprintf(`<span class="%s"></span>`, placeholderClasses[p[1:len(p)-1]])
+	})
+	return fmt.Sprintf(`<div style="width: 100%%; box-sizing: border-box; padding: 0 %.3fin 0 %.3fin; text-align: center; %s">%s</div>`,
+		l.left, l.right, headerFooterFontCSS, template)
+}
+
+// pageMarginStyles returns a style block setting the @page margins, so
+// explicit margins, and the room left for a header or footer, win over
+// margins in the print styles. It returns "" when neither was given.
+func (s PageSetup) pageMarginStyles() string {
+	l, err := s.layout()
+	if err != nil || (!l.marginsSet && s.HeaderTemplate == "" && s.FooterTemplate == "") {
+		return ""
+	}
+	return fmt.Sprintf(`<style id="page-setup">@page { margin: %.3fin %.3fin %.3fin %.3fin; }</style>`, l.top, l.right, l.bottom, l.left)
+}
+
+// pandocPDFArgs maps a page setup to LaTeX variables for Pandoc's PDF
+// output: paper size, orientation and margins through geometry, and the
+// header and footer through fancyhdr. Scale and page ranges have no
+// equivalent and are ignored.
+func (s PageSetup) pandocPDFArgs(title string) []string {
+	l, err := s.layout()
+	if err != nil {
+		return nil
+	}
+
+	var args []string
+	if l.width > 0 {
+		args = append(args,
+			"-V", fmt.Sprintf("geometry:paperwidth=%.3fin", l.width),
+			"-V", fmt.Sprintf("geometry:paperheight=%.3fin", l.height))
+	} else if s.Landscape {
+		args = append(args, "-V", "geometry:landscape")
+	}
+	if l.marginsSet || l.width > 0 || s.HeaderTemplate != "" || s.FooterTemplate != "" {
+		args = append(args,
+			"-V", fmt.Sprintf("geometry:top=%.3fin", l.top),
+			"-V", fmt.Sprintf("geometry:bottom=%.3fin", l.bottom),
+			"-V", fmt.Sprintf("geometry:left=%.3fin", l.left),
+			"-V", fmt.Sprintf("geometry:right=%.3fin", l.right))
+	}
+
+	if s.HeaderTemplate != "" || s.FooterTemplate != "" {
+		footer := `\thepage`
+		if s.FooterTemplate != "" {
+			footer = latexTemplate(s.FooterTemplate, title)
+		}
+		args = append(args, "-V", `header-includes=\usepackage{fancyhdr}\usepackage{lastpage}\pagestyle{fancy}\fancyhf{}`+
+			`\renewcommand{\headrulewidth}{0pt}`+
+			`\fancyhead[C]{\small `+latexTemplate(s.HeaderTemplate, title)+`}`+
+			`\fancyfoot[C]{\small `+footer+`}`)
+	}
+	return args
+}
+
+// latexTemplate converts a header or footer template to LaTeX: markup is
+// dropped, text is escaped and placeholders become page counters
+func latexTemplate(template, title string) string {
+	template = chromeFieldRegex.ReplaceAllStringFunc(template, func(span string) string {
+		class := chromeFieldRegex.FindStringSubmatch(span)[1]
+		for p, c := range placeholderClasses {
+			if c == class {
+				return "{" + p + "}"
+			}
+		}
+		return ""
+	})
+	text := html.UnescapeString(anyTagRegex.ReplaceAllString(template, ""))
+
+	var out strings.Builder
+	last := 0
+	for _, m := range placeholderRegex.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(escapeLaTeX(text[last:m[0]]))
+		switch text[m[2]:m[3]] {
+		case "page":
+			out.WriteString(`\thepage{}`)
+		case "pages":
+			out.WriteString(`\pageref*{LastPage}`)
+		case "title":
+			out.WriteString(escapeLaTeX(title))
+		case "date":
+			out.WriteString(`\today{}`)
+		}
+		last = m[1]
+	}
+	out.WriteString(escapeLaTeX(text[last:]))
+	return strings.Join(strings.Fields(out.String()), " ")
+}
+
+// escapeLaTeX escapes LaTeX special characters in text
+func escapeLaTeX(s string) string {
+	var out strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			out.WriteString(`\textbackslash{}`)
+		case '{', '}', '$', '&', '#', '%', '_':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case '~':
+			out.WriteString(`\textasciitilde{}`)
+		case '^':
+			out.WriteString(`\textasciicircum{}`)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// documentTitle returns the content of the document's <title> element, or
+// fallback if it has none
+func documentTitle(htmlContent, fallback string) string {
+	if m := titleElementRegex.FindStringSubmatch(htmlContent); m != nil {
+		if title := strings.TrimSpace(html.UnescapeString(m[1])); title != "" {
+			return title
+		}
+	}
+	return fallback
+}
+
+// ensureTitle gives the document a <title>, which Chrome shows for the
+// {title} placeholder in headers and footers
+func ensureTitle(htmlContent, title string) string {
+	if titleElementRegex.MatchString(htmlContent) {
+		return htmlContent
+	}
+	return document.EnsureHeadContent(htmlContent, "document-title", fmt.Sprintf(`<title id="document-title">%s</title>`, html.EscapeString(title)))
+}
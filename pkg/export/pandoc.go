@@ -0,0 +1,99 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"simple_html_docgen/pkg/document"
+	"sort"
+)
+
+// PandocFormat describes an export format produced by Pandoc
+type PandocFormat struct {
+	Name      string   // Name used in error messages, e.g. "DOCX"
+	Extension string   // File extension of the default output path
+	Writer    string   // Pandoc output format (-t)
+	Args      []string // Extra Pandoc arguments
+}
+
+// pandocFormats lists the formats exported with Pandoc. PDF is only made
+// with Pandoc when Chrome is not available; Pandoc writes LaTeX and runs
+// the PDF engine because the output path ends in .pdf. Pandoc runs in the
+// document folder, so media files are found and embedded.
+var pandocFormats = map[string]PandocFormat{
+	"pdf":      {Name: "PDF", Extension: "pdf", Writer: "latex", Args: []string{"--pdf-engine=xelatex"}}, // xelatex for Unicode/emoji support
+	"docx":     {Name: "DOCX", Extension: "docx", Writer: "docx"},
+	"odt":      {Name: "ODT", Extension: "odt", Writer: "odt"},
+	"rtf":      {Name: "RTF", Extension: "rtf", Writer: "rtf", Args: []string{"--standalone"}},
+	"pptx":     {Name: "PPTX", Extension: "pptx", Writer: "pptx"},
+	"latex":    {Name: "LaTeX", Extension: "tex", Writer: "latex", Args: []string{"--standalone"}},
+	"rst":      {Name: "reStructuredText", Extension: "rst", Writer: "rst", Args: []string{"--standalone"}},
+	"asciidoc": {Name: "AsciiDoc", Extension: "adoc", Writer: "asciidoc", Args: []string{"--standalone"}},
+}
+
+// Formats exported in Go or with Chrome
+var builtinFormats = []string{"html", "html_standalone", "epub", "markdown", "txt", "png", "jpeg", "zip"}
+
+// Formats returns the names of all export formats: those built in, then
+// the Pandoc formats in alphabetical order
+func Formats() []string {
+	names := make([]string, 0, len(pandocFormats))
+	for name := range pandocFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(append([]string{}, builtinFormats...), names...)
+}
+
+// exportWithPandoc exports the document with Pandoc in one of the formats
+// of pandocFormats. PDFs also get the page setup and watermark.
+func (e *Exporter) exportWithPandoc(doc *document.Document, format, outputPath string, opts Options, docSvc *document.Service) (string, error) {
+	pf, ok := pandocFormats[format]
+	if !ok {
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+	if err := e.checkPandoc(); err != nil {
+		return "", err
+	}
+	root := docSvc.GetDocumentPath(doc.ID)
+
+	// Create a temporary HTML file for Pandoc, using full-resolution images,
+	// diagrams rendered to SVG and MathML, which Pandoc converts to native
+	// equations. Code blocks are marked for Pandoc's own highlighter.
+	htmlContent, err := e.renderContent(doc, opts, docSvc, nil)
+	if err != nil {
+		return "", err
+	}
+	tmpHTMLPath, err := writeTempHTML(root, SelectHighResImages(RenderMath(PrepareCodeForPandoc(htmlContent))))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpHTMLPath)
+
+	// Run Pandoc conversion
+	args := []string{
+		"-f", "html",
+		"-t", pf.Writer,
+		"-o", outputPath,
+	}
+	args = append(args, pf.Args...)
+	args = append(args, pandocHighlightArgs(opts.CodeTheme)...)
+	if format == "pdf" {
+		args = append(args, opts.PageSetup.pandocPDFArgs(documentTitle(htmlContent, doc.Name))...)
+		watermark, err := resolveWatermark(doc, opts, docSvc)
+		if err != nil {
+			return "", err
+		}
+		watermarkArgs, err := watermark.pandocArgs()
+		if err != nil {
+			return "", err
+		}
+		args = append(args, watermarkArgs...)
+	}
+	args = append(args, tmpHTMLPath)
+
+	if err := e.runPandoc(args, root); err != nil {
+		return "", fmt.Errorf("%s conversion failed: %w", pf.Name, err)
+	}
+
+	return outputPath, nil
+}
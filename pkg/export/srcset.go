@@ -0,0 +1,95 @@
+package export
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	imgTagRegex       = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	srcsetAttrRegex   = regexp.MustCompile(`(?is)\s+srcset\s*=\s*("[^"]*"|'[^']*')`)
+	sizesAttrRegex    = regexp.MustCompile(`(?is)\s+sizes\s*=\s*("[^"]*"|'[^']*')`)
+	srcAttrRegex      = regexp.MustCompile(`(?is)(\s)src\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	pictureBlockRegex = regexp.MustCompile(`(?is)<picture\b[^>]*>.*?</picture>`)
+	sourceTagRegex    = regexp.MustCompile(`(?is)<source\b[^>]*>`)
+)
+
+// SelectHighResImages rewrites responsive images for print: every <img>
+// with a srcset gets the highest-resolution candidate as its src and loses
+// srcset/sizes, and <picture> elements drop their <source> alternatives
+// (after their candidates have been considered) so the rewritten <img> is
+// what renders. Screen viewports would otherwise pick small variants.
+func SelectHighResImages(htmlContent string) string {
+	htmlContent = pictureBlockRegex.ReplaceAllStringFunc(htmlContent, func(block string) string {
+		// Pool the <source> candidates into the <img> so the best one wins
+		var extra []string
+		for _, source := range sourceTagRegex.FindAllString(block, -1) {
+			if match := srcsetAttrRegex.FindStringSubmatch(source); match != nil {
+				extra = append(extra, unquote(match[1]))
+			}
+		}
+		block = sourceTagRegex.ReplaceAllString(block, "")
+		return imgTagRegex.ReplaceAllStringFunc(block, func(tag string) string {
+			return selectHighRes(tag, extra)
+		})
+	})
+
+	return imgTagRegex.ReplaceAllStringFunc(htmlContent, func(tag string) string {
+		return selectHighRes(tag, nil)
+	})
+}
+
+// selectHighRes rewrites a single <img> tag to use its best srcset candidate
+func selectHighRes(tag string, extraSrcsets []string) string {
+	srcsets := extraSrcsets
+	if match := srcsetAttrRegex.FindStringSubmatch(tag); match != nil {
+		srcsets = append(srcsets, unquote(match[1]))
+	}
+	if len(srcsets) == 0 {
+		return tag
+	}
+
+	best, bestScore := "", -1.0
+	for _, srcset := range srcsets {
+		for _, candidate := range strings.Split(srcset, ",") {
+			fields := strings.Fields(candidate)
+			if len(fields) == 0 {
+				continue
+			}
+
+			// Candidates without a descriptor count as 1x
+			score := 1.0
+			if len(fields) > 1 {
+				descriptor := strings.ToLower(fields[1])
+				if strings.HasSuffix(descriptor, "w") || strings.HasSuffix(descriptor, "x") {
+					if value, err := strconv.ParseFloat(descriptor[:len(descriptor)-1], 64); err == nil {
+						score = value
+					}
+				}
+			}
+			if score > bestScore {
+				best, bestScore = fields[0], score
+			}
+		}
+	}
+	if best == "" {
+		return tag
+	}
+
+	tag = srcsetAttrRegex.ReplaceAllString(tag, "")
+	tag = sizesAttrRegex.ReplaceAllString(tag, "")
+	quoted := `"` + strings.ReplaceAll(best, `"`, "&quot;") + `"`
+	if srcAttrRegex.MatchString(tag) {
+		return srcAttrRegex.ReplaceAllLiteralString(tag, " src="+quoted)
+	}
+	return strings.Replace(tag, "<img", "<img src="+quoted, 1)
+}
+
+// unquote strips matching single or double quotes from an attribute value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,279 @@
+package export
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"simple_html_docgen/pkg/document"
+	"strings"
+)
+
+// DefaultInlineMaxBytes is the largest file inlined into a standalone
+// HTML export when no limit is given
+const DefaultInlineMaxBytes = 10 << 20
+
+var (
+	// Attributes that load a file: src on media elements, poster on
+	// videos and data on objects
+	assetAttrRegex    = regexp.MustCompile(`(?is)(<(?:img|video|audio|source|track|embed|input|object)\b[^>]*?\s)(src|poster|data)\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	linkTagRegex      = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	anchorTagRegex    = regexp.MustCompile(`(?is)<a\b[^>]*>`)
+	hrefAttrRegex     = regexp.MustCompile(`(?is)(\s)href\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+	downloadAttrRegex = regexp.MustCompile(`(?i)\sdownload\b`)
+	inlineStyleRegex  = regexp.MustCompile(`(?is)(\sstyle\s*=\s*)("[^"]*"|'[^']*')`)
+	styleTagRegex     = regexp.MustCompile(`(?is)(<style\b[^>]*>)(.*?)(</style>)`)
+	cssURLRegex       = regexp.MustCompile(`(?i)url\(\s*("[^"]*"|'[^']*'|[^)\s]*)\s*\)`)
+	cssImportRegex    = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*)?("[^"]*"|'[^']*'|[^)\s;]+)\s*\)?\s*([^;]*);`)
+)
+
+// StandaloneResult describes a standalone HTML export
+type StandaloneResult struct {
+	Path     string   // The HTML file written
+	Inlined  int      // Number of files inlined
+	Bytes    int      // Size of the HTML file
+	Warnings []string // Files that were left as links, and why
+}
+
+// ExportStandaloneHTML exports a document as a single HTML file with its
+// local images, fonts, SVG, media and stylesheets inlined
+func (e *Exporter) ExportStandaloneHTML(documentID, outputPath string, opts Options, docSvc *document.Service) (*StandaloneResult, error) {
+	doc, err := docSvc.GetDocument(documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	outputPath, err = prepareOutputPath(documentID, "html_standalone", outputPath, docSvc)
+	if err != nil {
+		return nil, err
+	}
+	return e.exportStandaloneHTML(doc, outputPath, opts, docSvc)
+}
+
+// exportStandaloneHTML renders the document as for an HTML export, then
+// inlines the files it references so the output works anywhere
+func (e *Exporter) exportStandaloneHTML(doc *document.Document, outputPath string, opts Options, docSvc *document.Service) (*StandaloneResult, error) {
+	htmlContent, err := e.renderContent(doc, opts, docSvc, nil)
+	if err != nil {
+		return nil, err
+	}
+	// One image per element is enough for a portable file, so responsive
+	// variants collapse to the best one
+	htmlContent = SelectHighResImages(RenderMath(HighlightCode(htmlContent, opts.CodeTheme)))
+	if htmlContent, err = e.watermark(htmlContent, doc, opts, docSvc); err != nil {
+		return nil, err
+	}
+
+	maxBytes := opts.InlineMaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultInlineMaxBytes
+	}
+	in := &inliner{root: docSvc.GetDocumentPath(doc.ID), maxBytes: maxBytes, seen: map[string]bool{}}
+	htmlContent = in.inlineHTML(htmlContent)
+
+	if err := os.WriteFile(outputPath, []byte(htmlContent), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write HTML file: %w", err)
+	}
+	return &StandaloneResult{Path: outputPath, Inlined: in.inlined, Bytes: len(htmlContent), Warnings: in.warnings}, nil
+}
+
+// inliner replaces references to files in a document folder with data URIs
+type inliner struct {
+	root     string          // Document folder; files outside it are never read
+	maxBytes int64           // Larger files stay as links
+	inlined  int             // Files inlined so far
+	warnings []string        // Files left as links
+	seen     map[string]bool // Warnings already given, by reference
+}
+
+// inlineHTML inlines the files referenced by media elements, stylesheet
+// links, style blocks and style attributes
+func (in *inliner) inlineHTML(htmlContent string) string {
+	htmlContent = assetAttrRegex.ReplaceAllStringFunc(htmlContent, func(m string) string {
+		parts := assetAttrRegex.FindStringSubmatch(m)
+		ref := html.UnescapeString(unquote(parts[3]))
+		dataURI, ok := in.dataURI(ref, "")
+		if !ok {
+			return m
+		}
+		return fmt.Sprintf(`%s%s="%s"`, parts[1], parts[2], dataURI)
+	})
+
+	htmlContent = styleTagRegex.ReplaceAllStringFunc(htmlContent, func(m string) string {
+		parts := styleTagRegex.FindStringSubmatch(m)
+		return parts[1] + in.inlineCSS(parts[2], in.root, 0) + parts[3]
+	})
+
+	// Links to files in media/ become downloads of the inlined file
+	htmlContent = anchorTagRegex.ReplaceAllStringFunc(htmlContent, func(tag string) string {
+		attrs := tag[len("<a") : len(tag)-1]
+		href := attrValue(attrs, "href")
+		path, ok := in.resolve(href, in.root)
+		if rel, _ := filepath.Rel(in.root, path); !ok || !strings.HasPrefix(filepath.ToSlash(rel), "media/") {
+			return tag
+		}
+		dataURI, ok := in.dataURI(href, in.root)
+		if !ok {
+			return tag
+		}
+		tag = hrefAttrRegex.ReplaceAllString(tag, fmt.Sprintf(`${1}href="%s"`, dataURI))
+		if attrValue(attrs, "download") == "" && !downloadAttrRegex.MatchString(attrs) {
+			tag = tag[:len(tag)-1] + fmt.Sprintf(` download="%s">`, html.EscapeString(filepath.Base(path)))
+		}
+		return tag
+	})
+
+	htmlContent = linkTagRegex.ReplaceAllStringFunc(htmlContent, func(tag string) string {
+		rels := strings.Fields(strings.ToLower(attrValue(tag[len("<link"):], "rel")))
+		isStylesheet := false
+		for _, rel := range rels {
+			isStylesheet = isStylesheet || rel == "stylesheet"
+		}
+		if !isStylesheet || strings.Contains(strings.Join(rels, " "), "alternate") {
+			return tag
+		}
+		href := attrValue(tag[len("<link"):], "href")
+		css, path, ok := in.readCSS(href, in.root)
+		if !ok {
+			return tag
+		}
+		media := ""
+		if m := attrValue(tag[len("<link"):], "media"); m != "" {
+			media = fmt.Sprintf(` media="%s"`, html.EscapeString(m))
+		}
+		return fmt.Sprintf("<style%s>\n%s\n</style>", media, in.inlineCSS(css, filepath.Dir(path), 0))
+	})
+
+	return inlineStyleRegex.ReplaceAllStringFunc(htmlContent, func(m string) string {
+		parts := inlineStyleRegex.FindStringSubmatch(m)
+		quote := parts[2][:1]
+		css := html.UnescapeString(unquote(parts[2]))
+		if !cssURLRegex.MatchString(css) {
+			return m
+		}
+		css = in.inlineCSS(css, in.root, 0)
+		if quote == `"` {
+			css = strings.ReplaceAll(css, `"`, "&quot;")
+		} else {
+			css = strings.ReplaceAll(css, `'`, "&#39;")
+		}
+		return parts[1] + quote + css + quote
+	})
+}
+
+// inlineCSS inlines the url() references and local @import rules of a
+// stylesheet whose relative URLs resolve against base
+func (in *inliner) inlineCSS(css, base string, depth int) string {
+	css = cssImportRegex.ReplaceAllStringFunc(css, func(m string) string {
+		parts := cssImportRegex.FindStringSubmatch(m)
+		if depth >= 5 {
+			return m
+		}
+		imported, path, ok := in.readCSS(unquote(parts[1]), base)
+		if !ok {
+			return m
+		}
+		imported = in.inlineCSS(imported, filepath.Dir(path), depth+1)
+		if media := strings.TrimSpace(parts[2]); media != "" {
+			return fmt.Sprintf("@media %s {\n%s\n}", media, imported)
+		}
+		return imported
+	})
+
+	return cssURLRegex.ReplaceAllStringFunc(css, func(m string) string {
+		ref := unquote(cssURLRegex.FindStringSubmatch(m)[1])
+		dataURI, ok := in.dataURI(ref, base)
+		if !ok {
+			return m
+		}
+		return fmt.Sprintf(`url("%s")`, dataURI)
+	})
+}
+
+// readCSS reads a local stylesheet
+func (in *inliner) readCSS(ref, base string) (string, string, bool) {
+	path, ok := in.resolve(ref, base)
+	if !ok {
+		return "", "", false
+	}
+	data, ok := in.read(ref, path)
+	return string(data), path, ok
+}
+
+// dataURI returns a data URI for a local file, or false when the
+// reference is not local or the file cannot be inlined
+func (in *inliner) dataURI(ref, base string) (string, bool) {
+	if base == "" {
+		base = in.root
+	}
+	path, ok := in.resolve(ref, base)
+	if !ok {
+		return "", false
+	}
+	data, ok := in.read(ref, path)
+	if !ok {
+		return "", false
+	}
+
+	mimeType := document.DetectMIMEType(data, path)
+	if strings.HasPrefix(mimeType, "text/plain") && strings.EqualFold(filepath.Ext(path), ".css") {
+		mimeType = "text/css"
+	}
+	in.inlined++
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), true
+}
+
+// resolve maps a relative URL to a file inside the document folder.
+// Remote, data and fragment URLs are not local and are skipped quietly;
+// local paths that escape the folder get a warning.
+func (in *inliner) resolve(ref, base string) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return "", false
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Host != "" || (u.Scheme != "" && u.Scheme != "file") {
+		return "", false
+	}
+
+	path := filepath.FromSlash(u.Path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	path = filepath.Clean(path)
+	if rel, err := filepath.Rel(in.root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		in.warn(ref, "outside the document folder, left as a link")
+		return "", false
+	}
+	return path, true
+}
+
+// read reads a file unless it is missing or over the size limit
+func (in *inliner) read(ref, path string) ([]byte, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		in.warn(ref, "not found, left as a link")
+		return nil, false
+	}
+	if info.Size() > in.maxBytes {
+		in.warn(ref, fmt.Sprintf("%d bytes, over the %d byte limit, left as a link", info.Size(), in.maxBytes))
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		in.warn(ref, fmt.Sprintf("cannot be read (%v), left as a link", err))
+		return nil, false
+	}
+	return data, true
+}
+
+// warn records a warning once per reference
+func (in *inliner) warn(ref, reason string) {
+	if in.seen[ref] {
+		return
+	}
+	in.seen[ref] = true
+	in.warnings = append(in.warnings, ref+": "+reason)
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"simple_html_docgen/pkg/config"
+	"strings"
+	"testing"
+)
+
+func TestExportStandaloneHTML(t *testing.T) {
+	svc, doc := newTestDocument(t, `<html><head><link rel="stylesheet" href="style.css"></head><body>`+
+		`<h1>Guide</h1><p><img src="media/pic.png" alt="Pic"></p>`+
+		`<div style="background: url('media/pic.png')">Box</div>`+
+		`<p><a href="media/notes.txt">notes</a> <a href="https://example.com/">site</a></p>`+
+		`<p><img src="../secret.png" alt="Outside"> <img src="https://example.com/a.png" alt="Remote"></p></body></html>`)
+	root := svc.GetDocumentPath(doc.ID)
+	if err := os.WriteFile(filepath.Join(root, "style.css"), []byte(`@import "base.css"; h1 { background: url(media/pic.png) }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "base.css"), []byte("body { margin: 0 }"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "media", "notes.txt"), []byte("Some notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "guide.html")
+	res, err := NewExporter(&config.Config{}).ExportStandaloneHTML(doc.ID, out, Options{}, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := string(data)
+
+	for _, want := range []string{
+		`src="data:image/png;base64,`,
+		`url(&quot;data:image/png;base64,`,
+		`url("data:image/png;base64,`,
+		"body { margin: 0 }",
+		`href="data:text/plain`,
+		`download="notes.txt"`,
+		`href="https://example.com/"`,
+		`src="https://example.com/a.png"`,
+		`src="../secret.png"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %s", want)
+		}
+	}
+	for _, unwanted := range []string{`src="media/pic.png"`, `href="style.css"`, "@import"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("output still contains %s", unwanted)
+		}
+	}
+
+	if res.Bytes != len(data) || res.Inlined < 4 {
+		t.Errorf("result = %d bytes, %d inlined; file has %d bytes", res.Bytes, res.Inlined, len(data))
+	}
+	if len(res.Warnings) != 1 || !strings.HasPrefix(res.Warnings[0], "../secret.png: outside the document folder") {
+		t.Errorf("warnings = %q, want one for ../secret.png", res.Warnings)
+	}
+}
+
+func TestExportStandaloneHTMLSizeLimit(t *testing.T) {
+	svc, doc := newTestDocument(t, `<p><img src="media/pic.png" alt="Pic"><img src="media/pic.png" alt="Again"><img src="media/gone.png" alt="Gone"></p>`)
+
+	out := filepath.Join(t.TempDir(), "guide.html")
+	res, err := NewExporter(&config.Config{}).ExportStandaloneHTML(doc.ID, out, Options{InlineMaxBytes: 10}, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "data:") || !strings.Contains(string(data), `src="media/pic.png"`) {
+		t.Error("a file over the size limit was inlined")
+	}
+	if res.Inlined != 0 {
+		t.Errorf("inlined %d files, want 0", res.Inlined)
+	}
+
+	// One warning per reference, however often it appears
+	if len(res.Warnings) != 2 {
+		t.Fatalf("warnings = %q, want two", res.Warnings)
+	}
+	if !strings.Contains(res.Warnings[0], "over the 10 byte limit") || !strings.Contains(res.Warnings[1], "not found") {
+		t.Errorf("warnings = %q", res.Warnings)
+	}
+}
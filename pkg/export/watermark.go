@@ -0,0 +1,202 @@
+package export
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"simple_html_docgen/pkg/document"
+	"strings"
+)
+
+// Watermark placements
+const (
+	WatermarkCenter      = "center" // Middle of the page; text runs diagonally
+	WatermarkTopLeft     = "top-left"
+	WatermarkTopRight    = "top-right"
+	WatermarkBottomLeft  = "bottom-left"
+	WatermarkBottomRight = "bottom-right"
+)
+
+// DefaultWatermarkOpacity is used when a watermark gives no opacity
+const DefaultWatermarkOpacity = 0.15
+
+// StatusWatermarks maps document statuses to the text stamped on exports
+// when no watermark is given explicitly
+var StatusWatermarks = map[string]string{
+	"draft":        "DRAFT",
+	"review":       "FOR REVIEW",
+	"confidential": "CONFIDENTIAL",
+}
+
+var bodyOpenRegex = regexp.MustCompile(`(?i)<body\b[^>]*>`)
+
+var watermarkPositions = []string{WatermarkCenter, WatermarkTopLeft, WatermarkTopRight, WatermarkBottomLeft, WatermarkBottomRight}
+
+// Watermark is text or an image drawn over every page of PDF exports and
+// over HTML exports. The zero value draws nothing.
+type Watermark struct {
+	Text     string  // Text such as "DRAFT"
+	Image    string  // Image in the document's media folder, e.g. "media/logo.png"
+	Opacity  float64 // From 0 to 1; 0 uses DefaultWatermarkOpacity
+	Position string  // Placement; "" is WatermarkCenter
+}
+
+// Validate checks the watermark settings
+func (w Watermark) Validate() error {
+	if w.Text != "" && w.Image != "" {
+		return fmt.Errorf("a watermark can have text or an image, not both")
+	}
+	if w.Opacity < 0 || w.Opacity > 1 {
+		return fmt.Errorf("invalid watermark opacity: %g (must be between 0 and 1)", w.Opacity)
+	}
+	if w.Position != "" {
+		valid := false
+		for _, p := range watermarkPositions {
+			valid = valid || w.Position == p
+		}
+		if !valid {
+			return fmt.Errorf("invalid watermark position: %s (must be one of %s)", w.Position, strings.Join(watermarkPositions, ", "))
+		}
+	}
+	return nil
+}
+
+// empty reports whether the watermark draws nothing
+func (w Watermark) empty() bool {
+	return strings.TrimSpace(w.Text) == "" && w.Image == ""
+}
+
+// resolveWatermark returns the watermark for an export: the one given in
+// the options, or the stamp for the document's status unless disabled
+func resolveWatermark(doc *document.Document, opts Options, docSvc *document.Service) (Watermark, error) {
+	w := opts.Watermark
+	if !w.empty() || opts.NoWatermark {
+		return w, nil
+	}
+	props, err := docSvc.GetProperties(doc.ID)
+	if err != nil {
+		return w, err
+	}
+	w.Text = StatusWatermarks[strings.ToLower(strings.TrimSpace(props.Status))]
+	return w, nil
+}
+
+// applyWatermark adds a watermark to HTML as a fixed overlay, which
+// browsers repeat on every printed page. Images are inlined as data URIs
+// so the overlay works wherever the HTML is written.
+func applyWatermark(htmlContent string, w Watermark, documentID string, docSvc *document.Service) (string, error) {
+	if w.empty() {
+		return htmlContent, nil
+	}
+	if err := w.Validate(); err != nil {
+		return "", err
+	}
+
+	opacity := w.Opacity
+	if opacity == 0 {
+		opacity = DefaultWatermarkOpacity
+	}
+	position := w.Position
+	if position == "" {
+		position = WatermarkCenter
+	}
+
+	style := fmt.Sprintf("position:fixed;z-index:2147483647;pointer-events:none;opacity:%g;", opacity)
+	switch position {
+	case WatermarkCenter:
+		style += "top:50%;left:50%;transform:translate(-50%,-50%)"
+		if w.Image == "" {
+			style += " rotate(-45deg)"
+		}
+		style += ";"
+	default:
+		vertical, horizontal, _ := strings.Cut(position, "-")
+		style += fmt.Sprintf("%s:0.4in;%s:0.4in;", vertical, horizontal)
+	}
+
+	var mark string
+	if w.Image != "" {
+		data, err := docSvc.ReadMedia(documentID, w.Image)
+		if err != nil {
+			return "", fmt.Errorf("failed to read watermark image: %w", err)
+		}
+		mimeType := document.DetectMIMEType(data, w.Image)
+		if mediaType := document.MediaTypeForMIME(mimeType); mediaType != "image" && mediaType != "svg" {
+			return "", fmt.Errorf("watermark image %s is not an image (%s)", w.Image, mimeType)
+		}
+		size := "width:25vw;"
+		if position == WatermarkCenter {
+			size = "width:60vw;"
+		}
+		mark = fmt.Sprintf(`<img class="doc-watermark" src="data:%s;base64,%s" alt="" aria-hidden="true" style="%s%s">`,
+			mimeType, base64.StdEncoding.EncodeToString(data), style, size)
+	} else {
+		text := strings.TrimSpace(w.Text)
+		// Long text is set smaller so it still fits across the page
+		fontSize := "18pt"
+		if position == WatermarkCenter {
+			fontSize = fmt.Sprintf("%.1fvw", min(15, 110/float64(len([]rune(text)))))
+		}
+		mark = fmt.Sprintf(`<div class="doc-watermark" aria-hidden="true" style="%sfont:bold %s/1 Helvetica,Arial,sans-serif;color:#808080;letter-spacing:0.05em;white-space:nowrap;">%s</div>`,
+			style, fontSize, html.EscapeString(text))
+	}
+
+	// The overlay goes right after <body>, or first when there is none
+	if loc := bodyOpenRegex.FindStringIndex(htmlContent); loc != nil {
+		return htmlContent[:loc[1]] + "\n" + mark + htmlContent[loc[1]:], nil
+	}
+	return mark + "\n" + htmlContent, nil
+}
+
+// pandocArgs draws the watermark on every page of Pandoc's LaTeX output
+// with the draftwatermark package. SVG images are not supported there, so
+// they are an error rather than a PDF without the watermark.
+func (w Watermark) pandocArgs() ([]string, error) {
+	if w.empty() {
+		return nil, nil
+	}
+	if strings.EqualFold(path.Ext(w.Image), ".svg") {
+		return nil, fmt.Errorf("SVG watermark images are not supported when Pandoc makes the PDF (Chrome not found): %s", w.Image)
+	}
+	opacity := w.Opacity
+	if opacity == 0 {
+		opacity = DefaultWatermarkOpacity
+	}
+	corner := w.Position != "" && w.Position != WatermarkCenter
+
+	var mark string
+	scale := 1.0
+	switch {
+	case w.Image != "":
+		width := "0.6"
+		if corner {
+			width = "0.25"
+		}
+		mark = fmt.Sprintf(`\includegraphics[width=%s\paperwidth]{media/%s}`, width, path.Base(w.Image))
+	case corner:
+		mark = `\bfseries\sffamily ` + escapeLaTeX(strings.TrimSpace(w.Text))
+		scale = 0.3
+	default:
+		text := strings.TrimSpace(w.Text)
+		mark = `\bfseries\sffamily ` + escapeLaTeX(text)
+		// Long text is set smaller so it still fits across the page
+		scale = min(1.2, 6/float64(len([]rune(text))))
+	}
+
+	includes := `\usepackage{draftwatermark}\SetWatermarkText{` + mark + `}` +
+		fmt.Sprintf(`\SetWatermarkLightness{%.2f}\SetWatermarkScale{%.2f}`, 1-opacity, scale)
+	if corner {
+		vertical, horizontal, _ := strings.Cut(w.Position, "-")
+		x, y := `0.2\paperwidth`, `0.1\paperheight`
+		if horizontal == "right" {
+			x = `0.8\paperwidth`
+		}
+		if vertical == "bottom" {
+			y = `0.9\paperheight`
+		}
+		includes += `\SetWatermarkAngle{0}\SetWatermarkHorCenter{` + x + `}\SetWatermarkVerCenter{` + y + `}`
+	}
+	return []string{"-V", "header-includes=" + includes}, nil
+}
@@ -0,0 +1,374 @@
+package export
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Namespaces of XHTML documents and the foreign content they embed
+const (
+	xhtmlNamespace  = "http://www.w3.org/1999/xhtml"
+	mathmlNamespace = "http://www.w3.org/1998/Math/MathML"
+	svgNamespace    = "http://www.w3.org/2000/svg"
+	xlinkNamespace  = "http://www.w3.org/1999/xlink"
+)
+
+var (
+	xmlNameRegex = regexp.MustCompile(`^[A-Za-z_][-A-Za-z0-9_.]*(?::[A-Za-z_][-A-Za-z0-9_.]*)?$`)
+
+	// Elements without content or end tag
+	voidElements = setOf("area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param", "source", "track", "wbr")
+
+	// Document wrappers; their tags are dropped and their content kept
+	wrapperElements = setOf("html", "body")
+
+	// Elements dropped with their content; they do nothing in an e-book
+	droppedElements = setOf("script", "style", "noscript", "template", "title", "head", "link", "meta", "base")
+
+	// Elements whose start closes an open <p>
+	paragraphClosers = setOf("address", "article", "aside", "blockquote", "details", "div", "dl", "fieldset", "figcaption", "figure",
+		"footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "main", "menu", "nav", "ol", "p", "pre", "section", "table", "ul")
+
+	// Elements that stop the search for an element to close
+	scopeElements = setOf("table", "td", "th", "caption", "object", "math", "svg", "html", "body")
+
+	tableParts = setOf("table", "caption", "thead", "tbody", "tfoot", "tr", "td", "th")
+)
+
+// impliedEnds lists, for elements whose start tag ends an open element of
+// the same kind, the elements that end it and the containers it cannot
+// be closed beyond
+var impliedEnds = map[string]struct{ closes, within []string }{
+	"li":     {[]string{"li"}, []string{"ul", "ol", "menu"}},
+	"dt":     {[]string{"dt", "dd"}, []string{"dl"}},
+	"dd":     {[]string{"dt", "dd"}, []string{"dl"}},
+	"tr":     {[]string{"tr", "td", "th"}, []string{"table", "thead", "tbody", "tfoot"}},
+	"td":     {[]string{"td", "th"}, []string{"tr", "table"}},
+	"th":     {[]string{"td", "th"}, []string{"tr", "table"}},
+	"thead":  {[]string{"thead", "tbody", "tfoot", "tr", "td", "th"}, []string{"table"}},
+	"tbody":  {[]string{"thead", "tbody", "tfoot", "tr", "td", "th"}, []string{"table"}},
+	"tfoot":  {[]string{"thead", "tbody", "tfoot", "tr", "td", "th"}, []string{"table"}},
+	"option": {[]string{"option"}, []string{"select", "datalist", "optgroup"}},
+}
+
+// setOf makes a set of strings
+func setOf(items ...string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// xhtmlWriter turns an HTML fragment into well-formed XHTML
+type xhtmlWriter struct {
+	out     strings.Builder
+	stack   []string // Open elements, innermost last
+	foreign int      // Depth inside <svg> or <math>, where names keep their case
+}
+
+// ToXHTML converts an HTML fragment, such as the content of <body>, to
+// well-formed XHTML. Tag names are lowercased, attributes quoted, void
+// elements closed, implied end tags added and stray end tags dropped.
+// Comments, scripts, event handlers, styles and other head content are
+// removed, and <svg> and <math> get their namespaces.
+func ToXHTML(fragment string) string {
+	w := &xhtmlWriter{}
+	s := fragment
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			w.text(s)
+			break
+		}
+		if lt > 0 {
+			w.text(s[:lt])
+			s = s[lt:]
+		}
+
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			end := strings.Index(s[4:], "-->")
+			if end < 0 {
+				return w.finish()
+			}
+			s = s[4+end+3:]
+		case strings.HasPrefix(s, "<![CDATA["):
+			end := strings.Index(s, "]]>")
+			if end < 0 {
+				end = len(s) - 3
+			}
+			w.text(html.EscapeString(s[len("<![CDATA["):end]))
+			s = s[end+3:]
+		case strings.HasPrefix(s, "<!") || strings.HasPrefix(s, "<?"):
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				return w.finish()
+			}
+			s = s[end+1:]
+		case len(s) > 2 && s[1] == '/' && isASCIILetter(s[2]):
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				return w.finish()
+			}
+			w.endTag(strings.FieldsFunc(s[2:end], func(r rune) bool { return r == '/' || isSpace(byte(r)) })[0])
+			s = s[end+1:]
+		case len(s) > 1 && isASCIILetter(s[1]):
+			name, attrs, selfClosing, rest := parseStartTag(s)
+			s = rest
+			if w.foreign == 0 {
+				name = strings.ToLower(name)
+			}
+			if wrapperElements[name] && w.foreign == 0 {
+				continue
+			}
+			if droppedElements[name] && w.foreign == 0 {
+				if !voidElements[name] && !selfClosing {
+					_, s = rawContent(s, name)
+				}
+				continue
+			}
+			if name == "textarea" && w.foreign == 0 {
+				var content string
+				content, s = rawContent(s, name)
+				w.startTag(name, attrs, false)
+				w.text(content)
+				w.endTag(name)
+				continue
+			}
+			w.startTag(name, attrs, selfClosing)
+		default:
+			w.text("<")
+			s = s[1:]
+		}
+	}
+	return w.finish()
+}
+
+// finish closes the elements left open and returns the XHTML
+func (w *xhtmlWriter) finish() string {
+	for len(w.stack) > 0 {
+		w.pop()
+	}
+	return w.out.String()
+}
+
+// text writes character data, which may contain HTML character references
+func (w *xhtmlWriter) text(s string) {
+	s = html.UnescapeString(s)
+	w.out.WriteString(escapeXMLText(s))
+}
+
+// startTag writes a start tag, closing the elements it implicitly ends
+func (w *xhtmlWriter) startTag(name string, attrs [][2]string, selfClosing bool) {
+	if w.foreign == 0 {
+		if paragraphClosers[name] {
+			w.closeWithin([]string{"p"}, nil)
+		}
+		if implied, ok := impliedEnds[name]; ok {
+			w.closeWithin(implied.closes, implied.within)
+		}
+	}
+
+	foreignRoot := w.foreign == 0 && (name == "svg" || name == "math")
+	w.out.WriteString("<" + name)
+	seen := map[string]bool{}
+	if foreignRoot {
+		namespace := svgNamespace
+		if name == "math" {
+			namespace = mathmlNamespace
+		}
+		w.out.WriteString(` xmlns="` + namespace + `"`)
+		if name == "svg" {
+			w.out.WriteString(` xmlns:xlink="` + xlinkNamespace + `"`)
+		}
+		seen["xmlns"], seen["xmlns:xlink"] = true, true
+	}
+	for _, attr := range attrs {
+		key := attr[0]
+		if w.foreign == 0 && !foreignRoot {
+			key = strings.ToLower(key)
+		}
+		// Event handlers are scripts, which are removed
+		if seen[key] || !xmlNameRegex.MatchString(key) || !allowedAttrPrefix(key) || strings.HasPrefix(strings.ToLower(key), "on") {
+			continue
+		}
+		seen[key] = true
+		w.out.WriteString(" " + key + `="` + escapeXMLAttr(attr[1]) + `"`)
+	}
+
+	if voidElements[name] && w.foreign == 0 || selfClosing && (w.foreign > 0 || foreignRoot) {
+		w.out.WriteString("/>")
+		return
+	}
+	w.out.WriteString(">")
+	w.stack = append(w.stack, name)
+	if foreignRoot || w.foreign > 0 {
+		w.foreign++
+	}
+}
+
+// endTag closes the named element and any still open inside it. End tags
+// without a matching open element are dropped.
+func (w *xhtmlWriter) endTag(name string) {
+	if w.foreign == 0 {
+		name = strings.ToLower(name)
+	}
+	for i := len(w.stack) - 1; i >= 0; i-- {
+		if w.stack[i] == name || w.foreign > 0 && strings.EqualFold(w.stack[i], name) {
+			for len(w.stack) > i {
+				w.pop()
+			}
+			return
+		}
+		// Table end tags close the cells and rows still open inside them
+		if scopeElements[w.stack[i]] && !(tableParts[name] && tableParts[w.stack[i]]) || w.foreign > 0 && len(w.stack)-i >= w.foreign {
+			return
+		}
+	}
+}
+
+// closeWithin closes the outermost open element named in closes, with
+// everything inside it, that comes before a scope element or one of within
+func (w *xhtmlWriter) closeWithin(closes, within []string) {
+	target := -1
+	for i := len(w.stack) - 1; i >= 0; i-- {
+		name := w.stack[i]
+		if slices.Contains(closes, name) {
+			target = i
+			continue
+		}
+		if slices.Contains(within, name) || scopeElements[name] {
+			break
+		}
+	}
+	for target >= 0 && len(w.stack) > target {
+		w.pop()
+	}
+}
+
+// pop writes the end tag of the innermost open element
+func (w *xhtmlWriter) pop() {
+	name := w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+	if w.foreign > 0 {
+		w.foreign--
+	}
+	w.out.WriteString("</" + name + ">")
+}
+
+// parseStartTag reads a start tag at the beginning of s and returns its
+// name, its attributes with character references decoded, whether it ends
+// with "/>" and the input after it
+func parseStartTag(s string) (string, [][2]string, bool, string) {
+	i := 1
+	for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	name := s[1:i]
+
+	var attrs [][2]string
+	selfClosing := false
+	for i < len(s) {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return name, attrs, selfClosing, s[i+1:]
+		}
+		if s[i] == '/' {
+			selfClosing = true
+			i++
+			continue
+		}
+		selfClosing = false
+
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '=' && (s[i] != '/' || i == start) {
+			i++
+		}
+		key := s[start:i]
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) || s[i] != '=' {
+			// Boolean attributes take their name as value
+			attrs = append(attrs, [2]string{key, strings.ToLower(key)})
+			continue
+		}
+		i++
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		var value string
+		if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			value = s[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			start := i
+			for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+				i++
+			}
+			value = s[start:i]
+		}
+		attrs = append(attrs, [2]string{key, html.UnescapeString(value)})
+	}
+	return name, attrs, selfClosing, ""
+}
+
+// rawContent splits the input after a start tag into the content of an
+// element that is not parsed, such as a script, and the input after its
+// end tag
+func rawContent(s, name string) (string, string) {
+	end := strings.Index(strings.ToLower(s), "</"+name)
+	if end < 0 {
+		return s, ""
+	}
+	gt := strings.IndexByte(s[end:], '>')
+	if gt < 0 {
+		return s[:end], ""
+	}
+	return s[:end], s[end+gt+1:]
+}
+
+// allowedAttrPrefix reports whether an attribute's namespace prefix, if
+// any, is declared in the XHTML this package writes
+func allowedAttrPrefix(key string) bool {
+	prefix, _, found := strings.Cut(key, ":")
+	return !found || prefix == "xml" || prefix == "xlink" || prefix == "epub"
+}
+
+// escapeXMLText escapes character data and drops characters XML forbids
+func escapeXMLText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xFFFE || r == 0xFFFF {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// escapeXMLAttr escapes a double-quoted attribute value
+func escapeXMLAttr(s string) string {
+	return strings.ReplaceAll(escapeXMLText(s), `"`, "&quot;")
+}
+
+// isASCIILetter reports whether c is an ASCII letter
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isSpace reports whether c is HTML whitespace
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -2,11 +2,23 @@ package handler
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"simple_html_docgen/pkg/chart"
+	"simple_html_docgen/pkg/citation"
 	"simple_html_docgen/pkg/config"
 	"simple_html_docgen/pkg/document"
+	"simple_html_docgen/pkg/export"
+	"simple_html_docgen/pkg/highlight"
+	"simple_html_docgen/pkg/imaging"
+	"simple_html_docgen/pkg/pdf"
 	"simple_html_docgen/pkg/storage"
+	"simple_html_docgen/pkg/table"
+	"slices"
+	"strings"
 
 	"github.com/gomcpgo/mcp/pkg/protocol"
 )
@@ -16,15 +28,24 @@ type Handler struct {
 	config    *config.Config
 	docSvc    *document.Service
 	exportSvc ExportService
+	importSvc ImportService
 }
 
 // ExportService defines the interface for export functionality
 type ExportService interface {
-	ExportDocument(documentID, format, outputPath string, docSvc *document.Service) (string, error)
+	ExportDocument(documentID, format, outputPath string, opts export.Options, docSvc *document.Service) (string, error)
+	ExportImages(documentID, format, outputPath string, opts export.Options, docSvc *document.Service) (*export.ImageResult, error)
+	ExportStandaloneHTML(documentID, outputPath string, opts export.Options, docSvc *document.Service) (*export.StandaloneResult, error)
+	ExportBundle(documentID, outputPath string, opts export.Options, docSvc *document.Service) (*export.BundleResult, error)
+}
+
+// ImportService defines the interface for import functionality
+type ImportService interface {
+	ImportFile(sourcePath, name string, docSvc *document.Service) (*document.Document, error)
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(cfg *config.Config, exportSvc ExportService) *Handler {
+func NewHandler(cfg *config.Config, exportSvc ExportService, importSvc ImportService) *Handler {
 	storage := storage.NewStorage(cfg.RootDir)
 	docSvc := document.NewService(storage)
 
@@ -32,6 +53,7 @@ func NewHandler(cfg *config.Config, exportSvc ExportService) *Handler {
 		config:    cfg,
 		docSvc:    docSvc,
 		exportSvc: exportSvc,
+		importSvc: importSvc,
 	}
 }
 
@@ -58,6 +80,24 @@ func (h *Handler) CallTool(ctx context.Context, req *protocol.CallToolRequest) (
 		return h.handleListDocuments(ctx, req.Arguments)
 	case "export_document":
 		return h.handleExportDocument(ctx, req.Arguments)
+	case "import_file":
+		return h.handleImportFile(ctx, req.Arguments)
+	case "extract_inline_media":
+		return h.handleExtractInlineMedia(ctx, req.Arguments)
+	case "optimize_media":
+		return h.handleOptimizeMedia(ctx, req.Arguments)
+	case "render_chart":
+		return h.handleRenderChart(ctx, req.Arguments)
+	case "insert_table":
+		return h.handleInsertTable(ctx, req.Arguments)
+	case "add_bibliography":
+		return h.handleAddBibliography(ctx, req.Arguments)
+	case "number_document":
+		return h.handleNumberDocument(ctx, req.Arguments)
+	case "set_document_properties":
+		return h.handleSetDocumentProperties(ctx, req.Arguments)
+	case "verify_pdf":
+		return h.handleVerifyPDF(ctx, req.Arguments)
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", req.Name)
 	}
@@ -124,17 +164,52 @@ func (h *Handler) handleAddMedia(ctx context.Context, args map[string]interface{
 		return nil, fmt.Errorf("document_id is required and must be a string")
 	}
 
-	sourcePath, ok := args["source_path"].(string)
-	if !ok || sourcePath == "" {
-		return nil, fmt.Errorf("source_path is required and must be a string")
+	// Optional media_type (detected from the content when omitted) and replace target
+	opts := document.AddMediaOptions{}
+	opts.MediaType, _ = args["media_type"].(string)
+	opts.Replace, _ = args["replace"].(string)
+	opts.Optimize = optimizeOptions(args, false)
+	opts.ResponsiveWidths = intListArg(args, "responsive_widths")
+	if responsive, _ := args["responsive"].(bool); responsive && len(opts.ResponsiveWidths) == 0 {
+		opts.ResponsiveWidths = imaging.DefaultVariantWidths
 	}
+	opts.Sizes, _ = args["sizes"].(string)
+
+	// Exactly one content source must be provided
+	sourcePath, _ := args["source_path"].(string)
+	contentBase64, _ := args["content_base64"].(string)
+	textContent, _ := args["text_content"].(string)
 
-	mediaType, ok := args["media_type"].(string)
-	if !ok || mediaType == "" {
-		return nil, fmt.Errorf("media_type is required and must be a string")
+	sources := 0
+	for _, source := range []string{sourcePath, contentBase64, textContent} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("exactly one of source_path, content_base64 or text_content is required")
 	}
 
-	relativePath, err := h.docSvc.AddMedia(documentID, sourcePath, mediaType)
+	var info *document.MediaInfo
+	var err error
+	if sourcePath != "" {
+		info, err = h.docSvc.AddMedia(documentID, sourcePath, opts)
+	} else {
+		filename, ok := args["filename"].(string)
+		if !ok || filename == "" {
+			return nil, fmt.Errorf("filename is required when using content_base64 or text_content")
+		}
+
+		data := []byte(textContent)
+		if contentBase64 != "" {
+			data, err = decodeBase64(contentBase64)
+			if err != nil {
+				return h.errorResponse(fmt.Sprintf("Failed to add media: invalid content_base64: %v", err)), nil
+			}
+		}
+
+		info, err = h.docSvc.AddMediaContent(documentID, filename, data, opts)
+	}
 	if err != nil {
 		return h.errorResponse(fmt.Sprintf("Failed to add media: %v", err)), nil
 	}
@@ -142,8 +217,24 @@ func (h *Handler) handleAddMedia(ctx context.Context, args map[string]interface{
 	result := map[string]interface{}{
 		"status":        "succeeded",
 		"document_id":   documentID,
-		"relative_path": relativePath,
-		"media_type":    mediaType,
+		"relative_path": info.RelativePath,
+		"media_type":    info.MediaType,
+		"mime_type":     info.MIMEType,
+		"size_bytes":    info.SizeBytes,
+		"html_snippet":  info.HTMLSnippet,
+	}
+	if info.Width > 0 && info.Height > 0 {
+		result["width"] = info.Width
+		result["height"] = info.Height
+	}
+	if info.DurationSeconds > 0 {
+		result["duration_seconds"] = info.DurationSeconds
+	}
+	if info.Optimization != nil {
+		result["optimization"] = info.Optimization
+	}
+	if len(info.Variants) > 0 {
+		result["variants"] = info.Variants
 	}
 
 	return h.successResponse(result), nil
@@ -169,6 +260,9 @@ func (h *Handler) handleGetDocument(ctx context.Context, args map[string]interfa
 		"created_at":   doc.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		"updated_at":   doc.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
+	if props, err := h.docSvc.GetProperties(doc.ID); err == nil {
+		result["properties"] = props
+	}
 
 	return h.successResponse(result), nil
 }
@@ -211,8 +305,19 @@ func (h *Handler) handleExportDocument(ctx context.Context, args map[string]inte
 	}
 
 	// Validate format
-	if format != "html" && format != "pdf" && format != "docx" {
-		return nil, fmt.Errorf("invalid format: %s (must be html, pdf, or docx)", format)
+	switch format {
+	case "jpg":
+		format = "jpeg"
+	case "md":
+		format = "markdown"
+	case "tex":
+		format = "latex"
+	case "adoc":
+		format = "asciidoc"
+	}
+	imageFormat := format == "png" || format == "jpeg"
+	if !slices.Contains(export.Formats(), format) {
+		return nil, fmt.Errorf("invalid format: %s (must be one of %s)", format, strings.Join(export.Formats(), ", "))
 	}
 
 	// Get optional output_path
@@ -221,7 +326,152 @@ func (h *Handler) handleExportDocument(ctx context.Context, args map[string]inte
 		outputPath = path
 	}
 
-	exportedPath, err := h.exportSvc.ExportDocument(documentID, format, outputPath, h.docSvc)
+	// Get optional code_theme
+	opts := export.Options{}
+	if theme, ok := args["code_theme"].(string); ok && theme != "" {
+		if _, ok := highlight.GetTheme(theme); !ok {
+			return nil, fmt.Errorf("invalid code_theme: %s (must be one of %s)", theme, strings.Join(highlight.ThemeNames(), ", "))
+		}
+		opts.CodeTheme = theme
+	}
+
+	// Get optional citation_style and references_title
+	if style := stringArg(args, "citation_style"); style != "" {
+		if err := citation.ValidateStyle(style); err != nil {
+			return nil, err
+		}
+		opts.CitationStyle = style
+	}
+	opts.ReferencesTitle = stringArg(args, "references_title")
+	opts.Numbering = numberingOptions(args, true)
+
+	// Get optional PDF page setup
+	pageSetup, pageSetupSet, err := pageSetupArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	// Get optional image settings; split images use the paper size,
+	// orientation and margins
+	imageOpts, imageOptsSet, err := imageArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if !imageFormat && imageOptsSet {
+		return nil, fmt.Errorf("image options only apply to png and jpeg export")
+	}
+	if imageFormat {
+		if err := checkImagePageSetup(args, pageSetupSet, imageOpts.Mode); err != nil {
+			return nil, err
+		}
+	} else if format != "pdf" && format != "zip" && pageSetupSet {
+		return nil, fmt.Errorf("page setup options only apply to pdf export")
+	}
+	opts.PageSetup = pageSetup
+	opts.Image = imageOpts
+
+	// Get optional pdf_profile
+	if profile := stringArg(args, "pdf_profile"); profile != "" {
+		if format != "pdf" && format != "zip" {
+			return nil, fmt.Errorf("pdf_profile only applies to pdf export")
+		}
+		if err := pdf.ValidateProfile(profile); err != nil {
+			return nil, err
+		}
+		opts.PDFProfile = profile
+	}
+
+	// Get optional watermark
+	watermark, noWatermark, err := watermarkArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	watermarked := format == "html" || format == "html_standalone" || format == "pdf" || imageFormat || format == "zip"
+	if !watermarked && watermark != (export.Watermark{}) {
+		return nil, fmt.Errorf("watermark options only apply to html, html_standalone, pdf, png, jpeg and zip export")
+	}
+	opts.Watermark = watermark
+	opts.NoWatermark = noWatermark
+
+	// Get optional inline_max_bytes
+	if v, ok := args["inline_max_bytes"].(float64); ok {
+		if format != "html_standalone" {
+			return nil, fmt.Errorf("inline_max_bytes only applies to html_standalone export")
+		}
+		if v < 1 {
+			return nil, fmt.Errorf("inline_max_bytes must be positive")
+		}
+		opts.InlineMaxBytes = int64(v)
+	}
+
+	// Get optional bundle contents
+	bundle, err := bundleArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if format != "zip" && (bundle.IncludeUnreferenced || len(bundle.Renditions) > 0) {
+		return nil, fmt.Errorf("include_unreferenced_media and renditions only apply to zip export")
+	}
+	opts.Bundle = bundle
+
+	if format == "zip" {
+		zipped, err := h.exportSvc.ExportBundle(documentID, outputPath, opts, h.docSvc)
+		if err != nil {
+			return h.errorResponse(fmt.Sprintf("Failed to export document: %v", err)), nil
+		}
+		result := map[string]interface{}{
+			"status":      "succeeded",
+			"document_id": documentID,
+			"format":      format,
+			"output_path": zipped.Path,
+			"files":       zipped.Files,
+			"size_bytes":  zipped.Bytes,
+		}
+		if len(zipped.Missing) > 0 {
+			result["missing_media"] = zipped.Missing
+		}
+		return h.successResponse(result), nil
+	}
+
+	if format == "html_standalone" {
+		standalone, err := h.exportSvc.ExportStandaloneHTML(documentID, outputPath, opts, h.docSvc)
+		if err != nil {
+			return h.errorResponse(fmt.Sprintf("Failed to export document: %v", err)), nil
+		}
+		result := map[string]interface{}{
+			"status":        "succeeded",
+			"document_id":   documentID,
+			"format":        format,
+			"output_path":   standalone.Path,
+			"inlined_files": standalone.Inlined,
+			"size_bytes":    standalone.Bytes,
+		}
+		if len(standalone.Warnings) > 0 {
+			result["warnings"] = standalone.Warnings
+		}
+		return h.successResponse(result), nil
+	}
+
+	if imageFormat {
+		images, err := h.exportSvc.ExportImages(documentID, format, outputPath, opts, h.docSvc)
+		if err != nil {
+			return h.errorResponse(fmt.Sprintf("Failed to export document: %v", err)), nil
+		}
+		result := map[string]interface{}{
+			"status":      "succeeded",
+			"document_id": documentID,
+			"format":      format,
+			"output_path": images.Files[0],
+			"files":       images.Files,
+			"width":       images.Width,
+			"height":      images.Height,
+		}
+		if len(images.Thumbnails) > 0 {
+			result["thumbnails"] = images.Thumbnails
+		}
+		return h.successResponse(result), nil
+	}
+
+	exportedPath, err := h.exportSvc.ExportDocument(documentID, format, outputPath, opts, h.docSvc)
 	if err != nil {
 		return h.errorResponse(fmt.Sprintf("Failed to export document: %v", err)), nil
 	}
@@ -233,11 +483,652 @@ func (h *Handler) handleExportDocument(ctx context.Context, args map[string]inte
 		"output_path": exportedPath,
 	}
 
+	// Report what the export could not make conform to the profile
+	if opts.PDFProfile != "" && opts.PDFProfile != pdf.ProfileStandard {
+		problems, err := verifyPDFFile(exportedPath, opts.PDFProfile)
+		if err != nil {
+			return h.errorResponse(fmt.Sprintf("Failed to verify PDF: %v", err)), nil
+		}
+		result["pdf_profile"] = opts.PDFProfile
+		result["conformance_problems"] = problems
+	}
+
+	return h.successResponse(result), nil
+}
+
+func (h *Handler) handleVerifyPDF(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf("path is required and must be a string")
+	}
+	profile, ok := args["profile"].(string)
+	if !ok || profile == "" {
+		return nil, fmt.Errorf("profile is required and must be a string")
+	}
+	if err := pdf.ValidateProfile(profile); err != nil {
+		return nil, err
+	}
+
+	problems, err := verifyPDFFile(path, profile)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to verify PDF: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"status":               "succeeded",
+		"path":                 path,
+		"profile":              profile,
+		"conforms":             len(problems) == 0,
+		"conformance_problems": problems,
+	}
+	return h.successResponse(result), nil
+}
+
+func (h *Handler) handleImportFile(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	sourcePath, ok := args["source_path"].(string)
+	if !ok || sourcePath == "" {
+		return nil, fmt.Errorf("source_path is required and must be a string")
+	}
+
+	// Get optional name
+	name := ""
+	if n, ok := args["name"].(string); ok && n != "" {
+		name = n
+	}
+
+	doc, err := h.importSvc.ImportFile(sourcePath, name, h.docSvc)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to import file: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"status":      "succeeded",
+		"document_id": doc.ID,
+		"name":        doc.Name,
+		"source_path": sourcePath,
+		"file_path":   h.docSvc.GetHTMLPath(doc.ID),
+		"created_at":  doc.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		"updated_at":  doc.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	return h.successResponse(result), nil
+}
+
+func (h *Handler) handleExtractInlineMedia(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	documentID, ok := args["document_id"].(string)
+	if !ok || documentID == "" {
+		return nil, fmt.Errorf("document_id is required and must be a string")
+	}
+
+	doc, extracted, err := h.docSvc.ExtractInlineMedia(documentID)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to extract inline media: %v", err)), nil
+	}
+
+	files := extracted.Files
+	if files == nil {
+		files = []string{}
+	}
+
+	result := map[string]interface{}{
+		"status":             "succeeded",
+		"document_id":        doc.ID,
+		"files":              files,
+		"references_updated": extracted.References,
+		"bytes_before":       extracted.BytesBefore,
+		"bytes_after":        extracted.BytesAfter,
+		"updated_at":         doc.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	return h.successResponse(result), nil
+}
+
+func (h *Handler) handleOptimizeMedia(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	documentID, ok := args["document_id"].(string)
+	if !ok || documentID == "" {
+		return nil, fmt.Errorf("document_id is required and must be a string")
+	}
+
+	mediaPath, ok := args["media_path"].(string)
+	if !ok || mediaPath == "" {
+		return nil, fmt.Errorf("media_path is required and must be a string")
+	}
+
+	// Metadata is stripped by default here; optimizing is an explicit request
+	opts := optimizeOptions(args, true)
+	if opts == nil {
+		opts = &imaging.Options{StripMetadata: true}
+	}
+
+	info, err := h.docSvc.OptimizeMedia(documentID, mediaPath, *opts)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to optimize media: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"status":        "succeeded",
+		"document_id":   documentID,
+		"relative_path": info.RelativePath,
+		"mime_type":     info.MIMEType,
+		"width":         info.Width,
+		"height":        info.Height,
+		"optimization":  info.Optimization,
+		"html_snippet":  info.HTMLSnippet,
+	}
+
+	return h.successResponse(result), nil
+}
+
+func (h *Handler) handleRenderChart(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	documentID, ok := args["document_id"].(string)
+	if !ok || documentID == "" {
+		return nil, fmt.Errorf("document_id is required and must be a string")
+	}
+
+	chartType, ok := args["chart_type"].(string)
+	if !ok || chartType == "" {
+		return nil, fmt.Errorf("chart_type is required and must be a string")
+	}
+
+	// Decode the remaining arguments straight into the chart spec
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart arguments: %w", err)
+	}
+	var spec chart.Spec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("invalid chart arguments: %w", err)
+	}
+
+	svg, err := chart.Render(spec)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to render chart: %v", err)), nil
+	}
+
+	filename, _ := args["filename"].(string)
+	if filename == "" {
+		filename = spec.Title
+		if filename == "" {
+			filename = chartType + "-chart"
+		}
+	}
+	if !strings.HasSuffix(strings.ToLower(filename), ".svg") {
+		filename += ".svg"
+	}
+
+	info, err := h.docSvc.AddMediaContent(documentID, filename, svg, document.AddMediaOptions{
+		MediaType: document.MediaTypeSVG,
+		Replace:   stringArg(args, "replace"),
+	})
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to save chart: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"status":        "succeeded",
+		"document_id":   documentID,
+		"chart_type":    chartType,
+		"relative_path": info.RelativePath,
+		"width":         info.Width,
+		"height":        info.Height,
+		"html_snippet":  info.HTMLSnippet,
+	}
+
+	return h.successResponse(result), nil
+}
+
+func (h *Handler) handleInsertTable(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	documentID, ok := args["document_id"].(string)
+	if !ok || documentID == "" {
+		return nil, fmt.Errorf("document_id is required and must be a string")
+	}
+
+	sourcePath := stringArg(args, "source_path")
+	content := stringArg(args, "content")
+	data, hasData := args["data"].([]interface{})
+
+	sources := 0
+	for _, present := range []bool{sourcePath != "", content != "", hasData} {
+		if present {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("exactly one of source_path, content or data is required")
+	}
+
+	hasHeader := true
+	if v, ok := args["has_header"].(bool); ok {
+		hasHeader = v
+	}
+	format := strings.ToLower(stringArg(args, "format"))
+
+	var t *table.Table
+	var err error
+	switch {
+	case sourcePath != "":
+		t, err = table.LoadFile(sourcePath, format, hasHeader)
+	case content != "":
+		if format == "" {
+			format = "csv"
+		}
+		t, err = table.Parse([]byte(content), format, hasHeader)
+	default:
+		t, err = table.FromValues(data, hasHeader)
+	}
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to read table data: %v", err)), nil
+	}
+
+	opts := table.Options{
+		Caption:  stringArg(args, "caption"),
+		Decimals: -1,
+	}
+	if _, ok := args["decimals"]; ok {
+		opts.Decimals = intArg(args, "decimals")
+	}
+	if v, ok := args["thousands_separator"].(bool); ok {
+		opts.ThousandsSeparator = v
+	}
+	if items, ok := args["align"].([]interface{}); ok {
+		for _, item := range items {
+			align, _ := item.(string)
+			opts.Align = append(opts.Align, align)
+		}
+	}
+
+	fragment := table.Render(t, opts)
+	target := document.InsertTarget{
+		Selector:    stringArg(args, "selector"),
+		Placeholder: stringArg(args, "placeholder"),
+		Position:    stringArg(args, "position"),
+	}
+
+	doc, err := h.docSvc.InsertHTML(documentID, fragment, target, table.StyleID, table.Stylesheet)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to insert table: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"status":      "succeeded",
+		"document_id": documentID,
+		"columns":     t.Columns,
+		"row_count":   len(t.Rows),
+		"updated_at":  doc.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	return h.successResponse(result), nil
+}
+
+func (h *Handler) handleAddBibliography(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	documentID, ok := args["document_id"].(string)
+	if !ok || documentID == "" {
+		return nil, fmt.Errorf("document_id is required and must be a string")
+	}
+
+	sourcePath := stringArg(args, "source_path")
+	content := stringArg(args, "content")
+	if (sourcePath == "") == (content == "") {
+		return nil, fmt.Errorf("exactly one of source_path or content is required")
+	}
+
+	format := strings.ToLower(stringArg(args, "format"))
+	if format != "" && format != citation.FormatBibTeX && format != citation.FormatCSLJSON {
+		return nil, fmt.Errorf("invalid format: %s (must be %s or %s)", format, citation.FormatBibTeX, citation.FormatCSLJSON)
+	}
+
+	data := []byte(content)
+	filename := ""
+	if sourcePath != "" {
+		var err error
+		if data, err = os.ReadFile(sourcePath); err != nil {
+			return h.errorResponse(fmt.Sprintf("Failed to read bibliography: %v", err)), nil
+		}
+		filename = filepath.Base(sourcePath)
+	}
+
+	info, err := h.docSvc.AddBibliography(documentID, filename, data, format)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to add bibliography: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"status":        "succeeded",
+		"document_id":   documentID,
+		"relative_path": info.RelativePath,
+		"format":        info.Format,
+		"entry_count":   info.EntryCount,
+		"keys":          info.Keys,
+	}
+
+	return h.successResponse(result), nil
+}
+
+func (h *Handler) handleNumberDocument(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	documentID, ok := args["document_id"].(string)
+	if !ok || documentID == "" {
+		return nil, fmt.Errorf("document_id is required and must be a string")
+	}
+
+	doc, err := h.docSvc.GetDocument(documentID)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to get document: %v", err)), nil
+	}
+
+	htmlContent, numbering := export.ApplyNumbering(doc.HTMLContent, numberingOptions(args, false))
+	doc, err = h.docSvc.UpdateDocument(documentID, htmlContent)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to update document: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"status":      "succeeded",
+		"document_id": documentID,
+		"figures":     numbering.Figures,
+		"tables":      numbering.Tables,
+		"headings":    numbering.Headings,
+		"references":  numbering.References,
+		"updated_at":  doc.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if len(numbering.Unresolved) > 0 {
+		result["unresolved"] = numbering.Unresolved
+	}
+
+	return h.successResponse(result), nil
+}
+
+func (h *Handler) handleSetDocumentProperties(ctx context.Context, args map[string]interface{}) (*protocol.CallToolResponse, error) {
+	documentID, ok := args["document_id"].(string)
+	if !ok || documentID == "" {
+		return nil, fmt.Errorf("document_id is required and must be a string")
+	}
+
+	props, err := h.docSvc.GetProperties(documentID)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to get document properties: %v", err)), nil
+	}
+
+	// Only the given properties change
+	if v, ok := args["title"].(string); ok {
+		props.Title = v
+	}
+	if v, ok := args["author"].(string); ok {
+		props.Author = v
+	}
+	if v, ok := args["subject"].(string); ok {
+		props.Subject = v
+	}
+	if v, ok := args["status"].(string); ok {
+		props.Status = v
+	}
+	if v, ok := args["cover_image"].(string); ok {
+		props.CoverImage = v
+	}
+	if v, ok := args["keywords"]; ok {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("keywords must be an array of strings")
+		}
+		props.Keywords = nil
+		for _, k := range list {
+			s, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("keywords must be an array of strings")
+			}
+			props.Keywords = append(props.Keywords, s)
+		}
+	}
+
+	props, err = h.docSvc.SetProperties(documentID, *props)
+	if err != nil {
+		return h.errorResponse(fmt.Sprintf("Failed to set document properties: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"status":      "succeeded",
+		"document_id": documentID,
+		"properties":  props,
+	}
+
 	return h.successResponse(result), nil
 }
 
 // Helper methods
 
+// verifyPDFFile checks a PDF file against a profile. The problems are
+// never nil, so they are reported as an empty list.
+func verifyPDFFile(path, profile string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF file: %w", err)
+	}
+	problems, err := pdf.Verify(data, profile)
+	if err != nil {
+		return nil, err
+	}
+	if problems == nil {
+		problems = []string{}
+	}
+	return problems, nil
+}
+
+// Page setup arguments: those laying out pages, which split image exports
+// also use, and those only Chrome's PDF printing supports
+var (
+	pageLayoutArgs = []string{"paper_size", "width", "height", "orientation", "margin", "margin_top", "margin_right", "margin_bottom", "margin_left"}
+	pagePrintArgs  = []string{"scale", "page_ranges", "print_background", "header_template", "footer_template"}
+	imageArgNames  = []string{"image_mode", "viewport_width", "device_scale", "jpeg_quality", "thumbnail_width"}
+)
+
+// pageSetupArgs reads and validates PDF page setup arguments. Backgrounds
+// are printed unless print_background is false. The second result is true
+// when any of them was given.
+func pageSetupArgs(args map[string]interface{}) (export.PageSetup, bool, error) {
+	setup := export.PageSetup{
+		PaperSize:      strings.ToLower(stringArg(args, "paper_size")),
+		Width:          stringArg(args, "width"),
+		Height:         stringArg(args, "height"),
+		Margin:         stringArg(args, "margin"),
+		MarginTop:      stringArg(args, "margin_top"),
+		MarginRight:    stringArg(args, "margin_right"),
+		MarginBottom:   stringArg(args, "margin_bottom"),
+		MarginLeft:     stringArg(args, "margin_left"),
+		PageRanges:     stringArg(args, "page_ranges"),
+		Background:     true,
+		HeaderTemplate: stringArg(args, "header_template"),
+		FooterTemplate: stringArg(args, "footer_template"),
+	}
+	switch orientation := strings.ToLower(stringArg(args, "orientation")); orientation {
+	case "", "portrait":
+	case "landscape":
+		setup.Landscape = true
+	default:
+		return setup, false, fmt.Errorf("invalid orientation: %s (must be portrait or landscape)", orientation)
+	}
+	if scale, ok := args["scale"].(float64); ok {
+		setup.Scale = scale
+	}
+	if v, ok := args["print_background"].(bool); ok {
+		setup.Background = v
+	}
+	set := anyArg(args, pageLayoutArgs...) || anyArg(args, pagePrintArgs...)
+	return setup, set, setup.Validate()
+}
+
+// imageArgs reads and validates PNG and JPEG export arguments. The second
+// result is true when any of them was given.
+func imageArgs(args map[string]interface{}) (export.ImageOptions, bool, error) {
+	opts := export.ImageOptions{
+		Mode:           strings.ToLower(stringArg(args, "image_mode")),
+		ViewportWidth:  intArg(args, "viewport_width"),
+		Quality:        intArg(args, "jpeg_quality"),
+		ThumbnailWidth: intArg(args, "thumbnail_width"),
+	}
+	if scale, ok := args["device_scale"].(float64); ok {
+		opts.DeviceScale = scale
+	}
+	return opts, anyArg(args, imageArgNames...), opts.Validate()
+}
+
+// checkImagePageSetup checks that an image export only uses the page
+// setup options it supports: paper size, orientation and margins, which
+// lay out split images
+func checkImagePageSetup(args map[string]interface{}, set bool, mode string) error {
+	if anyArg(args, pagePrintArgs...) {
+		return fmt.Errorf("png and jpeg export do not support scale, page_ranges, print_background or header and footer templates")
+	}
+	if mode != export.ImageSplit && set {
+		return fmt.Errorf("paper size, orientation and margins only apply to png and jpeg export with image_mode split")
+	}
+	return nil
+}
+
+// watermarkArgs reads and validates watermark arguments. The second result
+// is true when watermark is false, which turns off the stamp for the
+// document's status.
+func watermarkArgs(args map[string]interface{}) (export.Watermark, bool, error) {
+	w := export.Watermark{
+		Text:     stringArg(args, "watermark_text"),
+		Image:    stringArg(args, "watermark_image"),
+		Position: strings.ToLower(stringArg(args, "watermark_position")),
+	}
+	if opacity, ok := args["watermark_opacity"].(float64); ok {
+		w.Opacity = opacity
+	}
+	off := false
+	if v, ok := args["watermark"].(bool); ok {
+		off = !v
+	}
+	if off && (w.Text != "" || w.Image != "") {
+		return w, off, fmt.Errorf("watermark is false but watermark_text or watermark_image is given")
+	}
+	return w, off, w.Validate()
+}
+
+// bundleArgs reads and validates ZIP export arguments
+func bundleArgs(args map[string]interface{}) (export.BundleOptions, error) {
+	var opts export.BundleOptions
+	if v, ok := args["include_unreferenced_media"].(bool); ok {
+		opts.IncludeUnreferenced = v
+	}
+	if items, ok := args["renditions"].([]interface{}); ok {
+		for _, item := range items {
+			format, ok := item.(string)
+			if !ok {
+				return opts, fmt.Errorf("renditions must be an array of strings")
+			}
+			opts.Renditions = append(opts.Renditions, strings.ToLower(format))
+		}
+	}
+	return opts, opts.Validate()
+}
+
+// numberingOptions reads figure and heading numbering arguments. Without
+// number_figures, number_document numbers figures and tables, while an
+// export only numbers those of documents that use numbering (data-ref
+// links or labels from number_document), so captions that already say
+// "Figure 1" are not labelled twice. Headings are only numbered when
+// number_headings is true.
+func numberingOptions(args map[string]interface{}, exporting bool) export.NumberingOptions {
+	opts := export.NumberingOptions{
+		FigureLabel:  stringArg(args, "figure_label"),
+		TableLabel:   stringArg(args, "table_label"),
+		SectionLabel: stringArg(args, "section_label"),
+	}
+	if v, ok := args["number_figures"].(bool); ok {
+		opts.Figures = v
+	} else if exporting {
+		opts.AutoFigures = true
+	} else {
+		opts.Figures = true
+	}
+	if v, ok := args["number_headings"].(bool); ok {
+		opts.Headings = v
+	}
+	return opts
+}
+
+// optimizeOptions reads image optimization arguments. It returns nil when
+// none are given, so media is stored untouched.
+func optimizeOptions(args map[string]interface{}, stripByDefault bool) *imaging.Options {
+	opts := &imaging.Options{
+		MaxWidth:       intArg(args, "max_width"),
+		Quality:        intArg(args, "quality"),
+		StripMetadata:  stripByDefault,
+		ThumbnailWidth: intArg(args, "thumbnail_width"),
+	}
+	strip, hasStrip := args["strip_metadata"].(bool)
+	if hasStrip {
+		opts.StripMetadata = strip
+	}
+
+	if opts.MaxWidth == 0 && opts.Quality == 0 && opts.ThumbnailWidth == 0 && !hasStrip {
+		return nil
+	}
+	return opts
+}
+
+// intListArg reads an optional array of integers
+func intListArg(args map[string]interface{}, key string) []int {
+	items, ok := args[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	values := make([]int, 0, len(items))
+	for _, item := range items {
+		if v, ok := item.(float64); ok && v > 0 {
+			values = append(values, int(v))
+		}
+	}
+	return values
+}
+
+// anyArg reports whether any of the arguments was given; nulls and empty
+// strings count as not given
+func anyArg(args map[string]interface{}, keys ...string) bool {
+	for _, key := range keys {
+		if v, ok := args[key]; ok && v != nil && v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// stringArg reads an optional string argument
+func stringArg(args map[string]interface{}, key string) string {
+	value, _ := args[key].(string)
+	return value
+}
+
+// intArg reads an optional integer argument; JSON numbers arrive as float64
+func intArg(args map[string]interface{}, key string) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// decodeBase64 decodes standard base64, tolerating a data: URI prefix,
+// missing padding and line breaks
+func decodeBase64(content string) ([]byte, error) {
+	if strings.HasPrefix(content, "data:") {
+		if idx := strings.Index(content, ","); idx != -1 {
+			content = content[idx+1:]
+		}
+	}
+	content = strings.Join(strings.Fields(content), "")
+
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(content, "="))
+	}
+	return data, nil
+}
+
 func (h *Handler) successResponse(data map[string]interface{}) *protocol.CallToolResponse {
 	jsonData, _ := json.MarshalIndent(data, "", "  ")
 	return &protocol.CallToolResponse{
@@ -47,7 +47,7 @@ func (h *Handler) GetTools() []protocol.Tool {
 		},
 		{
 			Name:        "add_media",
-			Description: "Add an image or video file to a document. Copies the file to the document's media folder and returns the relative path to use in HTML.",
+			Description: "Add an image, video, audio file, font, SVG or downloadable attachment (PDF/CSV/ZIP) to a document. Provide exactly one of source_path (a file on the server's filesystem), content_base64 (base64-encoded bytes) or text_content (text such as a generated SVG). The real type is detected from the file content and mismatches are rejected. Returns the relative path, detected MIME type, dimensions or duration where known, and a ready-to-paste HTML snippet. File names are sanitized; adding identical content again returns the existing file, and a different file with a taken name gets a numeric suffix (chart-2.png) so earlier references never break.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
@@ -59,13 +59,60 @@ func (h *Handler) GetTools() []protocol.Tool {
 						"type": "string",
 						"description": "The absolute path to the source media file"
 					},
+					"content_base64": {
+						"type": "string",
+						"description": "Base64-encoded media content. Use instead of source_path when the file is not on the server's filesystem. Requires filename."
+					},
+					"text_content": {
+						"type": "string",
+						"description": "Text media content, e.g. an SVG chart you generated. Use instead of source_path. Requires filename."
+					},
+					"filename": {
+						"type": "string",
+						"description": "File name to store the media under (e.g. 'chart.svg'). Required with content_base64 or text_content."
+					},
 					"media_type": {
 						"type": "string",
-						"enum": ["image", "video"],
-						"description": "The type of media file"
+						"enum": ["image", "video", "audio", "font", "svg", "attachment"],
+						"description": "The type of media file. Optional; detected from the content when omitted."
+					},
+					"replace": {
+						"type": "string",
+						"description": "Optional existing media file to overwrite on purpose (e.g. 'media/chart.png'). References to it will show the new content."
+					},
+					"max_width": {
+						"type": "integer",
+						"description": "Optional. Downscale JPEG/PNG images wider than this many pixels, preserving aspect ratio."
+					},
+					"quality": {
+						"type": "integer",
+						"minimum": 1,
+						"maximum": 100,
+						"description": "Optional. Re-encode JPEG images at this quality (1-100). PNGs are recompressed losslessly."
+					},
+					"strip_metadata": {
+						"type": "boolean",
+						"description": "Optional. Remove EXIF (GPS location, camera details), XMP and text metadata. Photos are rotated upright first."
+					},
+					"thumbnail_width": {
+						"type": "integer",
+						"description": "Optional. Also generate a thumbnail of this width, stored as <name>-thumb.<ext>."
+					},
+					"responsive": {
+						"type": "boolean",
+						"description": "Optional. Generate responsive width variants (480, 960 and 1600px, skipping any not smaller than the image) and return an <img srcset sizes> snippet. PDF export automatically uses the highest-resolution variant."
+					},
+					"responsive_widths": {
+						"type": "array",
+						"items": {"type": "integer"},
+						"description": "Optional. Explicit variant widths in pixels, e.g. [400, 800, 1200]. Implies responsive."
+					},
+					"sizes": {
+						"type": "string",
+						"description": "Optional sizes attribute for the srcset snippet, e.g. '(max-width: 600px) 100vw, 50vw'. Defaults to the full viewport up to the image's natural width."
 					}
 				},
-				"required": ["document_id", "source_path", "media_type"]
+				"required": ["document_id"]
 			}`),
 		},
 		{
@@ -92,7 +139,7 @@ func (h *Handler) GetTools() []protocol.Tool {
 		},
 		{
 			Name:        "export_document",
-			Description: "Export an HTML document to a specified format (html, pdf, or docx). Returns the path to the exported file.",
+			Description: "Export an HTML document to a specified format (html, pdf, docx, or png/jpeg images rendered with headless Chrome). html_standalone writes one portable HTML file with local images, fonts, SVG, small media files and stylesheets inlined as data URIs. epub builds an EPUB 3 e-book in Go with one chapter per main heading, a table of contents, a cover (see set_document_properties) and embedded images. odt, rtf, pptx, latex, rst and asciidoc are made with Pandoc, like docx. markdown (GitHub-flavored, with pipe tables) and txt are converted in Go, with images pointing at relative media/ paths. zip packs index.html with the media files it references, optional PDF and DOCX renditions and a manifest.json with checksums. LaTeX math written as $...$, $$...$$, \\(...\\), \\[...\\] or in <span class=\"math\"> is rendered as MathML (native equations in DOCX). Code in <pre><code class=\"language-x\"> blocks is syntax highlighted. <cite data-key=\"...\"> elements are formatted as citations with a generated References section from the document's bibliography (see add_bibliography). PDFs get bookmarks from the headings and the properties set with set_document_properties, and can be made tagged or PDF/A-2b with pdf_profile. A watermark can be drawn over every page, and drafts are stamped automatically (see set_document_properties). Figure and table captions are numbered and <a href=\"#id\" data-ref></a> links are filled in with \"Figure 3\"-style text (see number_document). Returns the path to the exported file, and for images all files written, including one per part in split mode and thumbnails.",
 			InputSchema: json.RawMessage(`{
 				"type": "object",
 				"properties": {
@@ -102,16 +149,493 @@ func (h *Handler) GetTools() []protocol.Tool {
 					},
 					"format": {
 						"type": "string",
-						"enum": ["html", "pdf", "docx"],
+						"enum": ["html", "html_standalone", "pdf", "docx", "odt", "rtf", "pptx", "latex", "rst", "asciidoc", "epub", "markdown", "txt", "png", "jpeg", "zip"],
 						"description": "The export format"
 					},
 					"output_path": {
 						"type": "string",
-						"description": "Optional output file path. If not provided, exports to the document's directory."
+						"description": "Optional output file path. If not provided, exports to the document's directory. PDF paths get a .pdf extension if they lack one."
+					},
+					"code_theme": {
+						"type": "string",
+						"enum": ["print", "github", "monokai", "solarized-light", "monochrome"],
+						"description": "Syntax highlighting theme for code blocks (default: print, a light theme that stays readable in grayscale). DOCX uses the closest Pandoc highlight style."
+					},
+					"citation_style": {
+						"type": "string",
+						"enum": ["apa", "chicago", "ieee"],
+						"description": "Citation style (default: apa). apa and chicago are author-year, ieee is numbered in order of first citation."
+					},
+					"references_title": {
+						"type": "string",
+						"description": "Heading of the generated reference list (default: References). Not used when the document has an element with id=\"references\", which receives the list instead."
+					},
+					"number_figures": {
+						"type": "boolean",
+						"description": "Number captioned figures and tables as Figure 1, Table 1, ... (default: only for documents with data-ref links or labels from number_document, so captions that already say \"Figure 1\" are not labelled twice)"
+					},
+					"number_headings": {
+						"type": "boolean",
+						"description": "Number headings as 1, 1.1, 1.1.1, ... (default: false). A single h1 is treated as the title and numbering starts at h2; headings with class \"unnumbered\" are skipped."
+					},
+					"figure_label": {
+						"type": "string",
+						"description": "Word used for figures in captions and references (default: Figure)"
+					},
+					"table_label": {
+						"type": "string",
+						"description": "Word used for tables in captions and references (default: Table)"
+					},
+					"section_label": {
+						"type": "string",
+						"description": "Word used for numbered headings in references (default: Section)"
+					},
+					"paper_size": {
+						"type": "string",
+						"enum": ["a3", "a4", "a5", "legal", "letter", "tabloid", "custom"],
+						"description": "PDF only. Paper size; custom requires width and height. Default: the document's CSS @page size, or letter."
+					},
+					"width": {
+						"type": "string",
+						"description": "PDF only. Paper width for paper_size custom, e.g. \"210mm\", \"8.5in\", \"21cm\""
+					},
+					"height": {
+						"type": "string",
+						"description": "PDF only. Paper height for paper_size custom"
+					},
+					"orientation": {
+						"type": "string",
+						"enum": ["portrait", "landscape"],
+						"description": "PDF only. Page orientation (default: portrait)"
+					},
+					"margin": {
+						"type": "string",
+						"description": "PDF only. All four margins as a length such as \"0.5in\", \"2cm\" or \"15mm\" (default: 0.4in, or 0.75in above a header and below a footer)"
+					},
+					"margin_top": {
+						"type": "string",
+						"description": "PDF only. Top margin, overriding margin"
+					},
+					"margin_right": {
+						"type": "string",
+						"description": "PDF only. Right margin, overriding margin"
+					},
+					"margin_bottom": {
+						"type": "string",
+						"description": "PDF only. Bottom margin, overriding margin"
+					},
+					"margin_left": {
+						"type": "string",
+						"description": "PDF only. Left margin, overriding margin"
+					},
+					"scale": {
+						"type": "number",
+						"minimum": 0.1,
+						"maximum": 2,
+						"description": "PDF only. Rendering scale (default: 1)"
+					},
+					"page_ranges": {
+						"type": "string",
+						"description": "PDF only. Pages to include, e.g. \"1-5, 8, 11-13\" (default: all)"
+					},
+					"print_background": {
+						"type": "boolean",
+						"description": "PDF only. Print background colors and images (default: true)"
+					},
+					"header_template": {
+						"type": "string",
+						"description": "PDF only. HTML shown at the top of every page. Placeholders: {page}, {pages}, {title}, {date}. Example: \"{title}\""
+					},
+					"footer_template": {
+						"type": "string",
+						"description": "PDF only. HTML shown at the bottom of every page, with the same placeholders. Example: \"Page {page} of {pages}\""
+					},
+					"inline_max_bytes": {
+						"type": "integer",
+						"description": "html_standalone only. Largest file to inline, in bytes (default: 10485760, 10 MB); larger files stay as links and are listed in warnings"
+					},
+					"include_unreferenced_media": {
+						"type": "boolean",
+						"description": "zip only. Also pack media files the document does not reference (default: false)"
+					},
+					"renditions": {
+						"type": "array",
+						"items": {"type": "string", "enum": ["pdf", "docx"]},
+						"description": "zip only. Other formats to add to the archive as <document-id>.pdf and <document-id>.docx, exported with the same options"
+					},
+					"image_mode": {
+						"type": "string",
+						"enum": ["full", "split"],
+						"description": "PNG and JPEG only. full (default): one screenshot of the whole document; split: the print layout, set by paper_size, orientation and margins (default letter), cut into page-sized images. These are not the PDF's pages: breaks may fall elsewhere, and @page rules, headers and footers are not drawn"
+					},
+					"viewport_width": {
+						"type": "integer",
+						"description": "PNG and JPEG full mode only. Browser width in CSS pixels, 200 to 4000 (default: 1280)"
+					},
+					"device_scale": {
+						"type": "number",
+						"description": "PNG and JPEG only. Device pixel ratio from 0.5 to 4, e.g. 2 for sharp images on high-density screens (default: 1)"
+					},
+					"jpeg_quality": {
+						"type": "integer",
+						"description": "JPEG only. Quality from 1 to 100 (default: 90)"
+					},
+					"thumbnail_width": {
+						"type": "integer",
+						"description": "PNG and JPEG only. Also write a thumbnail of each image at this width in pixels (the top of the page in full mode)"
+					},
+					"watermark_text": {
+						"type": "string",
+						"description": "HTML, PDF and image exports only. Text drawn over every page, e.g. \"DRAFT\" or \"CONFIDENTIAL\". Without watermark_text or watermark_image, documents whose status is draft, review or confidential get a matching stamp"
+					},
+					"watermark_image": {
+						"type": "string",
+						"description": "HTML, PDF and image exports only. Image from the document's media folder drawn over every page instead of text, e.g. \"media/logo.png\""
+					},
+					"watermark_opacity": {
+						"type": "number",
+						"description": "Watermark opacity from 0 to 1 (default: 0.15)"
+					},
+					"watermark_position": {
+						"type": "string",
+						"enum": ["center", "top-left", "top-right", "bottom-left", "bottom-right"],
+						"description": "Watermark placement (default: center, where text runs diagonally)"
+					},
+					"watermark": {
+						"type": "boolean",
+						"description": "Set to false to leave out the stamp for the document's status (default: true)"
+					},
+					"pdf_profile": {
+						"type": "string",
+						"enum": ["standard", "tagged", "pdfa"],
+						"description": "PDF only. standard (default); tagged for accessible PDFs with structure tags, language and title; pdfa for PDF/A-2b archival files with XMP metadata and an sRGB output intent. Fonts are not embedded afterwards: they must already be embedded by Chrome or XeLaTeX, and a pdfa export that fails the PDF/A check (for example because of an unembedded font) is an error and no file is kept. For tagged, the result lists any conformance problems found"
 					}
 				},
 				"required": ["document_id", "format"]
 			}`),
 		},
+		{
+			Name:        "import_file",
+			Description: "Import a local DOCX, ODT, Markdown or reStructuredText file as a new HTML document using Pandoc. Embedded images are extracted into the document's media folder. Returns the new document ID and file path.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"source_path": {
+						"type": "string",
+						"description": "The absolute path to the file to import (.docx, .odt, .md, .markdown or .rst)"
+					},
+					"name": {
+						"type": "string",
+						"description": "Optional document name. Defaults to the file name without extension."
+					}
+				},
+				"required": ["source_path"]
+			}`),
+		},
+		{
+			Name:        "extract_inline_media",
+			Description: "Move inline base64 images (data: URIs in src attributes and CSS url()) out of a document's HTML into files in its media folder, named by content hash so identical images are stored once. References are rewritten to media/... paths. create_document and update_document do this automatically; use this tool for documents written before that.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"document_id": {
+						"type": "string",
+						"description": "The unique document ID"
+					}
+				},
+				"required": ["document_id"]
+			}`),
+		},
+		{
+			Name:        "optimize_media",
+			Description: "Optimize an existing JPEG or PNG image in a document's media folder in place: downscale to a maximum width, re-encode at a target quality, strip EXIF metadata (on by default) and optionally generate a thumbnail. Original and optimized sizes are recorded in the document metadata. Runs in pure Go.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"document_id": {
+						"type": "string",
+						"description": "The unique document ID"
+					},
+					"media_path": {
+						"type": "string",
+						"description": "The media file to optimize, as returned by add_media (e.g. 'media/photo.jpg')"
+					},
+					"max_width": {
+						"type": "integer",
+						"description": "Downscale images wider than this many pixels, preserving aspect ratio"
+					},
+					"quality": {
+						"type": "integer",
+						"minimum": 1,
+						"maximum": 100,
+						"description": "Re-encode JPEG images at this quality (1-100). PNGs are recompressed losslessly."
+					},
+					"strip_metadata": {
+						"type": "boolean",
+						"description": "Remove EXIF, XMP and text metadata (default: true)"
+					},
+					"thumbnail_width": {
+						"type": "integer",
+						"description": "Generate a thumbnail of this width, stored as <name>-thumb.<ext>"
+					}
+				},
+				"required": ["document_id", "media_path"]
+			}`),
+		},
+		{
+			Name:        "render_chart",
+			Description: "Render a bar, line, pie, scatter or stacked bar chart from data as an SVG file in the document's media folder. Axes, ticks and legends are computed for you, and the SVG looks the same on screen and in PDF export. Returns the relative path and an <img> snippet to paste into the HTML.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"document_id": {
+						"type": "string",
+						"description": "The unique document ID"
+					},
+					"chart_type": {
+						"type": "string",
+						"enum": ["bar", "line", "pie", "scatter", "stacked"],
+						"description": "The chart type. 'stacked' is a stacked bar chart."
+					},
+					"title": {
+						"type": "string",
+						"description": "Optional chart title"
+					},
+					"labels": {
+						"type": "array",
+						"items": {"type": "string"},
+						"description": "Category labels for bar/line/stacked charts, or slice labels for pie charts. One per value."
+					},
+					"series": {
+						"type": "array",
+						"description": "Data series. Pie charts take exactly one series.",
+						"items": {
+							"type": "object",
+							"properties": {
+								"name": {"type": "string", "description": "Series name shown in the legend"},
+								"values": {"type": "array", "items": {"type": "number"}, "description": "Y values (slice values for pie)"},
+								"x_values": {"type": "array", "items": {"type": "number"}, "description": "X values, scatter charts only"},
+								"color": {"type": "string", "description": "Optional CSS color, e.g. '#4e79a7'"}
+							},
+							"required": ["values"]
+						}
+					},
+					"colors": {
+						"type": "array",
+						"items": {"type": "string"},
+						"description": "Optional palette; used per slice for pie charts"
+					},
+					"x_label": {
+						"type": "string",
+						"description": "Optional x axis title"
+					},
+					"y_label": {
+						"type": "string",
+						"description": "Optional y axis title"
+					},
+					"width": {
+						"type": "integer",
+						"description": "Width in pixels (default 640)"
+					},
+					"height": {
+						"type": "integer",
+						"description": "Height in pixels (default 400)"
+					},
+					"filename": {
+						"type": "string",
+						"description": "Optional file name (defaults to the title). '.svg' is appended if missing."
+					},
+					"replace": {
+						"type": "string",
+						"description": "Optional existing chart to overwrite (e.g. 'media/revenue.svg') when re-rendering with new data"
+					}
+				},
+				"required": ["document_id", "chart_type", "series"]
+			}`),
+		},
+		{
+			Name:        "insert_table",
+			Description: "Insert a styled, accessible HTML table into a document from a CSV, TSV or JSON file, inline CSV text or inline JSON rows. Header cells get <thead>/<th scope=\"col\">, numeric columns are detected and right-aligned, and a shared print-friendly stylesheet is added to the document head once. The table goes at a placeholder, relative to a simple selector (tag, #id, .class), or at the end of <body>.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"document_id": {
+						"type": "string",
+						"description": "The document ID"
+					},
+					"source_path": {
+						"type": "string",
+						"description": "Absolute path to a .csv, .tsv or .json data file"
+					},
+					"content": {
+						"type": "string",
+						"description": "Inline CSV or TSV text (set format to 'tsv' for tabs)"
+					},
+					"data": {
+						"type": "array",
+						"description": "Inline rows: an array of objects (keys become columns) or an array of arrays"
+					},
+					"format": {
+						"type": "string",
+						"enum": ["csv", "tsv", "json"],
+						"description": "Data format; inferred from the file extension, defaults to csv for inline content"
+					},
+					"has_header": {
+						"type": "boolean",
+						"description": "Whether the first row holds column names (default true; ignored for arrays of objects)"
+					},
+					"caption": {
+						"type": "string",
+						"description": "Optional table caption"
+					},
+					"align": {
+						"type": "array",
+						"items": {"type": "string", "enum": ["left", "center", "right"]},
+						"description": "Optional per-column alignment; numeric columns default to right"
+					},
+					"decimals": {
+						"type": "integer",
+						"description": "Fixed number of decimals for numeric cells (default: keep values as given)"
+					},
+					"thousands_separator": {
+						"type": "boolean",
+						"description": "Group digits of numeric cells with commas (default false)"
+					},
+					"placeholder": {
+						"type": "string",
+						"description": "Literal text in the document to replace with the table, e.g. '<!-- sales-table -->'"
+					},
+					"selector": {
+						"type": "string",
+						"description": "Simple selector for the target element: 'tag', '#id' or '.class' (first match)"
+					},
+					"position": {
+						"type": "string",
+						"enum": ["append", "prepend", "before", "after", "replace"],
+						"description": "Where to insert relative to the selector (default append)"
+					}
+				},
+				"required": ["document_id"]
+			}`),
+		},
+		{
+			Name:        "add_bibliography",
+			Description: "Attach a BibTeX (.bib) or CSL JSON bibliography to a document, replacing any previous one. Cite sources in the HTML with <cite data-key=\"smith2020\"></cite> (several keys separated by commas; optional data-locator=\"12\" for pages and data-mode=\"narrative\" for Smith (2020)). Citations and a References section are generated at export. Returns the citation keys found.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"document_id": {
+						"type": "string",
+						"description": "The unique document ID"
+					},
+					"source_path": {
+						"type": "string",
+						"description": "Absolute path to a .bib or CSL .json file. Provide either source_path or content."
+					},
+					"content": {
+						"type": "string",
+						"description": "Bibliography content as text. Provide either source_path or content."
+					},
+					"format": {
+						"type": "string",
+						"enum": ["bibtex", "csl-json"],
+						"description": "Bibliography format. Detected from the file extension or content if omitted."
+					}
+				},
+				"required": ["document_id"]
+			}`),
+		},
+		{
+			Name:        "number_document",
+			Description: "Number the figure and table captions (and optionally the headings) of a document and fill in cross-references, rewriting the stored HTML. Give a <figure>, <table> or heading an id and link to it with <a href=\"#fig-x\" data-ref></a>; the link text becomes \"Figure 3\" (or just \"3\" with data-ref=\"number\"). Numbers from an earlier run are replaced, so it is safe to run again after editing. Export applies the same numbering, so calling this is only needed to see the numbers in the stored document.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"document_id": {
+						"type": "string",
+						"description": "The unique document ID"
+					},
+					"number_figures": {
+						"type": "boolean",
+						"description": "Number captioned figures and tables as Figure 1, Table 1, ... (default: true)"
+					},
+					"number_headings": {
+						"type": "boolean",
+						"description": "Number headings as 1, 1.1, 1.1.1, ... (default: false). A single h1 is treated as the title and numbering starts at h2; headings with class \"unnumbered\" are skipped."
+					},
+					"figure_label": {
+						"type": "string",
+						"description": "Word used for figures in captions and references (default: Figure)"
+					},
+					"table_label": {
+						"type": "string",
+						"description": "Word used for tables in captions and references (default: Table)"
+					},
+					"section_label": {
+						"type": "string",
+						"description": "Word used for numbered headings in references (default: Section)"
+					}
+				},
+				"required": ["document_id"]
+			}`),
+		},
+		{
+			Name:        "set_document_properties",
+			Description: "Set a document's title, author, subject, keywords, status and cover image. They are written into the document information of PDF exports and the metadata of EPUB exports, along with the creation and modification dates. A status of draft, review or confidential stamps HTML and PDF exports with DRAFT, FOR REVIEW or CONFIDENTIAL. Only the given properties change; pass an empty string or array to clear one.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"document_id": {
+						"type": "string",
+						"description": "The unique document ID"
+					},
+					"title": {
+						"type": "string",
+						"description": "Document title (default: the document name)"
+					},
+					"author": {
+						"type": "string",
+						"description": "Author or authors"
+					},
+					"subject": {
+						"type": "string",
+						"description": "Short description of the content"
+					},
+					"keywords": {
+						"type": "array",
+						"items": {"type": "string"},
+						"description": "Keywords"
+					},
+					"status": {
+						"type": "string",
+						"description": "Workflow status such as draft, review, confidential or final"
+					},
+					"cover_image": {
+						"type": "string",
+						"description": "Image in the document's media folder used as the cover of EPUB exports, e.g. media/cover.jpg (default: media/cover.jpg, .png, .webp, .gif or .svg when present)"
+					}
+				},
+				"required": ["document_id"]
+			}`),
+		},
+		{
+			Name:        "verify_pdf",
+			Description: "Check a PDF file against a profile and list the conformance problems found. tagged checks the structure tree, tagged marking, language, title and title display; pdfa checks PDF/A-2b requirements such as XMP identification, the output intent, embedded fonts, printable annotations and the absence of JavaScript. These checks are not a full validator.",
+			InputSchema: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"path": {
+						"type": "string",
+						"description": "The absolute path to the PDF file"
+					},
+					"profile": {
+						"type": "string",
+						"enum": ["standard", "tagged", "pdfa"],
+						"description": "The profile to check against"
+					}
+				},
+				"required": ["path", "profile"]
+			}`),
+		},
 	}
 }
@@ -0,0 +1,103 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		lang string
+		code string
+		want []Token // Tokens that must be among the result
+	}{
+		{"go", "func main() {\n\t// hi\n\tx := \"a\\\"b\" + 0x1F\n\treturn nil\n}",
+			[]Token{{Keyword, "func"}, {Function, "main"}, {Comment, "// hi"}, {String, `"a\"b"`}, {Number, "0x1F"}, {Keyword, "return"}, {Constant, "nil"}}},
+		{"py", "@dec\ndef f(x):\n    return 1 # c\n\"\"\"doc\nstring\"\"\"",
+			[]Token{{Decorator, "@dec"}, {Keyword, "def"}, {Function, "f"}, {Comment, "# c"}, {String, "\"\"\"doc\nstring\"\"\""}}},
+		{"bash", `echo $HOME "${X}" # note`,
+			[]Token{{Builtin, "echo"}, {Variable, "$HOME"}, {String, `"${X}"`}, {Comment, "# note"}}},
+		{"html", `<a href="x">t &amp; u</a><!-- c -->`,
+			[]Token{{Tag, "<a"}, {Attribute, "href"}, {String, `"x"`}, {Constant, "&amp;"}, {Comment, "<!-- c -->"}}},
+		{"diff", "--- a\n+++ b\n@@ -1 +1 @@\n-old\n+new\n ctx",
+			[]Token{{Heading, "--- a\n"}, {Preprocessor, "@@ -1 +1 @@\n"}, {Deleted, "-old\n"}, {Inserted, "+new\n"}}},
+		{"json", `{"a": [1, true, null]}`,
+			[]Token{{Property, `"a"`}, {Number, "1"}, {Constant, "true"}, {Constant, "null"}}},
+	}
+	for _, tt := range tests {
+		tokens, ok := Tokenize(tt.code, tt.lang)
+		if !ok {
+			t.Errorf("%s: not supported", tt.lang)
+			continue
+		}
+		var text strings.Builder
+		for _, token := range tokens {
+			text.WriteString(token.Text)
+		}
+		if text.String() != tt.code {
+			t.Errorf("%s: tokens join to %q, want the code back", tt.lang, text.String())
+		}
+		for _, want := range tt.want {
+			found := false
+			for _, token := range tokens {
+				found = found || token == want
+			}
+			if !found {
+				t.Errorf("%s: no %v token %q in %v", tt.lang, want.Type, want.Text, tokens)
+			}
+		}
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	if _, ok := Tokenize("x", "cobol"); ok {
+		t.Error("unknown language tokenized")
+	}
+	for alias, pandoc := range map[string]string{"js": "javascript", "PY": "python", "yml": "yaml", "sh": "bash", "cobol": ""} {
+		if got := PandocLanguage(alias); got != pandoc {
+			t.Errorf("PandocLanguage(%q) = %q, want %q", alias, got, pandoc)
+		}
+		if Supported(alias) != (pandoc != "") {
+			t.Errorf("Supported(%q) = %v", alias, Supported(alias))
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	theme, ok := GetTheme("")
+	if !ok || theme != Themes[DefaultTheme] {
+		t.Fatal("GetTheme(\"\") does not return the default theme")
+	}
+	got, ok := HTML("x := \"<a>\" /* one\ntwo */", "go", theme)
+	if !ok {
+		t.Fatal("go not supported")
+	}
+	if strings.Contains(got, "<a>") || !strings.Contains(got, "&lt;a&gt;") {
+		t.Errorf("code not escaped: %s", got)
+	}
+	// Spans never cross lines
+	for _, line := range strings.Split(got, "\n") {
+		if strings.Count(line, "<span") != strings.Count(line, "</span>") {
+			t.Errorf("span crosses a line: %q", line)
+		}
+	}
+	if !strings.HasPrefix(got, "x ") {
+		t.Errorf("plain text styled: %s", got)
+	}
+}
+
+func TestThemes(t *testing.T) {
+	names := ThemeNames()
+	if len(names) != len(Themes) {
+		t.Fatalf("ThemeNames() = %v", names)
+	}
+	for _, name := range names {
+		theme, ok := GetTheme(strings.ToUpper(name))
+		if !ok || theme == nil {
+			t.Errorf("GetTheme(%q) failed", name)
+		}
+	}
+	if _, ok := GetTheme("nope"); ok {
+		t.Error("unknown theme found")
+	}
+}
@@ -0,0 +1,264 @@
+package highlight
+
+import "strings"
+
+// stringDelim describes one kind of string literal
+type stringDelim struct {
+	open, close string
+	escape      bool // Backslash escapes the next character
+	multiline   bool
+}
+
+// language describes the lexical structure of a programming language
+type language struct {
+	name            string // Canonical name, also understood by Pandoc
+	keywords        map[string]bool
+	types           map[string]bool
+	builtins        map[string]bool
+	constants       map[string]bool
+	lineComments    []string
+	blockComments   [][2]string
+	strings         []stringDelim
+	caseInsensitive bool   // Keywords match in any case (SQL)
+	preprocessor    bool   // Lines starting with # are directives (C, C++, C#)
+	decorators      bool   // @name annotations
+	variables       bool   // $name variables (shell, PHP, Perl)
+	stringPrefixes  string // Letters that may prefix a string literal (Python r"", f"")
+	identExtra      string // Extra identifier characters, such as $ in JavaScript or - in CSS
+	propertyKeys    bool   // Keys followed by ':' are properties (JSON, YAML, CSS)
+	lex             func(code string) []Token
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cStrings      = []stringDelim{{`"`, `"`, true, false}, {`'`, `'`, true, false}}
+	cComments     = [][2]string{{"/*", "*/"}}
+	scriptStrings = []stringDelim{{`"`, `"`, true, false}, {`'`, `'`, true, false}, {"`", "`", true, true}}
+)
+
+var jsKeywords = "async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new of return static super switch this throw try typeof var void while with yield get set"
+
+var languages = map[string]*language{
+	"go": {
+		name:          "go",
+		keywords:      words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var"),
+		types:         words("bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr any comparable"),
+		builtins:      words("append cap clear close complex copy delete imag len make max min new panic print println real recover"),
+		constants:     words("true false nil iota"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       []stringDelim{{`"`, `"`, true, false}, {`'`, `'`, true, false}, {"`", "`", false, true}},
+	},
+	"python": {
+		name:           "python",
+		keywords:       words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield match case"),
+		types:          words("int float complex str bytes bytearray bool list tuple dict set frozenset object type"),
+		builtins:       words("abs all any ascii bin callable chr classmethod compile delattr dir divmod enumerate eval exec filter format getattr globals hasattr hash help hex id input isinstance issubclass iter len locals map max min next oct open ord pow print property range repr reversed round setattr slice sorted staticmethod sum super vars zip self cls"),
+		constants:      words("True False None"),
+		lineComments:   []string{"#"},
+		strings:        []stringDelim{{`"""`, `"""`, true, true}, {`'''`, `'''`, true, true}, {`"`, `"`, true, false}, {`'`, `'`, true, false}},
+		decorators:     true,
+		stringPrefixes: "rRbBfFuU",
+	},
+	"javascript": {
+		name:          "javascript",
+		keywords:      words(jsKeywords),
+		builtins:      words("console window document Math JSON Promise Array Object String Number Boolean Symbol Map Set Date RegExp Error parseInt parseFloat require module exports"),
+		constants:     words("true false null undefined NaN Infinity"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       scriptStrings,
+		identExtra:    "$",
+	},
+	"typescript": {
+		name:          "typescript",
+		keywords:      words(jsKeywords + " abstract as declare enum implements interface keyof namespace private protected public readonly type satisfies is infer"),
+		types:         words("string number boolean any unknown never void object bigint symbol"),
+		builtins:      words("console window document Math JSON Promise Array Object String Number Boolean Symbol Map Set Date RegExp Error Record Partial Readonly Pick Omit"),
+		constants:     words("true false null undefined NaN Infinity"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       scriptStrings,
+		decorators:    true,
+		identExtra:    "$",
+	},
+	"java": {
+		name:          "java",
+		keywords:      words("abstract assert break case catch class continue default do else enum extends final finally for if implements import instanceof interface native new package private protected public return static strictfp super switch synchronized this throw throws transient try var volatile while record yield sealed permits"),
+		types:         words("boolean byte char double float int long short void String Object Integer Long Double List Map Set"),
+		constants:     words("true false null"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       []stringDelim{{`"""`, `"""`, true, true}, {`"`, `"`, true, false}, {`'`, `'`, true, false}},
+		decorators:    true,
+	},
+	"kotlin": {
+		name:          "kotlin",
+		keywords:      words("as break class continue do else for fun if in interface is object package return super this throw try typealias val var when while by catch constructor companion data enum final finally get import init inline internal lateinit open override private protected public sealed set suspend"),
+		types:         words("Any Boolean Byte Char Double Float Int Long Nothing Short String Unit List Map Set Array"),
+		constants:     words("true false null"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       []stringDelim{{`"""`, `"""`, false, true}, {`"`, `"`, true, false}, {`'`, `'`, true, false}},
+		decorators:    true,
+	},
+	"swift": {
+		name:          "swift",
+		keywords:      words("associatedtype class deinit enum extension fileprivate func import init inout internal let open operator private protocol public rethrows static struct subscript typealias var break case continue default defer do else fallthrough for guard if in repeat return switch where while as catch is super self Self throw throws try async await some any"),
+		types:         words("Int Double Float Bool String Character Array Dictionary Set Optional Void"),
+		constants:     words("true false nil"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       []stringDelim{{`"""`, `"""`, true, true}, {`"`, `"`, true, false}},
+		decorators:    true,
+	},
+	"c": {
+		name:          "c",
+		keywords:      words("auto break case const continue default do else enum extern for goto if inline register restrict return sizeof static struct switch typedef union volatile while"),
+		types:         words("char double float int long short signed unsigned void bool size_t ssize_t uint8_t uint16_t uint32_t uint64_t int8_t int16_t int32_t int64_t FILE"),
+		builtins:      words("printf fprintf sprintf snprintf malloc calloc realloc free memcpy memset strlen strcmp strcpy fopen fclose"),
+		constants:     words("NULL true false"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       cStrings,
+		preprocessor:  true,
+	},
+	"cpp": {
+		name:          "cpp",
+		keywords:      words("alignas alignof auto break case catch class const constexpr const_cast continue decltype default delete do dynamic_cast else enum explicit export extern for friend goto if inline mutable namespace new noexcept operator private protected public register reinterpret_cast return sizeof static static_assert static_cast struct switch template this throw try typedef typeid typename union using virtual volatile while override final co_await co_return co_yield concept requires"),
+		types:         words("bool char char16_t char32_t double float int long short signed unsigned void wchar_t size_t string vector map set unique_ptr shared_ptr"),
+		builtins:      words("std cout cin cerr endl printf malloc free move forward"),
+		constants:     words("true false nullptr NULL"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       cStrings,
+		preprocessor:  true,
+	},
+	"csharp": {
+		name:          "cs",
+		keywords:      words("abstract as async await base break case catch checked class const continue default delegate do else enum event explicit extern finally fixed for foreach goto if implicit in interface internal is lock namespace new operator out override params private protected public readonly record ref return sealed sizeof stackalloc static struct switch this throw try typeof unchecked unsafe using var virtual volatile while yield get set init"),
+		types:         words("bool byte char decimal double float int long object sbyte short string uint ulong ushort void dynamic String List Dictionary Task"),
+		constants:     words("true false null"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       []stringDelim{{`@"`, `"`, false, true}, {`"`, `"`, true, false}, {`'`, `'`, true, false}},
+		preprocessor:  true,
+	},
+	"rust": {
+		name:          "rust",
+		keywords:      words("as async await break const continue crate dyn else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while"),
+		types:         words("bool char f32 f64 i8 i16 i32 i64 i128 isize str u8 u16 u32 u64 u128 usize String Vec Option Result Box Rc Arc HashMap"),
+		builtins:      words("println print eprintln format vec panic assert assert_eq Some None Ok Err"),
+		constants:     words("true false"),
+		lineComments:  []string{"//"},
+		blockComments: cComments,
+		strings:       []stringDelim{{`"`, `"`, true, true}},
+	},
+	"ruby": {
+		name:          "ruby",
+		keywords:      words("alias and begin break case class def defined do else elsif end ensure for if in module next not or redo rescue retry return self super then undef unless until when while yield require require_relative attr_accessor attr_reader attr_writer include extend private protected public"),
+		builtins:      words("puts print p raise lambda proc loop"),
+		constants:     words("true false nil"),
+		lineComments:  []string{"#"},
+		blockComments: [][2]string{{"=begin", "=end"}},
+		strings:       []stringDelim{{`"`, `"`, true, true}, {`'`, `'`, true, true}},
+	},
+	"php": {
+		name:          "php",
+		keywords:      words("abstract and array as break callable case catch class clone const continue declare default do echo else elseif empty enddeclare endfor endforeach endif endswitch endwhile extends final finally fn for foreach function global goto if implements include include_once instanceof insteadof interface isset list match namespace new or print private protected public readonly require require_once return static switch throw trait try unset use var while yield"),
+		types:         words("int float bool string array object mixed void iterable"),
+		constants:     words("true false null TRUE FALSE NULL"),
+		lineComments:  []string{"//", "#"},
+		blockComments: cComments,
+		strings:       []stringDelim{{`"`, `"`, true, true}, {`'`, `'`, true, true}},
+		variables:     true,
+	},
+	"bash": {
+		name:         "bash",
+		keywords:     words("if then else elif fi case esac for while until do done in function select time return exit break continue local export readonly declare unset shift source"),
+		builtins:     words("echo printf read cd pwd ls cat grep sed awk find xargs test set eval exec trap wait kill mkdir rm cp mv chmod chown curl wget git sudo"),
+		constants:    words("true false"),
+		lineComments: []string{"#"},
+		strings:      []stringDelim{{`"`, `"`, true, true}, {`'`, `'`, false, true}},
+		variables:    true,
+		identExtra:   "-",
+	},
+	"sql": {
+		name:            "sql",
+		keywords:        words("select from where and or not insert into values update set delete create table alter drop index view as join inner left right outer full cross on group by order having limit offset union all distinct case when then else end is null in between like exists primary key foreign references default constraint unique check begin commit rollback transaction with recursive returning if replace"),
+		types:           words("int integer bigint smallint decimal numeric float real double precision varchar char text date time timestamp boolean blob json jsonb uuid serial"),
+		builtins:        words("count sum avg min max coalesce nullif cast now lower upper length substring trim round"),
+		constants:       words("true false null"),
+		lineComments:    []string{"--"},
+		blockComments:   cComments,
+		strings:         []stringDelim{{`'`, `'`, false, true}, {`"`, `"`, false, false}},
+		caseInsensitive: true,
+	},
+	"json": {
+		name:         "json",
+		constants:    words("true false null"),
+		strings:      []stringDelim{{`"`, `"`, true, false}},
+		propertyKeys: true,
+	},
+	"yaml": {
+		name:         "yaml",
+		constants:    words("true false null yes no on off True False Null"),
+		lineComments: []string{"#"},
+		strings:      []stringDelim{{`"`, `"`, true, false}, {`'`, `'`, false, false}},
+		propertyKeys: true,
+		identExtra:   "-.",
+	},
+	"css": {
+		name:          "css",
+		keywords:      words("important"),
+		constants:     words("inherit initial unset none auto"),
+		blockComments: cComments,
+		strings:       cStrings,
+		identExtra:    "-",
+		propertyKeys:  true,
+		decorators:    true,
+	},
+	"html": {name: "html", lex: lexMarkup},
+	"xml":  {name: "xml", lex: lexMarkup},
+	"diff": {name: "diff", lex: lexDiff},
+}
+
+// aliases maps common language class names to their definitions
+var aliases = map[string]string{
+	"golang": "go", "py": "python", "python3": "python", "js": "javascript",
+	"jsx": "javascript", "node": "javascript", "ts": "typescript", "tsx": "typescript",
+	"kt": "kotlin", "h": "c", "c++": "cpp", "cc": "cpp", "hpp": "cpp", "cs": "csharp",
+	"c#": "csharp", "rs": "rust", "rb": "ruby", "sh": "bash", "shell": "bash",
+	"zsh": "bash", "console": "bash", "postgresql": "sql", "mysql": "sql",
+	"sqlite": "sql", "yml": "yaml", "htm": "html", "xhtml": "html", "svg": "xml",
+	"patch": "diff", "scss": "css",
+}
+
+// lookup returns the definition of a language by name or alias
+func lookup(name string) *language {
+	name = strings.ToLower(name)
+	if canonical, ok := aliases[name]; ok {
+		name = canonical
+	}
+	return languages[name]
+}
+
+// Supported reports whether a language name or alias can be highlighted
+func Supported(name string) bool {
+	return lookup(name) != nil
+}
+
+// PandocLanguage returns the language name Pandoc's highlighter uses, or ""
+func PandocLanguage(name string) string {
+	if lang := lookup(name); lang != nil {
+		return lang.name
+	}
+	return ""
+}
@@ -0,0 +1,435 @@
+package highlight
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TokenType classifies a piece of source code
+type TokenType int
+
+const (
+	Plain TokenType = iota
+	Keyword
+	Type
+	Builtin
+	Constant
+	String
+	Number
+	Comment
+	Function
+	Operator
+	Punctuation
+	Preprocessor
+	Decorator
+	Variable
+	Attribute
+	Tag
+	Property
+	Inserted
+	Deleted
+	Heading
+)
+
+// Token is a run of source text of one type
+type Token struct {
+	Type TokenType
+	Text string
+}
+
+var (
+	numberRegex   = regexp.MustCompile(`^(?:0[xX][0-9a-fA-F_]+|0[bB][01_]+|0[oO][0-7_]+|(?:\d[\d_]*(?:\.[\d_]*)?|\.\d[\d_]*)(?:[eE][+-]?\d+)?)[a-zA-Z]*`)
+	hexColorRegex = regexp.MustCompile(`^#(?:[0-9a-fA-F]{8}|[0-9a-fA-F]{6}|[0-9a-fA-F]{3,4})\b`)
+)
+
+const operatorChars = "+-*/%=<>!&|^~?:"
+
+// Tokenize splits code into typed tokens. It returns false if the
+// language is not supported.
+func Tokenize(code, langName string) ([]Token, bool) {
+	lang := lookup(langName)
+	if lang == nil {
+		return nil, false
+	}
+	if lang.lex != nil {
+		return lang.lex(code), true
+	}
+	return lexGeneric(code, lang), true
+}
+
+// lexer accumulates tokens, merging adjacent runs of the same type
+type lexer struct {
+	tokens []Token
+}
+
+func (l *lexer) emit(t TokenType, text string) {
+	if text == "" {
+		return
+	}
+	if n := len(l.tokens); n > 0 && l.tokens[n-1].Type == t && (t == Plain || t == Comment || t == Operator) {
+		l.tokens[n-1].Text += text
+		return
+	}
+	l.tokens = append(l.tokens, Token{Type: t, Text: text})
+}
+
+// lexGeneric tokenizes C-like, scripting and data languages from their
+// definition: comments, strings, numbers, identifiers and operators
+func lexGeneric(code string, lang *language) []Token {
+	l := &lexer{}
+	i := 0
+	lineStart := true
+
+	isIdentStart := func(c byte) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80 ||
+			(lang.identExtra != "" && c != '-' && c != '.' && strings.IndexByte(lang.identExtra, c) >= 0)
+	}
+	isIdent := func(c byte) bool {
+		return isIdentStart(c) || (c >= '0' && c <= '9') || (lang.identExtra != "" && strings.IndexByte(lang.identExtra, c) >= 0)
+	}
+
+outer:
+	for i < len(code) {
+		c := code[i]
+
+		// Whitespace
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			j := i
+			for j < len(code) && strings.IndexByte(" \t\r\n", code[j]) >= 0 {
+				if code[j] == '\n' {
+					lineStart = true
+				}
+				j++
+			}
+			l.emit(Plain, code[i:j])
+			i = j
+			continue
+		}
+		atLineStart := lineStart
+		lineStart = false
+
+		// Preprocessor directives run to the end of the line
+		if lang.preprocessor && atLineStart && c == '#' {
+			j := endOfLine(code, i, true)
+			l.emit(Preprocessor, code[i:j])
+			i = j
+			continue
+		}
+
+		// Comments
+		for _, prefix := range lang.lineComments {
+			if strings.HasPrefix(code[i:], prefix) && (prefix != "#" || i == 0 || !isIdent(code[i-1]) && code[i-1] != '$' && code[i-1] != '{') {
+				j := endOfLine(code, i, false)
+				l.emit(Comment, code[i:j])
+				i = j
+				continue outer
+			}
+		}
+		for _, delims := range lang.blockComments {
+			if strings.HasPrefix(code[i:], delims[0]) {
+				j := strings.Index(code[i+len(delims[0]):], delims[1])
+				end := len(code)
+				if j != -1 {
+					end = i + len(delims[0]) + j + len(delims[1])
+				}
+				l.emit(Comment, code[i:end])
+				i = end
+				continue outer
+			}
+		}
+
+		// Strings
+		if end := matchString(code, i, lang.strings); end > i {
+			tokenType := String
+			if lang.propertyKeys && nextNonSpace(code, end) == ':' {
+				tokenType = Property
+			}
+			l.emit(tokenType, code[i:end])
+			i = end
+			continue
+		}
+
+		// Numbers
+		if (c >= '0' && c <= '9') || (c == '.' && i+1 < len(code) && code[i+1] >= '0' && code[i+1] <= '9') {
+			if i == 0 || !isIdent(code[i-1]) {
+				if m := numberRegex.FindString(code[i:]); m != "" {
+					l.emit(Number, m)
+					i += len(m)
+					continue
+				}
+			}
+		}
+
+		// CSS hex colors
+		if lang.name == "css" && c == '#' {
+			if m := hexColorRegex.FindString(code[i:]); m != "" {
+				l.emit(Number, m)
+				i += len(m)
+				continue
+			}
+		}
+
+		// Decorators and at-rules
+		if lang.decorators && c == '@' && i+1 < len(code) && isIdentStart(code[i+1]) {
+			j := i + 1
+			for j < len(code) && (isIdent(code[j]) || code[j] == '.') {
+				j++
+			}
+			l.emit(Decorator, code[i:j])
+			i = j
+			continue
+		}
+
+		// Variables
+		if lang.variables && c == '$' && i+1 < len(code) {
+			j := i + 1
+			if code[j] == '{' {
+				if k := strings.IndexByte(code[j:], '}'); k != -1 {
+					j += k + 1
+				}
+			} else {
+				for j < len(code) && (isIdent(code[j]) && code[j] != '-') {
+					j++
+				}
+				if j == i+1 && strings.IndexByte("?#@*!$0123456789", code[j]) >= 0 {
+					j++
+				}
+			}
+			if j > i+1 {
+				l.emit(Variable, code[i:j])
+				i = j
+				continue
+			}
+		}
+
+		// Identifiers, keywords and string prefixes
+		if isIdentStart(c) {
+			j := i
+			for j < len(code) && isIdent(code[j]) {
+				j++
+			}
+			word := code[i:j]
+
+			if lang.stringPrefixes != "" && len(word) <= 2 && strings.Trim(word, lang.stringPrefixes) == "" {
+				if end := matchString(code, j, lang.strings); end > j {
+					l.emit(String, code[i:end])
+					i = end
+					continue
+				}
+			}
+
+			l.emit(classifyWord(lang, word, code, j, atLineStart), word)
+			i = j
+			continue
+		}
+
+		// Operators and punctuation
+		if strings.IndexByte(operatorChars, c) >= 0 {
+			l.emit(Operator, code[i:i+1])
+		} else {
+			l.emit(Punctuation, code[i:i+1])
+		}
+		i++
+	}
+
+	return l.tokens
+}
+
+// classifyWord decides the token type of an identifier ending at end
+func classifyWord(lang *language, word, code string, end int, atLineStart bool) TokenType {
+	key := word
+	if lang.caseInsensitive {
+		key = strings.ToLower(word)
+	}
+	switch {
+	case lang.keywords[key]:
+		return Keyword
+	case lang.types[key]:
+		return Type
+	case lang.constants[key]:
+		return Constant
+	case lang.builtins[key]:
+		return Builtin
+	}
+
+	next := nextNonSpace(code, end)
+	if lang.propertyKeys && next == ':' {
+		// YAML keys start a line; CSS properties sit inside a rule
+		if lang.name != "yaml" || atLineStart || lineHasOnlyDashesBefore(code, end-len(word)) {
+			return Property
+		}
+	}
+	if next == '(' && lang.name != "css" {
+		return Function
+	}
+	if word[0] >= 'A' && word[0] <= 'Z' && (lang.name == "go" || lang.name == "rust" || lang.name == "java" ||
+		lang.name == "cs" || lang.name == "kotlin" || lang.name == "swift" || lang.name == "typescript") {
+		// Capitalized names are usually types in these languages
+		return Type
+	}
+	return Plain
+}
+
+// lineHasOnlyDashesBefore reports whether a YAML list item marker is all
+// that precedes position i on its line ("- key: value")
+func lineHasOnlyDashesBefore(code string, i int) bool {
+	start := strings.LastIndexByte(code[:i], '\n') + 1
+	return strings.Trim(code[start:i], " -") == "" && strings.Contains(code[start:i], "-")
+}
+
+// matchString returns the end of a string literal starting at i, or i if
+// none starts there. Longer delimiters are listed first.
+func matchString(code string, i int, delims []stringDelim) int {
+	for _, d := range delims {
+		if !strings.HasPrefix(code[i:], d.open) {
+			continue
+		}
+		j := i + len(d.open)
+		for j < len(code) {
+			if d.escape && code[j] == '\\' {
+				j += 2
+				continue
+			}
+			if strings.HasPrefix(code[j:], d.close) {
+				return j + len(d.close)
+			}
+			if code[j] == '\n' && !d.multiline {
+				return j
+			}
+			j++
+		}
+		return len(code)
+	}
+	return i
+}
+
+// endOfLine returns the position of the next newline, optionally honoring
+// backslash line continuations
+func endOfLine(code string, i int, continuations bool) int {
+	for {
+		j := strings.IndexByte(code[i:], '\n')
+		if j == -1 {
+			return len(code)
+		}
+		end := i + j
+		if continuations && end > 0 && code[end-1] == '\\' {
+			i = end + 1
+			continue
+		}
+		return end
+	}
+}
+
+// nextNonSpace returns the next character after i that is not a space or tab
+func nextNonSpace(code string, i int) byte {
+	for ; i < len(code); i++ {
+		if code[i] != ' ' && code[i] != '\t' {
+			return code[i]
+		}
+	}
+	return 0
+}
+
+var (
+	markupTokenRegex = regexp.MustCompile(`(?s)^(?:<!--.*?(?:-->|$)|<!\[CDATA\[.*?(?:\]\]>|$)|<[!?][^>]*>?)`)
+	markupTagRegex   = regexp.MustCompile(`^</?[A-Za-z][\w:.-]*`)
+	markupAttrRegex  = regexp.MustCompile(`^[^\s"'>/=]+`)
+)
+
+// lexMarkup tokenizes HTML and XML: tags, attributes, values, comments and entities
+func lexMarkup(code string) []Token {
+	l := &lexer{}
+	i := 0
+	for i < len(code) {
+		if m := markupTokenRegex.FindString(code[i:]); m != "" {
+			tokenType := Comment
+			if !strings.HasPrefix(m, "<!--") {
+				tokenType = Preprocessor
+			}
+			l.emit(tokenType, m)
+			i += len(m)
+			continue
+		}
+
+		if tag := markupTagRegex.FindString(code[i:]); tag != "" {
+			l.emit(Tag, tag)
+			i += len(tag)
+			// Attributes until the end of the tag
+			for i < len(code) {
+				c := code[i]
+				switch {
+				case c == '>':
+					l.emit(Tag, ">")
+					i++
+				case c == '/' && strings.HasPrefix(code[i:], "/>"):
+					l.emit(Tag, "/>")
+					i += 2
+				case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+					l.emit(Plain, code[i:i+1])
+					i++
+					continue
+				case c == '=':
+					l.emit(Operator, "=")
+					i++
+					continue
+				case c == '"' || c == '\'':
+					end := strings.IndexByte(code[i+1:], c)
+					if end == -1 {
+						end = len(code) - i - 2
+					}
+					l.emit(String, code[i:i+end+2])
+					i += end + 2
+					continue
+				default:
+					if attr := markupAttrRegex.FindString(code[i:]); attr != "" {
+						l.emit(Attribute, attr)
+						i += len(attr)
+					} else {
+						l.emit(Punctuation, code[i:i+1])
+						i++
+					}
+					continue
+				}
+				break
+			}
+			continue
+		}
+
+		if code[i] == '&' {
+			if end := strings.IndexByte(code[i:], ';'); end > 1 && end < 12 && !strings.ContainsAny(code[i+1:i+end], " <&\n") {
+				l.emit(Constant, code[i:i+end+1])
+				i += end + 1
+				continue
+			}
+		}
+
+		j := i + 1
+		for j < len(code) && code[j] != '<' && code[j] != '&' {
+			j++
+		}
+		l.emit(Plain, code[i:j])
+		i = j
+	}
+	return l.tokens
+}
+
+// lexDiff tokenizes unified diffs line by line
+func lexDiff(code string) []Token {
+	l := &lexer{}
+	for _, line := range strings.SplitAfter(code, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") ||
+			strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "index "):
+			l.emit(Heading, line)
+		case strings.HasPrefix(line, "@@"):
+			l.emit(Preprocessor, line)
+		case strings.HasPrefix(line, "+"):
+			l.emit(Inserted, line)
+		case strings.HasPrefix(line, "-"):
+			l.emit(Deleted, line)
+		default:
+			l.emit(Plain, line)
+		}
+	}
+	return l.tokens
+}
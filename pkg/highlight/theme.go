@@ -0,0 +1,196 @@
+package highlight
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultTheme is used when no theme is given. It is light, relies on
+// weight and italics as much as color, and so survives grayscale printing.
+const DefaultTheme = "print"
+
+// Theme maps token types to inline CSS
+type Theme struct {
+	Name        string
+	Background  string
+	Foreground  string
+	Styles      map[TokenType]string
+	PandocStyle string // Closest built-in --highlight-style for DOCX export
+}
+
+// Themes are the available highlighting themes by name
+var Themes = map[string]*Theme{
+	"print": {
+		Name:       "print",
+		Background: "#f8f8f8",
+		Foreground: "#1a1a1a",
+		Styles: map[TokenType]string{
+			Keyword:      "color: #000080; font-weight: bold",
+			Type:         "color: #006060",
+			Builtin:      "color: #004080",
+			Constant:     "color: #800080",
+			String:       "color: #006000",
+			Number:       "color: #800080",
+			Comment:      "color: #606060; font-style: italic",
+			Function:     "color: #003060",
+			Preprocessor: "color: #805000",
+			Decorator:    "color: #805000",
+			Variable:     "color: #600060",
+			Attribute:    "color: #805000",
+			Tag:          "color: #000080; font-weight: bold",
+			Property:     "color: #003060",
+			Inserted:     "color: #006000",
+			Deleted:      "color: #a00000",
+			Heading:      "font-weight: bold",
+		},
+		PandocStyle: "kate",
+	},
+	"github": {
+		Name:       "github",
+		Background: "#f6f8fa",
+		Foreground: "#24292f",
+		Styles: map[TokenType]string{
+			Keyword:      "color: #cf222e",
+			Type:         "color: #953800",
+			Builtin:      "color: #8250df",
+			Constant:     "color: #0550ae",
+			String:       "color: #0a3069",
+			Number:       "color: #0550ae",
+			Comment:      "color: #6e7781",
+			Function:     "color: #8250df",
+			Operator:     "color: #cf222e",
+			Preprocessor: "color: #cf222e",
+			Decorator:    "color: #8250df",
+			Variable:     "color: #953800",
+			Attribute:    "color: #0550ae",
+			Tag:          "color: #116329",
+			Property:     "color: #0550ae",
+			Inserted:     "color: #116329; background-color: #dafbe1",
+			Deleted:      "color: #82071e; background-color: #ffebe9",
+			Heading:      "color: #0550ae; font-weight: bold",
+		},
+		PandocStyle: "pygments",
+	},
+	"monokai": {
+		Name:       "monokai",
+		Background: "#272822",
+		Foreground: "#f8f8f2",
+		Styles: map[TokenType]string{
+			Keyword:      "color: #f92672",
+			Type:         "color: #66d9ef; font-style: italic",
+			Builtin:      "color: #66d9ef",
+			Constant:     "color: #ae81ff",
+			String:       "color: #e6db74",
+			Number:       "color: #ae81ff",
+			Comment:      "color: #75715e",
+			Function:     "color: #a6e22e",
+			Operator:     "color: #f92672",
+			Preprocessor: "color: #f92672",
+			Decorator:    "color: #a6e22e",
+			Variable:     "color: #fd971f",
+			Attribute:    "color: #a6e22e",
+			Tag:          "color: #f92672",
+			Property:     "color: #66d9ef",
+			Inserted:     "color: #a6e22e",
+			Deleted:      "color: #f92672",
+			Heading:      "color: #75715e; font-weight: bold",
+		},
+		PandocStyle: "breezedark",
+	},
+	"solarized-light": {
+		Name:       "solarized-light",
+		Background: "#fdf6e3",
+		Foreground: "#657b83",
+		Styles: map[TokenType]string{
+			Keyword:      "color: #859900",
+			Type:         "color: #b58900",
+			Builtin:      "color: #268bd2",
+			Constant:     "color: #cb4b16",
+			String:       "color: #2aa198",
+			Number:       "color: #d33682",
+			Comment:      "color: #93a1a1; font-style: italic",
+			Function:     "color: #268bd2",
+			Operator:     "color: #859900",
+			Preprocessor: "color: #cb4b16",
+			Decorator:    "color: #6c71c4",
+			Variable:     "color: #b58900",
+			Attribute:    "color: #b58900",
+			Tag:          "color: #268bd2",
+			Property:     "color: #268bd2",
+			Inserted:     "color: #859900",
+			Deleted:      "color: #dc322f",
+			Heading:      "color: #268bd2; font-weight: bold",
+		},
+		PandocStyle: "tango",
+	},
+	"monochrome": {
+		Name:       "monochrome",
+		Background: "#ffffff",
+		Foreground: "#000000",
+		Styles: map[TokenType]string{
+			Keyword:      "font-weight: bold",
+			Type:         "text-decoration: underline",
+			Comment:      "font-style: italic",
+			Preprocessor: "font-weight: bold",
+			Decorator:    "font-style: italic",
+			Tag:          "font-weight: bold",
+			Inserted:     "font-weight: bold",
+			Deleted:      "text-decoration: line-through",
+			Heading:      "font-weight: bold",
+		},
+		PandocStyle: "monochrome",
+	},
+}
+
+// ThemeNames returns the names of the available themes in sorted order
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetTheme returns a theme by name, or the default theme for ""
+func GetTheme(name string) (*Theme, bool) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	theme, ok := Themes[strings.ToLower(name)]
+	return theme, ok
+}
+
+// textEscaper escapes code for HTML text content, leaving quotes readable
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// HTML highlights code as HTML-escaped text wrapped in inline-styled
+// spans. It returns false if the language is not supported.
+func HTML(code, lang string, theme *Theme) (string, bool) {
+	tokens, ok := Tokenize(code, lang)
+	if !ok {
+		return "", false
+	}
+
+	var out strings.Builder
+	for _, token := range tokens {
+		text := textEscaper.Replace(token.Text)
+		style := theme.Styles[token.Type]
+		if style == "" {
+			out.WriteString(text)
+			continue
+		}
+		// Spans never cross lines so each line stays styled when split
+		// across pages or copied
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if i > 0 {
+				out.WriteByte('\n')
+			}
+			if line != "" {
+				out.WriteString(`<span style="` + style + `">` + line + `</span>`)
+			}
+		}
+	}
+	return out.String(), true
+}
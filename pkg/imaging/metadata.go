@@ -0,0 +1,161 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// strippedPNGChunks are ancillary PNG chunks that carry text or EXIF metadata
+var strippedPNGChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 if absent
+func jpegOrientation(data []byte) int {
+	exif := jpegExifSegment(data)
+	if exif == nil {
+		return 1
+	}
+
+	// TIFF header: byte order, magic 42, offset of IFD0
+	if len(exif) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(exif[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(exif[4:8]))
+	if ifd+2 > len(exif) {
+		return 1
+	}
+	count := int(order.Uint16(exif[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(exif) {
+			break
+		}
+		// 0x0112 is the Orientation tag, stored as a SHORT
+		if order.Uint16(exif[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(exif[entry+8 : entry+10]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 1
+		}
+	}
+
+	return 1
+}
+
+// jpegExifSegment returns the TIFF payload of a JPEG's EXIF APP1 segment
+func jpegExifSegment(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	for offset := 2; offset+4 <= len(data); {
+		if data[offset] != 0xFF {
+			return nil
+		}
+		marker := data[offset+1]
+		// Start of scan: no more metadata segments follow
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+
+		payload := data[offset+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return payload[6:]
+		}
+		offset = end
+	}
+
+	return nil
+}
+
+// stripJPEGMetadata removes EXIF/XMP (APP1), IPTC (APP13) and comment
+// segments without re-encoding. JFIF, ICC profile and Adobe segments are
+// kept because they affect how colors are decoded.
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("not a JPEG file")
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return nil, fmt.Errorf("corrupt JPEG segment at offset %d", offset)
+		}
+		marker := data[offset+1]
+		if marker == 0xDA {
+			// Entropy-coded data follows; copy the remainder verbatim
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("corrupt JPEG segment at offset %d", offset)
+		}
+
+		strip := marker == 0xE1 || marker == 0xED || marker == 0xFE
+		if !strip {
+			out.Write(data[offset:end])
+		}
+		offset = end
+	}
+	out.Write(data[offset:])
+
+	return out.Bytes(), nil
+}
+
+// stripPNGMetadata removes text, EXIF and timestamp chunks without re-encoding
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("not a PNG file")
+	}
+
+	var out bytes.Buffer
+	out.Write(pngSignature)
+
+	for offset := len(pngSignature); offset+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		end := offset + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("corrupt PNG chunk at offset %d", offset)
+		}
+
+		chunkType := string(data[offset+4 : offset+8])
+		if !strippedPNGChunks[chunkType] {
+			out.Write(data[offset:end])
+		}
+		offset = end
+
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	return out.Bytes(), nil
+}
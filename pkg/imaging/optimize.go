@@ -0,0 +1,237 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"sort"
+)
+
+const (
+	// DefaultJPEGQuality is used when re-encoding JPEGs without an explicit quality
+	DefaultJPEGQuality = 85
+	// DefaultThumbnailWidth is used when a thumbnail is requested without a width
+	DefaultThumbnailWidth = 320
+)
+
+// Options controls image optimization. Zero values leave that aspect unchanged.
+type Options struct {
+	MaxWidth       int  // Downscale images wider than this (pixels)
+	Quality        int  // JPEG quality 1-100; forces a re-encode
+	StripMetadata  bool // Remove EXIF/XMP/IPTC and text chunks
+	ThumbnailWidth int  // Generate a thumbnail of this width (pixels)
+}
+
+// Result holds an optimized image and statistics about the optimization
+type Result struct {
+	Data             []byte // Optimized image bytes (same format as the input)
+	Format           string // "jpeg" or "png"
+	OriginalSize     int
+	OptimizedSize    int
+	OriginalWidth    int
+	OriginalHeight   int
+	Width            int
+	Height           int
+	Thumbnail        []byte // Thumbnail bytes, if requested
+	ThumbnailWidth   int
+	ThumbnailHeight  int
+	MetadataStripped bool
+}
+
+// Supported reports whether the data is an image format Optimize can process
+func Supported(data []byte) bool {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	return err == nil && (format == "jpeg" || format == "png")
+}
+
+// Optimize downscales, re-encodes and strips metadata from a JPEG or PNG
+// image according to opts. Re-encoding always drops metadata, so EXIF
+// orientation is applied first to keep photos upright. If nothing but
+// metadata stripping is requested, the image data is left untouched.
+func Optimize(data []byte, opts Options) (*Result, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if format != "jpeg" && format != "png" {
+		return nil, fmt.Errorf("unsupported image format: %s (only JPEG and PNG can be optimized)", format)
+	}
+	if opts.Quality < 0 || opts.Quality > 100 {
+		return nil, fmt.Errorf("quality must be between 1 and 100")
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	// Display dimensions after applying EXIF orientation
+	origWidth, origHeight := cfg.Width, cfg.Height
+	if orientation >= 5 {
+		origWidth, origHeight = origHeight, origWidth
+	}
+	width, height := FitWidth(origWidth, origHeight, opts.MaxWidth)
+
+	result := &Result{
+		Format:         format,
+		OriginalSize:   len(data),
+		OriginalWidth:  origWidth,
+		OriginalHeight: origHeight,
+		Width:          origWidth,
+		Height:         origHeight,
+	}
+
+	resize := width != origWidth || height != origHeight
+	reencode := resize || opts.Quality > 0 || (opts.StripMetadata && orientation > 1)
+
+	var img image.Image
+	if reencode || opts.ThumbnailWidth > 0 {
+		img, _, err = image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		img = applyOrientation(img, orientation)
+	}
+
+	switch {
+	case reencode:
+		out := img
+		if resize {
+			out = Resize(img, width, height)
+		}
+		encoded, err := encode(out, format, opts.Quality)
+		if err != nil {
+			return nil, err
+		}
+
+		// A pure recompression that grows the file is not worth keeping,
+		// unless the original depended on EXIF orientation we must strip
+		if !resize && len(encoded) >= len(data) && !(opts.StripMetadata && orientation > 1) {
+			if opts.StripMetadata {
+				if result.Data, err = stripMetadata(data, format); err != nil {
+					return nil, err
+				}
+				result.MetadataStripped = true
+			} else {
+				result.Data = data
+			}
+		} else {
+			result.Data = encoded
+			result.Width, result.Height = width, height
+			result.MetadataStripped = true
+		}
+	case opts.StripMetadata:
+		if result.Data, err = stripMetadata(data, format); err != nil {
+			return nil, err
+		}
+		result.MetadataStripped = true
+	default:
+		result.Data = data
+	}
+	result.OptimizedSize = len(result.Data)
+
+	if opts.ThumbnailWidth > 0 {
+		bounds := img.Bounds()
+		thumbWidth, thumbHeight := FitWidth(bounds.Dx(), bounds.Dy(), opts.ThumbnailWidth)
+		thumb, err := encode(Resize(img, thumbWidth, thumbHeight), format, opts.Quality)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+		}
+		result.Thumbnail = thumb
+		result.ThumbnailWidth = thumbWidth
+		result.ThumbnailHeight = thumbHeight
+	}
+
+	return result, nil
+}
+
+// encode writes an image in the given format
+func encode(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		if quality <= 0 {
+			quality = DefaultJPEGQuality
+		}
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode JPEG: %w", err)
+		}
+	case "png":
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode PNG: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported image format: %s", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// stripMetadata removes metadata from an image without re-encoding it
+func stripMetadata(data []byte, format string) ([]byte, error) {
+	if format == "jpeg" {
+		return stripJPEGMetadata(data)
+	}
+	return stripPNGMetadata(data)
+}
+
+// Variant is a resized copy of an image for responsive srcset delivery
+type Variant struct {
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// DefaultVariantWidths are used when responsive variants are requested without explicit widths
+var DefaultVariantWidths = []int{480, 960, 1600}
+
+// Variants generates downscaled copies of a JPEG or PNG image for each
+// requested width. Widths at or above the image's own width are skipped,
+// since the original already serves them. Variants are returned in
+// ascending width order.
+func Variants(data []byte, widths []int, quality int) ([]Variant, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if format != "jpeg" && format != "png" {
+		return nil, fmt.Errorf("unsupported image format: %s (only JPEG and PNG variants can be generated)", format)
+	}
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	sorted := append([]int(nil), widths...)
+	sort.Ints(sorted)
+
+	bounds := img.Bounds()
+	var variants []Variant
+	for i, width := range sorted {
+		if width <= 0 || width >= bounds.Dx() || (i > 0 && width == sorted[i-1]) {
+			continue
+		}
+		w, h := FitWidth(bounds.Dx(), bounds.Dy(), width)
+		encoded, err := encode(Resize(img, w, h), format, quality)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, Variant{Width: w, Height: h, Data: encoded})
+	}
+
+	return variants, nil
+}
+
+// DisplaySize returns the dimensions an image is displayed at, taking the
+// EXIF orientation of JPEGs into account
+func DisplaySize(data []byte) (int, int, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read image: %w", err)
+	}
+	if format == "jpeg" && jpegOrientation(data) >= 5 {
+		return cfg.Height, cfg.Width, nil
+	}
+	return cfg.Width, cfg.Height, nil
+}
@@ -0,0 +1,270 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testImage returns a width x height gradient, which does not compress to nothing
+func testImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / width), uint8(y * 255 / height), 128, 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF APP1 segment carrying the given
+// orientation right after the JPEG's SOI marker
+func withOrientation(data []byte, orientation int) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], uint16(orientation))
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte(nil), data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// withTextChunk inserts a tEXt chunk right after a PNG's IHDR chunk
+func withTextChunk(data []byte, text string) []byte {
+	chunk := make([]byte, 8, 12+len(text))
+	binary.BigEndian.PutUint32(chunk, uint32(len(text)))
+	copy(chunk[4:], "tEXt")
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	ihdrEnd := len(pngSignature) + 12 + 13
+	out := append([]byte(nil), data[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+func decodeSize(t *testing.T, data []byte) (int, int) {
+	t.Helper()
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestSupported(t *testing.T) {
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, testImage(4, 4), nil); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"png", encodePNG(t, testImage(4, 4)), true},
+		{"jpeg", encodeJPEG(t, testImage(4, 4), 90), true},
+		{"gif", gifData.Bytes(), false},
+		{"text", []byte("not an image"), false},
+	}
+	for _, tt := range tests {
+		if got := Supported(tt.data); got != tt.want {
+			t.Errorf("%s: Supported() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := Optimize(gifData.Bytes(), Options{MaxWidth: 2}); err == nil {
+		t.Error("Optimize() accepted a GIF")
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	photo := encodeJPEG(t, testImage(200, 100), 100)
+
+	t.Run("max width", func(t *testing.T) {
+		res, err := Optimize(photo, Options{MaxWidth: 50})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Width != 50 || res.Height != 25 || res.OriginalWidth != 200 || res.OriginalHeight != 100 {
+			t.Errorf("size = %dx%d from %dx%d, want 50x25 from 200x100", res.Width, res.Height, res.OriginalWidth, res.OriginalHeight)
+		}
+		if w, h := decodeSize(t, res.Data); w != 50 || h != 25 {
+			t.Errorf("encoded image is %dx%d, want 50x25", w, h)
+		}
+		if res.Format != "jpeg" || res.OptimizedSize != len(res.Data) || res.OriginalSize != len(photo) {
+			t.Errorf("unexpected result stats: %+v", res)
+		}
+	})
+
+	t.Run("narrow image kept", func(t *testing.T) {
+		res, err := Optimize(photo, Options{MaxWidth: 400})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(res.Data, photo) || res.MetadataStripped {
+			t.Error("an image within the maximum width was changed")
+		}
+	})
+
+	t.Run("quality", func(t *testing.T) {
+		res, err := Optimize(photo, Options{Quality: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.OptimizedSize >= res.OriginalSize {
+			t.Errorf("quality 30 gave %d bytes from %d", res.OptimizedSize, res.OriginalSize)
+		}
+		if res.Width != 200 || res.Height != 100 {
+			t.Errorf("quality changed the size to %dx%d", res.Width, res.Height)
+		}
+	})
+
+	t.Run("bad quality", func(t *testing.T) {
+		if _, err := Optimize(photo, Options{Quality: 101}); err == nil {
+			t.Error("quality 101 accepted")
+		}
+	})
+
+	t.Run("strip jpeg metadata", func(t *testing.T) {
+		tagged := withOrientation(photo, 1)
+		res, err := Optimize(tagged, Options{StripMetadata: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.MetadataStripped || !bytes.Equal(res.Data, photo) {
+			t.Error("EXIF segment not removed without re-encoding")
+		}
+	})
+
+	t.Run("strip png metadata", func(t *testing.T) {
+		plain := encodePNG(t, testImage(8, 8))
+		tagged := withTextChunk(plain, "Comment\x00secret")
+		res, err := Optimize(tagged, Options{StripMetadata: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(res.Data, plain) {
+			t.Error("tEXt chunk not removed without re-encoding")
+		}
+	})
+
+	t.Run("orientation applied", func(t *testing.T) {
+		rotated := withOrientation(photo, 6)
+		res, err := Optimize(rotated, Options{StripMetadata: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Width != 100 || res.Height != 200 {
+			t.Errorf("size = %dx%d, want 100x200", res.Width, res.Height)
+		}
+		if w, h := decodeSize(t, res.Data); w != 100 || h != 200 {
+			t.Errorf("encoded image is %dx%d, want it rotated to 100x200", w, h)
+		}
+		if jpegOrientation(res.Data) != 1 {
+			t.Error("orientation tag kept after rotating the pixels")
+		}
+	})
+
+	t.Run("thumbnail", func(t *testing.T) {
+		res, err := Optimize(photo, Options{ThumbnailWidth: 40})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ThumbnailWidth != 40 || res.ThumbnailHeight != 20 {
+			t.Errorf("thumbnail = %dx%d, want 40x20", res.ThumbnailWidth, res.ThumbnailHeight)
+		}
+		if w, h := decodeSize(t, res.Thumbnail); w != 40 || h != 20 {
+			t.Errorf("encoded thumbnail is %dx%d, want 40x20", w, h)
+		}
+		if !bytes.Equal(res.Data, photo) {
+			t.Error("a thumbnail request changed the image")
+		}
+	})
+}
+
+func TestVariants(t *testing.T) {
+	data := encodePNG(t, testImage(100, 50))
+	variants, err := Variants(data, []int{80, 0, 40, 100, 160, 40}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]int{{40, 20}, {80, 40}}
+	if len(variants) != len(want) {
+		t.Fatalf("got %d variants, want %d", len(variants), len(want))
+	}
+	for i, v := range variants {
+		if v.Width != want[i][0] || v.Height != want[i][1] {
+			t.Errorf("variant %d = %dx%d, want %dx%d", i, v.Width, v.Height, want[i][0], want[i][1])
+		}
+		if w, h := decodeSize(t, v.Data); w != v.Width || h != v.Height {
+			t.Errorf("variant %d encodes %dx%d, want %dx%d", i, w, h, v.Width, v.Height)
+		}
+	}
+
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, testImage(4, 4), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Variants(gifData.Bytes(), []int{2}, 0); err == nil {
+		t.Error("Variants() accepted a GIF")
+	}
+}
+
+func TestDisplaySize(t *testing.T) {
+	photo := encodeJPEG(t, testImage(30, 10), 90)
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+	}{
+		{"png", encodePNG(t, testImage(30, 10)), 30, 10},
+		{"jpeg", photo, 30, 10},
+		{"rotated 180", withOrientation(photo, 3), 30, 10},
+		{"rotated 90", withOrientation(photo, 6), 10, 30},
+		{"transposed", withOrientation(photo, 5), 10, 30},
+	}
+	for _, tt := range tests {
+		w, h, err := DisplaySize(tt.data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if w != tt.width || h != tt.height {
+			t.Errorf("%s: DisplaySize() = %dx%d, want %dx%d", tt.name, w, h, tt.width, tt.height)
+		}
+	}
+}
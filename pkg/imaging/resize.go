@@ -0,0 +1,203 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Resize scales an image to the given dimensions using a separable tent
+// filter whose support grows with the scale factor, which avoids the
+// aliasing of nearest-neighbour or plain bilinear sampling when shrinking
+// photos by large factors. Sampling happens on premultiplied alpha so
+// transparent edges do not bleed dark fringes.
+func Resize(src image.Image, width, height int) *image.RGBA {
+	if width <= 0 || height <= 0 {
+		return image.NewRGBA(image.Rect(0, 0, 0, 0))
+	}
+
+	rgba := toRGBA(src)
+	srcW, srcH := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+	if srcW == width && srcH == height {
+		return rgba
+	}
+
+	// Horizontal pass: srcW x srcH -> width x srcH
+	xWeights := resampleWeights(srcW, width)
+	tmp := make([]float32, width*srcH*4)
+	for y := 0; y < srcH; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x, w := range xWeights {
+			var r, g, b, a float32
+			for i, weight := range w.weights {
+				p := (w.start + i) * 4
+				r += float32(row[p]) * weight
+				g += float32(row[p+1]) * weight
+				b += float32(row[p+2]) * weight
+				a += float32(row[p+3]) * weight
+			}
+			o := (y*width + x) * 4
+			tmp[o], tmp[o+1], tmp[o+2], tmp[o+3] = r, g, b, a
+		}
+	}
+
+	// Vertical pass: width x srcH -> width x height
+	yWeights := resampleWeights(srcH, height)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, w := range yWeights {
+		for x := 0; x < width; x++ {
+			var r, g, b, a float32
+			for i, weight := range w.weights {
+				p := ((w.start+i)*width + x) * 4
+				r += tmp[p] * weight
+				g += tmp[p+1] * weight
+				b += tmp[p+2] * weight
+				a += tmp[p+3] * weight
+			}
+			o := y*dst.Stride + x*4
+			dst.Pix[o] = clampUint8(r)
+			dst.Pix[o+1] = clampUint8(g)
+			dst.Pix[o+2] = clampUint8(b)
+			dst.Pix[o+3] = clampUint8(a)
+		}
+	}
+
+	return dst
+}
+
+// FitWidth returns dimensions scaled down to maxWidth, preserving aspect
+// ratio. Images already narrower than maxWidth are returned unchanged.
+func FitWidth(width, height, maxWidth int) (int, int) {
+	if maxWidth <= 0 || width <= maxWidth {
+		return width, height
+	}
+	newHeight := int(math.Round(float64(height) * float64(maxWidth) / float64(width)))
+	if newHeight < 1 {
+		newHeight = 1
+	}
+	return maxWidth, newHeight
+}
+
+// sampleWeights holds the contributing source pixels for one destination pixel
+type sampleWeights struct {
+	start   int
+	weights []float32
+}
+
+// resampleWeights computes normalized tent filter weights for mapping
+// srcSize pixels onto dstSize pixels
+func resampleWeights(srcSize, dstSize int) []sampleWeights {
+	scale := float64(srcSize) / float64(dstSize)
+	support := math.Max(scale, 1)
+
+	result := make([]sampleWeights, dstSize)
+	for i := range result {
+		center := (float64(i)+0.5)*scale - 0.5
+		start := int(math.Ceil(center - support))
+		end := int(math.Floor(center + support))
+		if start < 0 {
+			start = 0
+		}
+		if end > srcSize-1 {
+			end = srcSize - 1
+		}
+
+		weights := make([]float32, 0, end-start+1)
+		var sum float64
+		for j := start; j <= end; j++ {
+			weight := 1 - math.Abs(float64(j)-center)/support
+			if weight < 0 {
+				weight = 0
+			}
+			weights = append(weights, float32(weight))
+			sum += weight
+		}
+
+		// Pixels exactly on the sample point would otherwise get no weight
+		if sum == 0 {
+			nearest := int(math.Round(center))
+			if nearest < 0 {
+				nearest = 0
+			}
+			if nearest > srcSize-1 {
+				nearest = srcSize - 1
+			}
+			result[i] = sampleWeights{start: nearest, weights: []float32{1}}
+			continue
+		}
+
+		for k := range weights {
+			weights[k] = float32(float64(weights[k]) / sum)
+		}
+		result[i] = sampleWeights{start: start, weights: weights}
+	}
+
+	return result
+}
+
+// toRGBA converts any image to a zero-origin premultiplied RGBA image
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	if rgba, ok := src.(*image.RGBA); ok && bounds.Min == (image.Point{}) {
+		return rgba
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	return dst
+}
+
+// clampUint8 rounds and clamps a channel value to 0-255
+func clampUint8(v float32) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// applyOrientation rotates/flips an image according to an EXIF orientation
+// value (1-8) so it displays upright once the EXIF data is removed
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	rgba := toRGBA(src)
+	w, h := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+
+	// Orientations 5-8 swap width and height
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirror horizontal
+				dx, dy = w-1-x, y
+			case 3: // Rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // Mirror vertical
+				dx, dy = x, h-1-y
+			case 5: // Transpose
+				dx, dy = y, x
+			case 6: // Rotate 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // Transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // Rotate 270 clockwise
+				dx, dy = y, w-1-x
+			}
+			s := y*rgba.Stride + x*4
+			d := dy*dst.Stride + dx*4
+			copy(dst.Pix[d:d+4], rgba.Pix[s:s+4])
+		}
+	}
+
+	return dst
+}
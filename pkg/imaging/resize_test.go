@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFitWidth(t *testing.T) {
+	tests := []struct {
+		width, height, maxWidth int
+		wantW, wantH            int
+	}{
+		{200, 100, 50, 50, 25},
+		{200, 100, 200, 200, 100},
+		{200, 100, 400, 200, 100},
+		{200, 100, 0, 200, 100},
+		{300, 100, 100, 100, 33},
+		{1000, 1, 10, 10, 1},
+	}
+	for _, tt := range tests {
+		w, h := FitWidth(tt.width, tt.height, tt.maxWidth)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("FitWidth(%d, %d, %d) = %d, %d, want %d, %d", tt.width, tt.height, tt.maxWidth, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	// A solid image stays the same color at any size
+	src := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	fill := color.RGBA{200, 100, 50, 255}
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			src.Set(x, y, fill)
+		}
+	}
+	for _, size := range [][2]int{{16, 8}, {64, 32}, {128, 64}, {7, 3}} {
+		dst := Resize(src, size[0], size[1])
+		if b := dst.Bounds(); b.Dx() != size[0] || b.Dy() != size[1] {
+			t.Fatalf("Resize() to %dx%d gave %dx%d", size[0], size[1], b.Dx(), b.Dy())
+		}
+		for y := 0; y < size[1]; y++ {
+			for x := 0; x < size[0]; x++ {
+				if got := dst.RGBAAt(x, y); got != fill {
+					t.Fatalf("%dx%d: pixel (%d,%d) = %v, want %v", size[0], size[1], x, y, got, fill)
+				}
+			}
+		}
+	}
+
+	// Halving a left/right split keeps each half's color
+	split := image.NewRGBA(image.Rect(0, 0, 8, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 8; x++ {
+			if x < 4 {
+				split.Set(x, y, color.RGBA{0, 0, 0, 255})
+			} else {
+				split.Set(x, y, color.RGBA{255, 255, 255, 255})
+			}
+		}
+	}
+	dst := Resize(split, 4, 1)
+	if got := dst.RGBAAt(0, 0); got.R != 0 {
+		t.Errorf("left edge = %v, want black", got)
+	}
+	if got := dst.RGBAAt(3, 0); got.R != 255 {
+		t.Errorf("right edge = %v, want white", got)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// A 2x1 image with a red left pixel and a blue right pixel
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	src.SetRGBA(0, 0, red)
+	src.SetRGBA(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		width       int
+		height      int
+		redAt       image.Point
+	}{
+		{1, 2, 1, image.Pt(0, 0)},
+		{2, 2, 1, image.Pt(1, 0)},
+		{3, 2, 1, image.Pt(1, 0)},
+		{4, 2, 1, image.Pt(0, 0)},
+		{5, 1, 2, image.Pt(0, 0)},
+		{6, 1, 2, image.Pt(0, 0)},
+		{7, 1, 2, image.Pt(0, 1)},
+		{8, 1, 2, image.Pt(0, 1)},
+	}
+	for _, tt := range tests {
+		out := applyOrientation(src, tt.orientation)
+		if b := out.Bounds(); b.Dx() != tt.width || b.Dy() != tt.height {
+			t.Errorf("orientation %d: size %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.width, tt.height)
+			continue
+		}
+		if got := color.RGBAModel.Convert(out.At(tt.redAt.X, tt.redAt.Y)); got != red {
+			t.Errorf("orientation %d: pixel %v = %v, want red", tt.orientation, tt.redAt, got)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"simple_html_docgen/pkg/document"
+	"sort"
+	"strings"
+	"time"
+)
+
+// inputFormats maps supported file extensions to Pandoc reader names
+var inputFormats = map[string]string{
+	".docx":     "docx",
+	".odt":      "odt",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".rst":      "rst",
+}
+
+// Importer converts external files into HTML documents using Pandoc
+type Importer struct {
+	pandocTimeout time.Duration
+}
+
+// NewImporter creates a new importer instance
+func NewImporter() *Importer {
+	return &Importer{
+		pandocTimeout: 30 * time.Second,
+	}
+}
+
+// SupportedExtensions returns the file extensions that can be imported
+func SupportedExtensions() []string {
+	return []string{".docx", ".odt", ".md", ".markdown", ".rst"}
+}
+
+// ImportFile converts a local file to HTML and creates a new document from it.
+// Embedded images are extracted into the document's media folder.
+// If name is empty, the file name without extension is used.
+func (i *Importer) ImportFile(sourcePath, name string, docSvc *document.Service) (*document.Document, error) {
+	if sourcePath == "" {
+		return nil, fmt.Errorf("source path cannot be empty")
+	}
+
+	ext := strings.ToLower(filepath.Ext(sourcePath))
+	inputFormat, ok := inputFormats[ext]
+	if !ok {
+		return nil, fmt.Errorf("unsupported file type: %s (must be one of %s)", ext, strings.Join(SupportedExtensions(), ", "))
+	}
+
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access source file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("source path is a directory: %s", sourcePath)
+	}
+
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(sourcePath), filepath.Ext(sourcePath))
+	}
+
+	if err := i.checkPandoc(); err != nil {
+		return nil, err
+	}
+
+	// Extract media into a scratch directory so nothing lands in the
+	// document folder until the conversion has succeeded
+	tmpDir, err := os.MkdirTemp("", "simple_html_import_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	absSource, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source path: %w", err)
+	}
+
+	args := []string{
+		"-f", inputFormat,
+		"-t", "html5",
+		"-s",
+		"--metadata", "pagetitle=" + name,
+		"--extract-media", tmpDir,
+		"--resource-path", filepath.Dir(absSource),
+		absSource,
+	}
+
+	output, err := i.runPandoc(args, filepath.Dir(absSource))
+	if err != nil {
+		return nil, fmt.Errorf("import conversion failed: %w", err)
+	}
+
+	htmlContent := string(output)
+	if strings.TrimSpace(htmlContent) == "" {
+		return nil, fmt.Errorf("import produced no content")
+	}
+
+	doc, err := docSvc.CreateDocument(name, htmlContent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy extracted media and point references at the document's media folder
+	replacements, err := i.importMedia(doc.ID, tmpDir, docSvc)
+	if err != nil {
+		docSvc.DeleteDocument(doc.ID)
+		return nil, err
+	}
+
+	if len(replacements) > 0 {
+		htmlContent = rewriteMediaReferences(htmlContent, replacements)
+		updated, err := docSvc.UpdateDocument(doc.ID, htmlContent)
+		if err != nil {
+			docSvc.DeleteDocument(doc.ID)
+			return nil, err
+		}
+		doc = updated
+	}
+
+	return doc, nil
+}
+
+// importMedia copies every file Pandoc extracted into the document's media
+// folder and returns a map from the extracted path to the new relative path
+func (i *Importer) importMedia(documentID, tmpDir string, docSvc *document.Service) (map[string]string, error) {
+	replacements := make(map[string]string)
+
+	err := filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		// Let the content decide the media type; Word files may embed EMF or PDF objects
+		media, err := docSvc.AddMedia(documentID, path, document.AddMediaOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to import media %s: %w", filepath.Base(path), err)
+		}
+		replacements[path] = media.RelativePath
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return replacements, nil
+}
+
+// rewriteMediaReferences replaces extracted media paths in the HTML with their
+// document-relative equivalents. Longer paths are replaced first so that a path
+// which is a prefix of another is never partially rewritten.
+func rewriteMediaReferences(htmlContent string, replacements map[string]string) string {
+	paths := make([]string, 0, len(replacements))
+	for path := range replacements {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(a, b int) bool {
+		return len(paths[a]) > len(paths[b])
+	})
+
+	for _, path := range paths {
+		htmlContent = strings.ReplaceAll(htmlContent, path, filepath.ToSlash(replacements[path]))
+	}
+	return htmlContent
+}
+
+// checkPandoc checks if Pandoc is installed
+func (i *Importer) checkPandoc() error {
+	cmd := exec.Command("pandoc", "--version")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pandoc not found: please install pandoc to enable file import")
+	}
+	return nil
+}
+
+// runPandoc executes a Pandoc command with timeout and returns its stdout
+func (i *Importer) runPandoc(args []string, workDir string) ([]byte, error) {
+	cmd := exec.Command("pandoc", args...)
+	cmd.Dir = workDir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// Run with timeout
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Run()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			if stderr.Len() > 0 {
+				return nil, fmt.Errorf("pandoc error: %s", stderr.String())
+			}
+			return nil, fmt.Errorf("pandoc failed: %w", err)
+		}
+		return stdout.Bytes(), nil
+	case <-time.After(i.pandocTimeout):
+		if cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+		return nil, fmt.Errorf("pandoc conversion timed out after %v", i.pandocTimeout)
+	}
+}
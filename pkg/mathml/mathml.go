@@ -0,0 +1,933 @@
+// Package mathml converts LaTeX math to MathML in pure Go. It covers the
+// notation common in technical writing: scripts, fractions, roots, Greek
+// letters and symbols, big operators, functions, accents, font styles,
+// stretchy delimiters and matrix/cases/aligned environments. The output
+// renders natively in browsers (MathML Core) and is read by Pandoc as
+// native equations.
+package mathml
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxDepth limits nesting so malformed input cannot exhaust the stack
+const maxDepth = 64
+
+// Convert renders a LaTeX math expression as a <math> element. Display
+// math is rendered as a centered block. The LaTeX source is kept as an
+// annotation so converters that understand TeX can use it directly.
+func Convert(tex string, display bool) (string, error) {
+	p := &parser{src: []rune(tex)}
+	body, err := p.parseTop()
+	if err != nil {
+		return "", err
+	}
+
+	displayAttr := ""
+	if display {
+		displayAttr = ` display="block"`
+	}
+	return fmt.Sprintf(`<math xmlns="http://www.w3.org/1998/Math/MathML"%s><semantics>%s<annotation encoding="application/x-tex">%s</annotation></semantics></math>`,
+		displayAttr, body, escape(strings.TrimSpace(tex))), nil
+}
+
+// node is a rendered piece of MathML
+type node struct {
+	markup string
+	after  string // Markup following the node's scripts, e.g. function application
+	limits bool   // Scripts are placed under and over rather than beside
+	force  bool   // Limits stay under/over even in inline math (\limits)
+}
+
+// String returns the complete markup of a node
+func (n node) String() string {
+	return n.markup + n.after
+}
+
+// parser is a recursive descent LaTeX math parser
+type parser struct {
+	src   []rune
+	pos   int
+	font  string // Active font from \mathbf and friends
+	depth int
+}
+
+// parseTop parses a whole expression. Top-level & and \\ lay the
+// expression out as rows, as in an implicit aligned environment.
+func (p *parser) parseTop() (string, error) {
+	rows, err := p.parseRows("")
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 1 && len(rows[0]) == 1 {
+		return "<mrow>" + rows[0][0] + "</mrow>", nil
+	}
+	return renderTable(rows, "aligned"), nil
+}
+
+// parseRows parses table cells separated by & and rows separated by \\
+// until \end{env} (or the end of input when env is empty)
+func (p *parser) parseRows(env string) ([][]string, error) {
+	var rows [][]string
+	var row []string
+	for {
+		nodes, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, join(nodes))
+
+		p.skipSpace()
+		switch {
+		case p.peek() == '&':
+			p.pos++
+		case p.peekCommand() == `\` || p.peekCommand() == "cr":
+			p.readCommand()
+			p.skipOptional()
+			rows = append(rows, row)
+			row = nil
+		case p.peekCommand() == "end":
+			if env == "" {
+				return nil, fmt.Errorf(`unexpected \end`)
+			}
+			p.readCommand()
+			name, err := p.readName()
+			if err != nil {
+				return nil, err
+			}
+			if name != env {
+				return nil, fmt.Errorf(`\begin{%s} ended by \end{%s}`, env, name)
+			}
+			return appendRow(rows, row), nil
+		case p.peek() == 0:
+			if env != "" {
+				return nil, fmt.Errorf(`missing \end{%s}`, env)
+			}
+			return appendRow(rows, row), nil
+		case p.peek() == '}':
+			return nil, fmt.Errorf("unexpected }")
+		default:
+			return nil, fmt.Errorf(`unexpected \%s`, p.peekCommand())
+		}
+	}
+}
+
+// appendRow adds the final row, dropping it if a trailing \\ left it empty
+func appendRow(rows [][]string, row []string) [][]string {
+	if len(rows) > 0 && len(row) == 1 && row[0] == "" {
+		return rows
+	}
+	return append(rows, row)
+}
+
+// parseList parses atoms and their scripts until a closing brace, cell or
+// row separator, \right, \middle, \end or the end of input
+func (p *parser) parseList() ([]node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, fmt.Errorf("expression is nested too deeply")
+	}
+
+	var nodes []node
+	for {
+		p.skipSpace()
+		switch p.peek() {
+		case 0, '}', '&':
+			return nodes, nil
+		}
+		switch cmd := p.peekCommand(); cmd {
+		case `\`, "cr", "right", "middle", "end":
+			return nodes, nil
+		case "displaystyle", "textstyle":
+			p.readCommand()
+			rest, err := p.parseList()
+			if err != nil {
+				return nil, err
+			}
+			style := fmt.Sprintf(`<mstyle displaystyle="%t">%s</mstyle>`, cmd == "displaystyle", join(rest))
+			return append(nodes, node{markup: style}), nil
+		}
+
+		n, err := p.parseScripted()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+// parseScripted parses an atom followed by any subscript, superscript and primes
+func (p *parser) parseScripted() (node, error) {
+	p.skipSpace()
+	var base node
+	if c := p.peek(); c == '^' || c == '_' || c == '\'' {
+		base = node{markup: "<mrow></mrow>"}
+	} else {
+		var err error
+		if base, err = p.parseAtom(false); err != nil {
+			return node{}, err
+		}
+	}
+
+	var sub, sup, primes string
+	hasSub, hasSup := false, false
+	for {
+		p.skipSpace()
+		switch c := p.peek(); {
+		case c == '_':
+			if hasSub {
+				return node{}, fmt.Errorf("double subscript")
+			}
+			p.pos++
+			arg, err := p.parseArg()
+			if err != nil {
+				return node{}, err
+			}
+			sub, hasSub = arg, true
+		case c == '^':
+			if hasSup {
+				return node{}, fmt.Errorf("double superscript")
+			}
+			p.pos++
+			arg, err := p.parseArg()
+			if err != nil {
+				return node{}, err
+			}
+			sup, hasSup = arg, true
+		case c == '\'':
+			for p.peek() == '\'' {
+				primes += "′"
+				p.pos++
+			}
+		case p.peekCommand() == "limits":
+			p.readCommand()
+			base.limits, base.force = true, true
+		case p.peekCommand() == "nolimits":
+			p.readCommand()
+			base.limits = false
+		default:
+			if primes != "" {
+				sup = "<mrow><mo>" + primes + "</mo>" + sup + "</mrow>"
+				hasSup = true
+			}
+			return attachScripts(base, sub, sup, hasSub, hasSup), nil
+		}
+	}
+}
+
+// attachScripts wraps a base in the script element matching its scripts
+func attachScripts(base node, sub, sup string, hasSub, hasSup bool) node {
+	if !hasSub && !hasSup {
+		return base
+	}
+	after := base.after
+	under, over, both := "msub", "msup", "msubsup"
+	markup := base.markup
+	if base.limits {
+		under, over, both = "munder", "mover", "munderover"
+		if base.force && (strings.HasPrefix(markup, "<mo>") || strings.HasPrefix(markup, "<mo ")) {
+			if strings.Contains(markup, `movablelimits="true"`) {
+				markup = strings.Replace(markup, `movablelimits="true"`, `movablelimits="false"`, 1)
+			} else {
+				markup = strings.Replace(markup, "<mo", `<mo movablelimits="false"`, 1)
+			}
+		}
+	}
+	switch {
+	case hasSub && hasSup:
+		return node{markup: fmt.Sprintf("<%s>%s%s%s</%s>", both, markup, sub, sup, both), after: after}
+	case hasSub:
+		return node{markup: fmt.Sprintf("<%s>%s%s</%s>", under, markup, sub, under), after: after}
+	default:
+		return node{markup: fmt.Sprintf("<%s>%s%s</%s>", over, markup, sup, over), after: after}
+	}
+}
+
+// parseArg parses a command or script argument: a braced group or a
+// single token (one digit, as in \frac12)
+func (p *parser) parseArg() (string, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case 0:
+		return "", fmt.Errorf("missing argument")
+	case '{':
+		return p.parseGroup()
+	case '}', '&', '^', '_':
+		return "", fmt.Errorf("missing argument before %c", p.peek())
+	}
+	n, err := p.parseAtom(true)
+	if err != nil {
+		return "", err
+	}
+	return n.String(), nil
+}
+
+// parseGroup parses a braced group as a single <mrow>
+func (p *parser) parseGroup() (string, error) {
+	if err := p.expect('{'); err != nil {
+		return "", err
+	}
+	nodes, err := p.parseList()
+	if err != nil {
+		return "", err
+	}
+	if err := p.expect('}'); err != nil {
+		return "", err
+	}
+	if len(nodes) == 1 {
+		return nodes[0].String(), nil
+	}
+	return "<mrow>" + join(nodes) + "</mrow>", nil
+}
+
+// parseAtom parses one atom. In argument position a number is a single digit.
+func (p *parser) parseAtom(arg bool) (node, error) {
+	c := p.peek()
+	switch {
+	case c == '{':
+		markup, err := p.parseGroup()
+		return node{markup: markup}, err
+	case c == '\\':
+		return p.parseCommand()
+	case isDigit(c) || (c == '.' && isDigit(p.peekAt(1))):
+		return node{markup: p.parseNumber(arg)}, nil
+	case unicode.IsLetter(c):
+		p.pos++
+		return node{markup: p.identifier(string(c))}, nil
+	case c == '~':
+		p.pos++
+		return node{markup: `<mspace width="0.25em"></mspace>`}, nil
+	}
+
+	p.pos++
+	return node{markup: "<mo>" + escape(operatorChar(c)) + "</mo>"}, nil
+}
+
+// parseNumber parses digits with an optional decimal point
+func (p *parser) parseNumber(single bool) string {
+	start := p.pos
+	if single {
+		p.pos++
+	} else {
+		for isDigit(p.peek()) || (p.peek() == '.' && isDigit(p.peekAt(1))) {
+			p.pos++
+		}
+	}
+	digits := []rune(string(p.src[start:p.pos]))
+	for i, r := range digits {
+		digits[i] = styleRune(r, p.font)
+	}
+	return "<mn>" + escape(string(digits)) + "</mn>"
+}
+
+// identifier renders a letter in the active font
+func (p *parser) identifier(letter string) string {
+	switch p.font {
+	case "":
+		return "<mi>" + escape(letter) + "</mi>"
+	case "normal":
+		return `<mi mathvariant="normal">` + escape(letter) + "</mi>"
+	default:
+		return "<mi>" + escape(string(styleRune([]rune(letter)[0], p.font))) + "</mi>"
+	}
+}
+
+// parseCommand parses a backslash command and its arguments
+func (p *parser) parseCommand() (node, error) {
+	name := p.readCommand()
+
+	if symbol, ok := identifiers[name]; ok {
+		return node{markup: "<mi>" + symbol + "</mi>"}, nil
+	}
+	if symbol, ok := uprightIdentifiers[name]; ok {
+		return node{markup: `<mi mathvariant="normal">` + symbol + "</mi>"}, nil
+	}
+	if symbol, ok := operators[name]; ok {
+		return node{markup: "<mo>" + escape(symbol) + "</mo>"}, nil
+	}
+	if op, ok := bigOperators[name]; ok {
+		return node{markup: "<mo>" + op.symbol + "</mo>", limits: op.limits}, nil
+	}
+	if limits, ok := functions[name]; ok {
+		text := name
+		if display, ok := functionNames[name]; ok {
+			text = display
+		}
+		return functionNode(text, limits), nil
+	}
+	if width, ok := spaces[name]; ok {
+		return node{markup: `<mspace width="` + width + `"></mspace>`}, nil
+	}
+	if a, ok := accents[name]; ok {
+		return p.parseAccent(a)
+	}
+	if font, ok := fonts[name]; ok {
+		return p.parseFont(font)
+	}
+	if size, ok := bigDelimiterSizes[name]; ok {
+		delim, err := p.readDelimiter()
+		if err != nil {
+			return node{}, err
+		}
+		return node{markup: fmt.Sprintf(`<mo minsize="%s" maxsize="%s">%s</mo>`, size, size, escape(delim))}, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num, err := p.parseArg()
+		if err != nil {
+			return node{}, err
+		}
+		den, err := p.parseArg()
+		if err != nil {
+			return node{}, err
+		}
+		frac := "<mfrac>" + num + den + "</mfrac>"
+		switch name {
+		case "dfrac", "cfrac":
+			frac = `<mstyle displaystyle="true">` + frac + "</mstyle>"
+		case "tfrac":
+			frac = `<mstyle displaystyle="false">` + frac + "</mstyle>"
+		}
+		return node{markup: frac}, nil
+	case "binom", "dbinom", "tbinom":
+		top, err := p.parseArg()
+		if err != nil {
+			return node{}, err
+		}
+		bottom, err := p.parseArg()
+		if err != nil {
+			return node{}, err
+		}
+		return node{markup: `<mrow><mo>(</mo><mfrac linethickness="0">` + top + bottom + `</mfrac><mo>)</mo></mrow>`}, nil
+	case "sqrt":
+		index := ""
+		if p.peekNonSpace() == '[' {
+			p.skipSpace()
+			p.pos++
+			nodes, err := p.parseUntil(']')
+			if err != nil {
+				return node{}, err
+			}
+			index = "<mrow>" + join(nodes) + "</mrow>"
+		}
+		radicand, err := p.parseArg()
+		if err != nil {
+			return node{}, err
+		}
+		if index != "" {
+			return node{markup: "<mroot>" + radicand + index + "</mroot>"}, nil
+		}
+		return node{markup: "<msqrt>" + radicand + "</msqrt>"}, nil
+	case "text", "textrm", "textnormal", "mbox", "hbox", "textbf", "textit":
+		text, err := p.readRawGroup()
+		if err != nil {
+			return node{}, err
+		}
+		style := map[string]string{"textbf": ` style="font-weight: bold"`, "textit": ` style="font-style: italic"`}[name]
+		return node{markup: "<mtext" + style + ">" + escape(textSpaces(text)) + "</mtext>"}, nil
+	case "operatorname", "operatorname*":
+		text, err := p.readRawGroup()
+		if err != nil {
+			return node{}, err
+		}
+		return functionNode(strings.TrimSpace(text), name == "operatorname*"), nil
+	case "mod", "bmod":
+		return node{markup: `<mo lspace="0.5em" rspace="0.5em">mod</mo>`}, nil
+	case "pmod":
+		arg, err := p.parseArg()
+		if err != nil {
+			return node{}, err
+		}
+		return node{markup: `<mrow><mspace width="0.5em"></mspace><mo>(</mo><mi>mod</mi><mspace width="0.3333em"></mspace>` + arg + `<mo>)</mo></mrow>`}, nil
+	case "overset", "stackrel", "underset":
+		script, err := p.parseArg()
+		if err != nil {
+			return node{}, err
+		}
+		base, err := p.parseArg()
+		if err != nil {
+			return node{}, err
+		}
+		if name == "underset" {
+			return node{markup: "<munder>" + base + script + "</munder>"}, nil
+		}
+		return node{markup: "<mover>" + base + script + "</mover>"}, nil
+	case "color", "textcolor":
+		color, err := p.readRawGroup()
+		if err != nil {
+			return node{}, err
+		}
+		var body string
+		if name == "color" {
+			rest, err := p.parseList()
+			if err != nil {
+				return node{}, err
+			}
+			body = join(rest)
+		} else if body, err = p.parseArg(); err != nil {
+			return node{}, err
+		}
+		return node{markup: `<mrow mathcolor="` + escape(strings.TrimSpace(color)) + `">` + body + "</mrow>"}, nil
+	case "not":
+		p.skipSpace()
+		var symbol string
+		if p.peek() == '\\' {
+			symbol = operators[p.readCommand()]
+		} else if p.peek() != 0 {
+			symbol = operatorChar(p.peek())
+			p.pos++
+		}
+		if symbol == "" {
+			return node{}, fmt.Errorf(`\not must be followed by a relation`)
+		}
+		switch symbol {
+		case "=":
+			symbol = "≠"
+		case "∈":
+			symbol = "∉"
+		default:
+			symbol += "\u0338"
+		}
+		return node{markup: "<mo>" + escape(symbol) + "</mo>"}, nil
+	case "left":
+		return p.parseLeftRight()
+	case "begin":
+		return p.parseEnvironment()
+	case "label", "tag":
+		// Equation labels and tags are handled outside the math itself
+		if _, err := p.readRawGroup(); err != nil {
+			return node{}, err
+		}
+		return node{}, nil
+	case "nonumber", "notag", "limits", "nolimits":
+		return node{}, nil
+	}
+
+	return node{}, fmt.Errorf(`unsupported command \%s`, name)
+}
+
+// functionNode renders an upright operator name such as sin or lim
+func functionNode(text string, limits bool) node {
+	if limits {
+		return node{markup: `<mo form="prefix" movablelimits="true">` + escape(text) + "</mo>", limits: true}
+	}
+	// U+2061 FUNCTION APPLICATION binds the name to its argument
+	return node{markup: "<mi>" + escape(text) + "</mi>", after: "<mo>\u2061</mo>"}
+}
+
+// parseAccent parses an accent command's argument
+func (p *parser) parseAccent(a accent) (node, error) {
+	arg, err := p.parseArg()
+	if err != nil {
+		return node{}, err
+	}
+	stretchy := "false"
+	if a.wide {
+		stretchy = "true"
+	}
+	mark := fmt.Sprintf(`<mo stretchy="%s">%s</mo>`, stretchy, escape(a.mark))
+	if a.under {
+		// Braces take their label as a subscript, placed underneath
+		return node{markup: `<munder accentunder="true">` + arg + mark + "</munder>", limits: true, force: true}, nil
+	}
+	limits := a.mark == "⏞"
+	return node{markup: `<mover accent="true">` + arg + mark + "</mover>", limits: limits, force: limits}, nil
+}
+
+// parseFont parses the argument of a font command in that font
+func (p *parser) parseFont(font string) (node, error) {
+	saved := p.font
+	p.font = font
+	defer func() { p.font = saved }()
+
+	// Upright text such as \mathrm{max} reads best as a single identifier
+	if font == "normal" && p.peekNonSpace() == '{' {
+		start := p.pos
+		text, err := p.readRawGroup()
+		if err == nil && isLetters(text) {
+			return node{markup: `<mi mathvariant="normal">` + escape(text) + "</mi>"}, nil
+		}
+		p.pos = start
+	}
+
+	arg, err := p.parseArg()
+	if err != nil {
+		return node{}, err
+	}
+	return node{markup: arg}, nil
+}
+
+// parseLeftRight parses \left ... \middle ... \right with stretchy fences
+func (p *parser) parseLeftRight() (node, error) {
+	open, err := p.readDelimiter()
+	if err != nil {
+		return node{}, err
+	}
+
+	var b strings.Builder
+	b.WriteString("<mrow>")
+	b.WriteString(fence(open, "prefix"))
+	for {
+		nodes, err := p.parseList()
+		if err != nil {
+			return node{}, err
+		}
+		b.WriteString(join(nodes))
+
+		switch p.peekCommand() {
+		case "middle":
+			p.readCommand()
+			delim, err := p.readDelimiter()
+			if err != nil {
+				return node{}, err
+			}
+			b.WriteString(fence(delim, "infix"))
+		case "right":
+			p.readCommand()
+			closing, err := p.readDelimiter()
+			if err != nil {
+				return node{}, err
+			}
+			b.WriteString(fence(closing, "postfix"))
+			b.WriteString("</mrow>")
+			return node{markup: b.String()}, nil
+		default:
+			return node{}, fmt.Errorf(`\left without matching \right`)
+		}
+	}
+}
+
+// fence renders a stretchy delimiter; "." is an invisible fence
+func fence(delim, form string) string {
+	if delim == "." {
+		return ""
+	}
+	return fmt.Sprintf(`<mo fence="true" form="%s" stretchy="true">%s</mo>`, form, escape(delim))
+}
+
+// readDelimiter reads a delimiter after \left, \right, \big and friends
+func (p *parser) readDelimiter() (string, error) {
+	p.skipSpace()
+	c := p.peek()
+	switch {
+	case c == 0:
+		return "", fmt.Errorf("missing delimiter")
+	case c == '\\':
+		name := p.readCommand()
+		if symbol, ok := operators[name]; ok {
+			return symbol, nil
+		}
+		return "", fmt.Errorf(`invalid delimiter \%s`, name)
+	}
+	p.pos++
+	return operatorChar(c), nil
+}
+
+// parseEnvironment parses \begin{env} ... \end{env}
+func (p *parser) parseEnvironment() (node, error) {
+	name, err := p.readName()
+	if err != nil {
+		return node{}, err
+	}
+	env := strings.TrimSuffix(name, "*")
+
+	var columns string
+	switch env {
+	case "array":
+		spec, err := p.readRawGroup()
+		if err != nil {
+			return node{}, err
+		}
+		columns = spec
+	case "matrix", "pmatrix", "bmatrix", "Bmatrix", "vmatrix", "Vmatrix", "smallmatrix",
+		"cases", "rcases", "aligned", "align", "alignat", "alignedat", "split",
+		"gathered", "gather", "equation", "multline":
+		if env == "alignat" || env == "alignedat" {
+			// Skip the column count
+			if _, err := p.readRawGroup(); err != nil {
+				return node{}, err
+			}
+		}
+	default:
+		return node{}, fmt.Errorf("unsupported environment %s", name)
+	}
+
+	rows, err := p.parseRows(name)
+	if err != nil {
+		return node{}, err
+	}
+
+	layout := env
+	if columns != "" {
+		layout = "array:" + columns
+	}
+	table := renderTable(rows, layout)
+
+	fences := matrixFences[env]
+	if fences[0] == "" && fences[1] == "" {
+		return node{markup: table}, nil
+	}
+	markup := "<mrow>" + fence(orDot(fences[0]), "prefix") + table + fence(orDot(fences[1]), "postfix") + "</mrow>"
+	return node{markup: markup}, nil
+}
+
+// orDot returns "." (no fence) for an empty delimiter
+func orDot(delim string) string {
+	if delim == "" {
+		return "."
+	}
+	return delim
+}
+
+// renderTable lays out environment rows as an <mtable>
+func renderTable(rows [][]string, layout string) string {
+	aligns := func(col int) string { return "center" }
+	display := false
+	switch {
+	case layout == "cases" || layout == "rcases":
+		aligns = func(int) string { return "left" }
+	case layout == "aligned" || strings.HasPrefix(layout, "align") || layout == "split":
+		display = true
+		aligns = func(col int) string {
+			if col%2 == 0 {
+				return "right"
+			}
+			return "left"
+		}
+	case layout == "gathered" || layout == "gather" || layout == "equation" || layout == "multline":
+		display = true
+	case strings.HasPrefix(layout, "array:"):
+		var spec []string
+		for _, r := range strings.TrimPrefix(layout, "array:") {
+			switch r {
+			case 'l':
+				spec = append(spec, "left")
+			case 'r':
+				spec = append(spec, "right")
+			case 'c':
+				spec = append(spec, "center")
+			}
+		}
+		aligns = func(col int) string {
+			if col < len(spec) {
+				return spec[col]
+			}
+			return "center"
+		}
+	}
+
+	var b strings.Builder
+	if display {
+		b.WriteString(`<mtable displaystyle="true">`)
+	} else {
+		b.WriteString("<mtable>")
+	}
+	for _, row := range rows {
+		b.WriteString("<mtr>")
+		for col, cell := range row {
+			align := aligns(col)
+			if align == "center" {
+				fmt.Fprintf(&b, "<mtd>%s</mtd>", cell)
+			} else {
+				fmt.Fprintf(&b, `<mtd columnalign="%s" style="text-align: %s">%s</mtd>`, align, align, cell)
+			}
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+	return b.String()
+}
+
+// parseUntil parses a list terminated by the given character, as in \sqrt[n]
+func (p *parser) parseUntil(end rune) ([]node, error) {
+	var nodes []node
+	for {
+		p.skipSpace()
+		switch p.peek() {
+		case end:
+			p.pos++
+			return nodes, nil
+		case 0, '}', '&':
+			return nil, fmt.Errorf("missing %c", end)
+		}
+		n, err := p.parseScripted()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+// Lexing helpers
+
+func (p *parser) peek() rune {
+	return p.peekAt(0)
+}
+
+func (p *parser) peekAt(offset int) rune {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+offset]
+}
+
+// peekNonSpace returns the next non-space character without consuming anything
+func (p *parser) peekNonSpace() rune {
+	for i := p.pos; i < len(p.src); i++ {
+		if !unicode.IsSpace(p.src[i]) {
+			return p.src[i]
+		}
+	}
+	return 0
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) expect(c rune) error {
+	p.skipSpace()
+	if p.peek() != c {
+		return fmt.Errorf("expected %c", c)
+	}
+	p.pos++
+	return nil
+}
+
+// peekCommand returns the name of the command at the current position
+// without consuming it, or "" if there is none
+func (p *parser) peekCommand() string {
+	saved := p.pos
+	defer func() { p.pos = saved }()
+	if p.peek() != '\\' {
+		return ""
+	}
+	return p.readCommand()
+}
+
+// readCommand consumes a backslash command and returns its name: a run
+// of letters (with an optional * for \operatorname*) or one other character
+func (p *parser) readCommand() string {
+	p.pos++ // Backslash
+	start := p.pos
+	for p.pos < len(p.src) && isASCIILetter(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.pos < len(p.src) {
+			p.pos++
+		}
+		return string(p.src[start:p.pos])
+	}
+	name := string(p.src[start:p.pos])
+	if name == "operatorname" && p.peek() == '*' {
+		p.pos++
+		name += "*"
+	}
+	return name
+}
+
+// readRawGroup reads the raw text of a braced group, keeping nested braces
+func (p *parser) readRawGroup() (string, error) {
+	if err := p.expect('{'); err != nil {
+		return "", err
+	}
+	start := p.pos
+	depth := 1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				text := string(p.src[start:p.pos])
+				p.pos++
+				return text, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("missing }")
+}
+
+// readName reads an environment name such as {pmatrix}
+func (p *parser) readName() (string, error) {
+	name, err := p.readRawGroup()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(name), nil
+}
+
+// skipOptional skips an optional [..] argument, as in \\[2pt]
+func (p *parser) skipOptional() {
+	if p.peekNonSpace() != '[' {
+		return
+	}
+	saved := p.pos
+	p.skipSpace()
+	for p.pos < len(p.src) {
+		if p.src[p.pos] == ']' {
+			p.pos++
+			return
+		}
+		p.pos++
+	}
+	p.pos = saved
+}
+
+// operatorChar maps an ASCII operator to its typographic form
+func operatorChar(c rune) string {
+	switch c {
+	case '-':
+		return "−"
+	case '*':
+		return "∗"
+	}
+	return string(c)
+}
+
+// textSpaces keeps leading and trailing spaces in \text{} visible
+func textSpaces(text string) string {
+	trimmed := strings.TrimLeft(text, " ")
+	text = strings.Repeat(" ", len(text)-len(trimmed)) + trimmed
+	trimmed = strings.TrimRight(text, " ")
+	return trimmed + strings.Repeat(" ", len(text)-len(trimmed))
+}
+
+func join(nodes []node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(n.String())
+	}
+	return b.String()
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isASCIILetter(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if !isASCIILetter(r) {
+			return false
+		}
+	}
+	return s != ""
+}
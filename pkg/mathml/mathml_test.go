@@ -0,0 +1,52 @@
+package mathml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		tex  string
+		want string // The MathML inside <mrow>, before the annotation
+	}{
+		{`x^2`, `<msup><mi>x</mi><mn>2</mn></msup>`},
+		{`\frac{a}{b}`, `<mfrac><mi>a</mi><mi>b</mi></mfrac>`},
+		{`\sqrt{x}`, `<msqrt><mi>x</mi></msqrt>`},
+		{`\alpha + \beta`, `<mi>α</mi><mo>+</mo><mi>β</mi>`},
+		{`a_{i,j}`, `<msub><mi>a</mi><mrow><mi>i</mi><mo>,</mo><mi>j</mi></mrow></msub>`},
+		{`\sum_{i=1}^{n} i`, `<munderover><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover><mi>i</mi>`},
+		{`\left( x \right)`, `<mrow><mo fence="true" form="prefix" stretchy="true">(</mo><mi>x</mi><mo fence="true" form="postfix" stretchy="true">)</mo></mrow>`},
+		{`\begin{pmatrix} 1 & 2 \\ 3 & 4 \end{pmatrix}`, `<mtable><mtr><mtd><mn>1</mn></mtd><mtd><mn>2</mn></mtd></mtr><mtr><mtd><mn>3</mn></mtd><mtd><mn>4</mn></mtd></mtr></mtable>`},
+		{`\text{if } x<y`, `</mtext><mi>x</mi><mo>&lt;</mo><mi>y</mi>`},
+	}
+	for _, tt := range tests {
+		got, err := Convert(tt.tex, false)
+		if err != nil {
+			t.Errorf("Convert(%q): %v", tt.tex, err)
+			continue
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("Convert(%q) = %s\nwant it to contain %s", tt.tex, got, tt.want)
+		}
+		if !strings.HasPrefix(got, `<math xmlns="http://www.w3.org/1998/Math/MathML"><semantics>`) || strings.Contains(got, `display="block"`) {
+			t.Errorf("Convert(%q) has the wrong math element: %s", tt.tex, got)
+		}
+		if !strings.Contains(got, `<annotation encoding="application/x-tex">`) {
+			t.Errorf("Convert(%q) has no TeX annotation: %s", tt.tex, got)
+		}
+	}
+
+	got, err := Convert("x", true)
+	if err != nil || !strings.Contains(got, `display="block"`) {
+		t.Errorf("display math = %s, %v", got, err)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	for _, tex := range []string{`\frac{a}{`, `\unknowncmd`, `}`} {
+		if got, err := Convert(tex, false); err == nil {
+			t.Errorf("Convert(%q) = %s, want an error", tex, got)
+		}
+	}
+}
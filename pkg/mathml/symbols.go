@@ -0,0 +1,180 @@
+package mathml
+
+// identifiers maps commands to <mi> content: Greek letters and letter-like symbols
+var identifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅", "varnothing": "∅",
+	"hbar": "ℏ", "ell": "ℓ", "aleph": "ℵ", "wp": "℘", "Re": "ℜ", "Im": "ℑ",
+	"imath": "ı", "jmath": "ȷ",
+}
+
+// uprightIdentifiers are capital Greek letters, which LaTeX sets upright
+var uprightIdentifiers = map[string]string{
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+}
+
+// operators maps commands to <mo> content: binary operators, relations,
+// arrows, delimiters and punctuation
+var operators = map[string]string{
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "⋅", "ast": "∗",
+	"star": "⋆", "circ": "∘", "bullet": "∙", "oplus": "⊕", "ominus": "⊖",
+	"otimes": "⊗", "oslash": "⊘", "odot": "⊙", "cap": "∩", "cup": "∪",
+	"wedge": "∧", "land": "∧", "vee": "∨", "lor": "∨", "setminus": "∖",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"leqslant": "⩽", "geqslant": "⩾", "approx": "≈", "equiv": "≡", "sim": "∼",
+	"simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"prec": "≺", "succ": "≻", "preceq": "⪯", "succeq": "⪰",
+	"subset": "⊂", "supset": "⊃", "subseteq": "⊆", "supseteq": "⊇",
+	"in": "∈", "notin": "∉", "ni": "∋", "perp": "⊥", "parallel": "∥",
+	"mid": "∣", "nmid": "∤", "vdash": "⊢", "models": "⊨",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"Leftrightarrow": "⇔", "implies": "⟹", "impliedby": "⟸", "iff": "⟺",
+	"mapsto": "↦", "uparrow": "↑", "downarrow": "↓", "updownarrow": "↕",
+	"longrightarrow": "⟶", "longleftarrow": "⟵", "longmapsto": "⟼",
+	"Longrightarrow": "⟹", "Longleftarrow": "⟸", "hookrightarrow": "↪",
+	"rightharpoonup": "⇀", "leftharpoonup": "↼", "nearrow": "↗", "searrow": "↘",
+	"forall": "∀", "exists": "∃", "nexists": "∄", "neg": "¬", "lnot": "¬",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"angle": "∠", "triangle": "△", "square": "□", "prime": "′", "degree": "°",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "vert": "|", "lvert": "|", "rvert": "|",
+	"Vert": "‖", "lVert": "‖", "rVert": "‖", "|": "‖",
+	"{": "{", "}": "}", "lbrace": "{", "rbrace": "}", "lbrack": "[", "rbrack": "]",
+	"colon": ":", "backslash": "∖", "therefore": "∴", "because": "∵",
+	"$": "$", "%": "%", "&": "&", "#": "#", "_": "_",
+}
+
+// bigOperator is a large operator such as a sum or integral
+type bigOperator struct {
+	symbol string
+	limits bool // Scripts go under and over in display style
+}
+
+var bigOperators = map[string]bigOperator{
+	"sum": {"∑", true}, "prod": {"∏", true}, "coprod": {"∐", true},
+	"bigcup": {"⋃", true}, "bigcap": {"⋂", true}, "bigvee": {"⋁", true},
+	"bigwedge": {"⋀", true}, "bigoplus": {"⨁", true}, "bigotimes": {"⨂", true},
+	"bigodot": {"⨀", true}, "biguplus": {"⨄", true},
+	"int": {"∫", false}, "iint": {"∬", false}, "iiint": {"∭", false},
+	"oint": {"∮", false},
+}
+
+// functions are upright operator names; true marks those whose scripts
+// act as limits (\lim_{x \to 0})
+var functions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false, "csc": false,
+	"arcsin": false, "arccos": false, "arctan": false, "sinh": false, "cosh": false,
+	"tanh": false, "coth": false, "log": false, "ln": false, "lg": false, "exp": false,
+	"dim": false, "ker": false, "deg": false, "arg": false, "hom": false,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "gcd": true, "Pr": true,
+	"argmax": true, "argmin": true,
+}
+
+// functionNames overrides the displayed text of multi-word functions
+var functionNames = map[string]string{
+	"liminf": "lim inf", "limsup": "lim sup", "argmax": "arg max", "argmin": "arg min",
+}
+
+// accent is a mark placed over or under its argument
+type accent struct {
+	mark  string
+	under bool
+	wide  bool // Stretches across the argument
+}
+
+var accents = map[string]accent{
+	"hat": {"^", false, false}, "widehat": {"^", false, true},
+	"bar": {"¯", false, false}, "overline": {"¯", false, true},
+	"vec": {"→", false, false}, "overrightarrow": {"→", false, true},
+	"overleftarrow": {"←", false, true}, "dot": {"˙", false, false},
+	"ddot": {"¨", false, false}, "tilde": {"~", false, false},
+	"widetilde": {"~", false, true}, "check": {"ˇ", false, false},
+	"breve": {"˘", false, false}, "acute": {"´", false, false},
+	"grave": {"`", false, false}, "mathring": {"˚", false, false},
+	"overbrace": {"⏞", false, true}, "underbrace": {"⏟", true, true},
+	"underline": {"_", true, true},
+}
+
+// spaces maps spacing commands to widths
+var spaces = map[string]string{
+	",": "0.1667em", "thinspace": "0.1667em", ":": "0.2222em", ">": "0.2222em",
+	"medspace": "0.2222em", ";": "0.2778em", "thickspace": "0.2778em",
+	"quad": "1em", "qquad": "2em", "!": "-0.1667em", "negthinspace": "-0.1667em",
+	" ": "0.25em", "enspace": "0.5em",
+}
+
+// fonts maps font commands to the Unicode math alphabet they select
+var fonts = map[string]string{
+	"mathbf": "bold", "boldsymbol": "bold", "bm": "bold",
+	"mathit": "italic", "mathbb": "double-struck", "mathcal": "script",
+	"mathscr": "script", "mathfrak": "fraktur", "mathsf": "sans-serif",
+	"mathtt": "monospace", "mathrm": "normal", "mathnormal": "",
+}
+
+// bigDelimiterSizes are the heights of \big, \Big, \bigg and \Bigg delimiters
+var bigDelimiterSizes = map[string]string{
+	"big": "1.2em", "bigl": "1.2em", "bigr": "1.2em", "bigm": "1.2em",
+	"Big": "1.623em", "Bigl": "1.623em", "Bigr": "1.623em", "Bigm": "1.623em",
+	"bigg": "2.047em", "biggl": "2.047em", "biggr": "2.047em", "biggm": "2.047em",
+	"Bigg": "2.470em", "Biggl": "2.470em", "Biggr": "2.470em", "Biggm": "2.470em",
+}
+
+// matrixFences are the delimiters placed around matrix environments
+var matrixFences = map[string][2]string{
+	"matrix": {"", ""}, "smallmatrix": {"", ""}, "pmatrix": {"(", ")"},
+	"bmatrix": {"[", "]"}, "Bmatrix": {"{", "}"}, "vmatrix": {"|", "|"},
+	"Vmatrix": {"‖", "‖"}, "cases": {"{", ""}, "rcases": {"", "}"},
+}
+
+// alphabetStart holds the first code point of each styled alphabet for
+// capitals, small letters and digits (0 where Unicode has no such range)
+var alphabetStart = map[string][3]rune{
+	"bold":          {0x1D400, 0x1D41A, 0x1D7CE},
+	"italic":        {0x1D434, 0x1D44E, 0},
+	"double-struck": {0x1D538, 0x1D552, 0x1D7D8},
+	"script":        {0x1D49C, 0x1D4B6, 0},
+	"fraktur":       {0x1D504, 0x1D51E, 0},
+	"sans-serif":    {0x1D5A0, 0x1D5BA, 0x1D7E2},
+	"monospace":     {0x1D670, 0x1D68A, 0x1D7F6},
+}
+
+// alphabetExceptions are letters encoded outside the math alphanumeric block
+var alphabetExceptions = map[string]map[rune]rune{
+	"italic": {'h': 'ℎ'},
+	"double-struck": {
+		'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	},
+	"script": {
+		'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ',
+		'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+	},
+	"fraktur": {'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ'},
+}
+
+// styleRune maps a letter or digit to its styled math alphanumeric form
+func styleRune(r rune, font string) rune {
+	start, ok := alphabetStart[font]
+	if !ok {
+		return r
+	}
+	if mapped, ok := alphabetExceptions[font][r]; ok {
+		return mapped
+	}
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return start[0] + (r - 'A')
+	case r >= 'a' && r <= 'z':
+		return start[1] + (r - 'a')
+	case r >= '0' && r <= '9' && start[2] != 0:
+		return start[2] + (r - '0')
+	}
+	return r
+}
@@ -0,0 +1,102 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// sRGBDescription names the built-in output profile
+const sRGBDescription = "sRGB IEC61966-2.1"
+
+// sRGBProfile builds a version 2 ICC display profile for sRGB: D50-adapted
+// primaries and the sRGB tone curve as a 1024-entry table. It is embedded
+// as the output intent of PDF/A files, so no profile has to be shipped.
+func sRGBProfile() []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+
+	xyz := func(x, y, z float64) []byte {
+		var b bytes.Buffer
+		b.WriteString("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			_ = binary.Write(&b, binary.BigEndian, int32(math.Round(v*65536)))
+		}
+		return b.Bytes()
+	}
+
+	var curve bytes.Buffer
+	curve.WriteString("curv\x00\x00\x00\x00")
+	_ = binary.Write(&curve, binary.BigEndian, uint32(1024))
+	for i := 0; i < 1024; i++ {
+		v := float64(i) / 1023
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		_ = binary.Write(&curve, binary.BigEndian, uint16(math.Round(v*65535)))
+	}
+
+	var desc bytes.Buffer
+	desc.WriteString("desc\x00\x00\x00\x00")
+	_ = binary.Write(&desc, binary.BigEndian, uint32(len(sRGBDescription)+1))
+	desc.WriteString(sRGBDescription + "\x00")
+	desc.Write(make([]byte, 4+4+2+1+67)) // No Unicode or ScriptCode description
+
+	var cprt bytes.Buffer
+	cprt.WriteString("text\x00\x00\x00\x00No copyright, use freely\x00")
+
+	tags := []tag{
+		{"desc", desc.Bytes()},
+		{"cprt", cprt.Bytes()},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve.Bytes()},
+		{"gTRC", curve.Bytes()},
+		{"bTRC", curve.Bytes()},
+	}
+
+	// Tag data follows the header and tag table, 4-byte aligned; the three
+	// tone curves share one copy
+	offset := 128 + 4 + 12*len(tags)
+	var table, body bytes.Buffer
+	_ = binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	shared := map[string]int{}
+	for _, t := range tags {
+		at, ok := shared[string(t.data)]
+		if !ok {
+			at = offset + body.Len()
+			shared[string(t.data)] = at
+			body.Write(t.data)
+			for body.Len()%4 != 0 {
+				body.WriteByte(0)
+			}
+		}
+		table.WriteString(t.sig)
+		_ = binary.Write(&table, binary.BigEndian, uint32(at))
+		_ = binary.Write(&table, binary.BigEndian, uint32(len(t.data)))
+	}
+
+	size := offset + body.Len()
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(size))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // Version 2.1
+	copy(header[12:], "mntrRGB XYZ ")
+	binary.BigEndian.PutUint16(header[24:], 2024) // Creation date: 2024-01-01
+	binary.BigEndian.PutUint16(header[26:], 1)
+	binary.BigEndian.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	for i, v := range []float64{0.9642, 1.0, 0.8249} { // D50 illuminant
+		binary.BigEndian.PutUint32(header[68+4*i:], uint32(int32(math.Round(v*65536))))
+	}
+
+	profile := make([]byte, 0, size)
+	profile = append(profile, header...)
+	profile = append(profile, table.Bytes()...)
+	return append(profile, body.Bytes()...)
+}
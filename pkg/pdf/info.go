@@ -0,0 +1,74 @@
+// Package pdf post-processes PDF files produced by Chrome and Pandoc
+package pdf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Info is the document information dictionary of a PDF
+type Info struct {
+	Title        string
+	Author       string
+	Subject      string
+	Keywords     []string
+	Creator      string // Application that created the original content
+	CreationDate time.Time
+	ModDate      time.Time
+}
+
+// dict returns the information dictionary for an Info
+func (info Info) dict() string {
+	var dict strings.Builder
+	dict.WriteString("<<")
+	for _, entry := range []struct{ key, value string }{
+		{"Title", info.Title},
+		{"Author", info.Author},
+		{"Subject", info.Subject},
+		{"Keywords", strings.Join(info.Keywords, ", ")},
+		{"Creator", info.Creator},
+	} {
+		if entry.value != "" {
+			fmt.Fprintf(&dict, " /%s %s", entry.key, textString(entry.value))
+		}
+	}
+	if !info.CreationDate.IsZero() {
+		fmt.Fprintf(&dict, " /CreationDate %s", dateString(info.CreationDate))
+	}
+	if !info.ModDate.IsZero() {
+		fmt.Fprintf(&dict, " /ModDate %s", dateString(info.ModDate))
+	}
+	dict.WriteString(" >>")
+	return dict.String()
+}
+
+// textString encodes text as a PDF string: a literal string for ASCII,
+// UTF-16 with a byte order mark otherwise
+func textString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r > 126 || (r < 32 && r != '\t' && r != '\n') {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+		return "(" + r.Replace(s) + ")"
+	}
+
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// dateString formats a time as a PDF date string in UTC
+func dateString(t time.Time) string {
+	return t.UTC().Format("(D:20060102150405Z)")
+}
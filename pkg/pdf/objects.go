@@ -0,0 +1,423 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+var (
+	objHeaderRegex = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	refRegex       = regexp.MustCompile(`^(\d+)\s+(\d+)\s+R\b`)
+	intRegex       = regexp.MustCompile(`^\d+$`)
+)
+
+// file is a simple view of a PDF: its trailer and the latest definition
+// of every object. Objects are found by scanning rather than through the
+// cross-reference table, which is enough for the files Chrome and Pandoc
+// write and for updates made by this package.
+type file struct {
+	data    []byte
+	trailer *trailer
+	objects map[int][]byte // Object number to body, between "obj" and "endobj"
+}
+
+// parseFile indexes the objects of a PDF, including those packed in
+// object streams. Objects defined directly take precedence over packed
+// ones, and later definitions over earlier ones.
+func parseFile(data []byte) (*file, error) {
+	t, err := readTrailer(data)
+	if err != nil {
+		return nil, err
+	}
+	f := &file{data: data, trailer: t, objects: make(map[int][]byte)}
+
+	var streams [][]byte
+	pos := 0
+	for pos < len(data) {
+		loc := objHeaderRegex.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		start := pos + loc[0]
+		if start > 0 && !isWhitespace(data[start-1]) {
+			pos += loc[1]
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		bodyStart := pos + loc[1]
+		bodyEnd := f.objectEnd(bodyStart)
+		end := bodyEnd
+		if i := bytes.Index(data[bodyEnd:], []byte("endobj")); i != -1 {
+			end = bodyEnd + i + len("endobj")
+			bodyEnd += i
+		}
+		body := data[bodyStart:bodyEnd]
+		f.objects[num] = body
+		if bytes.Contains(dictOf(body), []byte("/ObjStm")) {
+			streams = append(streams, body)
+		}
+		pos = end
+	}
+
+	for _, stream := range streams {
+		f.unpackObjectStream(stream)
+	}
+	return f, nil
+}
+
+// objectEnd returns where an object's body ends, skipping stream data so
+// that bytes inside it are never taken for objects
+func (f *file) objectEnd(bodyStart int) int {
+	i := skipWhitespace(f.data, bodyStart)
+	if !bytes.HasPrefix(f.data[i:], []byte("<<")) {
+		return i
+	}
+	dictEnd := skipValue(f.data, i)
+	j := skipWhitespace(f.data, dictEnd)
+	if !bytes.HasPrefix(f.data[j:], []byte("stream")) {
+		return dictEnd
+	}
+	dataStart := j + len("stream")
+	if dataStart < len(f.data) && f.data[dataStart] == '\r' {
+		dataStart++
+	}
+	if dataStart < len(f.data) && f.data[dataStart] == '\n' {
+		dataStart++
+	}
+	if length, ok := f.intValue(lookup(f.data[i:dictEnd], "Length")); ok && dataStart+length <= len(f.data) {
+		if bytes.HasPrefix(bytes.TrimLeft(f.data[dataStart+length:], " \r\n"), []byte("endstream")) {
+			return dataStart + length
+		}
+	}
+	if k := bytes.Index(f.data[dataStart:], []byte("endstream")); k != -1 {
+		return dataStart + k
+	}
+	return len(f.data)
+}
+
+// intValue returns a direct or indirect integer. Indirect values are only
+// found if defined earlier in the file, which is where producers put
+// stream lengths unless they follow the stream; those are found by
+// searching for endstream instead.
+func (f *file) intValue(value []byte) (int, bool) {
+	value = bytes.TrimSpace(value)
+	if intRegex.Match(value) {
+		n, err := strconv.Atoi(string(value))
+		return n, err == nil
+	}
+	if obj := f.resolve(value); obj != nil {
+		return f.intValue(obj)
+	}
+	return 0, false
+}
+
+// unpackObjectStream adds the objects packed in an object stream, unless
+// they are also defined directly
+func (f *file) unpackObjectStream(body []byte) {
+	dict := dictOf(body)
+	n, ok1 := f.intValue(lookup(dict, "N"))
+	first, ok2 := f.intValue(lookup(dict, "First"))
+	data, err := streamData(body)
+	if !ok1 || !ok2 || err != nil || first > len(data) {
+		return
+	}
+
+	fields := bytes.Fields(data[:first])
+	if len(fields) < 2*n {
+		return
+	}
+	for i := 0; i < n; i++ {
+		num, err1 := strconv.Atoi(string(fields[2*i]))
+		offset, err2 := strconv.Atoi(string(fields[2*i+1]))
+		if err1 != nil || err2 != nil || first+offset > len(data) {
+			continue
+		}
+		if _, ok := f.objects[num]; ok {
+			continue
+		}
+		end := len(data)
+		if i+1 < n {
+			if next, err := strconv.Atoi(string(fields[2*i+3])); err == nil && first+next <= len(data) {
+				end = first + next
+			}
+		}
+		f.objects[num] = data[first+offset : end]
+	}
+}
+
+// resolve returns the body of the object an "n g R" reference points to,
+// or nil
+func (f *file) resolve(ref []byte) []byte {
+	m := refRegex.FindSubmatch(bytes.TrimSpace(ref))
+	if m == nil {
+		return nil
+	}
+	num, _ := strconv.Atoi(string(m[1]))
+	return f.objects[num]
+}
+
+// value returns a dictionary entry, following an indirect reference
+func (f *file) value(dict []byte, key string) []byte {
+	v := lookup(dict, key)
+	if obj := f.resolve(v); obj != nil {
+		return bytes.TrimSpace(dictOrValue(obj))
+	}
+	return v
+}
+
+// catalog returns the object number and dictionary of the document catalog
+func (f *file) catalog() (int, []byte, error) {
+	m := refRegex.FindStringSubmatch(f.trailer.root)
+	if m == nil {
+		return 0, nil, fmt.Errorf("PDF has an invalid /Root")
+	}
+	num, _ := strconv.Atoi(m[1])
+	obj, ok := f.objects[num]
+	if !ok {
+		return 0, nil, fmt.Errorf("PDF catalog object %d not found", num)
+	}
+	return num, dictOf(obj), nil
+}
+
+// infoDict returns the document information dictionary from the trailer
+func (f *file) infoDict() []byte {
+	if m := infoRefRegex.FindSubmatch(f.trailerDict()); m != nil {
+		return dictOf(f.resolve(m[1]))
+	}
+	return nil
+}
+
+// dictOf returns the dictionary at the start of an object body, or nil
+func dictOf(body []byte) []byte {
+	i := skipWhitespace(body, 0)
+	if !bytes.HasPrefix(body[i:], []byte("<<")) {
+		return nil
+	}
+	return body[i:skipValue(body, i)]
+}
+
+// dictOrValue returns the dictionary at the start of an object body, or
+// the whole body for other values
+func dictOrValue(body []byte) []byte {
+	if d := dictOf(body); d != nil {
+		return d
+	}
+	return body
+}
+
+// streamData returns the decoded data of a stream object. Only
+// FlateDecode, the filter Chrome and Pandoc use, is supported.
+func streamData(body []byte) ([]byte, error) {
+	dict := dictOf(body)
+	i := bytes.Index(body[len(dict):], []byte("stream"))
+	if dict == nil || i == -1 {
+		return nil, fmt.Errorf("not a stream")
+	}
+	data := body[len(dict)+i+len("stream"):]
+	data = bytes.TrimPrefix(data, []byte("\r"))
+	data = bytes.TrimPrefix(data, []byte("\n"))
+	if j := bytes.LastIndex(data, []byte("endstream")); j != -1 {
+		data = data[:j]
+	}
+
+	switch filter := string(bytes.Trim(lookup(dict, "Filter"), "[] \r\n")); filter {
+	case "":
+		return data, nil
+	case "/FlateDecode":
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		decoded, err := io.ReadAll(r)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported filter %s", filter)
+	}
+}
+
+// lookup returns the raw value of a key at the top level of a dictionary,
+// or nil
+func lookup(dict []byte, key string) []byte {
+	start, end := findKey(dict, key)
+	if start == -1 {
+		return nil
+	}
+	return bytes.TrimSpace(dict[start:end])
+}
+
+// removeKeys returns a copy of a dictionary without the given keys
+func removeKeys(dict []byte, keys ...string) []byte {
+	out := append([]byte(nil), dict...)
+	for _, key := range keys {
+		if start, end := findKey(out, key); start != -1 {
+			keyStart := bytes.LastIndex(out[:start], []byte("/"+key))
+			out = append(out[:keyStart], out[end:]...)
+		}
+	}
+	return out
+}
+
+// addEntries inserts entries before the closing >> of a dictionary
+func addEntries(dict []byte, entries string) []byte {
+	end := bytes.LastIndex(dict, []byte(">>"))
+	out := append([]byte(nil), dict[:end]...)
+	out = append(out, ' ')
+	out = append(out, entries...)
+	out = append(out, ' ')
+	return append(out, dict[end:]...)
+}
+
+// findKey returns the bounds of a key's value at the top level of a
+// dictionary, or -1, -1
+func findKey(dict []byte, key string) (int, int) {
+	i := skipWhitespace(dict, 0)
+	if !bytes.HasPrefix(dict[i:], []byte("<<")) {
+		return -1, -1
+	}
+	i += 2
+	for {
+		i = skipWhitespace(dict, i)
+		if i >= len(dict) || bytes.HasPrefix(dict[i:], []byte(">>")) || dict[i] != '/' {
+			return -1, -1
+		}
+		nameEnd := skipValue(dict, i)
+		name := string(dict[i+1 : nameEnd])
+		valueStart := skipWhitespace(dict, nameEnd)
+		valueEnd := skipValue(dict, valueStart)
+		// An indirect reference is three tokens
+		if m := refRegex.FindIndex(dict[valueStart:]); m != nil {
+			valueEnd = valueStart + m[1]
+		}
+		if name == key {
+			return valueStart, valueEnd
+		}
+		i = valueEnd
+	}
+}
+
+// skipValue returns the position just after the PDF value starting at i
+func skipValue(b []byte, i int) int {
+	if i >= len(b) {
+		return i
+	}
+	switch {
+	case bytes.HasPrefix(b[i:], []byte("<<")):
+		depth := 0
+		for i < len(b) {
+			switch {
+			case bytes.HasPrefix(b[i:], []byte("<<")):
+				depth++
+				i += 2
+			case bytes.HasPrefix(b[i:], []byte(">>")):
+				depth--
+				i += 2
+				if depth == 0 {
+					return i
+				}
+			case b[i] == '(':
+				i = skipString(b, i)
+			case b[i] == '<':
+				i = skipHexString(b, i)
+			default:
+				i++
+			}
+		}
+		return i
+	case b[i] == '[':
+		depth := 0
+		for i < len(b) {
+			switch b[i] {
+			case '[':
+				depth++
+				i++
+			case ']':
+				depth--
+				i++
+				if depth == 0 {
+					return i
+				}
+			case '(':
+				i = skipString(b, i)
+			case '<':
+				if bytes.HasPrefix(b[i:], []byte("<<")) {
+					i = skipValue(b, i)
+				} else {
+					i = skipHexString(b, i)
+				}
+			default:
+				i++
+			}
+		}
+		return i
+	case b[i] == '(':
+		return skipString(b, i)
+	case b[i] == '<':
+		return skipHexString(b, i)
+	case b[i] == '/':
+		i++
+	}
+	for i < len(b) && !isWhitespace(b[i]) && !isDelimiter(b[i]) {
+		i++
+	}
+	return i
+}
+
+// skipString returns the position after a literal string starting at i
+func skipString(b []byte, i int) int {
+	depth := 0
+	for i < len(b) {
+		switch b[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+		i++
+	}
+	return i
+}
+
+// skipHexString returns the position after a hex string starting at i
+func skipHexString(b []byte, i int) int {
+	if j := bytes.IndexByte(b[i:], '>'); j != -1 {
+		return i + j + 1
+	}
+	return len(b)
+}
+
+func skipWhitespace(b []byte, i int) int {
+	for i < len(b) {
+		switch {
+		case isWhitespace(b[i]):
+			i++
+		case b[i] == '%':
+			for i < len(b) && b[i] != '\n' && b[i] != '\r' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) != -1
+}
@@ -0,0 +1,315 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testObject is an object of a test PDF; packed objects go into an object
+// stream when the PDF uses a cross-reference stream
+type testObject struct {
+	num    int
+	body   string
+	packed bool
+}
+
+// buildPDF writes a PDF with the objects and a cross-reference table, or
+// a compressed cross-reference stream and object stream
+func buildPDF(t *testing.T, objects []testObject, xrefStream bool, trailerExtra string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	size := 0
+	offsets := map[int]int{}
+	var packed []testObject
+	for _, obj := range objects {
+		size = max(size, obj.num+1)
+		if obj.packed && xrefStream {
+			packed = append(packed, obj)
+			continue
+		}
+		offsets[obj.num] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", obj.num, obj.body)
+	}
+
+	streamNum := size
+	inStream := map[int]int{}
+	if len(packed) > 0 {
+		var header, content strings.Builder
+		for i, obj := range packed {
+			fmt.Fprintf(&header, "%d %d ", obj.num, content.Len())
+			content.WriteString(obj.body + "\n")
+			inStream[obj.num] = i
+		}
+		raw := header.String() + content.String()
+		data := deflate(t, []byte(raw))
+		offsets[streamNum] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode /Length %d >>\nstream\n", streamNum, len(packed), header.Len(), len(data))
+		out.Write(data)
+		out.WriteString("\nendstream\nendobj\n")
+		size = streamNum + 1
+	}
+
+	xrefOffset := out.Len()
+	if xrefStream {
+		xrefNum := size
+		offsets[xrefNum] = xrefOffset
+		size++
+		var rows bytes.Buffer
+		for num := 0; num < size; num++ {
+			switch {
+			case num == 0:
+				rows.Write([]byte{0, 0, 0, 0, 0, 0xff, 0xff})
+			case offsets[num] > 0:
+				rows.WriteByte(1)
+				_ = binary.Write(&rows, binary.BigEndian, uint32(offsets[num]))
+				_ = binary.Write(&rows, binary.BigEndian, uint16(0))
+			default:
+				rows.WriteByte(2)
+				_ = binary.Write(&rows, binary.BigEndian, uint32(streamNum))
+				_ = binary.Write(&rows, binary.BigEndian, uint16(inStream[num]))
+			}
+		}
+		data := deflate(t, rows.Bytes())
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Root 1 0 R %s /Filter /FlateDecode /Length %d >>\nstream\n", xrefNum, size, trailerExtra, len(data))
+		out.Write(data)
+		out.WriteString("\nendstream\nendobj\n")
+	} else {
+		fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", size)
+		for num := 1; num < size; num++ {
+			fmt.Fprintf(&out, "%010d 00000 n \n", offsets[num])
+		}
+		fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R %s >>\n", size, trailerExtra)
+	}
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+	return out.Bytes()
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// samplePDF returns a one-page PDF with a link annotation that is not set
+// to print and a font that is embedded unless unembedded is set
+func samplePDF(t *testing.T, xrefStream, unembedded bool) []byte {
+	fontFile := " /FontFile2 7 0 R"
+	if unembedded {
+		fontFile = ""
+	}
+	objects := []testObject{
+		{num: 1, body: "<< /Type /Catalog /Pages 2 0 R /StructTreeRoot 8 0 R >>", packed: true},
+		{num: 2, body: "<< /Type /Pages /Kids [3 0 R] /Count 1 >>", packed: true},
+		{num: 3, body: "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R /Annots [9 0 R] >>", packed: true},
+		{num: 4, body: "<< /Length 44 >>\nstream\nBT /F1 12 Tf 72 720 Td (Hello \\(PDF\\)) Tj ET\nendstream"},
+		{num: 5, body: "<< /Type /Font /Subtype /TrueType /BaseFont /ABCDEF+Arial /FontDescriptor 6 0 R >>", packed: true},
+		{num: 6, body: "<< /Type /FontDescriptor /FontName /ABCDEF+Arial" + fontFile + " >>", packed: true},
+		{num: 7, body: "<< /Length 4 >>\nstream\nfont\nendstream"},
+		{num: 8, body: "<< /Type /StructTreeRoot >>", packed: true},
+		{num: 9, body: "<< /Type /Annot /Subtype /Link /Rect [72 700 200 720] /A << /S /URI /URI (https://example.com) >> >>", packed: true},
+	}
+	return buildPDF(t, objects, xrefStream, "")
+}
+
+func TestProcessRoundTrip(t *testing.T) {
+	info := Info{
+		Title:        "Quarterly (Q3) report",
+		Author:       "Jörg Müller",
+		Keywords:     []string{"finance", "q3"},
+		Creator:      "simple-html-docgen",
+		CreationDate: time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC),
+		ModDate:      time.Date(2024, 6, 2, 10, 0, 0, 0, time.UTC),
+	}
+
+	for _, tt := range []struct {
+		name       string
+		xrefStream bool
+	}{
+		{"xref table", false},
+		{"xref stream", true},
+	} {
+		for _, profile := range Profiles {
+			t.Run(tt.name+"/"+profile, func(t *testing.T) {
+				original := samplePDF(t, tt.xrefStream, false)
+				before, err := parseFile(original)
+				if err != nil {
+					t.Fatalf("parsing the original: %v", err)
+				}
+				if tt.xrefStream && before.objects[3] == nil {
+					t.Fatal("objects packed in the object stream were not found")
+				}
+
+				data, err := Process(original, Options{Info: info, Profile: profile, Lang: "en-US"})
+				if err != nil {
+					t.Fatalf("Process: %v", err)
+				}
+				if !bytes.HasPrefix(data, original) {
+					t.Fatal("the update does not keep the original bytes")
+				}
+
+				f, err := parseFile(data)
+				if err != nil {
+					t.Fatalf("parsing the update: %v", err)
+				}
+				if f.trailer.xrefStream != tt.xrefStream {
+					t.Errorf("cross-reference stream = %v, want %v", f.trailer.xrefStream, tt.xrefStream)
+				}
+				if f.trailer.root != "1 0 R" {
+					t.Errorf("root = %q, want 1 0 R", f.trailer.root)
+				}
+				if !bytes.Contains(f.trailer.dict, []byte(fmt.Sprintf("/Prev %d", before.trailer.offset))) {
+					t.Errorf("the update does not point at the previous section: %s", f.trailer.dict)
+				}
+
+				infoDict := f.infoDict()
+				for key, want := range map[string]string{
+					"Title":        `(Quarterly \(Q3\) report)`,
+					"Author":       textString("Jörg Müller"),
+					"Keywords":     "(finance, q3)",
+					"CreationDate": "(D:20240501093000Z)",
+					"ModDate":      "(D:20240602100000Z)",
+				} {
+					if got := string(lookup(infoDict, key)); got != want {
+						t.Errorf("/%s = %s, want %s", key, got, want)
+					}
+				}
+
+				// Unchanged objects are still found after the update
+				if f.objects[5] == nil || !bytes.Contains(f.objects[4], []byte("Hello")) {
+					t.Error("objects of the original file are missing after the update")
+				}
+
+				problems, err := Verify(data, profile)
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if len(problems) > 0 {
+					t.Errorf("Verify(%s) found problems: %v", profile, problems)
+				}
+			})
+		}
+	}
+}
+
+func TestProcessTwice(t *testing.T) {
+	for _, xrefStream := range []bool{false, true} {
+		data := samplePDF(t, xrefStream, false)
+		var err error
+		for i := 0; i < 2; i++ {
+			data, err = Process(data, Options{Info: Info{Title: fmt.Sprintf("Pass %d", i+1)}, Profile: ProfilePDFA})
+			if err != nil {
+				t.Fatalf("pass %d: %v", i+1, err)
+			}
+		}
+		f, err := parseFile(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(lookup(f.infoDict(), "Title")); got != "(Pass 2)" {
+			t.Errorf("xref stream %v: title = %s, want (Pass 2)", xrefStream, got)
+		}
+		if problems, err := Verify(data, ProfilePDFA); err != nil || len(problems) > 0 {
+			t.Errorf("xref stream %v: Verify = %v, %v", xrefStream, problems, err)
+		}
+	}
+}
+
+func TestVerifyReportsProblems(t *testing.T) {
+	for _, xrefStream := range []bool{false, true} {
+		original := samplePDF(t, xrefStream, true)
+
+		problems, err := Verify(original, ProfilePDFA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"XMP metadata", "output intent", "/ID", "not set to print", "fonts are not embedded: ABCDEF+Arial"} {
+			if !containsProblem(problems, want) {
+				t.Errorf("xref stream %v: original: no problem mentioning %q in %v", xrefStream, want, problems)
+			}
+		}
+
+		// Process fixes everything but the font
+		data, err := Process(original, Options{Profile: ProfilePDFA})
+		if err != nil {
+			t.Fatal(err)
+		}
+		problems, err = Verify(data, ProfilePDFA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(problems) != 1 || !containsProblem(problems, "fonts are not embedded") {
+			t.Errorf("xref stream %v: processed: problems = %v, want only the unembedded font", xrefStream, problems)
+		}
+
+		problems, err = Verify(original, ProfileTagged)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"not marked as tagged", "language", "title"} {
+			if !containsProblem(problems, want) {
+				t.Errorf("xref stream %v: tagged: no problem mentioning %q in %v", xrefStream, want, problems)
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data string
+	}{
+		{"not a PDF", "hello"},
+		{"no startxref", "%PDF-1.7\n1 0 obj\n<< >>\nendobj\n"},
+		{"bad offset", "%PDF-1.7\nstartxref\n99999\n%%EOF\n"},
+		{"encrypted", "%PDF-1.7\nxref\n0 1\n0000000000 65535 f \ntrailer\n<< /Size 1 /Root 1 0 R /Encrypt 2 0 R >>\nstartxref\n9\n%%EOF\n"},
+	} {
+		if _, err := Process([]byte(tt.data), Options{}); err == nil {
+			t.Errorf("%s: Process succeeded", tt.name)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dict := []byte("<< /Type /Page /Parent 2 0 R /Rect [0 0 (a]b) 1] /Nested << /A (x>>y) >> /Name (te\\)xt) /Last 7 >>")
+	for key, want := range map[string]string{
+		"Type":    "/Page",
+		"Parent":  "2 0 R",
+		"Rect":    "[0 0 (a]b) 1]",
+		"Nested":  "<< /A (x>>y) >>",
+		"Name":    `(te\)xt)`,
+		"Last":    "7",
+		"Missing": "",
+	} {
+		if got := string(lookup(dict, key)); got != want {
+			t.Errorf("lookup(%s) = %q, want %q", key, got, want)
+		}
+	}
+
+	removed := removeKeys(dict, "Parent", "Nested")
+	if lookup(removed, "Parent") != nil || lookup(removed, "Nested") != nil || string(lookup(removed, "Last")) != "7" {
+		t.Errorf("removeKeys = %s", removed)
+	}
+}
+
+func containsProblem(problems []string, s string) bool {
+	for _, p := range problems {
+		if strings.Contains(p, s) {
+			return true
+		}
+	}
+	return false
+}
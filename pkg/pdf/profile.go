@@ -0,0 +1,192 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PDF profiles
+const (
+	ProfileStandard = "standard" // Document information only
+	ProfileTagged   = "tagged"   // Marked as tagged, with language and title display for accessibility
+	ProfilePDFA     = "pdfa"     // PDF/A-2b: XMP metadata, sRGB output intent and printable annotations
+)
+
+// Profiles lists the supported profiles
+var Profiles = []string{ProfileStandard, ProfileTagged, ProfilePDFA}
+
+var annotFlagsRegex = regexp.MustCompile(`^\d+$`)
+
+// Options controls how Process updates a PDF
+type Options struct {
+	Info    Info   // Document information, replacing the producer's
+	Profile string // standard (default), tagged or pdfa
+	Lang    string // Document language such as "en-US", set when the PDF has none
+}
+
+// ValidateProfile checks a profile name
+func ValidateProfile(profile string) error {
+	for _, p := range Profiles {
+		if profile == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid pdf_profile: %s (must be one of %s)", profile, strings.Join(Profiles, ", "))
+}
+
+// Process sets the document information of a PDF and prepares it for a
+// profile. The original bytes are kept and the changes are added as an
+// incremental update, so the structure written by the producer, such as
+// the outline and tags, is left as it is. Process does not embed fonts or
+// add tags: it cannot fix everything a profile requires that is missing
+// from the producer's output, and Verify reports those.
+func Process(data []byte, opts Options) ([]byte, error) {
+	if opts.Profile == "" {
+		opts.Profile = ProfileStandard
+	}
+	if err := ValidateProfile(opts.Profile); err != nil {
+		return nil, err
+	}
+
+	f, err := parseFile(data)
+	if err != nil {
+		return nil, err
+	}
+	u := newUpdate(f.trailer)
+	u.info = u.add([]byte(opts.Info.dict()))
+	if opts.Profile == ProfileStandard {
+		return u.write(data), nil
+	}
+
+	catalogNum, catalog, err := f.catalog()
+	if err != nil {
+		return nil, err
+	}
+	catalog = f.prepareTagged(catalog, opts.Lang)
+
+	if opts.Profile == ProfilePDFA {
+		xmp := xmpMetadata(opts.Info)
+		metadataNum := u.add([]byte(fmt.Sprintf("<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream", len(xmp), xmp)))
+		icc := sRGBProfile()
+		iccNum := u.add(append([]byte(fmt.Sprintf("<< /N 3 /Length %d >>\nstream\n", len(icc))), append(icc, "\nendstream"...)...))
+
+		catalog = removeKeys(catalog, "Metadata", "OutputIntents")
+		catalog = addEntries(catalog, fmt.Sprintf("/Metadata %d 0 R /OutputIntents [<< /Type /OutputIntent /S /GTS_PDFA1 /OutputConditionIdentifier %s /Info %s /DestOutputProfile %d 0 R >>]",
+			metadataNum, textString(sRGBDescription), textString(sRGBDescription), iccNum))
+
+		// Annotations must be printable
+		for num, body := range f.objects {
+			dict := dictOf(body)
+			if isAnnotation(dict) && !printable(dict) {
+				u.replace(num, append(addEntries(removeKeys(dict, "F"), "/F 4"), body[len(dict)+bytes.Index(body, dict):]...))
+			}
+		}
+
+		if f.trailer.id == "" {
+			sum := md5.Sum(data)
+			u.id = fmt.Sprintf("[<%X> <%X>]", sum, sum)
+		}
+	}
+
+	u.replace(catalogNum, catalog)
+	return u.write(data), nil
+}
+
+// prepareTagged updates a catalog for accessibility: the PDF is marked as
+// tagged when it has a structure tree, the language is set if missing
+// and viewers are asked to show the title rather than the file name
+func (f *file) prepareTagged(catalog []byte, lang string) []byte {
+	var entries []string
+	if lookup(catalog, "StructTreeRoot") != nil {
+		catalog = removeKeys(catalog, "MarkInfo")
+		entries = append(entries, "/MarkInfo << /Marked true >>")
+	}
+	if lookup(catalog, "Lang") == nil && lang != "" {
+		entries = append(entries, "/Lang "+textString(lang))
+	}
+
+	prefs := f.value(catalog, "ViewerPreferences")
+	if !bytes.HasPrefix(prefs, []byte("<<")) {
+		prefs = []byte("<< >>")
+	}
+	prefs = addEntries(removeKeys(prefs, "DisplayDocTitle"), "/DisplayDocTitle true")
+	catalog = removeKeys(catalog, "ViewerPreferences")
+	entries = append(entries, "/ViewerPreferences "+string(prefs))
+
+	return addEntries(catalog, strings.Join(entries, " "))
+}
+
+// isAnnotation reports whether a dictionary is an annotation other than a
+// popup, which PDF/A exempts from the print flag
+func isAnnotation(dict []byte) bool {
+	if dict == nil || lookup(dict, "Rect") == nil {
+		return false
+	}
+	subtype := string(lookup(dict, "Subtype"))
+	if subtype == "/Popup" {
+		return false
+	}
+	return string(lookup(dict, "Type")) == "/Annot" || subtype == "/Link" || subtype == "/Widget"
+}
+
+// printable reports whether an annotation's flags have Print set and
+// Invisible, Hidden and NoView clear
+func printable(dict []byte) bool {
+	value := string(lookup(dict, "F"))
+	if !annotFlagsRegex.MatchString(value) {
+		return false
+	}
+	flags, _ := strconv.Atoi(value)
+	return flags&4 != 0 && flags&(1|2|32) == 0
+}
+
+// xmpMetadata returns the XMP packet for a PDF/A-2b file, mirroring the
+// document information
+func xmpMetadata(info Info) string {
+	esc := func(s string) string {
+		var b bytes.Buffer
+		_ = xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	date := func(t time.Time) string {
+		return t.UTC().Format("2006-01-02T15:04:05Z")
+	}
+
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:pdf="http://ns.adobe.com/pdf/1.3/">` + "\n")
+	b.WriteString("<pdfaid:part>2</pdfaid:part>\n<pdfaid:conformance>B</pdfaid:conformance>\n")
+	b.WriteString("<dc:format>application/pdf</dc:format>\n")
+	if info.Title != "" {
+		fmt.Fprintf(&b, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", esc(info.Title))
+	}
+	if info.Author != "" {
+		fmt.Fprintf(&b, "<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", esc(info.Author))
+	}
+	if info.Subject != "" {
+		fmt.Fprintf(&b, "<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", esc(info.Subject))
+	}
+	if len(info.Keywords) > 0 {
+		fmt.Fprintf(&b, "<pdf:Keywords>%s</pdf:Keywords>\n", esc(strings.Join(info.Keywords, ", ")))
+	}
+	if info.Creator != "" {
+		fmt.Fprintf(&b, "<xmp:CreatorTool>%s</xmp:CreatorTool>\n", esc(info.Creator))
+	}
+	if !info.CreationDate.IsZero() {
+		fmt.Fprintf(&b, "<xmp:CreateDate>%s</xmp:CreateDate>\n", date(info.CreationDate))
+	}
+	if !info.ModDate.IsZero() {
+		fmt.Fprintf(&b, "<xmp:ModifyDate>%s</xmp:ModifyDate>\n", date(info.ModDate))
+	}
+	b.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString(`<?xpacket end="w"?>`)
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var (
+	startXrefRegex = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	rootRegex      = regexp.MustCompile(`/Root\s+(\d+\s+\d+\s+R)`)
+	infoRefRegex   = regexp.MustCompile(`/Info\s+(\d+\s+\d+\s+R)`)
+	sizeRegex      = regexp.MustCompile(`/Size\s+(\d+)`)
+	idRegex        = regexp.MustCompile(`/ID\s*(\[[^\]]*\])`)
+	encryptRegex   = regexp.MustCompile(`/Encrypt\s`)
+	xrefObjRegex   = regexp.MustCompile(`^\d+\s+\d+\s+obj\s*<<`)
+)
+
+// trailer holds the entries of the last trailer that an update carries over
+type trailer struct {
+	offset     int    // Offset of the last cross-reference section
+	dict       []byte // The trailer dictionary, or the cross-reference stream's
+	root       string // Reference to the document catalog, e.g. "1 0 R"
+	size       int    // Number of objects
+	id         string // File identifier array, if any
+	xrefStream bool   // Whether the cross-reference section is a stream
+}
+
+// trailerDict returns the dictionary of the last trailer
+func (f *file) trailerDict() []byte {
+	return f.trailer.dict
+}
+
+// readTrailer finds the last cross-reference section and reads the
+// trailer entries needed for an update
+func readTrailer(data []byte) (*trailer, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+
+	tail := data
+	if len(tail) > 1024 {
+		tail = tail[len(tail)-1024:]
+	}
+	m := startXrefRegex.FindSubmatch(tail)
+	if m == nil {
+		return nil, fmt.Errorf("PDF has no startxref")
+	}
+	offset, err := strconv.Atoi(string(m[1]))
+	if err != nil || offset >= len(data) {
+		return nil, fmt.Errorf("PDF has an invalid startxref")
+	}
+
+	t := &trailer{offset: offset}
+	section := data[offset:]
+	switch {
+	case bytes.HasPrefix(section, []byte("xref")):
+		i := bytes.Index(section, []byte("trailer"))
+		if i == -1 {
+			return nil, fmt.Errorf("PDF has no trailer")
+		}
+		t.dict = dictOf(section[i+len("trailer"):])
+	case xrefObjRegex.Match(section):
+		t.xrefStream = true
+		t.dict = dictOf(section[bytes.Index(section, []byte("obj"))+3:])
+	}
+	if t.dict == nil {
+		return nil, fmt.Errorf("PDF cross-reference section not found at offset %d", offset)
+	}
+
+	if encryptRegex.Match(t.dict) {
+		return nil, fmt.Errorf("encrypted PDFs are not supported")
+	}
+	root := rootRegex.FindSubmatch(t.dict)
+	size := sizeRegex.FindSubmatch(t.dict)
+	if root == nil || size == nil {
+		return nil, fmt.Errorf("PDF trailer has no /Root or /Size")
+	}
+	t.root = string(root[1])
+	t.size, _ = strconv.Atoi(string(size[1]))
+	if id := idRegex.FindSubmatch(t.dict); id != nil {
+		t.id = string(id[1])
+	}
+	return t, nil
+}
+
+// update collects the objects of an incremental update
+type update struct {
+	t       *trailer
+	objects map[int][]byte // Object number to body
+	next    int            // Next free object number
+	info    int            // Object number of the new info dictionary, if any
+	id      string         // File identifier to set when the file has none
+}
+
+func newUpdate(t *trailer) *update {
+	return &update{t: t, objects: make(map[int][]byte), next: t.size}
+}
+
+// add adds a new object and returns its number
+func (u *update) add(body []byte) int {
+	num := u.next
+	u.next++
+	u.objects[num] = body
+	return num
+}
+
+// replace gives an existing object a new body
+func (u *update) replace(num int, body []byte) {
+	u.objects[num] = body
+}
+
+// write appends the update to the original bytes: the objects, then a
+// cross-reference section of the same kind as the file's last one
+func (u *update) write(data []byte) []byte {
+	var out bytes.Buffer
+	out.Write(data)
+	if data[len(data)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(u.objects)+1)
+	for num := range u.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	offsets := make(map[int]int)
+	for _, num := range nums {
+		offsets[num] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", num)
+		out.Write(u.objects[num])
+		out.WriteString("\nendobj\n")
+	}
+
+	entries := fmt.Sprintf("/Root %s /Prev %d", u.t.root, u.t.offset)
+	if u.info != 0 {
+		entries += fmt.Sprintf(" /Info %d 0 R", u.info)
+	} else if m := infoRefRegex.FindSubmatch(u.t.dict); m != nil {
+		entries += " /Info " + string(m[1])
+	}
+	if u.t.id != "" {
+		entries += " /ID " + u.t.id
+	} else if u.id != "" {
+		entries += " /ID " + u.id
+	}
+
+	xrefOffset := out.Len()
+	if u.t.xrefStream {
+		// A file using cross-reference streams is updated with one, which
+		// also lists itself
+		xrefNum := u.next
+		offsets[xrefNum] = xrefOffset
+		nums = append(nums, xrefNum)
+		var rows bytes.Buffer
+		var index bytes.Buffer
+		for _, num := range nums {
+			rows.WriteByte(1)
+			_ = binary.Write(&rows, binary.BigEndian, uint32(offsets[num]))
+			_ = binary.Write(&rows, binary.BigEndian, uint16(0))
+			fmt.Fprintf(&index, " %d 1", num)
+		}
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Type /XRef /Size %d /Index [%s ] /W [1 4 2] %s /Length %d >>\nstream\n",
+			xrefNum, xrefNum+1, index.String(), entries, rows.Len())
+		out.Write(rows.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	} else {
+		out.WriteString("xref\n")
+		for _, num := range nums {
+			fmt.Fprintf(&out, "%d 1\n%010d 00000 n \n", num, offsets[num])
+		}
+		fmt.Fprintf(&out, "trailer\n<< /Size %d %s >>\n", u.next, entries)
+	}
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+	return out.Bytes()
+}
@@ -0,0 +1,188 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	pdfaPartRegex        = regexp.MustCompile(`pdfaid:part(?:>|="|=')\s*(\d)`)
+	pdfaConformanceRegex = regexp.MustCompile(`pdfaid:conformance(?:>|="|=')\s*([ABUabu])`)
+	javaScriptRegex      = regexp.MustCompile(`/(JavaScript|JS)\b`)
+	launchRegex          = regexp.MustCompile(`/S\s*/Launch\b`)
+	lzwRegex             = regexp.MustCompile(`/LZWDecode\b`)
+)
+
+// Verify checks a PDF against a profile and returns the problems found,
+// which are empty for a conforming file. The checks cover what this
+// package and the producers control; they are not a full validator.
+func Verify(data []byte, profile string) ([]string, error) {
+	if profile == "" {
+		profile = ProfileStandard
+	}
+	if err := ValidateProfile(profile); err != nil {
+		return nil, err
+	}
+	f, err := parseFile(data)
+	if err != nil {
+		return nil, err
+	}
+	_, catalog, err := f.catalog()
+	if err != nil {
+		return nil, err
+	}
+
+	// PDF/A-2b, level B conformance, does not require tags
+	switch profile {
+	case ProfileTagged:
+		return f.verifyTagged(catalog), nil
+	case ProfilePDFA:
+		return f.verifyPDFA(catalog), nil
+	}
+	return nil, nil
+}
+
+// verifyTagged checks the structure and metadata needed by assistive
+// technology
+func (f *file) verifyTagged(catalog []byte) []string {
+	var problems []string
+	if lookup(catalog, "StructTreeRoot") == nil {
+		problems = append(problems, "the PDF has no structure tree (it is not tagged)")
+	}
+	if !bytes.Contains(f.value(catalog, "MarkInfo"), []byte("/Marked true")) {
+		problems = append(problems, "the catalog is not marked as tagged (/MarkInfo /Marked true)")
+	}
+	if lang := lookup(catalog, "Lang"); lang == nil || string(lang) == "()" {
+		problems = append(problems, "the document language (/Lang) is not set")
+	}
+	if title := lookup(f.infoDict(), "Title"); title == nil || string(title) == "()" {
+		problems = append(problems, "the document has no title")
+	}
+	if string(lookup(f.value(catalog, "ViewerPreferences"), "DisplayDocTitle")) != "true" {
+		problems = append(problems, "viewers are not asked to display the title (/DisplayDocTitle)")
+	}
+	return problems
+}
+
+// verifyPDFA checks the PDF/A-2b requirements
+func (f *file) verifyPDFA(catalog []byte) []string {
+	var problems []string
+
+	header := f.data
+	if len(header) > 64 {
+		header = header[:64]
+	}
+	if lines := bytes.SplitN(header, []byte("\n"), 3); len(lines) < 2 || !binaryComment(bytes.TrimSuffix(lines[1], []byte("\r"))) {
+		problems = append(problems, "the header is not followed by a binary comment")
+	}
+	if f.trailer.id == "" {
+		problems = append(problems, "the trailer has no file identifier (/ID)")
+	}
+
+	metadata := f.resolve(lookup(catalog, "Metadata"))
+	if metadata == nil {
+		problems = append(problems, "the catalog has no XMP metadata stream")
+	} else if xmp, err := streamData(metadata); err != nil {
+		problems = append(problems, fmt.Sprintf("the XMP metadata cannot be read: %v", err))
+	} else {
+		if lookup(dictOf(metadata), "Filter") != nil {
+			problems = append(problems, "the XMP metadata stream is compressed")
+		}
+		part := pdfaPartRegex.FindSubmatch(xmp)
+		conformance := pdfaConformanceRegex.FindSubmatch(xmp)
+		if part == nil || conformance == nil {
+			problems = append(problems, "the XMP metadata does not identify the PDF/A part and conformance")
+		}
+	}
+
+	intents := f.value(catalog, "OutputIntents")
+	if !bytes.Contains(intents, []byte("/GTS_PDFA1")) || !bytes.Contains(intents, []byte("/DestOutputProfile")) {
+		problems = append(problems, "the catalog has no PDF/A output intent with an ICC profile")
+	}
+
+	nums := make([]int, 0, len(f.objects))
+	for num := range f.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	var fonts []string
+	seen := map[string]bool{}
+	var javaScript, launch, lzw bool
+	var hiddenAnnots int
+	for _, num := range nums {
+		body := f.objects[num]
+		dict := dictOf(body)
+		if dict == nil {
+			continue
+		}
+		if string(lookup(dict, "Type")) == "/Font" {
+			if name := f.unembeddedFont(dict); name != "" && !seen[name] {
+				seen[name] = true
+				fonts = append(fonts, name)
+			}
+		}
+		if isAnnotation(dict) && !printable(dict) {
+			hiddenAnnots++
+		}
+		javaScript = javaScript || javaScriptRegex.Match(dict)
+		launch = launch || launchRegex.Match(dict)
+		lzw = lzw || lzwRegex.Match(dict)
+	}
+
+	if len(fonts) > 0 {
+		problems = append(problems, "fonts are not embedded: "+strings.Join(fonts, ", "))
+	}
+	if hiddenAnnots > 0 {
+		problems = append(problems, fmt.Sprintf("%d annotation(s) are not set to print", hiddenAnnots))
+	}
+	if javaScript {
+		problems = append(problems, "the PDF contains JavaScript")
+	}
+	if launch {
+		problems = append(problems, "the PDF contains launch actions")
+	}
+	if lzw {
+		problems = append(problems, "the PDF uses LZW compression")
+	}
+	return problems
+}
+
+// unembeddedFont returns the name of a font whose program is not embedded,
+// or "" if it is. Composite fonts are checked through their descendant
+// font objects and Type 3 fonts are defined in the PDF itself.
+func (f *file) unembeddedFont(dict []byte) string {
+	subtype := string(lookup(dict, "Subtype"))
+	if subtype == "/Type0" || subtype == "/Type3" {
+		return ""
+	}
+	descriptor := f.value(dict, "FontDescriptor")
+	for _, key := range []string{"FontFile", "FontFile2", "FontFile3"} {
+		if lookup(descriptor, key) != nil {
+			return ""
+		}
+	}
+	name := strings.TrimPrefix(string(lookup(dict, "BaseFont")), "/")
+	if name == "" {
+		name = "unnamed " + strings.TrimPrefix(subtype, "/") + " font"
+	}
+	return name
+}
+
+// binaryComment reports whether a line is a comment of at least four
+// bytes above 127, which marks the file as binary
+func binaryComment(line []byte) bool {
+	if !bytes.HasPrefix(line, []byte("%")) {
+		return false
+	}
+	high := 0
+	for _, c := range line[1:] {
+		if c > 127 {
+			high++
+		}
+	}
+	return high >= 4
+}
@@ -1,12 +1,24 @@
 package storage
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"simple_html_docgen/pkg/document"
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+const (
+	// mediaTempPrefix marks partially written media files
+	mediaTempPrefix = ".upload-"
+	// maxMediaStemLength is the maximum length of a media file name without extension
+	maxMediaStemLength = 60
 )
 
 // Storage handles file operations for HTML documents
@@ -93,13 +105,15 @@ func (s *Storage) UpdateDocument(doc *document.Document) error {
 		return fmt.Errorf("failed to write HTML file: %w", err)
 	}
 
-	// Update metadata
-	metadata := document.Metadata{
-		Name:      doc.Name,
-		CreatedAt: doc.CreatedAt,
-		UpdatedAt: doc.UpdatedAt,
+	// Update metadata, preserving fields the document does not carry
+	metadata, err := s.ReadMetadata(doc.ID)
+	if err != nil {
+		metadata = &document.Metadata{}
 	}
-	if err := s.WriteMetadata(doc.ID, &metadata); err != nil {
+	metadata.Name = doc.Name
+	metadata.CreatedAt = doc.CreatedAt
+	metadata.UpdatedAt = doc.UpdatedAt
+	if err := s.WriteMetadata(doc.ID, metadata); err != nil {
 		return fmt.Errorf("failed to write metadata: %w", err)
 	}
 
@@ -134,7 +148,8 @@ func (s *Storage) GetDocument(documentID string) (*document.Document, error) {
 	}, nil
 }
 
-// WriteMetadata writes metadata to disk
+// WriteMetadata writes metadata to disk. The file is replaced in one step
+// so a concurrent ReadMetadata never sees it half written.
 func (s *Storage) WriteMetadata(documentID string, metadata *document.Metadata) error {
 	metadataPath := s.GetMetadataPath(documentID)
 	data, err := json.MarshalIndent(metadata, "", "  ")
@@ -142,7 +157,12 @@ func (s *Storage) WriteMetadata(documentID string, metadata *document.Metadata)
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+	tmpPath := metadataPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+	if err := os.Rename(tmpPath, metadataPath); err != nil {
+		os.Remove(tmpPath)
 		return fmt.Errorf("failed to write metadata file: %w", err)
 	}
 
@@ -201,13 +221,11 @@ func (s *Storage) ListDocuments() ([]*document.DocumentInfo, error) {
 	return docs, nil
 }
 
-// CopyMediaFile copies a media file to the document's media directory
+// CopyMediaFile copies a media file to the document's media directory.
+// If replace names an existing media file, that file is overwritten;
+// otherwise the name is made collision-safe (see storeMedia).
 // Returns the relative path to the media file
-func (s *Storage) CopyMediaFile(documentID, sourcePath string) (string, error) {
-	if !s.DocumentExists(documentID) {
-		return "", fmt.Errorf("document %s does not exist", documentID)
-	}
-
+func (s *Storage) CopyMediaFile(documentID, sourcePath, replace string) (string, error) {
 	// Open source file
 	srcFile, err := os.Open(sourcePath)
 	if err != nil {
@@ -215,28 +233,198 @@ func (s *Storage) CopyMediaFile(documentID, sourcePath string) (string, error) {
 	}
 	defer srcFile.Close()
 
-	// Get filename
-	filename := filepath.Base(sourcePath)
+	return s.storeMedia(documentID, filepath.Base(sourcePath), srcFile, replace)
+}
+
+// WriteMediaFile writes raw bytes to the document's media directory using
+// the same naming rules as CopyMediaFile
+// Returns the relative path to the media file
+func (s *Storage) WriteMediaFile(documentID, filename string, data []byte, replace string) (string, error) {
+	return s.storeMedia(documentID, filename, bytes.NewReader(data), replace)
+}
+
+// storeMedia writes media content into the document's media directory.
+// Content identical to an existing media file is not stored twice; the
+// existing path is returned instead. A different file with the same name
+// gets a numeric suffix, so earlier references never break.
+func (s *Storage) storeMedia(documentID, filename string, src io.Reader, replace string) (string, error) {
+	if !s.DocumentExists(documentID) {
+		return "", fmt.Errorf("document %s does not exist", documentID)
+	}
 
-	// Create destination path
+	// Ensure media directory exists
 	mediaDir := s.GetMediaDir(documentID)
-	destPath := filepath.Join(mediaDir, filename)
+	if err := os.MkdirAll(mediaDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create media directory: %w", err)
+	}
+
+	var replacePath string
+	if replace != "" {
+		name := filepath.Base(filepath.FromSlash(replace))
+		replacePath = filepath.Join(mediaDir, name)
+		if info, err := os.Stat(replacePath); err != nil || !info.Mode().IsRegular() {
+			return "", fmt.Errorf("media file to replace does not exist: %s", replace)
+		}
+	}
 
-	// Create destination file
-	destFile, err := os.Create(destPath)
+	// Stream into a temp file while hashing, so large videos are never held in memory
+	tmpFile, err := os.CreateTemp(mediaDir, mediaTempPrefix+"*")
 	if err != nil {
 		return "", fmt.Errorf("failed to create destination file: %w", err)
 	}
-	defer destFile.Close()
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
 
-	// Copy file
-	if _, err := io.Copy(destFile, srcFile); err != nil {
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hasher), src)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
 		return "", fmt.Errorf("failed to copy file: %w", err)
 	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return "", fmt.Errorf("failed to set media file permissions: %w", err)
+	}
+
+	if replacePath != "" {
+		if err := os.Rename(tmpPath, replacePath); err != nil {
+			return "", fmt.Errorf("failed to replace media file: %w", err)
+		}
+		return filepath.Join("media", filepath.Base(replacePath)), nil
+	}
+
+	// Reuse an existing file with identical content
+	existing, err := findMediaByHash(mediaDir, size, hasher.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+	if existing != "" {
+		return filepath.Join("media", existing), nil
+	}
+
+	name, err := uniqueMediaName(mediaDir, SanitizeMediaFilename(filename))
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(mediaDir, name)); err != nil {
+		return "", fmt.Errorf("failed to store media file: %w", err)
+	}
 
 	// Return relative path from document root
-	relativePath := filepath.Join("media", filename)
-	return relativePath, nil
+	return filepath.Join("media", name), nil
+}
+
+// SanitizeMediaFilename turns an arbitrary file name into a safe media file
+// name: no directory components, no leading dots, and only lowercase ASCII
+// letters, digits and hyphens in the stem
+func SanitizeMediaFilename(filename string) string {
+	filename = filepath.Base(filepath.FromSlash(strings.ReplaceAll(filename, "\\", "/")))
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	stem := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+	// Extensions are kept only when they are plain alphanumerics
+	for _, r := range strings.TrimPrefix(ext, ".") {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			ext = ""
+			break
+		}
+	}
+	if len(ext) < 2 || len(ext) > 10 {
+		ext = ""
+	}
+
+	stem = slug.Make(stem)
+	if len(stem) > maxMediaStemLength {
+		stem = strings.TrimRight(stem[:maxMediaStemLength], "-")
+	}
+	if stem == "" {
+		stem = "media"
+	}
+
+	return stem + ext
+}
+
+// uniqueMediaName returns name, or name with a numeric suffix if a file with
+// that name already exists in the media directory
+func uniqueMediaName(mediaDir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	candidate := name
+	for i := 2; ; i++ {
+		_, err := os.Lstat(filepath.Join(mediaDir, candidate))
+		if os.IsNotExist(err) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check media file: %w", err)
+		}
+		candidate = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+}
+
+// findMediaByHash returns the name of a media file whose content has the
+// given size and SHA-256 hash, or "" if there is none
+func findMediaByHash(mediaDir string, size int64, sum []byte) (string, error) {
+	entries, err := os.ReadDir(mediaDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read media directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), mediaTempPrefix) {
+			continue
+		}
+
+		// Comparing sizes first avoids hashing almost every file
+		info, err := entry.Info()
+		if err != nil || info.Size() != size {
+			continue
+		}
+
+		existingSum, err := hashFile(filepath.Join(mediaDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(existingSum, sum) {
+			return entry.Name(), nil
+		}
+	}
+
+	return "", nil
+}
+
+// hashFile returns the SHA-256 hash of a file's content
+func hashFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+// ReadMediaFile reads a media file given its path relative to the document root
+func (s *Storage) ReadMediaFile(documentID, relativePath string) ([]byte, error) {
+	if !s.DocumentExists(documentID) {
+		return nil, fmt.Errorf("document %s does not exist", documentID)
+	}
+
+	// Only files directly inside the media directory can be read
+	name := filepath.Base(filepath.FromSlash(relativePath))
+	data, err := os.ReadFile(filepath.Join(s.GetMediaDir(documentID), name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media file: %w", err)
+	}
+
+	return data, nil
 }
 
 // DeleteDocument deletes a document and all its files
@@ -0,0 +1,174 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"simple_html_docgen/pkg/document"
+	"simple_html_docgen/pkg/storage"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDocument returns a storage in a temporary folder holding one document
+func newTestDocument(t *testing.T) (*storage.Storage, string) {
+	t.Helper()
+	s := storage.NewStorage(t.TempDir())
+	now := time.Now().UTC().Truncate(time.Second)
+	doc := &document.Document{ID: "doc", Name: "Report", HTMLContent: "<p>Hello</p>", CreatedAt: now, UpdatedAt: now}
+	if err := s.CreateDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	return s, doc.ID
+}
+
+func TestSanitizeMediaFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"photo.png", "photo.png"},
+		{"My Holiday Photo.JPG", "my-holiday-photo.jpg"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\logo.svg`, "logo.svg"},
+		{".hidden.png", "hidden.png"},
+		{".png", "media.png"},
+		{"archive.tar.gz", "archive-tar.gz"},
+		{"odd.p$g", "odd"},
+		{"file.verylongextension", "file"},
+		{"trailing.", "trailing"},
+		{"", "media"},
+		{strings.Repeat("a", 80) + ".png", strings.Repeat("a", 60) + ".png"},
+	}
+	for _, tt := range tests {
+		if got := storage.SanitizeMediaFilename(tt.filename); got != tt.want {
+			t.Errorf("SanitizeMediaFilename(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestWriteMediaFile(t *testing.T) {
+	s, id := newTestDocument(t)
+
+	first, err := s.WriteMediaFile(id, "Logo.PNG", []byte("first"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != filepath.Join("media", "logo.png") {
+		t.Fatalf("first file stored as %s", first)
+	}
+
+	// Identical content is stored once, whatever its name
+	same, err := s.WriteMediaFile(id, "other.png", []byte("first"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same != first {
+		t.Errorf("identical content stored as %s, want %s", same, first)
+	}
+
+	// Different content with a taken name gets a numeric suffix
+	second, err := s.WriteMediaFile(id, "logo.png", []byte("second"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := s.WriteMediaFile(id, "logo.png", []byte("third"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != filepath.Join("media", "logo-2.png") || third != filepath.Join("media", "logo-3.png") {
+		t.Errorf("colliding names stored as %s and %s, want logo-2.png and logo-3.png", second, third)
+	}
+
+	// Replace overwrites the named file in place
+	replaced, err := s.WriteMediaFile(id, "anything.png", []byte("replaced"), "media/logo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced != first {
+		t.Errorf("replace wrote %s, want %s", replaced, first)
+	}
+	data, err := s.ReadMediaFile(id, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "replaced" {
+		t.Errorf("replaced file holds %q", data)
+	}
+	if _, err := s.WriteMediaFile(id, "x.png", []byte("x"), "media/missing.png"); err == nil {
+		t.Error("replacing a missing file succeeded")
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(s.GetMediaDir(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("media directory holds %v, want 3 files", names)
+	}
+
+	if _, err := s.WriteMediaFile("missing", "a.png", []byte("a"), ""); err == nil {
+		t.Error("wrote media into a document that does not exist")
+	}
+}
+
+func TestReadMediaFileStaysInMediaDir(t *testing.T) {
+	s, id := newTestDocument(t)
+	if _, err := s.ReadMediaFile(id, "media/../index.html"); err == nil {
+		t.Error("read a file outside the media directory")
+	}
+}
+
+func TestMetadataRoundTrip(t *testing.T) {
+	s, id := newTestDocument(t)
+
+	metadata, err := s.ReadMetadata(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Name != "Report" {
+		t.Fatalf("name = %q, want Report", metadata.Name)
+	}
+
+	metadata.Diagrams = map[string]string{"key": "media/diagram.svg"}
+	metadata.Properties = &document.Properties{Author: "Ada", Status: "draft"}
+	if err := s.WriteMetadata(id, metadata); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.GetMetadataPath(id) + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary metadata file left behind")
+	}
+
+	got, err := s.ReadMetadata(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Diagrams["key"] != "media/diagram.svg" || got.Properties == nil || got.Properties.Author != "Ada" {
+		t.Errorf("metadata not preserved: %+v", got)
+	}
+	if !got.CreatedAt.Equal(metadata.CreatedAt) {
+		t.Errorf("created_at = %v, want %v", got.CreatedAt, metadata.CreatedAt)
+	}
+
+	// Updating the document keeps fields it does not carry
+	doc, err := s.GetDocument(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.HTMLContent = "<p>Changed</p>"
+	if err := s.UpdateDocument(doc); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.ReadMetadata(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Diagrams["key"] == "" || got.Properties == nil {
+		t.Error("UpdateDocument dropped metadata fields")
+	}
+}
@@ -0,0 +1,410 @@
+package table
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// numberRegex matches decimal numbers, with commas only between groups of
+// three digits, so "1,234" is a number and "1,5" is not
+var numberRegex = regexp.MustCompile(`^[+-]?(?:\d{1,3}(?:,\d{3})+(?:\.\d*)?|\d+(?:\.\d*)?|\.\d+)(?:[eE][+-]?\d+)?$`)
+
+// MaxRows is the largest number of data rows a table may contain
+const MaxRows = 10000
+
+// StyleID identifies the shared table stylesheet injected into documents
+const StyleID = "data-table-styles"
+
+// Stylesheet styles tables produced by Render. It is print friendly and
+// keeps header rows repeating across PDF pages.
+const Stylesheet = `<style id="` + StyleID + `">
+table.data-table { border-collapse: collapse; width: 100%; margin: 1em 0; font-size: 0.95em; }
+table.data-table caption { caption-side: top; text-align: left; font-weight: bold; padding: 0.4em 0; }
+table.data-table th, table.data-table td { border: 1px solid #d0d7de; padding: 0.4em 0.6em; vertical-align: top; }
+table.data-table thead th { background: #f3f5f7; font-weight: 600; }
+table.data-table tbody tr:nth-child(even) { background: #fafbfc; }
+table.data-table .num { text-align: right; font-variant-numeric: tabular-nums; white-space: nowrap; }
+table.data-table .center { text-align: center; }
+table.data-table .right { text-align: right; }
+table.data-table .left { text-align: left; }
+@media print {
+  table.data-table thead { display: table-header-group; }
+  table.data-table tr { page-break-inside: avoid; }
+}
+</style>`
+
+// Table is tabular data with a header row
+type Table struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// Options controls how a table is rendered
+type Options struct {
+	Caption            string   // Optional <caption> text
+	Align              []string // Per-column "left", "center" or "right"; numeric columns default to right
+	Decimals           int      // Fixed decimals for numeric cells; -1 keeps values as given
+	ThousandsSeparator bool     // Group digits of numeric cells with commas
+}
+
+// LoadFile reads a CSV, TSV or JSON file. The format is taken from the
+// extension unless given explicitly.
+func LoadFile(path, format string, hasHeader bool) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			format = "csv"
+		case ".tsv", ".tab":
+			format = "tsv"
+		case ".json":
+			format = "json"
+		default:
+			return nil, fmt.Errorf("cannot infer data format from %s (use format: csv, tsv or json)", filepath.Base(path))
+		}
+	}
+
+	return Parse(data, format, hasHeader)
+}
+
+// Parse reads CSV, TSV or JSON data. JSON may be an array of objects (keys
+// become columns, in order of first appearance) or an array of arrays.
+func Parse(data []byte, format string, hasHeader bool) (*Table, error) {
+	var rows [][]string
+	var err error
+
+	switch format {
+	case "csv", "tsv":
+		rows, err = parseDelimited(data, format == "tsv")
+	case "json":
+		var t *Table
+		t, err = parseJSON(data, hasHeader)
+		if err == nil {
+			return t, validateSize(t)
+		}
+	default:
+		return nil, fmt.Errorf("invalid data format: %s (must be csv, tsv or json)", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := FromRows(rows, hasHeader)
+	return t, validateSize(t)
+}
+
+// FromRows builds a table from string rows, taking the first row as the
+// header if hasHeader is set or generating "Column N" headers otherwise.
+// Short rows are padded so every row has one cell per column.
+func FromRows(rows [][]string, hasHeader bool) *Table {
+	t := &Table{}
+	if hasHeader && len(rows) > 0 {
+		t.Columns = append([]string(nil), rows[0]...)
+		rows = rows[1:]
+	}
+
+	width := len(t.Columns)
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	for i := len(t.Columns); i < width; i++ {
+		t.Columns = append(t.Columns, fmt.Sprintf("Column %d", i+1))
+	}
+
+	for _, row := range rows {
+		padded := make([]string, width)
+		copy(padded, row)
+		t.Rows = append(t.Rows, padded)
+	}
+	return t
+}
+
+// validateSize rejects empty and oversized tables
+func validateSize(t *Table) error {
+	if len(t.Columns) == 0 {
+		return fmt.Errorf("table has no columns")
+	}
+	if len(t.Rows) > MaxRows {
+		return fmt.Errorf("table has %d rows, more than the maximum of %d", len(t.Rows), MaxRows)
+	}
+	return nil
+}
+
+// parseDelimited reads CSV or TSV, tolerating ragged rows and a UTF-8 BOM
+func parseDelimited(data []byte, tabs bool) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if tabs {
+		reader.Comma = '\t'
+	}
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+	return rows, nil
+}
+
+// parseJSON reads an array of objects or an array of arrays, preserving
+// object key order so columns appear as written
+func parseJSON(data []byte, hasHeader bool) (*Table, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("JSON data must be an array of objects or arrays")
+	}
+
+	var columns []string
+	index := make(map[string]int)
+	var objects []map[string]string
+	var arrays [][]string
+
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JSON data: %w", err)
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			row := make(map[string]string)
+			for decoder.More() {
+				keyTok, err := decoder.Token()
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse JSON data: %w", err)
+				}
+				key := keyTok.(string)
+				var value interface{}
+				if err := decoder.Decode(&value); err != nil {
+					return nil, fmt.Errorf("failed to parse JSON data: %w", err)
+				}
+				if _, seen := index[key]; !seen {
+					index[key] = len(columns)
+					columns = append(columns, key)
+				}
+				row[key] = cellString(value)
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, fmt.Errorf("failed to parse JSON data: %w", err)
+			}
+			objects = append(objects, row)
+		case json.Delim('['):
+			var row []string
+			for decoder.More() {
+				var value interface{}
+				if err := decoder.Decode(&value); err != nil {
+					return nil, fmt.Errorf("failed to parse JSON data: %w", err)
+				}
+				row = append(row, cellString(value))
+			}
+			if _, err := decoder.Token(); err != nil {
+				return nil, fmt.Errorf("failed to parse JSON data: %w", err)
+			}
+			arrays = append(arrays, row)
+		default:
+			return nil, fmt.Errorf("JSON data must be an array of objects or arrays")
+		}
+	}
+
+	if len(objects) > 0 && len(arrays) > 0 {
+		return nil, fmt.Errorf("JSON data mixes objects and arrays")
+	}
+	if len(arrays) > 0 {
+		return FromRows(arrays, hasHeader), nil
+	}
+
+	t := &Table{Columns: columns}
+	for _, object := range objects {
+		row := make([]string, len(columns))
+		for key, value := range object {
+			row[index[key]] = value
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
+
+// FromValues builds a table from inline tool data: an array of objects or
+// an array of arrays, as decoded from JSON arguments
+func FromValues(values []interface{}, hasHeader bool) (*Table, error) {
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid table data: %w", err)
+	}
+	t, err := parseJSON(raw, hasHeader)
+	if err != nil {
+		return nil, err
+	}
+	return t, validateSize(t)
+}
+
+// cellString converts a decoded JSON value to cell text
+func cellString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// Render produces an accessible HTML table with a caption, a <thead> of
+// column headers and a <tbody>. Numeric columns are detected, formatted
+// and right-aligned. Styling comes from Stylesheet via the data-table class.
+func Render(t *Table, opts Options) string {
+	numeric := make([]bool, len(t.Columns))
+	for c := range t.Columns {
+		numeric[c] = isNumericColumn(t, c)
+	}
+
+	classes := make([]string, len(t.Columns))
+	for c := range t.Columns {
+		align := ""
+		if c < len(opts.Align) {
+			align = strings.ToLower(opts.Align[c])
+		}
+		switch {
+		case align == "left" || align == "center" || align == "right":
+			classes[c] = align
+			if numeric[c] {
+				classes[c] = "num " + align
+			}
+		case numeric[c]:
+			classes[c] = "num"
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`<table class="data-table">` + "\n")
+	if opts.Caption != "" {
+		fmt.Fprintf(&b, "<caption>%s</caption>\n", html.EscapeString(opts.Caption))
+	}
+
+	b.WriteString("<thead>\n<tr>")
+	for c, column := range t.Columns {
+		fmt.Fprintf(&b, `<th scope="col"%s>%s</th>`, classAttr(classes[c]), html.EscapeString(column))
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+
+	for _, row := range t.Rows {
+		b.WriteString("<tr>")
+		for c, cell := range row {
+			if numeric[c] {
+				cell = formatNumber(cell, opts.Decimals, opts.ThousandsSeparator)
+			}
+			fmt.Fprintf(&b, "<td%s>%s</td>", classAttr(classes[c]), html.EscapeString(cell))
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>")
+
+	return b.String()
+}
+
+// classAttr renders a class attribute, or nothing for an empty class
+func classAttr(class string) string {
+	if class == "" {
+		return ""
+	}
+	return ` class="` + class + `"`
+}
+
+// isNumericColumn reports whether every non-empty cell in a column is a number
+func isNumericColumn(t *Table, column int) bool {
+	seen := false
+	for _, row := range t.Rows {
+		cell := strings.TrimSpace(row[column])
+		if cell == "" {
+			continue
+		}
+		if _, ok := parseNumber(cell); !ok {
+			return false
+		}
+		seen = true
+	}
+	return seen
+}
+
+// parseNumber parses a decimal number that may group its digits with
+// thousands separators. NaN, infinities and hex floats are not numbers.
+func parseNumber(cell string) (float64, bool) {
+	cell = strings.TrimSpace(cell)
+	if !numberRegex.MatchString(cell) {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.ReplaceAll(cell, ",", ""), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// formatNumber reformats a numeric cell with fixed decimals and digit
+// grouping. Without either, the cell is returned unchanged.
+func formatNumber(cell string, decimals int, thousands bool) string {
+	if decimals < 0 && !thousands {
+		return cell
+	}
+	v, ok := parseNumber(cell)
+	if !ok {
+		return cell
+	}
+
+	var text string
+	if decimals >= 0 {
+		text = strconv.FormatFloat(v, 'f', decimals, 64)
+	} else {
+		text = strings.ReplaceAll(strings.TrimSpace(cell), ",", "")
+	}
+	if !thousands {
+		return text
+	}
+
+	sign := ""
+	if strings.HasPrefix(text, "-") || strings.HasPrefix(text, "+") {
+		sign, text = text[:1], text[1:]
+	}
+	intPart, fracPart := text, ""
+	if idx := strings.IndexAny(text, ".eE"); idx != -1 {
+		intPart, fracPart = text[:idx], text[idx:]
+	}
+	// Exponent notation is left alone
+	if strings.ContainsAny(fracPart, "eE") {
+		return sign + text
+	}
+
+	var grouped strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(r)
+	}
+	return sign + grouped.String() + fracPart
+}
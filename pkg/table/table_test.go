@@ -0,0 +1,69 @@
+package table
+
+import "testing"
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		cell string
+		want float64
+		ok   bool
+	}{
+		{"42", 42, true},
+		{" -3.5 ", -3.5, true},
+		{"+.25", 0.25, true},
+		{"1,234", 1234, true},
+		{"12,345,678.9", 12345678.9, true},
+		{"6.02e23", 6.02e23, true},
+		{"1,5", 0, false},
+		{"1,23", 0, false},
+		{"12,3456", 0, false},
+		{",123", 0, false},
+		{"NaN", 0, false},
+		{"Inf", 0, false},
+		{"-infinity", 0, false},
+		{"0x1p-2", 0, false},
+		{"1_000", 0, false},
+		{"", 0, false},
+		{"12abc", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseNumber(tt.cell)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseNumber(%q) = %v, %v; want %v, %v", tt.cell, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		cell      string
+		decimals  int
+		thousands bool
+		want      string
+	}{
+		{"1,234", -1, false, "1,234"},
+		{" 1234.50 ", -1, false, " 1234.50 "},
+		{"007", -1, false, "007"},
+		{"1234567", -1, true, "1,234,567"},
+		{"1,234", 2, false, "1234.00"},
+		{"-1234.5", 1, true, "-1,234.5"},
+		{"0.125", 2, false, "0.12"},
+		{"1e6", -1, true, "1e6"},
+		{"n/a", 2, true, "n/a"},
+	}
+	for _, tt := range tests {
+		if got := formatNumber(tt.cell, tt.decimals, tt.thousands); got != tt.want {
+			t.Errorf("formatNumber(%q, %d, %v) = %q, want %q", tt.cell, tt.decimals, tt.thousands, got, tt.want)
+		}
+	}
+}
+
+func TestNumericColumnKeepsCells(t *testing.T) {
+	tbl := &Table{Columns: []string{"Price", "Count"}, Rows: [][]string{{"1,5", "1,234"}, {"2,25", "12"}}}
+	if isNumericColumn(tbl, 0) {
+		t.Error("column of European decimals is numeric")
+	}
+	if !isNumericColumn(tbl, 1) {
+		t.Error("column with thousands separators is not numeric")
+	}
+}
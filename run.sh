@@ -73,6 +73,22 @@ case "$command" in
         bin/simple_html_docgen -add-media "$1" -media-path "$2" -media-type "$media_type"
         ;;
 
+    import)
+        if [ -z "$1" ]; then
+            echo "Usage: ./run.sh import <file_path> [name]"
+            exit 1
+        fi
+        bin/simple_html_docgen -import "$1" -name "${2:-}"
+        ;;
+
+    extract-inline)
+        if [ -z "$1" ]; then
+            echo "Usage: ./run.sh extract-inline <document_id>"
+            exit 1
+        fi
+        bin/simple_html_docgen -extract-inline-media "$1"
+        ;;
+
     clean)
         echo "Cleaning build artifacts..."
         rm -rf bin
@@ -94,6 +110,8 @@ case "$command" in
         echo "  update <id> <html>             Update document content"
         echo "  export <id> <format>           Export document (html/pdf/docx)"
         echo "  add-media <id> <path> [type]   Add media file to document"
+        echo "  import <path> [name]           Import DOCX/ODT/MD/RST file as a document"
+        echo "  extract-inline <id>            Move inline base64 images into media/"
         echo "  clean                          Remove build artifacts"
         echo ""
         echo "Examples:"